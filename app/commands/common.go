@@ -0,0 +1,16 @@
+// Copyright 2017 Delos Development Foundation and contributors. Licensed
+// under the Apache License, Version 2.0. See the COPYING file at the root
+// of this distribution or at http://www.apache.org/licenses/LICENSE-2.0
+package commands
+
+import (
+	"gopkg.in/urfave/cli.v1"
+
+	acfg "github.com/DelosIsland/core/dngine/config"
+	"github.com/DelosIsland/core/module/lib/go-config"
+)
+
+// nodeConfig reads the config of the node in --datadir, the node must be stopped
+func nodeConfig(ctx *cli.Context) *config.MapConfig {
+	return acfg.GetConfig(ctx.GlobalString("datadir"))
+}
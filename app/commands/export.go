@@ -0,0 +1,120 @@
+// Copyright 2017 Delos Development Foundation and contributors. Licensed
+// under the Apache License, Version 2.0. See the COPYING file at the root
+// of this distribution or at http://www.apache.org/licenses/LICENSE-2.0
+package commands
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+
+	"go.uber.org/zap"
+	"gopkg.in/urfave/cli.v1"
+
+	"github.com/DelosIsland/core/app/node"
+	"github.com/DelosIsland/core/dngine"
+	"github.com/DelosIsland/core/dngine/blockchain"
+	"github.com/DelosIsland/core/dngine/types"
+)
+
+var (
+	ExportCommand = cli.Command{
+		Name:      "export",
+		Usage:     "write the genesis and the blocks of a stopped node to a file",
+		ArgsUsage: "<file>",
+		Category:  "Maintenance",
+		Action:    exportChain,
+		Flags: []cli.Flag{
+			cli.IntFlag{
+				Name:  "height",
+				Usage: "last block to export, 0 for all",
+			},
+		},
+	}
+
+	ImportCommand = cli.Command{
+		Name:      "import",
+		Usage:     "verify and replay the blocks of an export into a new node",
+		ArgsUsage: "<file>",
+		Category:  "Maintenance",
+		Action:    importChain,
+	}
+)
+
+// ./app --datadir=/path/to/node export --height 1000 chain.export
+func exportChain(ctx *cli.Context) error {
+	path := ctx.Args().First()
+	if path == "" {
+		return errors.New("the file to export to is missing")
+	}
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	w := bufio.NewWriterSize(f, 1<<20)
+	height, err := dngine.Export(nodeConfig(ctx), w, ctx.Int("height"))
+	if err == nil {
+		err = w.Flush()
+	}
+	if err == nil {
+		err = f.Sync()
+	}
+	f.Close()
+	if err != nil {
+		return err
+	}
+	fmt.Printf("Exported the genesis and the blocks up to %d to %s\n", height, path)
+	return nil
+}
+
+// ./app --datadir=/path/to/new/node import chain.export
+// The genesis of the export is written as the genesis_file of the node unless it has one already.
+func importChain(ctx *cli.Context) error {
+	path := ctx.Args().First()
+	if path == "" {
+		return errors.New("the file to import is missing")
+	}
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	r, err := blockchain.NewExportReader(bufio.NewReaderSize(f, 1<<20))
+	if err != nil {
+		return err
+	}
+
+	conf := nodeConfig(ctx)
+	genesisFile := conf.GetString("genesis_file")
+	if buf, err := ioutil.ReadFile(genesisFile); err == nil {
+		genDoc, err := types.ReadGenesisDoc(buf)
+		if err != nil {
+			return fmt.Errorf("%s: %v", genesisFile, err)
+		}
+		if genDoc.ChainID != r.Genesis().ChainID {
+			return fmt.Errorf("%s is the genesis of chain %s, the export is of %s", genesisFile, genDoc.ChainID, r.Genesis().ChainID)
+		}
+	} else if !os.IsNotExist(err) {
+		return err
+	} else if err := r.Genesis().SaveAs(genesisFile); err != nil {
+		return err
+	}
+
+	app := node.NewMyApp(zap.NewNop(), conf)
+	e := dngine.NewDngine(&dngine.DngineTunes{Conf: conf})
+	e.ConnectApp(app)
+	defer e.Stop()
+
+	start := e.Height()
+	height, err := e.ImportBlocks(r, func(height int) {
+		if height%1000 == 0 {
+			fmt.Printf("Imported block %d\n", height)
+		}
+	})
+	if height > start {
+		fmt.Printf("Imported the blocks %d to %d\n", start+1, height)
+	}
+	return err
+}
@@ -0,0 +1,254 @@
+// Copyright 2017 Delos Development Foundation and contributors. Licensed
+// under the Apache License, Version 2.0. See the COPYING file at the root
+// of this distribution or at http://www.apache.org/licenses/LICENSE-2.0
+package commands
+
+import (
+	"bytes"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"time"
+
+	"gopkg.in/urfave/cli.v1"
+
+	"github.com/DelosIsland/core/app/node"
+	"github.com/DelosIsland/core/dngine/types"
+	"github.com/DelosIsland/core/module/lib/go-crypto"
+)
+
+var (
+	GenesisCommands = cli.Command{
+		Name:     "genesis",
+		Usage:    "make and check the genesis_file of a node",
+		Category: "Setup",
+		Flags: []cli.Flag{
+			cli.StringFlag{
+				Name:  "file",
+				Usage: "genesis file to work on instead of the genesis_file of --datadir",
+			},
+		},
+		Subcommands: []cli.Command{
+			{
+				Name:   "new",
+				Usage:  "write a genesis without validators for a chain",
+				Action: genesisNew,
+				Flags: []cli.Flag{
+					cli.StringFlag{
+						Name:  "chain-id",
+						Usage: "id of the chain",
+					},
+					cli.StringFlag{
+						Name:  "plugins",
+						Value: "specialop",
+						Usage: "comma separated core plugins",
+					},
+					cli.BoolFlag{
+						Name:  "force",
+						Usage: "overwrite an existing genesis",
+					},
+				},
+			},
+			{
+				Name:   "add-validator",
+				Usage:  "add a validator from its public key",
+				Action: genesisAddValidator,
+				Flags: []cli.Flag{
+					cli.StringFlag{
+						Name:  "pubkey",
+						Usage: "hex ed25519 public key, the pub_key of its priv_validator.json",
+					},
+					cli.Int64Flag{
+						Name:  "power",
+						Value: 100,
+						Usage: "voting power",
+					},
+					cli.BoolFlag{
+						Name:  "is-ca",
+						Usage: "the validator signs the signbyCA of the nodes",
+					},
+					cli.StringFlag{
+						Name:  "rpc",
+						Usage: "rpc address of the validator",
+					},
+					cli.StringFlag{
+						Name:  "name",
+						Usage: "name of the validator",
+					},
+				},
+			},
+			{
+				Name:      "set-app-state",
+				Usage:     "set the app_state from a JSON file and the app_hash the app makes of it",
+				ArgsUsage: "<file>",
+				Action:    genesisSetAppState,
+			},
+			{
+				Name:      "set-app-hash",
+				Usage:     "set the app_hash, for an app starting from a state of its own",
+				ArgsUsage: "<hex>",
+				Action:    genesisSetAppHash,
+			},
+			{
+				Name:   "validate",
+				Usage:  "check that the genesis can start a chain",
+				Action: genesisValidate,
+			},
+		},
+	}
+)
+
+func genesisFile(ctx *cli.Context) string {
+	if file := ctx.Parent().String("file"); file != "" {
+		return file
+	}
+	return nodeConfig(ctx).GetString("genesis_file")
+}
+
+func readGenesis(ctx *cli.Context) (*types.GenesisDoc, string, error) {
+	path := genesisFile(ctx)
+	buf, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, path, err
+	}
+	genDoc, err := types.ReadGenesisDoc(buf)
+	if err != nil {
+		return nil, path, fmt.Errorf("%s: %v", path, err)
+	}
+	return genDoc, path, nil
+}
+
+// ./app --datadir=/path/to/node genesis new --chain-id mychain
+func genesisNew(ctx *cli.Context) error {
+	chainID := ctx.String("chain-id")
+	if chainID == "" {
+		return errors.New("the chain id is missing")
+	}
+	path := genesisFile(ctx)
+	if _, err := os.Stat(path); err == nil && !ctx.Bool("force") {
+		return fmt.Errorf("%s exists, use --force to overwrite it", path)
+	}
+	genDoc := &types.GenesisDoc{
+		GenesisTime: time.Now(),
+		ChainID:     chainID,
+		Plugins:     ctx.String("plugins"),
+	}
+	if err := genDoc.SaveAs(path); err != nil {
+		return err
+	}
+	fmt.Printf("Wrote the genesis of %s to %s, add its validators next\n", chainID, path)
+	return nil
+}
+
+// ./app --datadir=/path/to/node genesis add-validator --pubkey 1A3D... --power 100 --is-ca --rpc tcp://10.0.0.1:46657 --name v1
+func genesisAddValidator(ctx *cli.Context) error {
+	pubKey, err := parsePubKey(ctx.String("pubkey"))
+	if err != nil {
+		return err
+	}
+	genDoc, path, err := readGenesis(ctx)
+	if err != nil {
+		return err
+	}
+	genDoc.Validators = append(genDoc.Validators, types.GenesisValidator{
+		PubKey:     pubKey,
+		Amount:     ctx.Int64("power"),
+		Name:       ctx.String("name"),
+		IsCA:       ctx.Bool("is-ca"),
+		RPCAddress: ctx.String("rpc"),
+	})
+	if err := genDoc.Validate(); err != nil {
+		return err
+	}
+	if err := genDoc.SaveAs(path); err != nil {
+		return err
+	}
+	fmt.Printf("Added validator %d to %s\n", len(genDoc.Validators), path)
+	return nil
+}
+
+func parsePubKey(hexKey string) (crypto.PubKey, error) {
+	if hexKey == "" {
+		return nil, errors.New("the public key is missing")
+	}
+	key, err := hex.DecodeString(hexKey)
+	if err != nil || len(key) != 32 {
+		return nil, fmt.Errorf("%s is not a hex ed25519 public key", hexKey)
+	}
+	var pubKey crypto.PubKeyEd25519
+	copy(pubKey[:], key)
+	return pubKey, nil
+}
+
+// ./app --datadir=/path/to/node genesis set-app-state accounts.json
+// where accounts.json is {"accounts": {"<address>": {"balance": 1000, "nonce": 0}}}
+func genesisSetAppState(ctx *cli.Context) error {
+	if ctx.Args().First() == "" {
+		return errors.New("the app_state file is missing")
+	}
+	appState, err := ioutil.ReadFile(ctx.Args().First())
+	if err != nil {
+		return err
+	}
+	appHash, err := node.GenesisAppHash(appState)
+	if err != nil {
+		return err
+	}
+	genDoc, path, err := readGenesis(ctx)
+	if err != nil {
+		return err
+	}
+	compact := new(bytes.Buffer)
+	if err := json.Compact(compact, appState); err != nil {
+		return err
+	}
+	genDoc.AppState, genDoc.AppHash = compact.Bytes(), appHash
+	if err := genDoc.SaveAs(path); err != nil {
+		return err
+	}
+	fmt.Printf("Set the app_state and the app_hash %X of %s\n", appHash, path)
+	return nil
+}
+
+// ./app --datadir=/path/to/node genesis set-app-hash 29C7C500...
+func genesisSetAppHash(ctx *cli.Context) error {
+	appHash, err := hex.DecodeString(ctx.Args().First())
+	if err != nil {
+		return fmt.Errorf("bad app hash: %v", err)
+	}
+	genDoc, path, err := readGenesis(ctx)
+	if err != nil {
+		return err
+	}
+	genDoc.AppHash = appHash
+	if err := genDoc.SaveAs(path); err != nil {
+		return err
+	}
+	fmt.Printf("Set the app_hash of %s\n", path)
+	return nil
+}
+
+// ./app --datadir=/path/to/node genesis validate
+func genesisValidate(ctx *cli.Context) error {
+	genDoc, path, err := readGenesis(ctx)
+	if err != nil {
+		return err
+	}
+	if err := genDoc.Validate(); err != nil {
+		return fmt.Errorf("%s: %v", path, err)
+	}
+	if len(genDoc.AppState) != 0 {
+		appHash, err := node.GenesisAppHash(genDoc.AppState)
+		if err != nil {
+			return fmt.Errorf("%s: %v", path, err)
+		}
+		if !bytes.Equal(appHash, genDoc.AppHash) {
+			return fmt.Errorf("%s: the app_hash is %X, the app makes %X of the app_state", path, genDoc.AppHash, appHash)
+		}
+	}
+	fmt.Printf("%s is a valid genesis of %s with %d validators\n", path, genDoc.ChainID, len(genDoc.Validators))
+	return nil
+}
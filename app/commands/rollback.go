@@ -0,0 +1,51 @@
+// Copyright 2017 Delos Development Foundation and contributors. Licensed
+// under the Apache License, Version 2.0. See the COPYING file at the root
+// of this distribution or at http://www.apache.org/licenses/LICENSE-2.0
+package commands
+
+import (
+	"fmt"
+
+	"go.uber.org/zap"
+	"gopkg.in/urfave/cli.v1"
+
+	"github.com/DelosIsland/core/app/node"
+	"github.com/DelosIsland/core/dngine"
+)
+
+var (
+	RollbackCommand = cli.Command{
+		Name:     "rollback",
+		Usage:    "rewind the state of a stopped node and of its app by some heights",
+		Category: "Maintenance",
+		Action:   rollback,
+		Flags: []cli.Flag{
+			cli.IntFlag{
+				Name:  "heights",
+				Value: 1,
+				Usage: "number of heights to go back, at most rollback_heights",
+			},
+			cli.BoolFlag{
+				Name:  "remove-blocks",
+				Usage: "remove the blocks above from the store too, instead of executing them again on start",
+			},
+		},
+	}
+)
+
+// ./app --datadir=/path/to/node rollback --heights 2 --remove-blocks
+func rollback(ctx *cli.Context) error {
+	conf := nodeConfig(ctx)
+	app := node.NewMyApp(zap.NewNop(), conf)
+	res, err := dngine.Rollback(conf, app, ctx.Int("heights"), ctx.Bool("remove-blocks"))
+	if err != nil {
+		return err
+	}
+	fmt.Printf("Rolled back the state and the app from height %d to %d\n", res.From, res.To)
+	if res.StoreHeight > res.To {
+		fmt.Printf("The blocks up to %d are kept, the node executes them again when it starts\n", res.StoreHeight)
+	} else {
+		fmt.Printf("Removed the blocks above %d, the node syncs them again from its peers\n", res.To)
+	}
+	return nil
+}
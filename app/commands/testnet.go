@@ -0,0 +1,78 @@
+// Copyright 2017 Delos Development Foundation and contributors. Licensed
+// under the Apache License, Version 2.0. See the COPYING file at the root
+// of this distribution or at http://www.apache.org/licenses/LICENSE-2.0
+package commands
+
+import (
+	"errors"
+	"fmt"
+
+	"gopkg.in/urfave/cli.v1"
+
+	"github.com/DelosIsland/core/dngine/testnet"
+)
+
+var (
+	TestnetCommand = cli.Command{
+		Name:      "testnet",
+		Usage:     "generate the directories of the nodes of a local testnet",
+		Category:  "Setup",
+		ArgsUsage: "<dir>",
+		Action:    generateTestnet,
+		Flags: []cli.Flag{
+			cli.IntFlag{
+				Name:  "nodes",
+				Value: 4,
+				Usage: "number of nodes",
+			},
+			cli.IntFlag{
+				Name:  "validators",
+				Usage: "number of validators among the nodes, all of them if 0",
+			},
+			cli.StringFlag{
+				Name:  "chain-id",
+				Usage: "id of the chain, a random one if empty",
+			},
+			cli.StringFlag{
+				Name:  "host",
+				Value: "127.0.0.1",
+				Usage: "host the nodes reach each other at",
+			},
+			cli.IntFlag{
+				Name:  "base-port",
+				Value: 46656,
+				Usage: "p2p port of node0, node i listens from base-port+10*i",
+			},
+			cli.Int64Flag{
+				Name:  "power",
+				Value: 100,
+				Usage: "voting power of each validator",
+			},
+		},
+	}
+)
+
+// ./app testnet --nodes 4 --validators 3 /path/to/testnet
+func generateTestnet(ctx *cli.Context) error {
+	dir := ctx.Args().First()
+	if dir == "" {
+		return errors.New("the directory of the testnet is missing")
+	}
+	tn, err := testnet.Generate(testnet.Config{
+		Dir:        dir,
+		Nodes:      ctx.Int("nodes"),
+		Validators: ctx.Int("validators"),
+		ChainID:    ctx.String("chain-id"),
+		Host:       ctx.String("host"),
+		BasePort:   ctx.Int("base-port"),
+		Power:      ctx.Int64("power"),
+	})
+	if err != nil {
+		return err
+	}
+	fmt.Printf("Generated the testnet %s with %d validators\n", tn.Genesis.ChainID, len(tn.Genesis.Validators))
+	for _, n := range tn.Nodes {
+		fmt.Printf("  %s  p2p %s  rpc %s  ./app --datadir=%s\n", n.Moniker, n.P2PAddr, n.RPCAddr, n.Dir)
+	}
+	return nil
+}
@@ -0,0 +1,160 @@
+// Copyright 2017 Delos Development Foundation and contributors. Licensed
+// under the Apache License, Version 2.0. See the COPYING file at the root
+// of this distribution or at http://www.apache.org/licenses/LICENSE-2.0
+package commands
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"gopkg.in/urfave/cli.v1"
+
+	"github.com/DelosIsland/core/dngine/consensus"
+)
+
+var (
+	WALCommands = cli.Command{
+		Name:     "wal",
+		Usage:    "inspect and repair the consensus WAL of a stopped node",
+		Category: "Maintenance",
+		Subcommands: []cli.Command{
+			{
+				Name:   "list",
+				Usage:  "list the heights of the WAL with their messages",
+				Action: walList,
+			},
+			{
+				Name:   "dump",
+				Usage:  "print the messages of a range of heights",
+				Action: walDump,
+				Flags: []cli.Flag{
+					cli.IntFlag{
+						Name:  "from",
+						Usage: "first height",
+					},
+					cli.IntFlag{
+						Name:  "to",
+						Usage: "last height, 0 for the end of the WAL",
+					},
+				},
+			},
+			{
+				Name:   "repair",
+				Usage:  "cut a corrupted or truncated WAL back to its last good record",
+				Action: walRepair,
+			},
+			{
+				Name:   "migrate",
+				Usage:  "rewrite a WAL of the former JSON format in the binary one, the node does it when it starts too",
+				Action: walMigrate,
+			},
+		},
+	}
+)
+
+func walDir(ctx *cli.Context) string {
+	return nodeConfig(ctx).GetString("cs_wal_dir")
+}
+
+type walHeight struct {
+	height   int
+	first    time.Time
+	last     time.Time
+	messages int
+	kinds    map[string]int
+}
+
+// ./app --datadir=/path/to/node wal list
+func walList(ctx *cli.Context) error {
+	var heights []*walHeight
+	err := consensus.ScanWAL(walDir(ctx), func(r *consensus.WALRecord) bool {
+		if r.Marker || len(heights) == 0 {
+			heights = append(heights, &walHeight{height: r.Height, kinds: make(map[string]int)})
+		}
+		if !r.Marker {
+			h := heights[len(heights)-1]
+			if h.messages == 0 {
+				h.first = r.Msg.Time
+			}
+			h.last = r.Msg.Time
+			h.messages++
+			h.kinds[r.Kind()]++
+		}
+		return true
+	})
+
+	for _, h := range heights {
+		kinds := make([]string, 0, len(h.kinds))
+		for kind, n := range h.kinds {
+			kinds = append(kinds, fmt.Sprintf("%s:%d", kind, n))
+		}
+		sort.Strings(kinds)
+		if h.messages == 0 {
+			fmt.Printf("height %d: no messages\n", h.height)
+			continue
+		}
+		fmt.Printf("height %d: %d messages from %s to %s (%s)\n", h.height, h.messages,
+			h.first.Format("2006-01-02 15:04:05"), h.last.Format("15:04:05"), strings.Join(kinds, " "))
+	}
+	return walResult(err)
+}
+
+// ./app --datadir=/path/to/node wal dump --from=10 --to=12
+func walDump(ctx *cli.Context) error {
+	from, to := ctx.Int("from"), ctx.Int("to")
+	if to != 0 && to < from {
+		return fmt.Errorf("--to must not be lower than --from")
+	}
+	err := consensus.ScanWAL(walDir(ctx), func(r *consensus.WALRecord) bool {
+		if to != 0 && r.Height > to {
+			return false
+		}
+		if r.Height >= from {
+			if r.Marker {
+				fmt.Println(r)
+			} else {
+				fmt.Println(" ", r)
+			}
+		}
+		return true
+	})
+	return walResult(err)
+}
+
+// walResult tells how to deal with a corrupted WAL
+func walResult(err error) error {
+	if c, ok := err.(*consensus.WALCorruption); ok {
+		return fmt.Errorf("%v, run wal repair to cut the WAL back to the last good record", c)
+	}
+	return err
+}
+
+// ./app --datadir=/path/to/node wal repair
+func walRepair(ctx *cli.Context) error {
+	c, err := consensus.RepairWAL(walDir(ctx))
+	if err != nil {
+		return err
+	}
+	if c == nil {
+		fmt.Println("the WAL is sound")
+		return nil
+	}
+	fmt.Printf("%v\nthe WAL was cut back to the record before\n", c)
+	return nil
+}
+
+// ./app --datadir=/path/to/node wal migrate
+func walMigrate(ctx *cli.Context) error {
+	dir := walDir(ctx)
+	c, err := consensus.MigrateWAL(dir)
+	if err != nil {
+		return err
+	}
+	if c != nil {
+		fmt.Printf("%v\nthe records from there on were dropped\n", c)
+	}
+	fmt.Println("the WAL is in the binary format")
+	return nil
+}
@@ -4,32 +4,63 @@
 package main
 
 import (
-	"flag"
+	"fmt"
+	"os"
 	"path"
 
+	"go.uber.org/zap"
+	"gopkg.in/urfave/cli.v1"
+
+	"github.com/DelosIsland/core/app/commands"
 	"github.com/DelosIsland/core/app/node"
 	"github.com/DelosIsland/core/dngine"
 	acfg "github.com/DelosIsland/core/dngine/config"
-	"go.uber.org/zap"
-)
-
-// params
-var (
-	InitFlag = flag.Bool("init", false, "set initial files")
-	DataDir  = flag.String("datadir", "", "set data direction")
 )
 
 func main() {
-	flag.Parse()
-	configPath := *DataDir //"/home/vagrant/gohome/src/github.com/DelosIsland/core/dngine-test/src/config2/"
+	app := cli.NewApp()
+	app.Name = "app"
+	app.Usage = "run a node, or maintain the data of a stopped one"
+	app.Action = run
+
+	app.Commands = []cli.Command{
+		commands.WALCommands,
+		commands.RollbackCommand,
+		commands.ExportCommand,
+		commands.ImportCommand,
+		commands.GenesisCommands,
+		commands.TestnetCommand,
+	}
+
+	app.Flags = []cli.Flag{
+		cli.BoolFlag{
+			Name:  "init",
+			Usage: "set initial files",
+		},
+		cli.StringFlag{
+			Name:  "datadir",
+			Usage: "set data direction",
+		},
+	}
+
+	if err := app.Run(os.Args); err != nil {
+		fmt.Fprintln(os.Stderr, "Error:", err)
+		os.Exit(1)
+	}
+}
+
+// run starts the node, or sets its initial files with --init
+func run(ctx *cli.Context) error {
+	configPath := ctx.String("datadir") //"/home/vagrant/gohome/src/github.com/DelosIsland/core/dngine-test/src/config2/"
 	conf := acfg.GetConfig(configPath)
 	env := conf.GetString("environment")
 	logger := Initialize(env, path.Join("", "node.output.log"), path.Join("", "node.err.log"))
-	if *InitFlag == true {
+	if ctx.Bool("init") {
 		dngine.Initialize(&dngine.DngineTunes{Conf: conf})
 	} else {
 		node.RunNode(logger, conf)
 	}
+	return nil
 }
 
 // Initialize init log
@@ -0,0 +1,179 @@
+// Copyright 2017 Delos Development Foundation and contributors. Licensed
+// under the Apache License, Version 2.0. See the COPYING file at the root
+// of this distribution or at http://www.apache.org/licenses/LICENSE-2.0
+package node
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"sync"
+
+	"github.com/DelosIsland/core/module/lib/go-db"
+	"github.com/DelosIsland/core/module/lib/go-merkle"
+	"github.com/DelosIsland/core/module/lib/go-wire"
+)
+
+const accountsCacheSize = 10000
+
+// AccountState keeps the balances in an IAVL tree saved in the app db,
+// the tree root is the AppHash so that any balance can be proven.
+// Goroutine-safe.
+type AccountState struct {
+	mtx  sync.Mutex
+	db   db.DB
+	tree *merkle.IAVLTree
+
+	// the last saved tree, queries read it so that proofs match the AppHash
+	committed *merkle.IAVLTree
+}
+
+func NewAccountState(database db.DB) *AccountState {
+	tree := merkle.NewIAVLTree(accountsCacheSize, database)
+	return &AccountState{db: database, tree: tree, committed: tree.Copy().(*merkle.IAVLTree)}
+}
+
+// KeepVersions keeps the trees saved at the n heights before the last one,
+// so that the state can be rolled back to them
+func (s *AccountState) KeepVersions(n int) {
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+	s.tree.KeepVersions(n)
+}
+
+// Saved tells whether the tree with the given root is still in db
+func (s *AccountState) Saved(root []byte) bool {
+	return len(root) == 0 || len(s.db.Get(root)) != 0
+}
+
+// Load resets the state to the tree saved with the given root
+func (s *AccountState) Load(root []byte) {
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+	s.tree.Load(root)
+	s.committed = s.tree.Copy().(*merkle.IAVLTree)
+}
+
+// Account is what the tree stores for every address
+type Account struct {
+	Balance uint64 `json:"balance"`
+	Nonce   uint64 `json:"nonce"` // number of txs sent, the next tx must carry it
+}
+
+// GenesisAppState is the app_state of the genesis, the accounts the chain starts with.
+type GenesisAppState struct {
+	Accounts map[string]Account `json:"accounts"`
+}
+
+// LoadGenesis sets the accounts of the app_state of the genesis in the working tree
+func (s *AccountState) LoadGenesis(appState json.RawMessage) error {
+	var gs GenesisAppState
+	dec := json.NewDecoder(bytes.NewReader(appState))
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(&gs); err != nil {
+		return fmt.Errorf("bad app_state: %v", err)
+	}
+	// the tree depends on the order of the insertions
+	addresses := make([]string, 0, len(gs.Accounts))
+	for address := range gs.Accounts {
+		if address == "" {
+			return fmt.Errorf("bad app_state: an account has no address")
+		}
+		addresses = append(addresses, address)
+	}
+	sort.Strings(addresses)
+	for _, address := range addresses {
+		s.Set(address, gs.Accounts[address])
+	}
+	return nil
+}
+
+// GenesisAppHash returns the AppHash the app starts with from an app_state
+func GenesisAppHash(appState json.RawMessage) ([]byte, error) {
+	s := NewAccountState(db.NewMemDB())
+	if err := s.LoadGenesis(appState); err != nil {
+		return nil, err
+	}
+	return s.Save(), nil
+}
+
+// Get returns the account of an address, false if the address was never used
+func (s *AccountState) Get(address string) (Account, bool) {
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+
+	var acc Account
+	_, value, exists := s.tree.Get([]byte(address))
+	if !exists {
+		return acc, false
+	}
+	if err := wire.ReadBinaryBytes(value, &acc); err != nil {
+		return acc, false
+	}
+	return acc, true
+}
+
+// Set stores the account of an address in the working tree
+func (s *AccountState) Set(address string, acc Account) {
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+	s.tree.Set([]byte(address), wire.BinaryBytes(acc))
+}
+
+// Proof returns the IAVL proof of an address balance against the last saved root
+func (s *AccountState) Proof(address string) ([]byte, bool) {
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+	return s.committed.Proof([]byte(address))
+}
+
+// GetCommitted returns the raw value of a key in the last saved tree and its proof if prove is set,
+// the proof is a merkle.IAVLAbsenceProof when the key isn't in the tree
+func (s *AccountState) GetCommitted(key []byte, prove bool) (value, proof []byte, exists bool) {
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+	return getProven(s.committed, key, prove)
+}
+
+// GetSaved is GetCommitted on the tree saved with the given root, kept is false if it was pruned
+func (s *AccountState) GetSaved(root, key []byte, prove bool) (value, proof []byte, exists, kept bool) {
+	// Save prunes the old trees under the lock, a root still in db has all its nodes
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+	if !s.Saved(root) {
+		return nil, nil, false, false
+	}
+	tree := s.committed.Copy().(*merkle.IAVLTree)
+	tree.Load(root)
+	value, proof, exists = getProven(tree, key, prove)
+	return value, proof, exists, true
+}
+
+func getProven(tree *merkle.IAVLTree, key []byte, prove bool) (value, proof []byte, exists bool) {
+	_, value, exists = tree.Get(key)
+	switch {
+	case !prove:
+	case exists:
+		proof, _ = tree.Proof(key)
+	default:
+		proof, _ = tree.AbsenceProof(key)
+	}
+	return
+}
+
+// Hash is the root of the working tree, including the changes not saved yet
+func (s *AccountState) Hash() []byte {
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+	return s.tree.Hash()
+}
+
+// Save writes the tree to db and returns its root
+func (s *AccountState) Save() []byte {
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+	root := s.tree.Save()
+	s.committed = s.tree.Copy().(*merkle.IAVLTree)
+	return root
+}
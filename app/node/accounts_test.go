@@ -0,0 +1,73 @@
+// Copyright 2017 Delos Development Foundation and contributors. Licensed
+// under the Apache License, Version 2.0. See the COPYING file at the root
+// of this distribution or at http://www.apache.org/licenses/LICENSE-2.0
+package node
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/DelosIsland/core/module/lib/go-db"
+)
+
+func TestAccountStateReload(t *testing.T) {
+	database := db.NewMemDB()
+	state := NewAccountState(database)
+	state.Set("alice", Account{Balance: 90, Nonce: 1})
+	root := state.Save()
+	state.Set("alice", Account{Balance: 80, Nonce: 2}) // not saved
+
+	reloaded := NewAccountState(database)
+	reloaded.Load(root)
+	if acc, _ := reloaded.Get("alice"); acc.Balance != 90 || acc.Nonce != 1 {
+		t.Errorf("Expected alice to have 90 at nonce 1, got %+v", acc)
+	}
+	if _, ok := reloaded.Get("carol"); ok {
+		t.Errorf("Expected carol to be unknown")
+	}
+	if _, ok := reloaded.Proof("alice"); !ok {
+		t.Errorf("Expected a proof for alice")
+	}
+}
+
+func TestAccountStateKeepVersions(t *testing.T) {
+	state := NewAccountState(db.NewMemDB())
+	state.KeepVersions(2)
+	var roots [][]byte
+	for i := uint64(1); i <= 5; i++ {
+		state.Set("alice", Account{Balance: 100 - i, Nonce: i})
+		roots = append(roots, state.Save())
+	}
+	for i, root := range roots {
+		if saved := i >= len(roots)-3; state.Saved(root) != saved {
+			t.Errorf("Expected the tree %d to be saved: %v", i+1, saved)
+		}
+	}
+
+	state.Load(roots[2])
+	if acc, _ := state.Get("alice"); acc.Nonce != 3 {
+		t.Errorf("Expected alice at nonce 3 in the third tree, got %+v", acc)
+	}
+}
+
+func TestAccountStateLoadGenesis(t *testing.T) {
+	appState := []byte(`{"accounts":{"bob":{"balance":50},"alice":{"balance":100,"nonce":1}}}`)
+	state := NewAccountState(db.NewMemDB())
+	if err := state.LoadGenesis(appState); err != nil {
+		t.Fatal(err)
+	}
+	if acc, _ := state.Get("alice"); acc.Balance != 100 || acc.Nonce != 1 {
+		t.Errorf("Expected alice to have 100 at nonce 1, got %+v", acc)
+	}
+	appHash, err := GenesisAppHash(appState)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if root := state.Save(); !bytes.Equal(root, appHash) {
+		t.Errorf("Expected the app hash %X, got %X", root, appHash)
+	}
+
+	if err := state.LoadGenesis([]byte(`{"acounts":{}}`)); err == nil {
+		t.Error("Expected an unknown field to be refused")
+	}
+}
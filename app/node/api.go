@@ -0,0 +1,407 @@
+// Copyright 2017 Delos Development Foundation and contributors. Licensed
+// under the Apache License, Version 2.0. See the COPYING file at the root
+// of this distribution or at http://www.apache.org/licenses/LICENSE-2.0
+package node
+
+import (
+	"encoding/hex"
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+
+	types "github.com/DelosIsland/core/dngine/types"
+	rpc "github.com/DelosIsland/core/module/lib/go-rpc/server"
+	rpctypes "github.com/DelosIsland/core/module/lib/go-rpc/types"
+	"github.com/DelosIsland/core/module/lib/go-wire"
+)
+
+// OpenAPIPath serves the description of the REST gateway
+const OpenAPIPath = "/openapi.json"
+
+// apiRoute is a REST resource of the gateway served on api_laddr.
+// It runs the handler of an rpc method, whose group decides who may call it.
+type apiRoute struct {
+	Method  string
+	Path    string // segments like {height} are path parameters
+	RPC     string
+	Summary string
+	Query   []apiParam
+	Body    interface{} // request body, for the document
+	Result  interface{} // response body, for the document
+	Status  int         // on success
+
+	// results of handlers which change the status
+	OtherResults map[int]interface{}
+
+	handle func(h *rpcHandler, req *apiRequest) (interface{}, error)
+}
+
+// apiParam is a path or query parameter, Type is an OpenAPI type
+type apiParam struct {
+	Name        string
+	Type        string
+	Description string
+}
+
+type apiRequest struct {
+	*http.Request
+	vars  map[string]string
+	query url.Values
+	body  []byte
+
+	// handlers may change the status of the route, e.g. 200 instead of 202
+	status int
+}
+
+// apiTx is a committed tx
+type apiTx struct {
+	Hash   []byte   `json:"hash"`
+	Height uint64   `json:"height"`
+	Index  int      `json:"index"`
+	Tx     types.Tx `json:"tx"`
+}
+
+// apiBroadcastTx is the body of POST /chains/{chainid}/txs
+type apiBroadcastTx struct {
+	Tx     string `json:"tx"`     // hex
+	Commit bool   `json:"commit"` // wait for the tx to be committed
+}
+
+// apiChains lists the chains of the node, the main chain first
+type apiChains struct {
+	Chains []string `json:"chains"`
+}
+
+// apiError is the body of every failed request
+type apiError struct {
+	Error *rpctypes.RPCError `json:"error"`
+}
+
+var chainIDParam = apiParam{Name: ChainIDArg, Type: "string", Description: "chain id, the main chain or one of its shards"}
+
+func (n *Node) apiRoutes() []apiRoute {
+	return []apiRoute{
+		{
+			Method: "GET", Path: "/chains", RPC: "shards",
+			Summary: "Chains run by the node",
+			Result:  apiChains{},
+			handle: func(h *rpcHandler, req *apiRequest) (interface{}, error) {
+				res, err := h.Shards()
+				if err != nil {
+					return nil, err
+				}
+				shards := res.(*types.ResultShards).Names
+				sort.Strings(shards)
+				return &apiChains{Chains: append([]string{h.node.MainChainID}, shards...)}, nil
+			},
+		},
+		{
+			Method: "GET", Path: "/chains/{chainid}/status", RPC: "status",
+			Summary: "Node info and latest block of the chain",
+			Result:  types.ResultStatus{},
+			handle: func(h *rpcHandler, req *apiRequest) (interface{}, error) {
+				return h.Status(req.vars[ChainIDArg])
+			},
+		},
+		{
+			Method: "GET", Path: "/chains/{chainid}/genesis", RPC: "genesis",
+			Summary: "Genesis document of the chain",
+			Result:  types.ResultGenesis{},
+			handle: func(h *rpcHandler, req *apiRequest) (interface{}, error) {
+				return h.Genesis(req.vars[ChainIDArg])
+			},
+		},
+		{
+			Method: "GET", Path: "/chains/{chainid}/blocks", RPC: "blockchain",
+			Summary: "Metas of a range of blocks, the last 20 by default",
+			Query: []apiParam{
+				{Name: "min_height", Type: "integer", Description: "lowest height"},
+				{Name: "max_height", Type: "integer", Description: "highest height, the last block by default"},
+			},
+			Result: types.ResultBlockchainInfo{},
+			handle: func(h *rpcHandler, req *apiRequest) (interface{}, error) {
+				minHeight, err := req.queryInt("min_height")
+				if err != nil {
+					return nil, err
+				}
+				maxHeight, err := req.queryInt("max_height")
+				if err != nil {
+					return nil, err
+				}
+				return h.BlockchainInfo(req.vars[ChainIDArg], minHeight, maxHeight)
+			},
+		},
+		{
+			Method: "GET", Path: "/chains/{chainid}/blocks/{height}", RPC: "block",
+			Summary: "Block at height",
+			Result:  types.ResultBlock{},
+			handle: func(h *rpcHandler, req *apiRequest) (interface{}, error) {
+				height, err := strconv.Atoi(req.vars["height"])
+				if err != nil {
+					return nil, rpctypes.NewRPCError(rpctypes.CodeInvalidParams, "invalid height")
+				}
+				return h.Block(req.vars[ChainIDArg], height)
+			},
+		},
+		{
+			Method: "GET", Path: "/chains/{chainid}/validators", RPC: "validators",
+			Summary: "Current validator set",
+			Result:  types.ResultValidators{},
+			handle: func(h *rpcHandler, req *apiRequest) (interface{}, error) {
+				return h.Validators(req.vars[ChainIDArg])
+			},
+		},
+		{
+			Method: "GET", Path: "/chains/{chainid}/txs/{hash}", RPC: "query",
+			Summary: "Committed tx by hex hash",
+			Result:  apiTx{},
+			handle: func(h *rpcHandler, req *apiRequest) (interface{}, error) {
+				return h.apiTx(req.vars[ChainIDArg], req.vars["hash"])
+			},
+		},
+		{
+			Method: "POST", Path: "/chains/{chainid}/txs", RPC: "broadcast_tx_sync",
+			Summary: "Broadcast a tx, 202 once it is in the mempool or 200 once it is executed",
+			Body:    apiBroadcastTx{},
+			Result:  types.ResultBroadcastTx{},
+			Status:  http.StatusAccepted,
+			OtherResults: map[int]interface{}{
+				http.StatusOK: types.ResultBroadcastTxCommit{},
+			},
+			handle: func(h *rpcHandler, req *apiRequest) (interface{}, error) {
+				var body apiBroadcastTx
+				if err := json.Unmarshal(req.body, &body); err != nil {
+					return nil, rpctypes.NewRPCError(rpctypes.CodeInvalidParams, "invalid body: "+err.Error())
+				}
+				tx, err := hex.DecodeString(strings.TrimPrefix(body.Tx, "0x"))
+				if err != nil || len(tx) == 0 {
+					return nil, rpctypes.NewRPCError(rpctypes.CodeInvalidParams, "tx must be non empty hex")
+				}
+				if !body.Commit {
+					return h.BroadcastTx(req.vars[ChainIDArg], tx)
+				}
+				if err := rpc.CheckAccess(req.Request, "broadcast_tx_commit"); err != nil {
+					return nil, rpctypes.NewRPCError(rpctypes.CodeUnauthorized, err.Error())
+				}
+				res, err := h.BroadcastTxCommit(req.vars[ChainIDArg], tx)
+				if err != nil {
+					return nil, err
+				}
+				// refused like a sync broadcast, a failed execution is still committed
+				if checkTx := res.(*types.ResultBroadcastTxCommit).CheckTx; checkTx.Code != types.CodeType_OK {
+					return nil, types.NewRPCAppError(types.NewResult(checkTx.Code, checkTx.Data, checkTx.Log))
+				}
+				req.status = http.StatusOK
+				return res, nil
+			},
+		},
+		{
+			Method: "GET", Path: "/chains/{chainid}/unconfirmed_txs", RPC: "unconfirmed_txs",
+			Summary: "Txs in the mempool",
+			Result:  types.ResultUnconfirmedTxs{},
+			handle: func(h *rpcHandler, req *apiRequest) (interface{}, error) {
+				return h.UnconfirmedTxs(req.vars[ChainIDArg])
+			},
+		},
+		{
+			Method: "GET", Path: "/chains/{chainid}/query", RPC: "query",
+			Summary: "Application query on a committed state",
+			Query: []apiParam{
+				{Name: "path", Type: "string", Description: "query path, e.g. /account"},
+				{Name: "data", Type: "string", Description: "hex query data"},
+				{Name: "height", Type: "integer", Description: "committed height, the last one by default, only the last rollback_heights heights are kept"},
+				{Name: "prove", Type: "boolean", Description: "return a merkle proof, of absence for a missing account"},
+			},
+			Result: types.ResultQuery{},
+			handle: func(h *rpcHandler, req *apiRequest) (interface{}, error) {
+				data, err := hex.DecodeString(strings.TrimPrefix(req.query.Get("data"), "0x"))
+				if err != nil {
+					return nil, rpctypes.NewRPCError(rpctypes.CodeInvalidParams, "data must be hex")
+				}
+				height, err := req.queryInt("height")
+				if err != nil || height < 0 {
+					return nil, rpctypes.NewRPCError(rpctypes.CodeInvalidParams, "invalid height")
+				}
+				prove := req.query.Get("prove") == "true"
+				return h.Query(req.vars[ChainIDArg], req.query.Get("path"), data, uint64(height), prove)
+			},
+		},
+	}
+}
+
+// apiTx finds a tx through the location index of the application
+func (h *rpcHandler) apiTx(chainID, hexHash string) (interface{}, error) {
+	hash, err := hex.DecodeString(strings.TrimPrefix(hexHash, "0x"))
+	if err != nil {
+		return nil, rpctypes.NewRPCError(rpctypes.CodeInvalidParams, "hash must be hex")
+	}
+	res, err := h.Query(chainID, QueryPathTx, hash, 0, false)
+	if err != nil {
+		return nil, err
+	}
+	result := res.(*types.ResultQuery).Result
+	if result.IsErr() {
+		return nil, rpctypes.NewRPCError(rpctypes.CodeNotFound, result.Log)
+	}
+	var loc TxLocation
+	if err := wire.ReadBinaryBytes(result.Value, &loc); err != nil {
+		return nil, err
+	}
+	block, err := h.Block(chainID, int(loc.Height))
+	if err != nil {
+		return nil, err
+	}
+	txs := block.(*types.ResultBlock).Block.Txs
+	if loc.Index >= len(txs) {
+		return nil, rpctypes.NewRPCError(rpctypes.CodeNotFound, "tx not found")
+	}
+	return &apiTx{Hash: hash, Height: loc.Height, Index: loc.Index, Tx: txs[loc.Index]}, nil
+}
+
+func (req *apiRequest) queryInt(name string) (int, error) {
+	s := req.query.Get(name)
+	if s == "" {
+		return 0, nil
+	}
+	i, err := strconv.Atoi(s)
+	if err != nil {
+		return 0, rpctypes.NewRPCError(rpctypes.CodeInvalidParams, "invalid "+name)
+	}
+	return i, nil
+}
+
+// matchAPIPath returns the path parameters if path matches the pattern of a route
+func matchAPIPath(pattern, path string) (map[string]string, bool) {
+	patternSegs := strings.Split(strings.Trim(pattern, "/"), "/")
+	pathSegs := strings.Split(strings.Trim(path, "/"), "/")
+	if len(patternSegs) != len(pathSegs) {
+		return nil, false
+	}
+	vars := make(map[string]string)
+	for i, seg := range patternSegs {
+		if strings.HasPrefix(seg, "{") && strings.HasSuffix(seg, "}") {
+			if pathSegs[i] == "" {
+				return nil, false
+			}
+			v, err := url.PathUnescape(pathSegs[i])
+			if err != nil {
+				return nil, false
+			}
+			vars[seg[1:len(seg)-1]] = v
+		} else if seg != pathSegs[i] {
+			return nil, false
+		}
+	}
+	return vars, true
+}
+
+// apiStatus maps the errors of the rpc handlers to http statuses
+func apiStatus(err *rpctypes.RPCError) int {
+	if err == ErrInvalidChainID {
+		return http.StatusNotFound
+	}
+	switch err.Code {
+	case rpctypes.CodeInvalidParams, rpctypes.CodeInvalidRequest:
+		return http.StatusBadRequest
+	case rpctypes.CodeNotFound:
+		return http.StatusNotFound
+	case rpctypes.CodeUnauthorized:
+		return http.StatusForbidden
+	case rpctypes.CodeAppError:
+		return http.StatusUnprocessableEntity
+	}
+	return http.StatusInternalServerError
+}
+
+func toRPCError(err error) *rpctypes.RPCError {
+	if rpcErr, ok := err.(*rpctypes.RPCError); ok {
+		return rpcErr
+	}
+	return rpctypes.NewRPCError(rpctypes.CodeServerError, err.Error())
+}
+
+func writeAPIError(w http.ResponseWriter, err error) {
+	rpcErr := toRPCError(err)
+	body, _ := json.Marshal(apiError{Error: rpcErr})
+	writeAPIJSON(w, apiStatus(rpcErr), body)
+}
+
+func writeAPIJSON(w http.ResponseWriter, status int, body []byte) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	w.Write(body)
+}
+
+// apiHandler serves the routes, results are written with go-wire like the rpc ones.
+// A request body larger than maxBodyBytes is refused.
+func (n *Node) apiHandler(routes []apiRoute, maxBodyBytes int64) http.Handler {
+	h := newRPCHandler(n)
+	doc, _ := json.MarshalIndent(openAPIDocument(routes), "", "  ")
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == OpenAPIPath {
+			writeAPIJSON(w, http.StatusOK, doc)
+			return
+		}
+
+		pathFound := false
+		for _, route := range routes {
+			vars, ok := matchAPIPath(route.Path, r.URL.Path)
+			if !ok {
+				continue
+			}
+			pathFound = true
+			if r.Method != route.Method {
+				continue
+			}
+
+			if err := rpc.CheckAccess(r, route.RPC); err != nil {
+				writeAPIError(w, rpctypes.NewRPCError(rpctypes.CodeUnauthorized, err.Error()))
+				return
+			}
+			req := &apiRequest{Request: r, vars: vars, query: r.URL.Query(), status: route.Status}
+			if req.status == 0 {
+				req.status = http.StatusOK
+			}
+			if r.Body != nil {
+				body, err := ioutil.ReadAll(http.MaxBytesReader(w, r.Body, maxBodyBytes))
+				if err != nil {
+					writeAPIError(w, rpctypes.NewRPCError(rpctypes.CodeInvalidRequest, err.Error()))
+					return
+				}
+				req.body = body
+			}
+
+			res, err := route.handle(h, req)
+			if err != nil {
+				writeAPIError(w, err)
+				return
+			}
+			writeAPIJSON(w, req.status, wire.JSONBytes(res))
+			return
+		}
+
+		if pathFound {
+			w.Header().Set("Allow", strings.Join(allowedMethods(routes, r.URL.Path), ", "))
+			body, _ := json.Marshal(apiError{Error: rpctypes.NewRPCError(rpctypes.CodeMethodNotFound, "method not allowed")})
+			writeAPIJSON(w, http.StatusMethodNotAllowed, body)
+			return
+		}
+		body, _ := json.Marshal(apiError{Error: rpctypes.NewRPCError(rpctypes.CodeMethodNotFound, "no such resource")})
+		writeAPIJSON(w, http.StatusNotFound, body)
+	})
+}
+
+func allowedMethods(routes []apiRoute, path string) []string {
+	var methods []string
+	for _, route := range routes {
+		if _, ok := matchAPIPath(route.Path, path); ok {
+			methods = append(methods, route.Method)
+		}
+	}
+	return methods
+}
@@ -0,0 +1,115 @@
+// Copyright 2017 Delos Development Foundation and contributors. Licensed
+// under the Apache License, Version 2.0. See the COPYING file at the root
+// of this distribution or at http://www.apache.org/licenses/LICENSE-2.0
+package node
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/DelosIsland/core/dngine/types"
+	rpctypes "github.com/DelosIsland/core/module/lib/go-rpc/types"
+)
+
+func TestMatchAPIPath(t *testing.T) {
+	vars, ok := matchAPIPath("/chains/{chainid}/blocks/{height}", "/chains/test-chain/blocks/12")
+	if !ok || vars["chainid"] != "test-chain" || vars["height"] != "12" {
+		t.Errorf("Unexpected match %v %v", vars, ok)
+	}
+	for _, path := range []string{"/chains/test-chain/blocks", "/chains//blocks/12", "/chains/test-chain/txs/12"} {
+		if _, ok := matchAPIPath("/chains/{chainid}/blocks/{height}", path); ok {
+			t.Errorf("Expected %s not to match", path)
+		}
+	}
+}
+
+func TestAPIHandler(t *testing.T) {
+	routes := []apiRoute{
+		{
+			Method: "GET", Path: "/chains/{chainid}/blocks/{height}", RPC: "block", Result: types.ResultBlock{},
+			handle: func(h *rpcHandler, req *apiRequest) (interface{}, error) {
+				switch req.vars["height"] {
+				case "1":
+					return &types.ResultBlock{}, nil
+				case "0":
+					return nil, rpctypes.NewRPCError(rpctypes.CodeInvalidParams, "height must be greater than 0")
+				}
+				return nil, rpctypes.NewRPCError(rpctypes.CodeNotFound, "no such block")
+			},
+		},
+		{
+			Method: "POST", Path: "/chains/{chainid}/txs", RPC: "broadcast_tx_sync", Status: http.StatusAccepted,
+			Result: types.ResultBroadcastTx{},
+			handle: func(h *rpcHandler, req *apiRequest) (interface{}, error) {
+				if string(req.body) == "refused" {
+					return nil, types.NewRPCAppError(types.NewResult(types.CodeType_BadNonce, nil, "bad nonce"))
+				}
+				return nil, ErrInvalidChainID
+			},
+		},
+	}
+	handler := (&Node{}).apiHandler(routes, 16)
+
+	cases := []struct {
+		method, path, body string
+		status             int
+	}{
+		{"GET", "/chains/c/blocks/1", "", http.StatusOK},
+		{"GET", "/chains/c/blocks/0", "", http.StatusBadRequest},
+		{"GET", "/chains/c/blocks/2", "", http.StatusNotFound},
+		{"POST", "/chains/c/txs", "refused", http.StatusUnprocessableEntity},
+		{"POST", "/chains/c/txs", "", http.StatusNotFound},
+		{"POST", "/chains/c/txs", strings.Repeat("tx", 9), http.StatusBadRequest},
+		{"GET", "/chains/c/txs", "", http.StatusMethodNotAllowed},
+		{"GET", "/chains", "", http.StatusNotFound},
+		{"GET", OpenAPIPath, "", http.StatusOK},
+	}
+	for _, c := range cases {
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, httptest.NewRequest(c.method, c.path, strings.NewReader(c.body)))
+		if w.Code != c.status {
+			t.Errorf("%s %s: expected status %d, got %d %s", c.method, c.path, c.status, w.Code, w.Body.String())
+		}
+		if w.Code >= 400 {
+			var res apiError
+			if err := json.Unmarshal(w.Body.Bytes(), &res); err != nil || res.Error == nil {
+				t.Errorf("%s %s: expected an error body, got %s", c.method, c.path, w.Body.String())
+			}
+		}
+	}
+}
+
+func TestOpenAPIDocument(t *testing.T) {
+	doc := openAPIDocument((&Node{}).apiRoutes())
+	raw, err := json.Marshal(doc)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// every reference must be defined
+	schemas := doc["components"].(map[string]interface{})["schemas"].(map[string]interface{})
+	for _, part := range strings.Split(string(raw), `"$ref":"#/components/schemas/`)[1:] {
+		name := part[:strings.Index(part, `"`)]
+		if _, ok := schemas[name]; !ok {
+			t.Errorf("Undefined schema %s", name)
+		}
+	}
+
+	paths := doc["paths"].(map[string]interface{})
+	txs, ok := paths["/chains/{chainid}/txs"].(map[string]interface{})
+	if !ok || txs["post"] == nil {
+		t.Fatal("Expected POST /chains/{chainid}/txs to be described")
+	}
+	responses := txs["post"].(map[string]interface{})["responses"].(map[string]interface{})
+	for _, status := range []string{"200", "202", "default"} {
+		if responses[status] == nil {
+			t.Errorf("Expected a %s response for POST /chains/{chainid}/txs", status)
+		}
+	}
+	if _, ok := schemas["ResultBlock"]; !ok {
+		t.Error("Expected the ResultBlock schema")
+	}
+}
@@ -0,0 +1,85 @@
+// Copyright 2017 Delos Development Foundation and contributors. Licensed
+// under the Apache License, Version 2.0. See the COPYING file at the root
+// of this distribution or at http://www.apache.org/licenses/LICENSE-2.0
+package node
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path"
+	"testing"
+	"time"
+
+	"go.uber.org/zap"
+
+	"github.com/DelosIsland/core/dngine/testnet"
+	"github.com/DelosIsland/core/dngine/types"
+	"github.com/DelosIsland/core/module/lib/go-config"
+	"github.com/DelosIsland/core/module/lib/go-crypto"
+)
+
+// fundCluster credits the accounts in the genesis of every node of the cluster
+func fundCluster(c *testnet.Cluster, accounts map[string]Account) error {
+	appState, err := json.Marshal(GenesisAppState{Accounts: accounts})
+	if err != nil {
+		return err
+	}
+	genDoc := c.Testnet.Genesis
+	genDoc.AppState = appState
+	if genDoc.AppHash, err = GenesisAppHash(appState); err != nil {
+		return err
+	}
+	for _, n := range c.Testnet.Nodes {
+		if err := genDoc.SaveAs(path.Join(n.Dir, "genesis.json")); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func TestClusterTransfer(t *testing.T) {
+	if testing.Short() {
+		t.Skip("runs a 3 nodes chain")
+	}
+	dir, err := ioutil.TempDir("", "myapp_cluster")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	c, err := testnet.NewCluster(dir, 3, func(conf config.Config) types.Application {
+		return NewMyApp(zap.NewNop(), conf)
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Stop()
+	privKey := crypto.GenPrivKeyEd25519()
+	if err := fundCluster(c, map[string]Account{AddressFromPubKey(privKey.PubKey()): {Balance: testBalance}}); err != nil {
+		t.Fatal(err)
+	}
+	if err := c.Start(); err != nil {
+		t.Fatal(err)
+	}
+
+	tx := &MyTx{
+		ChainID:     c.Testnet.Genesis.ChainID,
+		Time:        time.Now(),
+		DestAddress: testDest,
+		Amount:      10,
+	}
+	tx.SignByPrivKey(privKey)
+	res, err := c.Nodes[0].Dngine.BroadcastTxCommit(TagMyTx(testTxJSON(tx)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := c.WaitForHeight(res.Height+1, 30*time.Second); err != nil {
+		t.Fatal(err)
+	}
+	for i, node := range c.Nodes {
+		if acc, _ := node.App.(*MyApp).state.Get(testDest); acc.Balance != 10 {
+			t.Errorf("Expected the transfer on node%d, got %+v", i, acc)
+		}
+	}
+}
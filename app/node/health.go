@@ -0,0 +1,57 @@
+// Copyright 2017 Delos Development Foundation and contributors. Licensed
+// under the Apache License, Version 2.0. See the COPYING file at the root
+// of this distribution or at http://www.apache.org/licenses/LICENSE-2.0
+package node
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/DelosIsland/core/dngine"
+)
+
+// healthResponse is the body of /health and /ready
+type healthResponse struct {
+	Status     string `json:"status"` // ok or unavailable
+	Error      string `json:"error,omitempty"`
+	ChainID    string `json:"chain_id"`
+	Height     int    `json:"height"`
+	CatchingUp bool   `json:"catching_up"`
+}
+
+// registerHealthHandlers serves the probes of the orchestrators:
+// /health fails when the node must be restarted, /ready until it can serve its chain.
+// They answer for the main chain, or the one given as chainid in the query.
+func (n *Node) registerHealthHandlers(mux *http.ServeMux) {
+	mux.HandleFunc("/health", n.healthHandler((*dngine.Dngine).CheckHealth))
+	mux.HandleFunc("/ready", n.healthHandler((*dngine.Dngine).CheckReady))
+}
+
+func (n *Node) healthHandler(check func(*dngine.Dngine) error) http.HandlerFunc {
+	h := newRPCHandler(n)
+	return func(w http.ResponseWriter, r *http.Request) {
+		chainID := r.URL.Query().Get(ChainIDArg)
+		if chainID == "" {
+			chainID = n.MainChainID
+		}
+		shard, err := h.getShard(chainID)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusNotFound)
+			return
+		}
+
+		res := healthResponse{
+			Status:     "ok",
+			ChainID:    chainID,
+			Height:     shard.Dngine.Height(),
+			CatchingUp: shard.Dngine.IsCatchingUp(),
+		}
+		status := http.StatusOK
+		if err := check(shard.Dngine); err != nil {
+			res.Status, res.Error, status = "unavailable", err.Error(), http.StatusServiceUnavailable
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(status)
+		json.NewEncoder(w).Encode(res)
+	}
+}
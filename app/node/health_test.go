@@ -0,0 +1,67 @@
+// Copyright 2017 Delos Development Foundation and contributors. Licensed
+// under the Apache License, Version 2.0. See the COPYING file at the root
+// of this distribution or at http://www.apache.org/licenses/LICENSE-2.0
+package node
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/DelosIsland/core/dngine/testnet"
+)
+
+func TestHealthHandler(t *testing.T) {
+	if testing.Short() {
+		t.Skip("runs a chain")
+	}
+	dir, err := ioutil.TempDir("", "health")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	c, err := testnet.NewCluster(dir, 1, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Stop()
+	if err := c.Start(); err != nil {
+		t.Fatal(err)
+	}
+	if err := c.WaitForHeight(1, 30*time.Second); err != nil {
+		t.Fatal(err)
+	}
+
+	n := &Node{
+		MainChainID: c.Testnet.Genesis.ChainID,
+		MainShard:   &MyNode{Dngine: c.Nodes[0].Dngine},
+	}
+	mux := http.NewServeMux()
+	n.registerHealthHandlers(mux)
+	probe := func(path string) (int, healthResponse) {
+		w := httptest.NewRecorder()
+		mux.ServeHTTP(w, httptest.NewRequest("GET", path, nil))
+		var res healthResponse
+		json.Unmarshal(w.Body.Bytes(), &res)
+		return w.Code, res
+	}
+
+	for _, path := range []string{"/health", "/ready"} {
+		if status, res := probe(path); status != http.StatusOK || res.Status != "ok" || res.Height < 1 {
+			t.Errorf("%s: expected the node to be ok, got %d %+v", path, status, res)
+		}
+	}
+	if status, _ := probe("/ready?chainid=nochain"); status != http.StatusNotFound {
+		t.Errorf("Expected an unknown chain to be not found, got %d", status)
+	}
+
+	c.Nodes[0].Dngine.Stop()
+	if status, res := probe("/health"); status != http.StatusServiceUnavailable || res.Error == "" {
+		t.Errorf("Expected a stopped node to be unhealthy, got %d %+v", status, res)
+	}
+}
@@ -0,0 +1,57 @@
+// Copyright 2017 Delos Development Foundation and contributors. Licensed
+// under the Apache License, Version 2.0. See the COPYING file at the root
+// of this distribution or at http://www.apache.org/licenses/LICENSE-2.0
+package node
+
+import (
+	"net"
+	"net/http"
+	"strings"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+	dto "github.com/prometheus/client_model/go"
+
+	"github.com/DelosIsland/core/module/lib/go-rpc/server"
+)
+
+// StartMetrics serves the prometheus metrics of the node on metrics_laddr, under /metrics,
+// along with the /health and /ready probes
+func (n *Node) StartMetrics() ([]net.Listener, error) {
+	listenAddrs := strings.Split(n.config.GetString("metrics_laddr"), ",")
+	listeners := make([]net.Listener, len(listenAddrs))
+
+	mux := http.NewServeMux()
+	mux.Handle("/metrics", promhttp.HandlerFor(n.MetricsGatherer(), promhttp.HandlerOpts{}))
+	n.registerHealthHandlers(mux)
+	for i, listenAddr := range listenAddrs {
+		listener, err := rpcserver.StartHTTPServer(n.logger, listenAddr, mux)
+		if err != nil {
+			return nil, err
+		}
+		listeners[i] = listener
+	}
+	return listeners, nil
+}
+
+// MetricsGatherer gathers the metrics of the process, of the main chain and of the running shards,
+// those of the chains are told apart by their chain_id label
+func (n *Node) MetricsGatherer() prometheus.Gatherer {
+	process := prometheus.NewRegistry()
+	process.MustRegister(collectors.NewGoCollector(), collectors.NewProcessCollector(collectors.ProcessCollectorOpts{}))
+
+	return prometheus.GathererFunc(func() ([]*dto.MetricFamily, error) {
+		gatherers := prometheus.Gatherers{process, n.MainShard.Dngine.Metrics()}
+		if app, ok := n.MainShard.Application.(*MyApp); ok {
+			app.Lock()
+			for _, shard := range app.Shards {
+				if shard.IsRunning() {
+					gatherers = append(gatherers, shard.Dngine.Metrics())
+				}
+			}
+			app.Unlock()
+		}
+		return gatherers.Gather()
+	})
+}
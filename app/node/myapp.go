@@ -5,11 +5,16 @@ package node
 
 import (
 	"bytes"
+	"encoding/binary"
+	"encoding/hex"
 	"encoding/json"
 	"fmt"
+	"io/ioutil"
+	"regexp"
 	"sync"
 	"time"
 
+	"github.com/syndtr/goleveldb/leveldb/util"
 	"go.uber.org/zap"
 
 	"github.com/DelosIsland/core/dngine/types"
@@ -17,35 +22,56 @@ import (
 	"github.com/DelosIsland/core/module/lib/go-config"
 	"github.com/DelosIsland/core/module/lib/go-crypto"
 	"github.com/DelosIsland/core/module/lib/go-db"
-	"github.com/DelosIsland/core/module/lib/go-merkle"
 	"github.com/DelosIsland/core/module/lib/go-wire"
 )
 
-//const (
-//	ShardTag = "shrd"
-//
-//	ShardRegister byte = iota
-//	ShardCreate
-//	ShardJoin
-//	ShardLeave
-//	ShardDelete
-//)
+// Shard acts burn a fee from the balance of the sender so that they aren't free,
+// a creation costs more since every host starts a chain for it
+const (
+	ShardCreateFee uint64 = 1000
+	ShardTxFee     uint64 = 10
+)
 
-type LastBlockInfo struct {
-	Height uint64
-	Txs    [][]byte
-	Hash   []byte
+// addressPattern is what AddressFromPubKey makes, funds sent elsewhere could never be spent
+var addressPattern = regexp.MustCompile(`^0x[0-9a-f]{40}$`)
+
+// MyTx.Act, a zero Act is a transfer.
+// Shard acts are main chain txs managing the child chains hosted by the nodes.
+const (
+	ActTransfer    byte = iota
+	ActShardCreate      // registers ShardID running App with Genesis, the sender node hosts it
+	ActShardJoin        // the sender node starts hosting ShardID
+	ActShardLeave       // the sender node stops hosting ShardID
+	ActShardDelete      // the owner removes ShardID, every host stops it and drops its data
+)
+
+// MyTxTag prefixes the JSON of every MyTx in blocks
+var MyTxTag = []byte("mytx")
+
+// TagMyTx makes the bytes to broadcast out of the JSON of a MyTx
+func TagMyTx(tx []byte) []byte {
+	return types.WrapTx(MyTxTag, tx)
 }
 
-type ManagedState struct {
-	accounts map[string]int
+func IsMyTx(tx []byte) bool {
+	return bytes.HasPrefix(tx, MyTxTag)
 }
 
-var managedState = ManagedState{accounts:make(map[string]int)}
+// TxLocation is where a valid tx was committed, it is indexed by tx hash
+type TxLocation struct {
+	Hash   []byte `json:"hash"`
+	Height uint64 `json:"height"`
+	Index  int    `json:"index"` // in Block.Txs
+}
+
+// LastBlockInfo is saved on every commit, Hash is the root of the accounts tree
+type LastBlockInfo struct {
+	Height uint64
+	Hash   []byte
+}
 
 type MyApp struct {
 	config      config.Config
-	privkey     crypto.PrivKeyEd25519
 	chainDb     *db.GoLevelDB
 	node        *Node
 	engineHooks types.Hooks
@@ -53,7 +79,24 @@ type MyApp struct {
 
 	mtx sync.Mutex
 
-	Txs             [][]byte
+	state *AccountState
+
+	// valid txs of the block being executed, indexed once it is committed
+	pendingTxs []TxLocation
+
+	// shards registered or deleted by the block being executed,
+	// the hosts catch up with them once it is committed
+	pendingShards []string
+
+	// shards to start or stop, served by syncShards so that the commit never waits for them
+	syncMtx   sync.Mutex
+	syncQueue []string
+	syncWake  chan struct{}
+	syncDone  chan struct{}
+	quit      chan struct{}
+	startOnce sync.Once
+	stopOnce  sync.Once
+
 	RunningChainIDs map[string]string
 	Shards          map[string]*MyNode
 }
@@ -63,45 +106,94 @@ type Accounts struct {
 }
 
 type MyTx struct {
-	App             string                  `json:"app"`
-	Act             byte                    `json:"act"`
-	ChainID         string                  `json:"chainid"`
-	Genesis         types.GenesisDoc        `json:"genesis"`
-	Config          map[string]interface{}  `json:"config"`
-	Time            time.Time               `json:"time"`
-	Signature       crypto.SignatureEd25519 `json:"signature"`
-	DestAddress	    string   		        `json:"destaddress"`
-	SourceAddress	string		            `json:"sourceaddress"`
-	Amount          int	                    `json:"amount"`
-}
-
-func (t *MyTx) SignByPrivKey(p crypto.PrivKeyEd25519) error {
-	txBytes, err := json.Marshal(t)
-	if err != nil {
-		return err
-	}
-	t.Signature = p.Sign(txBytes).(crypto.SignatureEd25519)
+	App         string                 `json:"app"`
+	Act         byte                   `json:"act"`
+	ChainID     string                 `json:"chainid"`
+	ShardID     string                 `json:"shardid"` // chain id of the shard for shard acts
+	Genesis     types.GenesisDoc       `json:"genesis"`
+	Config      map[string]interface{} `json:"config"`
+	Time        time.Time              `json:"time"`
+	PubKey      []byte                 `json:"pubkey"`    // go-wire encoded crypto.PubKey of the sender
+	Signature   []byte                 `json:"signature"` // go-wire encoded crypto.Signature of SignBytes
+	DestAddress string                 `json:"destaddress"`
+	Amount      uint64                 `json:"amount"`
+	Nonce       uint64                 `json:"nonce"` // must match the sender's account nonce
+}
 
-	return nil
+// AddressFromPubKey is how accounts are named, 0x followed by the hex of the pubkey address
+func AddressFromPubKey(pubKey crypto.PubKey) string {
+	return "0x" + hex.EncodeToString(pubKey.Address())
 }
 
-func (t *MyTx) VerifySignature(pubKey crypto.PubKeyEd25519, signature crypto.SignatureEd25519) bool {
-	//tBytes, err := json.Marshal(t)
-	//if err != nil {
-	//	return false
-	//}
-	//pubKeyBytes := [32]byte(pubKey)
-	//sig := [64]byte(signature)
-	//return ed25519.Verify(&pubKeyBytes, tBytes, &sig)
-	return true
+// SignBytes is what the sender signs: the go-wire encoding of the tx fields,
+// Genesis and Config are signed as JSON.
+func (t *MyTx) SignBytes() []byte {
+	config, _ := json.Marshal(t.Config) // sorted keys
+	return wire.BinaryBytes(struct {
+		App         string
+		Act         byte
+		ChainID     string
+		ShardID     string
+		Genesis     []byte
+		Config      []byte
+		Time        int64
+		PubKey      []byte
+		DestAddress string
+		Amount      uint64
+		Nonce       uint64
+	}{t.App, t.Act, t.ChainID, t.ShardID, wire.JSONBytes(&t.Genesis), config, t.Time.UnixNano(), t.PubKey, t.DestAddress, t.Amount, t.Nonce})
 }
 
-var (
-	lastBlockKey = []byte("lastblock")
+// SignByPrivKey sets the sender to the key owner and signs the tx
+func (t *MyTx) SignByPrivKey(p crypto.PrivKey) {
+	t.PubKey = p.PubKey().Bytes()
+	t.Signature = p.Sign(t.SignBytes()).Bytes()
+}
+
+// Sender verifies the signature and returns the sender address
+func (t *MyTx) Sender() (string, error) {
+	pubKey, err := crypto.PubKeyFromBytes(t.PubKey)
+	if err != nil {
+		return "", ErrInvalidPubKey
+	}
+	sig, err := crypto.SignatureFromBytes(t.Signature)
+	if err != nil {
+		return "", ErrInvalidSignature
+	}
+	if !pubKey.VerifyBytes(t.SignBytes(), sig) {
+		return "", ErrInvalidSignature
+	}
+	return AddressFromPubKey(pubKey), nil
+}
 
-	ErrUnknownTx   = fmt.Errorf("unknown tx")
-	ErrShardExists = fmt.Errorf("shard exists")
-	ErrFailToStop  = fmt.Errorf("stop shard failed")
+var (
+	lastBlockKey = []byte("laststate")
+
+	// the apps hashing all the txs saved them under this key. The AppHash of their
+	// blocks can't be matched by the accounts tree, such a chain can't be upgraded:
+	// it has to start over from a new genesis with wiped data.
+	legacyLastBlockKey = []byte("lastblock")
+
+	rootKeyPrefix = []byte("root/")
+
+	ErrUnknownTx        = fmt.Errorf("unknown tx")
+	ErrUnknownAct       = fmt.Errorf("unknown tx act")
+	ErrInvalidPubKey    = fmt.Errorf("invalid sender pubkey")
+	ErrInvalidSignature = fmt.Errorf("invalid signature")
+	ErrInvalidAmount    = fmt.Errorf("amount must be positive")
+	ErrInvalidAddress   = fmt.Errorf("invalid destination address")
+	ErrWrongChainID     = fmt.Errorf("tx is for another chain")
+	ErrBadNonce         = fmt.Errorf("bad nonce")
+	ErrInsufficientFund = fmt.Errorf("insufficient balance")
+	ErrInvalidShard     = fmt.Errorf("invalid shard")
+	ErrUnknownApp       = fmt.Errorf("unknown shard app")
+	ErrShardExists      = fmt.Errorf("shard exists")
+	ErrShardNotFound    = fmt.Errorf("no such shard")
+	ErrNotShardOwner    = fmt.Errorf("only the owner may delete a shard")
+	ErrAlreadyMember    = fmt.Errorf("already hosting the shard")
+	ErrNotMember        = fmt.Errorf("not hosting the shard")
+	ErrFailToStop       = fmt.Errorf("stop shard failed")
+	ErrLegacyAppDb      = fmt.Errorf("the app db is from a version whose AppHash hashed the txs, the chain must start over from a new genesis with wiped data")
 )
 
 func NewMyApp(logger *zap.Logger, conf config.Config) *MyApp {
@@ -110,7 +202,10 @@ func NewMyApp(logger *zap.Logger, conf config.Config) *MyApp {
 		config: conf,
 		logger: logger,
 
-		Txs:             make([][]byte, 0),
+		syncWake: make(chan struct{}, 1),
+		syncDone: make(chan struct{}),
+		quit:     make(chan struct{}),
+
 		RunningChainIDs: make(map[string]string),
 		Shards:          make(map[string]*MyNode),
 	}
@@ -119,6 +214,16 @@ func NewMyApp(logger *zap.Logger, conf config.Config) *MyApp {
 	if app.chainDb, err = db.NewGoLevelDB("chaindata", datadir); err != nil {
 		cmn.PanicCrisis(err)
 	}
+	if len(app.chainDb.Get(legacyLastBlockKey)) != 0 {
+		cmn.PanicCrisis(ErrLegacyAppDb)
+	}
+	app.state = NewAccountState(app.chainDb)
+	app.state.KeepVersions(conf.GetInt("rollback_heights"))
+	if lastBlock := app.LoadLastBlock(); lastBlock.Height == 0 && len(lastBlock.Hash) == 0 {
+		app.loadGenesis()
+	} else {
+		app.state.Load(lastBlock.Hash)
+	}
 
 	app.engineHooks = types.Hooks{
 		OnExecute: types.NewHook(app.OnExecute),
@@ -128,6 +233,26 @@ func NewMyApp(logger *zap.Logger, conf config.Config) *MyApp {
 	return &app
 }
 
+// loadGenesis starts the accounts from the app_state of the genesis, if it has one
+func (app *MyApp) loadGenesis() {
+	buf, err := ioutil.ReadFile(app.config.GetString("genesis_file"))
+	if err != nil {
+		return
+	}
+	genDoc, err := types.ReadGenesisDoc(buf)
+	if err != nil || len(genDoc.AppState) == 0 {
+		return
+	}
+	if err := app.state.LoadGenesis(genDoc.AppState); err != nil {
+		cmn.PanicCrisis(err)
+	}
+	root := app.state.Save()
+	if !bytes.Equal(root, genDoc.AppHash) {
+		cmn.PanicCrisis(fmt.Sprintf("the app_hash %X of the genesis doesn't match its app_state, expected %X", genDoc.AppHash, root))
+	}
+	app.SaveLastBlock(LastBlockInfo{Height: 0, Hash: root})
+}
+
 func (app *MyApp) Lock() {
 	app.mtx.Lock()
 }
@@ -142,20 +267,24 @@ func (app *MyApp) setNode(n *Node) {
 
 // Stop stops all still running shards
 func (app *MyApp) Stop() {
+	app.stopOnce.Do(func() {
+		// a shard being started or stopped is done with before the others are stopped
+		app.startOnce.Do(func() { close(app.syncDone) })
+		close(app.quit)
+		<-app.syncDone
+	})
+
+	app.Lock()
+	defer app.Unlock()
 	for i := range app.Shards {
 		app.Shards[i].Stop()
 	}
 }
 
-// Start will restore all shards according to tx history
+// Start restarts the shards hosted by this node, the accounts are loaded from db in NewMyApp
 func (app *MyApp) Start() {
-	lastBlock := app.LoadLastBlock()
-	if lastBlock.Txs != nil && len(lastBlock.Txs) > 0 {
-		app.Txs = lastBlock.Txs
-		for _, tx := range lastBlock.Txs {
-			app.ExecuteTx(nil, tx, 0)
-		}
-	}
+	app.startOnce.Do(func() { go app.syncShards() })
+	app.restoreShards()
 }
 
 func (app *MyApp) GetDngineHooks() types.Hooks {
@@ -166,40 +295,33 @@ func (app *MyApp) CompatibleWithDngine() {}
 
 // ExecuteTx execute tx one by one in the loop, without lock, so should always be called between Lock() and Unlock() on the *stateDup
 func (app *MyApp) ExecuteTx(blockHash []byte, bs []byte, txIndex int) (validtx []byte, err error) {
-	//if !app.IsShardingTx(bs) {
-	//	return nil, ErrUnknownTx
-	//}
+	validtx, _, err = app.executeTx(bs)
+	return validtx, err
+}
+
+// executeTx returns the JSON account of the sender after the tx as result data
+func (app *MyApp) executeTx(bs []byte) (validtx []byte, data []byte, err error) {
+	if !IsMyTx(bs) {
+		return nil, nil, ErrUnknownTx
+	}
 
 	txBytes := types.UnwrapTx(bs)
 	tx := MyTx{}
 	if err := json.Unmarshal(txBytes, &tx); err != nil {
 		app.logger.Info("Unmarshal tx failed", zap.Binary("tx", txBytes), zap.String("error", err.Error()))
-		return nil, err
-	}
-
-	sig := tx.Signature
-	tx.Signature = [64]byte{}
-	pubkey, ok := app.node.PrivValidator().PubKey.(crypto.PubKeyEd25519)
-	if !ok {
-		return nil, fmt.Errorf("node's pubkey must be crypto.PubKeyEd25519")
+		return nil, nil, err
 	}
 
-	if !tx.VerifySignature(pubkey, sig) {
-		app.logger.Debug("this tx is not for me", zap.Binary("tx", bs))
-		return bs, nil
+	if err := app.applyTx(&tx, true); err != nil {
+		app.logger.Debug("Invalid tx", zap.Binary("tx", bs), zap.String("error", err.Error()))
+		return nil, nil, err
 	}
 
-	baseAmount := 1000000
-	if _, ok := managedState.accounts[tx.SourceAddress]; !ok {
-		managedState.accounts[tx.SourceAddress] = baseAmount
-	}
-	if _, ok := managedState.accounts[tx.DestAddress]; !ok {
-		managedState.accounts[tx.DestAddress] = baseAmount
-	}
-	managedState.accounts[tx.SourceAddress] = managedState.accounts[tx.SourceAddress] - tx.Amount
-	managedState.accounts[tx.DestAddress] = managedState.accounts[tx.DestAddress] + tx.Amount
-
-	return bs, nil
+	// the sender is valid once the tx applied
+	from, _ := tx.Sender()
+	acc, _ := app.state.Get(from)
+	data, _ = json.Marshal(acc)
+	return bs, data, nil
 }
 
 // OnExecute would not care about Block.ExTxs
@@ -207,32 +329,37 @@ func (app *MyApp) OnExecute(height, round int, block *types.Block) (interface{},
 	var (
 		res types.ExecuteResult
 		err error
-
-		blockHash = block.Hash()
 	)
 
 	for i := range block.Txs {
-		vtx, err := app.ExecuteTx(blockHash, block.Txs[i], i)
+		vtx, data, err := app.executeTx(block.Txs[i])
 		if err == nil {
 			res.ValidTxs = append(res.ValidTxs, vtx)
+			res.ValidTxsData = append(res.ValidTxsData, data)
+			app.pendingTxs = append(app.pendingTxs, TxLocation{Hash: block.Txs[i].Hash(), Height: uint64(height), Index: i})
 		} else {
 			if err == ErrUnknownTx {
 				// maybe we could do something with another app or so
 			} else {
-				res.InvalidTxs = append(res.InvalidTxs, types.ExecuteInvalidTx{Bytes: block.Txs[i], Error: err})
+				res.InvalidTxs = append(res.InvalidTxs, types.ExecuteInvalidTx{Bytes: block.Txs[i], Error: txResult(err)})
 			}
 		}
 	}
 
-	app.Txs = append(app.Txs, res.ValidTxs...)
-
 	return res, err
 }
 
 // OnCommit run in a sync way, we don't need to lock stateDupMtx, but stateMtx is still needed
 func (app *MyApp) OnCommit(height, round int, block *types.Block) (interface{}, error) {
-	lastBlock := LastBlockInfo{Height: uint64(height), Txs: app.Txs, Hash: merkle.SimpleHashFromHashes(app.Txs)}
+	lastBlock := LastBlockInfo{Height: uint64(height), Hash: app.state.Save()}
+	app.saveRoot(lastBlock)
+	app.saveTxLocations(app.pendingTxs)
+	app.pendingTxs = nil
 	app.SaveLastBlock(lastBlock)
+
+	app.queueShardSyncs(app.pendingShards...)
+	app.pendingShards = nil
+
 	return types.CommitResult{AppHash: lastBlock.Hash}, nil
 }
 
@@ -248,6 +375,61 @@ func (app *MyApp) LoadLastBlock() (lastBlock LastBlockInfo) {
 	return lastBlock
 }
 
+func rootKey(height uint64) []byte {
+	key := make([]byte, len(rootKeyPrefix)+8)
+	copy(key, rootKeyPrefix)
+	binary.BigEndian.PutUint64(key[len(rootKeyPrefix):], height)
+	return key
+}
+
+// saveRoot indexes the accounts tree of a height for the queries of the past heights,
+// the trees beyond rollback_heights are pruned so their roots are forgotten
+func (app *MyApp) saveRoot(lastBlock LastBlockInfo) {
+	batch := app.chainDb.NewBatch()
+	batch.Set(rootKey(lastBlock.Height), lastBlock.Hash)
+	keep := uint64(app.config.GetInt("rollback_heights"))
+	if keep < 1 {
+		keep = 1
+	}
+	if lastBlock.Height > keep {
+		batch.Delete(rootKey(lastBlock.Height - keep - 1))
+	}
+	batch.Write()
+}
+
+// loadRoot returns the root of the accounts tree saved at the end of height
+func (app *MyApp) loadRoot(height uint64) ([]byte, bool) {
+	root := app.chainDb.Get(rootKey(height))
+	return root, root != nil
+}
+
+func txKey(hash []byte) []byte {
+	return append([]byte("tx/"), hash...)
+}
+
+func (app *MyApp) saveTxLocations(locs []TxLocation) {
+	if len(locs) == 0 {
+		return
+	}
+	batch := app.chainDb.NewBatch()
+	for _, loc := range locs {
+		batch.Set(txKey(loc.Hash), wire.BinaryBytes(loc))
+	}
+	batch.Write()
+}
+
+// LoadTxLocation returns where the tx with the given hash was committed
+func (app *MyApp) LoadTxLocation(hash []byte) (loc TxLocation, ok bool) {
+	buf := app.chainDb.Get(txKey(hash))
+	if len(buf) == 0 {
+		return loc, false
+	}
+	if err := wire.ReadBinaryBytes(buf, &loc); err != nil {
+		return loc, false
+	}
+	return loc, true
+}
+
 func (app *MyApp) SaveLastBlock(lastBlock LastBlockInfo) {
 	buf, n, err := new(bytes.Buffer), new(int), new(error)
 	wire.WriteBinary(lastBlock, buf, n, err)
@@ -257,28 +439,227 @@ func (app *MyApp) SaveLastBlock(lastBlock LastBlockInfo) {
 	app.chainDb.SetSync(lastBlockKey, buf.Bytes())
 }
 
+// Rollback goes back to the accounts tree saved at the end of height, the txs
+// committed above it are forgotten
+func (app *MyApp) Rollback(height int, appHash []byte) error {
+	lastBlock := app.LoadLastBlock()
+	if lastBlock.Height < uint64(height) {
+		return fmt.Errorf("the app is at height %d", lastBlock.Height)
+	}
+	if !app.state.Saved(appHash) {
+		return fmt.Errorf("the accounts tree %X of height %d was not kept", appHash, height)
+	}
+	app.state.Load(appHash)
+
+	iter := app.chainDb.DB().NewIterator(util.BytesPrefix(txKey(nil)), nil)
+	batch := app.chainDb.NewBatch()
+	for h := uint64(height) + 1; h <= lastBlock.Height; h++ {
+		batch.Delete(rootKey(h))
+	}
+	for iter.Next() {
+		var loc TxLocation
+		if err := wire.ReadBinaryBytes(iter.Value(), &loc); err == nil && loc.Height > uint64(height) {
+			batch.Delete(append([]byte{}, iter.Key()...))
+		}
+	}
+	iter.Release()
+	if err := iter.Error(); err != nil {
+		return err
+	}
+	batch.Write()
+
+	app.SaveLastBlock(LastBlockInfo{Height: uint64(height), Hash: appHash})
+	return nil
+}
+
+// CheckTx returns the refusals as a types.Result so that the rpc reports their code
 func (app *MyApp) CheckTx(bs []byte) error {
-	//if !app.IsShardingTx(bs) {
-	//	return ErrUnknownTx
-	//}
+	if !IsMyTx(bs) {
+		return txResult(ErrUnknownTx)
+	}
 
 	txBytes := types.UnwrapTx(bs)
 	tx := MyTx{}
 
 	if err := json.Unmarshal(txBytes, &tx); err != nil {
 		app.logger.Info("Unmarshal tx failed", zap.Binary("tx", txBytes), zap.String("error", err.Error()))
-		return err
+		return types.NewResult(types.CodeType_EncodingError, nil, err.Error())
 	}
-	sig := tx.Signature
-	tx.Signature = [64]byte{}
-	pubkey, ok := app.node.PrivValidator().PubKey.(crypto.PubKeyEd25519)
+	if err := app.applyTx(&tx, false); err != nil {
+		return txResult(err)
+	}
+	return nil
+}
+
+// txErrorCodes are the result codes of the tx errors, the others are invalid txs
+var txErrorCodes = map[error]types.CodeType{
+	ErrUnknownTx:        types.CodeType_UnknownRequest,
+	ErrInvalidPubKey:    types.CodeType_BaseInvalidPubKey,
+	ErrInvalidSignature: types.CodeType_BaseInvalidSignature,
+	ErrInvalidAmount:    types.CodeType_BaseInvalidInput,
+	ErrInvalidAddress:   types.CodeType_BaseInvalidOutput,
+	ErrInsufficientFund: types.CodeType_InsufficientFunds,
+	ErrNotShardOwner:    types.CodeType_Unauthorized,
+}
+
+func txResult(err error) types.Result {
+	if res, ok := err.(types.Result); ok {
+		return res
+	}
+	code, ok := txErrorCodes[err]
 	if !ok {
-		return fmt.Errorf("my key is not crypto.PubKeyEd25519")
+		code = types.CodeType_InvalidTx
 	}
-	if !tx.VerifySignature(pubkey, sig) {
-		return fmt.Errorf("wrong tx for wrong node: %X", pubkey)
+	return types.NewResult(code, nil, err.Error())
+}
+
+// applyTx checks the tx and applies it to the state if apply is set
+func (app *MyApp) applyTx(tx *MyTx, apply bool) error {
+	switch tx.Act {
+	case ActTransfer:
+		return app.applyTransfer(tx, apply)
+	case ActShardCreate, ActShardJoin, ActShardLeave, ActShardDelete:
+		return app.applyShardTx(tx, apply)
 	}
+	return ErrUnknownAct
+}
 
+// checkSender verifies the chain, the signature and the nonce of a tx and returns the sender account.
+// CheckTx accepts nonces ahead of the account so that a sender can queue several txs,
+// blocks must use them in order.
+func (app *MyApp) checkSender(tx *MyTx, apply bool) (string, Account, error) {
+	var fromAcc Account
+	// set by the dngine, app.node isn't there yet while replaying blocks
+	if tx.ChainID != app.config.GetString("chain_id") {
+		return "", fromAcc, ErrWrongChainID
+	}
+	from, err := tx.Sender()
+	if err != nil {
+		return "", fromAcc, err
+	}
+
+	fromAcc, _ = app.state.Get(from)
+	if tx.Nonce < fromAcc.Nonce || (apply && tx.Nonce != fromAcc.Nonce) {
+		return "", fromAcc, types.NewResult(types.CodeType_BadNonce, nil, fmt.Sprintf("%v: expected %d, got %d", ErrBadNonce, fromAcc.Nonce, tx.Nonce))
+	}
+	return from, fromAcc, nil
+}
+
+// applyTransfer checks the tx against the sender account and moves the funds if apply is set
+func (app *MyApp) applyTransfer(tx *MyTx, apply bool) error {
+	if tx.Amount == 0 {
+		return ErrInvalidAmount
+	}
+	if !addressPattern.MatchString(tx.DestAddress) {
+		return ErrInvalidAddress
+	}
+	from, fromAcc, err := app.checkSender(tx, apply)
+	if err != nil {
+		return err
+	}
+	if fromAcc.Balance < tx.Amount {
+		return ErrInsufficientFund
+	}
+	toAcc, _ := app.state.Get(tx.DestAddress)
+	if tx.DestAddress != from && toAcc.Balance+tx.Amount < toAcc.Balance {
+		return ErrInvalidAmount
+	}
+	if !apply {
+		return nil
+	}
+
+	fromAcc.Balance -= tx.Amount
+	fromAcc.Nonce++
+	app.state.Set(from, fromAcc)
+	if tx.DestAddress == from {
+		toAcc = fromAcc
+	}
+	toAcc.Balance += tx.Amount
+	app.state.Set(tx.DestAddress, toAcc)
+	return nil
+}
+
+// applyShardTx checks a shard act against the registry and updates it if apply is set,
+// the nodes start or stop their copy of the shard once the block is committed.
+func (app *MyApp) applyShardTx(tx *MyTx, apply bool) error {
+	if !shardIDPattern.MatchString(tx.ShardID) || tx.ShardID == app.config.GetString("chain_id") {
+		return ErrInvalidShard
+	}
+	from, fromAcc, err := app.checkSender(tx, apply)
+	if err != nil {
+		return err
+	}
+	pubKey, _ := crypto.PubKeyFromBytes(tx.PubKey) // checked by checkSender
+
+	info, exists := app.state.GetShard(tx.ShardID)
+	switch tx.Act {
+	case ActShardCreate:
+		if exists {
+			return ErrShardExists
+		}
+		if tx.Genesis.ChainID != tx.ShardID || len(tx.Genesis.Validators) == 0 {
+			return ErrInvalidShard
+		}
+		if !AppExists(tx.App) {
+			return ErrUnknownApp
+		}
+		config, err := json.Marshal(tx.Config)
+		if err != nil {
+			return ErrInvalidShard
+		}
+		info = ShardInfo{
+			App:     tx.App,
+			Owner:   from,
+			Genesis: wire.JSONBytes(&tx.Genesis),
+			Config:  config,
+			// the other validators join with their own tx, a node only hosts what its key signed for
+			Members: []string{memberKey(pubKey)},
+		}
+	case ActShardJoin:
+		if !exists {
+			return ErrShardNotFound
+		}
+		if info.HasMember(pubKey) {
+			return ErrAlreadyMember
+		}
+		info.Members = append(info.Members, memberKey(pubKey))
+	case ActShardLeave:
+		if !exists {
+			return ErrShardNotFound
+		}
+		i := info.memberIndex(pubKey)
+		if i < 0 {
+			return ErrNotMember
+		}
+		info.Members = append(info.Members[:i:i], info.Members[i+1:]...)
+	case ActShardDelete:
+		if !exists {
+			return ErrShardNotFound
+		}
+		if info.Owner != from {
+			return ErrNotShardOwner
+		}
+	}
+	fee := ShardTxFee
+	if tx.Act == ActShardCreate {
+		fee = ShardCreateFee
+	}
+	if fromAcc.Balance < fee {
+		return ErrInsufficientFund
+	}
+	if !apply {
+		return nil
+	}
+
+	fromAcc.Balance -= fee
+	fromAcc.Nonce++
+	app.state.Set(from, fromAcc)
+	if tx.Act == ActShardDelete {
+		app.state.RemoveShard(tx.ShardID)
+	} else {
+		app.state.SetShard(tx.ShardID, info)
+	}
+	app.pendingShards = append(app.pendingShards, tx.ShardID)
 	return nil
 }
 
@@ -291,17 +672,84 @@ func (app *MyApp) Info() (resInfo types.ResultInfo) {
 	return
 }
 
-func (app *MyApp) Query(query []byte) types.Result {
-	if len(query) == 0 {
-		return types.NewResultOK([]byte{}, "Empty query")
+// Balance returns the balance of an address, 0 for addresses never used
+func (app *MyApp) Balance(address string) uint64 {
+	acc, _ := app.state.Get(address)
+	return acc.Balance
+}
+
+// Query paths, Data is the address, the shard chain id or the tx hash.
+// Values are the go-wire encoding of Account and ShardInfo, as proven by the IAVL proof,
+// and of TxLocation which is only indexed locally and comes without proof.
+// The proof of a key missing from the tree is an IAVL absence proof.
+const (
+	QueryPathAccount = "/account"
+	QueryPathShard   = "/shard"
+	QueryPathTx      = "/tx"
+)
+
+// Query reads the last committed state by default, the trees of the rollback_heights
+// heights before it can be queried too, the older ones are pruned
+func (app *MyApp) Query(req types.RequestQuery) types.ResponseQuery {
+	return app.queryState(req, app.LoadLastBlock().Height)
+}
+
+func (app *MyApp) queryState(req types.RequestQuery, height uint64) types.ResponseQuery {
+	if req.Height > height {
+		return types.NewQueryError(types.CodeType_BaseInvalidInput, fmt.Sprintf("the last committed height is %d", height))
 	}
-	var res types.Result
-	action := query[0]
-	// _ = query[1:]
-	switch action {
+
+	var key []byte
+	switch req.Path {
+	case QueryPathTx:
+		return app.queryTx(req, height)
+	case QueryPathAccount:
+		key = req.Data
+	case QueryPathShard:
+		key = []byte(shardKeyPrefix + string(req.Data))
 	default:
-		res = types.NewError(types.CodeType_BaseInvalidInput, "unimplemented query")
+		return types.NewQueryError(types.CodeType_UnknownRequest, fmt.Sprintf("unknown query path %s", req.Path))
+	}
+	if len(req.Data) == 0 {
+		return types.NewQueryError(types.CodeType_BaseInvalidInput, "empty query data")
 	}
 
+	res := types.ResponseQuery{Key: req.Data, Height: height}
+	var value, proof []byte
+	var exists bool
+	if req.Height == 0 || req.Height == height {
+		value, proof, exists = app.state.GetCommitted(key, req.Prove)
+	} else {
+		root, ok := app.loadRoot(req.Height)
+		kept := false
+		if ok {
+			value, proof, exists, kept = app.state.GetSaved(root, key, req.Prove)
+		}
+		if !kept {
+			return types.NewQueryError(types.CodeType_BaseInvalidInput, fmt.Sprintf("height %d was pruned, only the last rollback_heights heights can be queried", req.Height))
+		}
+		res.Height = req.Height
+	}
+	switch {
+	case exists:
+		res.Value, res.Proof = value, proof
+	case req.Path == QueryPathAccount:
+		// the empty account isn't in the tree, the proof shows it is missing
+		res.Value, res.Proof = wire.BinaryBytes(Account{}), proof
+		res.Log = "account never used"
+	default:
+		res.Code, res.Log = types.CodeType_BaseInvalidInput, ErrShardNotFound.Error()
+	}
 	return res
 }
+
+func (app *MyApp) queryTx(req types.RequestQuery, height uint64) types.ResponseQuery {
+	if req.Prove {
+		return types.NewQueryError(types.CodeType_BaseInvalidInput, "tx locations have no proof")
+	}
+	loc, ok := app.LoadTxLocation(req.Data)
+	if !ok {
+		return types.NewQueryError(types.CodeType_BaseInvalidInput, "tx not found")
+	}
+	return types.ResponseQuery{Key: req.Data, Value: wire.BinaryBytes(loc), Height: height}
+}
@@ -0,0 +1,570 @@
+// Copyright 2017 Delos Development Foundation and contributors. Licensed
+// under the Apache License, Version 2.0. See the COPYING file at the root
+// of this distribution or at http://www.apache.org/licenses/LICENSE-2.0
+package node
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path"
+	"strings"
+	"testing"
+	"time"
+
+	"go.uber.org/zap"
+
+	ac "github.com/DelosIsland/core/dngine/config"
+	"github.com/DelosIsland/core/dngine/types"
+	"github.com/DelosIsland/core/module/lib/go-config"
+	"github.com/DelosIsland/core/module/lib/go-crypto"
+	"github.com/DelosIsland/core/module/lib/go-db"
+	"github.com/DelosIsland/core/module/lib/go-merkle"
+	rpctypes "github.com/DelosIsland/core/module/lib/go-rpc/types"
+	"github.com/DelosIsland/core/module/lib/go-wire"
+)
+
+func newTestApp() *MyApp {
+	return &MyApp{
+		config: config.NewMapConfig(map[string]interface{}{"chain_id": "test-chain"}),
+		logger: zap.NewNop(),
+		state:  NewAccountState(db.NewMemDB()),
+	}
+}
+
+// testBalance is what fundTestAccount credits
+const testBalance = 1000000
+
+// testDest receives the test transfers
+const testDest = "0x00112233445566778899aabbccddeeff00112233"
+
+// fundTestAccount credits the account of privKey, returns its address
+func fundTestAccount(app *MyApp, privKey crypto.PrivKey) string {
+	address := AddressFromPubKey(privKey.PubKey())
+	app.state.Set(address, Account{Balance: testBalance})
+	return address
+}
+
+func newTestTransfer(privKey crypto.PrivKey, amount, nonce uint64) *MyTx {
+	tx := &MyTx{
+		ChainID:     "test-chain",
+		Time:        time.Unix(1500000000, 0),
+		DestAddress: testDest,
+		Amount:      amount,
+		Nonce:       nonce,
+	}
+	tx.SignByPrivKey(privKey)
+	return tx
+}
+
+func TestMyTxSignature(t *testing.T) {
+	privKey := crypto.GenPrivKeyEd25519()
+	tx := newTestTransfer(privKey, 10, 0)
+	sender, err := tx.Sender()
+	if err != nil {
+		t.Fatalf("Expected valid signature, got %v", err)
+	}
+	if sender != AddressFromPubKey(privKey.PubKey()) {
+		t.Errorf("Expected sender %v, got %v", AddressFromPubKey(privKey.PubKey()), sender)
+	}
+
+	tx.Amount = 1000
+	if _, err := tx.Sender(); err != ErrInvalidSignature {
+		t.Errorf("Expected tampered tx to be refused, got %v", err)
+	}
+
+	secpKey := crypto.GenPrivKeySecp256k1()
+	if _, err := newTestTransfer(secpKey, 10, 0).Sender(); err != nil {
+		t.Errorf("Expected secp256k1 signature to be valid, got %v", err)
+	}
+}
+
+func testTxJSON(tx *MyTx) []byte {
+	buf, _ := json.Marshal(tx)
+	return buf
+}
+
+func TestCheckTxCodes(t *testing.T) {
+	app := newTestApp()
+	privKey := crypto.GenPrivKeyEd25519()
+	fundTestAccount(app, privKey)
+
+	cases := []struct {
+		tx   []byte
+		code types.CodeType
+	}{
+		{[]byte("untagged"), types.CodeType_UnknownRequest},
+		{TagMyTx([]byte("{")), types.CodeType_EncodingError},
+		{TagMyTx(testTxJSON(newTestTransfer(privKey, testBalance+1, 0))), types.CodeType_InsufficientFunds},
+		{TagMyTx(testTxJSON(newTestTransfer(crypto.GenPrivKeyEd25519(), 10, 0))), types.CodeType_InsufficientFunds},
+		{TagMyTx(testTxJSON(newTestTransfer(privKey, 10, 0))), types.CodeType_OK},
+	}
+	for i, c := range cases {
+		err := app.CheckTx(c.tx)
+		if c.code == types.CodeType_OK {
+			if err != nil {
+				t.Errorf("%d: expected tx to pass, got %v", i, err)
+			}
+			continue
+		}
+		if res, ok := err.(types.Result); !ok || res.Code != c.code {
+			t.Errorf("%d: expected code %v, got %v", i, c.code, err)
+		}
+		// what a client reads from the rpc error
+		var rpcErr *rpctypes.RPCError
+		buf, _ := json.Marshal(types.NewRPCAppError(err))
+		json.Unmarshal(buf, &rpcErr)
+		if data, ok := types.AppErrorData(rpcErr); !ok || data.Code != c.code {
+			t.Errorf("%d: expected rpc error data with code %v, got %+v", i, c.code, data)
+		}
+	}
+}
+
+func TestOnExecuteResult(t *testing.T) {
+	app := newTestApp()
+	privKey := crypto.GenPrivKeyEd25519()
+	fundTestAccount(app, privKey)
+
+	block := &types.Block{Data: &types.Data{Txs: types.Txs{
+		TagMyTx(testTxJSON(newTestTransfer(privKey, 10, 0))),
+		TagMyTx(testTxJSON(newTestTransfer(privKey, 10, 0))),
+	}}}
+	out, err := app.OnExecute(1, 0, block)
+	if err != nil {
+		t.Fatal(err)
+	}
+	res := out.(types.ExecuteResult)
+	if len(res.ValidTxs) != 1 || len(res.ValidTxsData) != 1 || len(res.InvalidTxs) != 1 {
+		t.Fatalf("Expected one valid and one invalid tx, got %+v", res)
+	}
+	var acc Account
+	if err := json.Unmarshal(res.ValidTxsData[0], &acc); err != nil || acc.Nonce != 1 || acc.Balance != testBalance-10 {
+		t.Errorf("Expected the sender account as result data, got %s", res.ValidTxsData[0])
+	}
+	if invalid, ok := res.InvalidTxs[0].Error.(types.Result); !ok || invalid.Code != types.CodeType_BadNonce {
+		t.Errorf("Expected the replayed tx to fail with BadNonce, got %v", res.InvalidTxs[0].Error)
+	}
+}
+
+func TestApplyTransfer(t *testing.T) {
+	app := newTestApp()
+	privKey := crypto.GenPrivKeyEd25519()
+	if err := app.applyTransfer(newTestTransfer(privKey, 10, 0), false); err != ErrInsufficientFund {
+		t.Errorf("Expected an account never funded to be empty, got %v", err)
+	}
+	sender := fundTestAccount(app, privKey)
+
+	if err := app.applyTransfer(newTestTransfer(privKey, 10, 1), false); err != nil {
+		t.Errorf("Expected CheckTx to accept a queued nonce, got %v", err)
+	}
+	if err := app.applyTransfer(newTestTransfer(privKey, 10, 1), true); err == nil {
+		t.Errorf("Expected a block to refuse a nonce gap")
+	}
+	if err := app.applyTransfer(newTestTransfer(privKey, 10, 0), true); err != nil {
+		t.Fatalf("Expected transfer to succeed, got %v", err)
+	}
+	if err := app.applyTransfer(newTestTransfer(privKey, 10, 0), false); err == nil {
+		t.Errorf("Expected replayed tx to be refused")
+	}
+	if err := app.applyTransfer(newTestTransfer(privKey, testBalance, 1), false); err != ErrInsufficientFund {
+		t.Errorf("Expected overdraft to be refused, got %v", err)
+	}
+	if err := app.applyTransfer(newTestTransfer(privKey, 0, 1), false); err != ErrInvalidAmount {
+		t.Errorf("Expected empty transfer to be refused, got %v", err)
+	}
+
+	if acc, _ := app.state.Get(sender); acc.Balance != testBalance-10 || acc.Nonce != 1 {
+		t.Errorf("Unexpected sender account %+v", acc)
+	}
+	if app.Balance(testDest) != 10 {
+		t.Errorf("Expected dest balance 10, got %v", app.Balance(testDest))
+	}
+
+	// funds sent to anything but an address would be lost
+	for _, dest := range []string{"", "0xdest", shardKeyPrefix + "test-shard", "0x00112233445566778899AABBCCDDEEFF00112233", testDest + "00"} {
+		tx := newTestTransfer(privKey, 10, 1)
+		tx.DestAddress = dest
+		tx.SignByPrivKey(privKey)
+		if err := app.applyTransfer(tx, false); err != ErrInvalidAddress {
+			t.Errorf("Expected the destination %q to be refused, got %v", dest, err)
+		}
+	}
+}
+
+func newTestShardTx(privKey crypto.PrivKey, act byte, nonce uint64) *MyTx {
+	tx := &MyTx{
+		App:     MyAppName,
+		Act:     act,
+		ChainID: "test-chain",
+		ShardID: "test-shard",
+		Time:    time.Unix(1500000000, 0),
+		Nonce:   nonce,
+	}
+	if act == ActShardCreate {
+		tx.Genesis = types.GenesisDoc{
+			ChainID:    "test-shard",
+			Validators: []types.GenesisValidator{{PubKey: privKey.PubKey(), Amount: 10}},
+		}
+	}
+	tx.SignByPrivKey(privKey)
+	return tx
+}
+
+func TestShardIDs(t *testing.T) {
+	app := newTestApp()
+	app.config.(*config.MapConfig).Set("datadir", "/data")
+	owner := crypto.GenPrivKeyEd25519()
+
+	for _, id := range []string{"", "..", "../..", "a/b", "a.b", strings.Repeat("a", 65)} {
+		tx := newTestShardTx(owner, ActShardCreate, 0)
+		tx.ShardID, tx.Genesis.ChainID = id, id
+		tx.SignByPrivKey(owner)
+		for _, apply := range []bool{false, true} {
+			if err := app.applyTx(tx, apply); err != ErrInvalidShard {
+				t.Errorf("Expected shard id %q to be refused, got %v", id, err)
+			}
+		}
+		if _, err := app.shardDir(id); err == nil {
+			t.Errorf("Expected no directory for shard id %q", id)
+		}
+	}
+	if root, err := app.shardDir("test-shard_1"); err != nil || root != "/data/"+shardsDir+"/test-shard_1" {
+		t.Errorf("Unexpected shard directory %v (%v)", root, err)
+	}
+}
+
+func TestApplyShardTx(t *testing.T) {
+	Apps[MyAppName] = func(config.Config) types.Application { return nil }
+	defer delete(Apps, MyAppName)
+
+	app := newTestApp()
+	owner := crypto.GenPrivKeyEd25519()
+	other := crypto.GenPrivKeyEd25519()
+
+	if err := app.applyTx(newTestShardTx(owner, ActShardJoin, 0), true); err != ErrShardNotFound {
+		t.Errorf("Expected joining an unknown shard to fail, got %v", err)
+	}
+	if err := app.applyTx(newTestShardTx(owner, ActShardCreate, 0), false); err != ErrInsufficientFund {
+		t.Errorf("Expected a creator without the fee to be refused, got %v", err)
+	}
+	ownerAddress := fundTestAccount(app, owner)
+	otherAddress := fundTestAccount(app, other)
+
+	// the other genesis validator has to join by itself
+	create := newTestShardTx(owner, ActShardCreate, 0)
+	create.Genesis.Validators = append(create.Genesis.Validators, types.GenesisValidator{PubKey: other.PubKey(), Amount: 10})
+	create.SignByPrivKey(owner)
+	if err := app.applyTx(create, true); err != nil {
+		t.Fatalf("Expected shard creation to succeed, got %v", err)
+	}
+	if err := app.applyTx(newTestShardTx(other, ActShardCreate, 0), false); err != ErrShardExists {
+		t.Errorf("Expected duplicate shard to be refused, got %v", err)
+	}
+	if info, ok := app.state.GetShard("test-shard"); !ok || !info.HasMember(owner.PubKey()) || info.HasMember(other.PubKey()) {
+		t.Errorf("Expected the creator only to host the shard, got %+v", info)
+	}
+	if balance := app.Balance(ownerAddress); balance != testBalance-ShardCreateFee {
+		t.Errorf("Expected the creation fee to be charged, got a balance of %d", balance)
+	}
+
+	if err := app.applyTx(newTestShardTx(other, ActShardJoin, 0), true); err != nil {
+		t.Fatalf("Expected join to succeed, got %v", err)
+	}
+	if err := app.applyTx(newTestShardTx(other, ActShardDelete, 1), true); err != ErrNotShardOwner {
+		t.Errorf("Expected a member to be refused deleting, got %v", err)
+	}
+	if err := app.applyTx(newTestShardTx(other, ActShardLeave, 1), true); err != nil {
+		t.Fatalf("Expected leave to succeed, got %v", err)
+	}
+	if info, _ := app.state.GetShard("test-shard"); info.HasMember(other.PubKey()) {
+		t.Errorf("Expected the member to be gone, got %+v", info)
+	}
+	if balance := app.Balance(otherAddress); balance != testBalance-2*ShardTxFee {
+		t.Errorf("Expected the join and the leave to be charged, got a balance of %d", balance)
+	}
+	app.state.Set(otherAddress, Account{Nonce: 2})
+	if err := app.applyTx(newTestShardTx(other, ActShardJoin, 2), false); err != ErrInsufficientFund {
+		t.Errorf("Expected a join without the fee to be refused, got %v", err)
+	}
+
+	if err := app.applyTx(newTestShardTx(owner, ActShardDelete, 1), true); err != nil {
+		t.Fatalf("Expected delete to succeed, got %v", err)
+	}
+	if ids := app.state.ShardIDs(); len(ids) != 0 {
+		t.Errorf("Expected no shard left, got %v", ids)
+	}
+	if len(app.pendingShards) != 4 {
+		t.Errorf("Expected every applied shard tx to be pending, got %v", app.pendingShards)
+	}
+}
+
+func TestInitShardDirSettings(t *testing.T) {
+	dir, err := ioutil.TempDir("", "myapp_shard")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	app := newTestApp()
+	for k, v := range map[string]interface{}{
+		"node_laddr":         "tcp://127.0.0.1:46656",
+		"priv_validator_key": "validator",
+		"keystore_dir":       dir,
+		"moniker":            "host",
+		"environment":        "test",
+		"log_path":           dir,
+		"rpc_laddr":          "",
+	} {
+		app.config.(*config.MapConfig).Set(k, v)
+	}
+	settings, err := json.Marshal(map[string]interface{}{
+		"timeout_commit": 10,
+		"node_laddr":     "tcp://8.8.8.8:1",
+		"pprof":          true,
+		"db_backend":     "memdb",
+		"moniker":        "shard",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := app.initShardDir(dir, ShardInfo{Genesis: []byte("{}"), Config: settings}); err != nil {
+		t.Fatal(err)
+	}
+	conf, err := config.ReadMapConfigFromFile(path.Join(dir, ac.CONFIGFILE))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if conf.GetInt("timeout_commit") != 10 {
+		t.Errorf("Expected the shard to set its timeout_commit, got %v", conf.Get("timeout_commit"))
+	}
+	for _, k := range []string{"pprof", "db_backend"} {
+		if conf.IsSet(k) {
+			t.Errorf("Expected %s not to be set by the shard, got %v", k, conf.Get(k))
+		}
+	}
+	if laddr := conf.GetString("node_laddr"); !strings.HasPrefix(laddr, "tcp://127.0.0.1:") {
+		t.Errorf("Expected the host to pick the p2p address, got %s", laddr)
+	}
+	if conf.GetString("moniker") != "host" {
+		t.Errorf("Expected the moniker of the host, got %s", conf.GetString("moniker"))
+	}
+}
+
+func TestLegacyAppDb(t *testing.T) {
+	dir, err := ioutil.TempDir("", "myapp_legacy")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	chainDb, err := db.NewGoLevelDB("chaindata", dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	chainDb.SetSync(legacyLastBlockKey, []byte{1})
+	chainDb.Close()
+
+	crisis := func() (crisis interface{}) {
+		defer func() { crisis = recover() }()
+		NewMyApp(zap.NewNop(), config.NewMapConfig(map[string]interface{}{
+			"chain_id":         "test-chain",
+			"db_dir":           dir,
+			"genesis_file":     path.Join(dir, "genesis.json"),
+			"rollback_heights": 0,
+		}))
+		return nil
+	}()
+	if crisis == nil || !strings.Contains(fmt.Sprint(crisis), ErrLegacyAppDb.Error()) {
+		t.Errorf("Expected an app db hashing the txs to be refused, got %v", crisis)
+	}
+}
+
+func TestQueueShardSyncs(t *testing.T) {
+	dir, err := ioutil.TempDir("", "myapp_queue")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	app := NewMyApp(zap.NewNop(), config.NewMapConfig(map[string]interface{}{
+		"chain_id":         "test-chain",
+		"db_dir":           dir,
+		"genesis_file":     path.Join(dir, "genesis.json"),
+		"rollback_heights": 0,
+	}))
+
+	// nothing serves the queue yet, the commit must not wait for it
+	done := make(chan struct{})
+	go func() {
+		for i := 0; i < 10; i++ {
+			app.queueShardSyncs("test-shard")
+		}
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("Expected queueing shard syncs not to block")
+	}
+
+	stopped := make(chan struct{})
+	go func() {
+		app.Stop()
+		app.Stop()
+		close(stopped)
+	}()
+	select {
+	case <-stopped:
+	case <-time.After(time.Second):
+		t.Fatal("Expected an app never started to stop")
+	}
+}
+
+func TestQueryAccount(t *testing.T) {
+	app := newTestApp()
+	privKey := crypto.GenPrivKeyEd25519()
+	sender := fundTestAccount(app, privKey)
+	if err := app.applyTransfer(newTestTransfer(privKey, 10, 0), true); err != nil {
+		t.Fatalf("Expected transfer to succeed, got %v", err)
+	}
+
+	req := types.RequestQuery{Path: QueryPathAccount, Data: []byte(sender), Prove: true}
+	var acc Account
+	if res := app.queryState(req, 0); res.IsErr() || len(res.Proof) != 0 {
+		t.Errorf("Expected the uncommitted account to have no proof, got %+v", res)
+	}
+
+	root := app.state.Save()
+	res := app.queryState(req, 1)
+	if res.IsErr() || res.Height != 1 {
+		t.Fatalf("Expected account at height 1, got %+v", res)
+	}
+	if err := wire.ReadBinaryBytes(res.Value, &acc); err != nil || acc.Balance != testBalance-10 || acc.Nonce != 1 {
+		t.Errorf("Unexpected account %+v (%v)", acc, err)
+	}
+	proof, err := merkle.LoadProof(res.Proof)
+	if err != nil || !proof.Valid() || !bytes.Equal(proof.Root(), root) || !bytes.Equal(proof.Value(), res.Value) {
+		t.Errorf("Expected a valid proof against the saved root, got %v", err)
+	}
+
+	if res := app.queryState(types.RequestQuery{Path: QueryPathAccount, Data: []byte(sender), Height: 2}, 1); res.IsOK() {
+		t.Errorf("Expected a pruned height to be refused")
+	}
+	if res := app.queryState(types.RequestQuery{Path: "/unknown", Data: []byte(sender)}, 1); res.IsOK() {
+		t.Errorf("Expected an unknown path to be refused")
+	}
+}
+
+func TestQueryHeights(t *testing.T) {
+	dir, err := ioutil.TempDir("", "myapp_query")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	app := newTestApp()
+	app.config.(*config.MapConfig).Set("rollback_heights", 2)
+	if app.chainDb, err = db.NewGoLevelDB("chaindata", dir); err != nil {
+		t.Fatal(err)
+	}
+	defer app.chainDb.Close()
+	app.state = NewAccountState(app.chainDb)
+	app.state.KeepVersions(2)
+
+	roots := map[uint64][]byte{}
+	for h := uint64(1); h <= 5; h++ {
+		app.state.Set("alice", Account{Balance: 100, Nonce: h})
+		res, err := app.OnCommit(int(h), 0, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		roots[h] = res.(types.CommitResult).AppHash
+	}
+
+	// the trees of the 2 heights before the last one are kept
+	for h := uint64(3); h <= 5; h++ {
+		res := app.Query(types.RequestQuery{Path: QueryPathAccount, Data: []byte("alice"), Height: h, Prove: true})
+		var acc Account
+		if res.IsErr() || res.Height != h {
+			t.Fatalf("Expected alice at height %d, got %+v", h, res)
+		}
+		if err := wire.ReadBinaryBytes(res.Value, &acc); err != nil || acc.Nonce != h {
+			t.Errorf("Expected alice at nonce %d, got %+v (%v)", h, acc, err)
+		}
+		proof, err := merkle.LoadProof(res.Proof)
+		if err != nil || !proof.Valid() || !bytes.Equal(proof.Root(), roots[h]) {
+			t.Errorf("Expected a valid proof against the root of height %d, got %v", h, err)
+		}
+	}
+	for _, h := range []uint64{2, 6} {
+		if res := app.Query(types.RequestQuery{Path: QueryPathAccount, Data: []byte("alice"), Height: h}); res.IsOK() {
+			t.Errorf("Expected height %d to be refused", h)
+		}
+	}
+
+	// a missing account comes with the proof of its absence
+	res := app.Query(types.RequestQuery{Path: QueryPathAccount, Data: []byte("bob"), Height: 4, Prove: true})
+	if res.IsErr() || len(res.Proof) == 0 {
+		t.Fatalf("Expected the absence of bob to be proven, got %+v", res)
+	}
+	absence, err := merkle.LoadAbsenceProof(res.Proof)
+	if err != nil || !absence.Valid() || !bytes.Equal(absence.Key(), []byte("bob")) || !bytes.Equal(absence.Root(), roots[4]) {
+		t.Errorf("Expected a valid absence proof against the root of height 4, got %v", err)
+	}
+
+	// the heights rolled back can't be queried anymore
+	if err := app.Rollback(3, roots[3]); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := app.loadRoot(4); ok {
+		t.Error("Expected the roots above the rollback height to be forgotten")
+	}
+	if res := app.Query(types.RequestQuery{Path: QueryPathAccount, Data: []byte("alice"), Height: 4}); res.IsOK() {
+		t.Error("Expected a height above the rollback to be refused")
+	}
+}
+
+func TestLoadGenesis(t *testing.T) {
+	dir, err := ioutil.TempDir("", "myapp_genesis")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	// above 2^53, where a float64 rounds
+	appState := json.RawMessage(`{"accounts":{"whale":{"balance":9007199254740993}}}`)
+	appHash, err := GenesisAppHash(appState)
+	if err != nil {
+		t.Fatal(err)
+	}
+	genDoc := &types.GenesisDoc{ChainID: "test-chain", AppState: appState, AppHash: appHash}
+	genFile := path.Join(dir, "genesis.json")
+	loadGenesis := func() (app *MyApp, crisis interface{}) {
+		defer func() { crisis = recover() }()
+		if err := genDoc.SaveAs(genFile); err != nil {
+			t.Fatal(err)
+		}
+		app = newTestApp()
+		app.config.(*config.MapConfig).Set("genesis_file", genFile)
+		if app.chainDb, err = db.NewGoLevelDB("chaindata", dir); err != nil {
+			t.Fatal(err)
+		}
+		defer app.chainDb.Close()
+		app.state = NewAccountState(app.chainDb)
+		app.loadGenesis()
+		return app, nil
+	}
+
+	app, crisis := loadGenesis()
+	if crisis != nil {
+		t.Fatalf("Expected the genesis to load, got %v", crisis)
+	}
+	if balance := app.Balance("whale"); balance != 9007199254740993 {
+		t.Errorf("Expected the exact genesis balance, got %d", balance)
+	}
+
+	genDoc.AppHash = []byte("wrong")
+	if _, crisis := loadGenesis(); crisis == nil {
+		t.Error("Expected an app_hash not matching the app_state to stop the app")
+	}
+}
@@ -8,10 +8,11 @@ import (
 
 	"go.uber.org/zap"
 
+	"github.com/DelosIsland/core/client/sdk"
 	"github.com/DelosIsland/core/dngine"
 	"github.com/DelosIsland/core/dngine/types"
 	"github.com/DelosIsland/core/module/lib/go-config"
-	client "github.com/DelosIsland/core/module/lib/go-rpc/client"
+	"github.com/DelosIsland/core/module/lib/go-crypto"
 )
 
 type MyNode struct {
@@ -49,7 +50,9 @@ func NewMyNode(logger *zap.Logger, appName string, conf config.Config) *MyNode {
 
 func (s *MyNode) Start() error {
 	if atomic.CompareAndSwapInt64(&s.running, 0, 1) {
-		s.Application.Start()
+		if s.Application != nil {
+			s.Application.Start()
+		}
 		return s.Dngine.Start()
 	}
 	return fmt.Errorf("already started")
@@ -57,7 +60,9 @@ func (s *MyNode) Start() error {
 
 func (s *MyNode) Stop() bool {
 	if atomic.CompareAndSwapInt64(&s.running, 1, 0) {
-		s.Application.Stop()
+		if s.Application != nil {
+			s.Application.Stop()
+		}
 		return s.Dngine.Stop()
 	}
 	return false
@@ -68,15 +73,9 @@ func (s *MyNode) IsRunning() bool {
 }
 
 func (s *MyNode) GetSpecialVote(data []byte, validator *types.Validator) ([]byte, error) {
-	clientJSON := client.NewClientJSONRPC(s.logger, validator.RPCAddress) // all shard nodes share the same rpc address of the Node
-	tmResult := new(types.RPCResult)
-	_, err := clientJSON.Call("vote_special_op", []interface{}{s.GenesisDoc.ChainID, data}, tmResult)
-	if err != nil {
-		return nil, err
+	c := sdk.NewClient(s.logger, validator.RPCAddress, s.GenesisDoc.ChainID) // all shard nodes share the same rpc address of the Node
+	if privKey, ok := s.Dngine.PrivValidator().GetPrivateKey().(crypto.PrivKeyEd25519); ok {
+		c.SetSigner(privKey)
 	}
-	res := (*tmResult).(*types.ResultRequestSpecialOP)
-	if res.Code == types.CodeType_OK {
-		return res.Data, nil
-	}
-	return nil, fmt.Errorf(res.Log)
+	return c.VoteSpecialOP(data)
 }
@@ -4,12 +4,15 @@
 package node
 
 import (
+	"crypto/tls"
+	"encoding/hex"
 	"fmt"
 	"net"
 	"net/http"
 	_ "net/http/pprof"
 	"strconv"
 	"strings"
+	"sync"
 	"time"
 
 	"go.uber.org/zap"
@@ -32,6 +35,8 @@ const (
 
 var Apps = make(map[string]types.AppMaker)
 
+// MyAppName is the name shards use to run MyApp itself
+const MyAppName = "myapp"
 
 type Node struct {
 	MainChainID string
@@ -41,6 +46,9 @@ type Node struct {
 	privValidator *types.PrivValidator
 	nodeInfo      *p2p.NodeInfo
 
+	subsMtx sync.Mutex
+	subs    map[string]int // websocket subscriptions per remote address
+
 	logger *zap.Logger
 }
 
@@ -51,6 +59,11 @@ func AppExists(name string) (yes bool) {
 
 func NewNode(logger *zap.Logger, config cfg.Config) *Node {
 	conf := config.(*cfg.MapConfig)
+	if !AppExists(MyAppName) {
+		Apps[MyAppName] = func(c cfg.Config) types.Application {
+			return NewMyApp(logger, c)
+		}
+	}
 	myApp := NewMyApp(logger, conf)
 	tune := &dngine.DngineTunes{Conf: conf}
 	mainDngine := dngine.NewDngine(tune)
@@ -77,8 +90,40 @@ func NewNode(logger *zap.Logger, config cfg.Config) *Node {
 	return node
 }
 
+// NewSeedNode makes a node which only crawls the p2p network and serves addresses,
+// there is no application behind it.
+func NewSeedNode(logger *zap.Logger, config cfg.Config) *Node {
+	conf := config.(*cfg.MapConfig)
+	tune := &dngine.DngineTunes{Conf: conf}
+	seedDngine := dngine.NewDngine(tune)
+
+	node := &Node{
+		MainChainID: seedDngine.Genesis().ChainID,
+		MainShard: &MyNode{
+			logger:     logger,
+			Dngine:     seedDngine,
+			DngineTune: tune,
+			GenesisDoc: seedDngine.Genesis(),
+		},
+
+		nodeInfo:      makeNodeInfo(conf, seedDngine.PrivValidator().PubKey.(crypto.PubKeyEd25519), seedDngine.P2PHost(), seedDngine.P2PPort()),
+		config:        conf,
+		privValidator: seedDngine.PrivValidator(),
+		logger:        logger,
+	}
+
+	seedDngine.RegisterNodeInfo(node.nodeInfo)
+
+	return node
+}
+
 func RunNode(logger *zap.Logger, config cfg.Config) {
-	node := NewNode(logger, config)
+	var node *Node
+	if config.GetBool("seed_mode") {
+		node = NewSeedNode(logger, config)
+	} else {
+		node = NewNode(logger, config)
+	}
 	if err := node.Start(); err != nil {
 		cmn.Exit(cmn.Fmt("Failed to start node: %v", err))
 	}
@@ -87,6 +132,16 @@ func RunNode(logger *zap.Logger, config cfg.Config) {
 			cmn.PanicCrisis(err)
 		}
 	}
+	if config.GetString("api_laddr") != "" && !node.IsSeed() {
+		if _, err := node.StartAPI(); err != nil {
+			cmn.PanicCrisis(err)
+		}
+	}
+	if config.GetString("metrics_laddr") != "" {
+		if _, err := node.StartMetrics(); err != nil {
+			cmn.PanicCrisis(err)
+		}
+	}
 	if config.GetBool("pprof") {
 		go func() {
 			http.ListenAndServe(":6060", nil)
@@ -107,14 +162,18 @@ func (n *Node) Start() error {
 	}
 
 	// restore will take charge of restarting all shards
-	n.MainShard.Application.(*MyApp).Start()
+	if app, ok := n.MainShard.Application.(*MyApp); ok {
+		app.Start()
+	}
 
 	return nil
 }
 
 func (n *Node) Stop() {
 	n.logger.Info("Stopping Node")
-	n.MainShard.Application.(*MyApp).Stop()
+	if app, ok := n.MainShard.Application.(*MyApp); ok {
+		app.Stop()
+	}
 	n.MainShard.Stop()
 }
 
@@ -151,13 +210,29 @@ func (n *Node) NodeInfo() *p2p.NodeInfo {
 func (n *Node) StartRPC() ([]net.Listener, error) {
 	listenAddrs := strings.Split(n.config.GetString("rpc_laddr"), ",")
 	listeners := make([]net.Listener, len(listenAddrs))
+	routes := n.rpcRoutes()
+	if n.IsSeed() {
+		routes = n.seedRPCRoutes()
+	}
+	ac, err := n.rpcAccessControl()
+	if err != nil {
+		return nil, err
+	}
+	tlsConfig, err := n.rpcTLSConfig()
+	if err != nil {
+		return nil, err
+	}
 
 	for i, listenAddr := range listenAddrs {
 		mux := http.NewServeMux()
-		// wm := rpcserver.NewWebsocketManager(rpcRoutes, n.evsw)
-		// mux.HandleFunc("/websocket", wm.WebsocketHandler)
-		rpcserver.RegisterRPCFuncs(n.logger, mux, n.rpcRoutes())
-		listener, err := rpcserver.StartHTTPServer(n.logger, listenAddr, mux)
+		if !n.IsSeed() {
+			// unsubscribes the main chain listeners of closed connections
+			wm := rpcserver.NewWebsocketManager(n.logger, routes, n.MainShard.Dngine.EventSwitch())
+			mux.HandleFunc("/websocket", wm.WebsocketHandler)
+		}
+		n.registerHealthHandlers(mux)
+		rpcserver.RegisterRPCFuncs(n.logger, mux, routes)
+		listener, err := rpcserver.StartHTTPSServer(n.logger, listenAddr, ac.Handler(mux), tlsConfig)
 		if err != nil {
 			return nil, err
 		}
@@ -167,6 +242,73 @@ func (n *Node) StartRPC() ([]net.Listener, error) {
 	return listeners, nil
 }
 
+// StartAPI serves the REST gateway on api_laddr,
+// with the access lists and the TLS settings of the rpc.
+func (n *Node) StartAPI() ([]net.Listener, error) {
+	listenAddrs := strings.Split(n.config.GetString("api_laddr"), ",")
+	listeners := make([]net.Listener, len(listenAddrs))
+	ac, err := n.rpcAccessControl()
+	if err != nil {
+		return nil, err
+	}
+	tlsConfig, err := n.rpcTLSConfig()
+	if err != nil {
+		return nil, err
+	}
+
+	handler := ac.Handler(n.apiHandler(n.apiRoutes(), int64(n.config.GetInt("api_max_body_bytes"))))
+	for i, listenAddr := range listenAddrs {
+		listener, err := rpcserver.StartHTTPSServer(n.logger, listenAddr, handler, tlsConfig)
+		if err != nil {
+			return nil, err
+		}
+		listeners[i] = listener
+	}
+	return listeners, nil
+}
+
+func (n *Node) rpcTLSConfig() (*tls.Config, error) {
+	certFile := n.config.GetString("rpc_tls_cert_file")
+	if certFile == "" {
+		return nil, nil
+	}
+	return rpcserver.NewServerTLSConfig(certFile, n.config.GetString("rpc_tls_key_file"), n.config.GetString("rpc_tls_client_ca_file"))
+}
+
+// rpcAccessControl builds the rpc access lists from the config.
+// Validators can always authenticate by signing with their node key,
+// so that special op votes keep working once the group is restricted.
+func (n *Node) rpcAccessControl() (*rpcserver.AccessControl, error) {
+	ac := rpcserver.NewAccessControl(rpcMethodGroups,
+		strings.Split(n.config.GetString("rpc_public_groups"), ","),
+		strings.Split(n.config.GetString("rpc_auth_groups"), ","))
+	ac.SetMaxBodyBytes(int64(n.config.GetInt("rpc_max_body_bytes")))
+	ac.AddTokens(strings.Split(n.config.GetString("rpc_auth_tokens"), ",")...)
+	for _, hexKey := range strings.Split(n.config.GetString("rpc_auth_pubkeys"), ",") {
+		if hexKey = strings.TrimSpace(hexKey); hexKey == "" {
+			continue
+		}
+		keyBytes, err := hex.DecodeString(hexKey)
+		if err != nil || len(keyBytes) != 32 {
+			return nil, fmt.Errorf("invalid rpc_auth_pubkeys entry %s", hexKey)
+		}
+		var pubKey crypto.PubKeyEd25519
+		copy(pubKey[:], keyBytes)
+		ac.AddPubKeys(pubKey)
+	}
+	if !n.IsSeed() {
+		ac.SetPubKeyFilter(func(pubKey crypto.PubKeyEd25519) bool {
+			return n.MainShard.Dngine.IsNodeValidator(pubKey)
+		})
+	}
+	return ac, nil
+}
+
+// IsSeed tells whether the node only crawls the network
+func (n *Node) IsSeed() bool {
+	return n.MainShard.Dngine.IsSeed()
+}
+
 func (n *Node) PrivValidator() *types.PrivValidator {
 	return n.privValidator
 }
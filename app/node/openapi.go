@@ -0,0 +1,188 @@
+// Copyright 2017 Delos Development Foundation and contributors. Licensed
+// under the Apache License, Version 2.0. See the COPYING file at the root
+// of this distribution or at http://www.apache.org/licenses/LICENSE-2.0
+package node
+
+import (
+	"net/http"
+	"path"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/DelosIsland/core/app/version"
+)
+
+// openAPIDocument describes the routes in OpenAPI 3.0,
+// the schemas are derived from the go-wire JSON encoding of the Go types.
+func openAPIDocument(routes []apiRoute) map[string]interface{} {
+	schemas := newSchemaSet()
+	paths := make(map[string]interface{})
+	for _, route := range routes {
+		var params []interface{}
+		for _, seg := range strings.Split(route.Path, "/") {
+			if strings.HasPrefix(seg, "{") && strings.HasSuffix(seg, "}") {
+				p := apiParam{Name: seg[1 : len(seg)-1], Type: "string"}
+				if p.Name == ChainIDArg {
+					p = chainIDParam
+				}
+				params = append(params, openAPIParam(p, "path", true))
+			}
+		}
+		for _, p := range route.Query {
+			params = append(params, openAPIParam(p, "query", false))
+		}
+
+		status := route.Status
+		if status == 0 {
+			status = http.StatusOK
+		}
+		responses := map[string]interface{}{
+			strconv.Itoa(status): statusResponse(status, schemas.of(reflect.TypeOf(route.Result))),
+			"default": map[string]interface{}{
+				"description": "error, its code is the rpc error code",
+				"content":     jsonContent(schemas.of(reflect.TypeOf(apiError{}))),
+			},
+		}
+		for other, result := range route.OtherResults {
+			responses[strconv.Itoa(other)] = statusResponse(other, schemas.of(reflect.TypeOf(result)))
+		}
+
+		op := map[string]interface{}{
+			"summary":     route.Summary,
+			"operationId": route.RPC,
+			"responses":   responses,
+		}
+		if len(params) > 0 {
+			op["parameters"] = params
+		}
+		if route.Body != nil {
+			op["requestBody"] = map[string]interface{}{
+				"required": true,
+				"content":  jsonContent(schemas.of(reflect.TypeOf(route.Body))),
+			}
+		}
+
+		item, _ := paths[route.Path].(map[string]interface{})
+		if item == nil {
+			item = make(map[string]interface{})
+			paths[route.Path] = item
+		}
+		item[strings.ToLower(route.Method)] = op
+	}
+
+	return map[string]interface{}{
+		"openapi": "3.0.0",
+		"info": map[string]interface{}{
+			"title":   "node REST API",
+			"version": version.GetVersion(),
+		},
+		"paths":      paths,
+		"components": map[string]interface{}{"schemas": schemas.defs},
+	}
+}
+
+func openAPIParam(p apiParam, in string, required bool) map[string]interface{} {
+	param := map[string]interface{}{
+		"name":     p.Name,
+		"in":       in,
+		"required": required,
+		"schema":   map[string]interface{}{"type": p.Type},
+	}
+	if p.Description != "" {
+		param["description"] = p.Description
+	}
+	return param
+}
+
+func jsonContent(schema interface{}) map[string]interface{} {
+	return map[string]interface{}{"application/json": map[string]interface{}{"schema": schema}}
+}
+
+func statusResponse(status int, schema interface{}) map[string]interface{} {
+	return map[string]interface{}{"description": http.StatusText(status), "content": jsonContent(schema)}
+}
+
+var timeType = reflect.TypeOf(time.Time{})
+
+// schemaSet names the struct schemas after their type,
+// the package is prepended when two types have the same name.
+type schemaSet struct {
+	defs  map[string]interface{}
+	names map[reflect.Type]string
+}
+
+func newSchemaSet() *schemaSet {
+	return &schemaSet{defs: make(map[string]interface{}), names: make(map[reflect.Type]string)}
+}
+
+func (s *schemaSet) of(t reflect.Type) interface{} {
+	switch {
+	case t.Kind() == reflect.Ptr:
+		return s.of(t.Elem())
+	case t == timeType:
+		return map[string]interface{}{"type": "string", "format": "date-time"}
+	case (t.Kind() == reflect.Slice || t.Kind() == reflect.Array) && t.Elem().Kind() == reflect.Uint8:
+		return map[string]interface{}{"type": "string", "format": "hex"}
+	}
+
+	switch t.Kind() {
+	case reflect.Bool:
+		return map[string]interface{}{"type": "boolean"}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32:
+		return map[string]interface{}{"type": "integer"}
+	case reflect.Int64, reflect.Uint64:
+		return map[string]interface{}{"type": "integer", "format": "int64"}
+	case reflect.Float32, reflect.Float64:
+		return map[string]interface{}{"type": "number"}
+	case reflect.String:
+		return map[string]interface{}{"type": "string"}
+	case reflect.Slice, reflect.Array:
+		return map[string]interface{}{"type": "array", "items": s.of(t.Elem())}
+	case reflect.Map:
+		return map[string]interface{}{"type": "object", "additionalProperties": s.of(t.Elem())}
+	case reflect.Struct:
+		return s.ref(t)
+	}
+	// interfaces are written by go-wire as [type byte, value]
+	return map[string]interface{}{}
+}
+
+func (s *schemaSet) ref(t reflect.Type) interface{} {
+	name, ok := s.names[t]
+	if !ok {
+		name = t.Name()
+		if name == "" {
+			return s.structSchema(t)
+		}
+		if _, taken := s.defs[name]; taken {
+			name = path.Base(t.PkgPath()) + "." + name
+		}
+		// named before the fields so that recursive types end
+		s.names[t] = name
+		s.defs[name] = s.structSchema(t)
+	}
+	return map[string]interface{}{"$ref": "#/components/schemas/" + name}
+}
+
+// structSchema follows go-wire: exported fields named by their json tag, embedded ones aren't flattened
+func (s *schemaSet) structSchema(t reflect.Type) interface{} {
+	props := make(map[string]interface{})
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		if f.PkgPath != "" {
+			continue
+		}
+		tag := f.Tag.Get("json")
+		if tag == "-" {
+			continue
+		}
+		name := strings.Split(tag, ",")[0]
+		if name == "" {
+			name = f.Name
+		}
+		props[name] = s.of(f.Type)
+	}
+	return map[string]interface{}{"type": "object", "properties": props}
+}
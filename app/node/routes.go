@@ -9,12 +9,16 @@ import (
 	"strings"
 	"time"
 
+	"github.com/DelosIsland/core/dngine"
 	types "github.com/DelosIsland/core/dngine/types"
 	"github.com/DelosIsland/core/module/lib/go-crypto"
+	"github.com/DelosIsland/core/module/lib/go-p2p"
+	"github.com/DelosIsland/core/module/lib/go-pubsub"
+	"github.com/DelosIsland/core/module/lib/go-pubsub/query"
 	rpc "github.com/DelosIsland/core/module/lib/go-rpc/server"
+	rpctypes "github.com/DelosIsland/core/module/lib/go-rpc/types"
 	"github.com/DelosIsland/core/module/lib/go-wire"
 	"github.com/DelosIsland/core/app/version"
-	"math/big"
 )
 
 const ChainIDArg = "chainid"
@@ -25,17 +29,19 @@ type RPCNode interface {
 	Height() int
 	GetBlock(height int) (*types.Block, *types.BlockMeta)
 	BroadcastTx(tx []byte) error
-	BroadcastTxCommit(tx []byte) error
+	BroadcastTxCommit(tx []byte) (types.EventDataTx, error)
 	FlushMempool()
 	GetValidators() (int, []*types.Validator)
 	GetP2PNetInfo() (bool, []string, []*types.Peer)
+	GetBannedPeers() []p2p.PeerScore
+	UnbanPeer(peerKey string) (p2p.PeerScore, bool)
+	GetChannelMetrics() []p2p.ChannelMetrics
 	GetNumPeers() int
 	GetConsensusStateInfo() (string, []string)
 	GetNumUnconfirmedTxs() int
 	GetUnconfirmedTxs() []types.Tx
 	IsNodeValidator(pub crypto.PubKey) bool
 	GetBlacklist() []string
-	GetAccountAmount(address string) *big.Int
 }
 
 type rpcHandler struct {
@@ -43,7 +49,8 @@ type rpcHandler struct {
 }
 
 var (
-	ErrInvalidChainID = fmt.Errorf("no such chain id")
+	ErrInvalidChainID       = rpctypes.NewRPCError(rpctypes.CodeInvalidParams, "no such chain id")
+	ErrTooManySubscriptions = rpctypes.NewRPCError(rpctypes.CodeInvalidRequest, "too many subscriptions on the connection")
 )
 
 func newRPCHandler(n *Node) *rpcHandler {
@@ -54,13 +61,14 @@ func (n *Node) rpcRoutes() map[string]*rpc.RPCFunc {
 	h := newRPCHandler(n)
 	return map[string]*rpc.RPCFunc{
 		// subscribe/unsubscribe are reserved for websocket events.
-		// "subscribe":   rpc.NewWSRPCFunc(SubscribeResult, argsWithChainID("event")),
-		// "unsubscribe": rpc.NewWSRPCFunc(UnsubscribeResult, argsWithChainID("event")),
+		"subscribe":   rpc.NewWSRPCFunc(h.Subscribe, argsWithChainID("event")),
+		"unsubscribe": rpc.NewWSRPCFunc(h.Unsubscribe, argsWithChainID("event")),
 
 		// info API
 		"shards":               rpc.NewRPCFunc(h.Shards, ""),
 		"status":               rpc.NewRPCFunc(h.Status, argsWithChainID("")),
 		"net_info":             rpc.NewRPCFunc(h.NetInfo, argsWithChainID("")),
+		"p2p_metrics":          rpc.NewRPCFunc(h.P2PMetrics, argsWithChainID("")),
 		"blockchain":           rpc.NewRPCFunc(h.BlockchainInfo, argsWithChainID("minHeight,maxHeight")),
 		"genesis":              rpc.NewRPCFunc(h.Genesis, argsWithChainID("")),
 		"block":                rpc.NewRPCFunc(h.Block, argsWithChainID("height")),
@@ -76,12 +84,14 @@ func (n *Node) rpcRoutes() map[string]*rpc.RPCFunc {
 		"broadcast_tx_sync":   rpc.NewRPCFunc(h.BroadcastTx, argsWithChainID("tx")),
 
 		// query API
-		"query": rpc.NewRPCFunc(h.Query, argsWithChainID("query")),
+		"query": rpc.NewRPCFunc(h.Query, argsWithChainID("path,data,height,prove")),
 		"info":  rpc.NewRPCFunc(h.Info, argsWithChainID("")),
 
 		// control API
-		// "dial_seeds":           rpc.NewRPCFunc(h.UnsafeDialSeeds, argsWithChainID("seeds")),
+		"dial_seeds":           rpc.NewRPCFunc(h.UnsafeDialSeeds, argsWithChainID("seeds")),
+		"unsafe_dial_peers":    rpc.NewRPCFunc(h.UnsafeDialPeers, argsWithChainID("peers,persistent")),
 		"unsafe_flush_mempool": rpc.NewRPCFunc(h.UnsafeFlushMempool, argsWithChainID("")),
+		"unsafe_unban_peer":    rpc.NewRPCFunc(h.UnsafeUnbanPeer, argsWithChainID("key")),
 		// "unsafe_set_config":    rpc.NewRPCFunc(h.UnsafeSetConfig, argsWithChainID("type,key,value")),
 
 		// profiler API
@@ -97,31 +107,86 @@ func (n *Node) rpcRoutes() map[string]*rpc.RPCFunc {
 		// refuse_list API
 		"blacklist": rpc.NewRPCFunc(h.Blacklist, argsWithChainID("")),
 
-		"get_account_amount": rpc.NewRPCFunc(h.GetAccountAmount, argsWithChainID("")),
 		// sharding API
 		// "shard_join": rpc.NewRPCFunc(h.ShardJoin, "gdata,cdata,sig"),
 	}
 }
 
+// rpcMethodGroups sorts the routes into the groups used by rpc_public_groups
+// and rpc_auth_groups, routes missing here are treated as unsafe.
+var rpcMethodGroups = map[string]string{
+	"subscribe":            rpc.GroupInfo,
+	"unsubscribe":          rpc.GroupInfo,
+	"shards":               rpc.GroupInfo,
+	"status":               rpc.GroupInfo,
+	"net_info":             rpc.GroupInfo,
+	"p2p_metrics":          rpc.GroupInfo,
+	"blockchain":           rpc.GroupInfo,
+	"genesis":              rpc.GroupInfo,
+	"block":                rpc.GroupInfo,
+	"validators":           rpc.GroupInfo,
+	"dump_consensus_state": rpc.GroupInfo,
+	"unconfirmed_txs":      rpc.GroupInfo,
+	"num_unconfirmed_txs":  rpc.GroupInfo,
+	"za_surveillance":      rpc.GroupInfo,
+	"core_version":         rpc.GroupInfo,
+	"query":                rpc.GroupInfo,
+	"info":                 rpc.GroupInfo,
+	"blacklist":            rpc.GroupInfo,
+
+	"broadcast_tx_commit": rpc.GroupBroadcast,
+	"broadcast_tx_sync":   rpc.GroupBroadcast,
+
+	"dial_seeds":           rpc.GroupUnsafe,
+	"unsafe_dial_peers":    rpc.GroupUnsafe,
+	"unsafe_flush_mempool": rpc.GroupUnsafe,
+	"unsafe_unban_peer":    rpc.GroupUnsafe,
+
+	"request_special_op": rpc.GroupSpecialOP,
+	"vote_special_op":    rpc.GroupSpecialOP,
+}
+
+// seedRPCRoutes is the subset of routes a seed node can answer,
+// it has neither application, mempool nor consensus.
+func (n *Node) seedRPCRoutes() map[string]*rpc.RPCFunc {
+	h := newRPCHandler(n)
+	return map[string]*rpc.RPCFunc{
+		// info API
+		"status":      rpc.NewRPCFunc(h.Status, argsWithChainID("")),
+		"net_info":    rpc.NewRPCFunc(h.NetInfo, argsWithChainID("")),
+		"p2p_metrics": rpc.NewRPCFunc(h.P2PMetrics, argsWithChainID("")),
+		"genesis":     rpc.NewRPCFunc(h.Genesis, argsWithChainID("")),
+
+		// control API
+		"dial_seeds":        rpc.NewRPCFunc(h.UnsafeDialSeeds, argsWithChainID("seeds")),
+		"unsafe_dial_peers": rpc.NewRPCFunc(h.UnsafeDialPeers, argsWithChainID("peers,persistent")),
+		"unsafe_unban_peer": rpc.NewRPCFunc(h.UnsafeUnbanPeer, argsWithChainID("key")),
+
+		// refuse_list API
+		"blacklist": rpc.NewRPCFunc(h.Blacklist, argsWithChainID("")),
+	}
+}
+
 func (h *rpcHandler) getShard(chainID string) (*MyNode, error) {
-	var shard *MyNode
-	//var err error
 	if chainID == h.node.MainChainID {
-		shard = h.node.MainShard
-	}
-//else {
-//		app := h.node.MainShard.Application.(*MyApp)
-//		shard, err = app.GetShard(chainID)
-//		if err != nil {
-//			return nil, ErrInvalidChainID
-//		}
-//	}
-
+		return h.node.MainShard, nil
+	}
+	app, ok := h.node.MainShard.Application.(*MyApp)
+	if !ok {
+		return nil, ErrInvalidChainID
+	}
+	shard, err := app.GetShard(chainID)
+	if err != nil {
+		return nil, ErrInvalidChainID
+	}
 	return shard, nil
 }
 
 func (h *rpcHandler) Shards() (types.RPCResult, error) {
-	app := h.node.MainShard.Application.(*MyApp)
+	app, ok := h.node.MainShard.Application.(*MyApp)
+	if !ok {
+		return &types.ResultShards{}, nil
+	}
 	app.Lock()
 	defer app.Unlock()
 	names := make([]string, 0, len(app.Shards))
@@ -150,13 +215,19 @@ func (h *rpcHandler) Status(chainID string) (types.RPCResult, error) {
 		latestBlockTime = latestBlockMeta.Header.Time.UnixNano()
 	}
 
+	votingPower, lastSignedHeight := shard.Dngine.GetValidatorStatus()
+
 	return &types.ResultStatus{
 		NodeInfo:          shard.Dngine.GetNodeInfo(),
 		PubKey:            shard.Dngine.PrivValidator().PubKey,
 		LatestBlockHash:   latestBlockHash,
 		LatestAppHash:     latestAppHash,
 		LatestBlockHeight: latestHeight,
-		LatestBlockTime:   latestBlockTime}, nil
+		LatestBlockTime:   latestBlockTime,
+		CatchingUp:        shard.Dngine.IsCatchingUp(),
+		SyncTargetHeight:  shard.Dngine.GetSyncTarget(),
+		VotingPower:       votingPower,
+		LastSignedHeight:  lastSignedHeight}, nil
 }
 
 func (h *rpcHandler) Genesis(chainID string) (types.RPCResult, error) {
@@ -167,27 +238,16 @@ func (h *rpcHandler) Genesis(chainID string) (types.RPCResult, error) {
 	return &types.ResultGenesis{Genesis: shard.GenesisDoc}, nil
 }
 
-type GetAccountAmountRes struct {
-	Amount int `json:"amount"`
-}
-
-func (h *rpcHandler) GetAccountAmount(address string) (GetAccountAmountRes, error) {
-	res := GetAccountAmountRes{
-		Amount:managedState.accounts[address],
-	}
-	return res, nil
-}
-
 func (h *rpcHandler) Block(chainID string, height int) (types.RPCResult, error) {
 	shard, err := h.getShard(chainID)
 	if err != nil {
 		return nil, ErrInvalidChainID
 	}
-	if height == 0 {
-		return nil, fmt.Errorf("height must be greater than 0")
+	if height <= 0 {
+		return nil, rpctypes.NewRPCError(rpctypes.CodeInvalidParams, "height must be greater than 0")
 	}
 	if height > shard.Dngine.Height() {
-		return nil, fmt.Errorf("height must be less than the current blockchain height")
+		return nil, rpctypes.NewRPCError(rpctypes.CodeNotFound, "height must be less than the current blockchain height")
 	}
 	res := types.ResultBlock{}
 	res.Block, res.BlockMeta = shard.Dngine.GetBlock(height)
@@ -200,7 +260,7 @@ func (h *rpcHandler) BlockchainInfo(chainID string, minHeight, maxHeight int) (t
 		return nil, ErrInvalidChainID
 	}
 	if minHeight > maxHeight {
-		return nil, fmt.Errorf("maxHeight has to be bigger than minHeight")
+		return nil, rpctypes.NewRPCError(rpctypes.CodeInvalidParams, "maxHeight has to be bigger than minHeight")
 	}
 
 	blockStoreHeight := shard.Dngine.Height()
@@ -262,15 +322,56 @@ func (h *rpcHandler) UnsafeFlushMempool(chainID string) (types.RPCResult, error)
 	return &types.ResultUnsafeFlushMempool{}, nil
 }
 
-func (h *rpcHandler) BroadcastTx(chainID string, tx []byte) (*types.ResultBroadcastTx, error) {
+// UnsafeUnbanPeer lifts the temporary ban of the peer with key, as listed by net_info
+func (h *rpcHandler) UnsafeUnbanPeer(chainID, key string) (types.RPCResult, error) {
 	shard, err := h.getShard(chainID)
+	if err != nil {
+		return nil, ErrInvalidChainID
+	}
+	score, ok := shard.Dngine.UnbanPeer(key)
+	if !ok {
+		return nil, rpctypes.NewRPCError(rpctypes.CodeInvalidParams, "no such peer")
+	}
+	return &types.ResultUnsafeUnbanPeer{Peer: score}, nil
+}
 
+func (h *rpcHandler) UnsafeDialSeeds(chainID string, seeds []string) (types.RPCResult, error) {
+	shard, err := h.getShard(chainID)
 	if err != nil {
 		return nil, ErrInvalidChainID
 	}
-	if err := shard.Application.CheckTx(tx); err != nil {
+	if len(seeds) == 0 {
+		return nil, fmt.Errorf("no seeds provided")
+	}
+	if err := shard.Dngine.DialSeeds(seeds); err != nil {
+		return nil, err
+	}
+	return &types.ResultDialSeeds{Log: "Dialing seeds in progress. See /net_info for details"}, nil
+}
+
+func (h *rpcHandler) UnsafeDialPeers(chainID string, peers []string, persistent bool) (types.RPCResult, error) {
+	shard, err := h.getShard(chainID)
+	if err != nil {
+		return nil, ErrInvalidChainID
+	}
+	if len(peers) == 0 {
+		return nil, fmt.Errorf("no peers provided")
+	}
+	if err := shard.Dngine.DialPeers(peers, persistent); err != nil {
 		return nil, err
 	}
+	return &types.ResultDialPeers{Log: "Dialing peers in progress. See /net_info for details"}, nil
+}
+
+func (h *rpcHandler) BroadcastTx(chainID string, tx []byte) (types.RPCResult, error) {
+	shard, err := h.getShard(chainID)
+
+	if err != nil {
+		return nil, ErrInvalidChainID
+	}
+	if err := shard.Application.CheckTx(tx); err != nil {
+		return nil, types.NewRPCAppError(err)
+	}
 
 	if err := shard.Dngine.BroadcastTx(tx); err != nil {
 		return nil, err
@@ -279,27 +380,43 @@ func (h *rpcHandler) BroadcastTx(chainID string, tx []byte) (*types.ResultBroadc
 	return res, nil
 }
 
+// BroadcastTxCommit reports the refusal of the app or of the mempool in CheckTx,
+// and the outcome of the execution in DeliverTx.
 func (h *rpcHandler) BroadcastTxCommit(chainID string, tx []byte) (types.RPCResult, error) {
 	shard, err := h.getShard(chainID)
 	if err != nil {
 		return nil, ErrInvalidChainID
 	}
+	res := &types.ResultBroadcastTxCommit{Hash: types.Tx(tx).Hash()}
 	if err := shard.Application.CheckTx(tx); err != nil {
-		return nil, err
+		appRes := types.ResultOf(err)
+		res.CheckTx = types.ResultTxPhase{Code: appRes.Code, Data: appRes.Data, Log: appRes.Log}
+		return res, nil
 	}
-	if err := shard.Dngine.BroadcastTxCommit(tx); err != nil {
-		return nil, err
+	deliver, err := shard.Dngine.BroadcastTxCommit(tx)
+	if err == dngine.ErrBroadcastTxTimeout || err == dngine.ErrBroadcastTxCanceled {
+		return nil, rpctypes.NewRPCError(rpctypes.CodeServerError, fmt.Sprintf("%v: %X", err, res.Hash))
+	}
+	if err != nil {
+		res.CheckTx = types.ResultTxPhase{Code: types.CodeType_InternalError, Log: err.Error()}
+		return res, nil
 	}
 
-	return &types.ResultBroadcastTxCommit{Code: 0}, nil
+	res.Height = deliver.Height
+	res.DeliverTx = types.ResultTxPhase{Code: deliver.Code, Data: deliver.Data, Log: deliver.Error}
+	return res, nil
 }
 
-func (h *rpcHandler) Query(chainID string, query []byte) (types.RPCResult, error) {
+// Query reads the application state at height, 0 for the last committed one.
+// The app keeps the trees of the rollback_heights heights before the last one,
+// the older heights are refused.
+func (h *rpcHandler) Query(chainID, path string, data []byte, height uint64, prove bool) (types.RPCResult, error) {
 	shard, err := h.getShard(chainID)
 	if err != nil {
 		return nil, ErrInvalidChainID
 	}
-	return &types.ResultQuery{Result: shard.Application.Query(query)}, nil
+	req := types.RequestQuery{Path: path, Data: data, Height: height, Prove: prove}
+	return &types.ResultQuery{Result: shard.Application.Query(req)}, nil
 }
 
 func (h *rpcHandler) Info(chainID string) (types.RPCResult, error) {
@@ -403,9 +520,19 @@ func (h *rpcHandler) NetInfo(chainID string) (types.RPCResult, error) {
 	}
 	res := types.ResultNetInfo{}
 	res.Listening, res.Listeners, res.Peers = shard.Dngine.GetP2PNetInfo()
+	res.Banned = shard.Dngine.GetBannedPeers()
+	res.Channels = shard.Dngine.GetChannelMetrics()
 	return &res, nil
 }
 
+func (h *rpcHandler) P2PMetrics(chainID string) (types.RPCResult, error) {
+	shard, err := h.getShard(chainID)
+	if err != nil {
+		return nil, ErrInvalidChainID
+	}
+	return &types.ResultP2PMetrics{Channels: shard.Dngine.GetChannelMetrics()}, nil
+}
+
 func (h *rpcHandler) Blacklist(chainID string) (types.RPCResult, error) {
 	shard, err := h.getShard(chainID)
 	if err != nil {
@@ -414,6 +541,128 @@ func (h *rpcHandler) Blacklist(chainID string) (types.RPCResult, error) {
 	return &types.ResultRefuseList{Result: shard.Dngine.GetBlacklist()}, nil
 }
 
+// subscribableEvents can be subscribed to by name, along with the Tx:<hash> events.
+// The hook events carry reply channels and stay internal.
+var subscribableEvents = map[string]bool{
+	types.EventStringNewBlock():         true,
+	types.EventStringNewBlockHeader():   true,
+	types.EventStringNewRound():         true,
+	types.EventStringNewRoundStep():     true,
+	types.EventStringTimeoutPropose():   true,
+	types.EventStringCompleteProposal(): true,
+	types.EventStringPolka():            true,
+	types.EventStringUnlock():           true,
+	types.EventStringLock():             true,
+	types.EventStringRelock():           true,
+	types.EventStringTimeoutWait():      true,
+	types.EventStringVote():             true,
+}
+
+// subscriptionQuery reads the event of subscribe and unsubscribe, either a query
+// like tm.event='Tx' AND tx.height>5 or the name of an event
+func subscriptionQuery(event string) (q *query.Query, byName bool, err error) {
+	if strings.ContainsAny(event, "=<> ") {
+		q, err = query.New(event)
+		return q, false, err
+	}
+	if !subscribableEvents[event] && !strings.HasPrefix(event, "Tx:") {
+		return nil, true, fmt.Errorf("event %s can't be subscribed to", event)
+	}
+	q, err = types.EventQuery(event)
+	return q, true, err
+}
+
+// Subscribe forwards the events of the chain matching event to the websocket as ResultEvent responses
+// whose id is the subscribe request id followed by "#event". Their name is the event subscribed to,
+// or the tm.event of the event for a query.
+// A connection that is gone or too slow to drain its events loses the subscription.
+func (h *rpcHandler) Subscribe(wsCtx rpctypes.WSRPCContext, chainID, event string) (types.RPCResult, error) {
+	shard, err := h.getShard(chainID)
+	if err != nil {
+		return nil, ErrInvalidChainID
+	}
+	q, byName, err := subscriptionQuery(event)
+	if err != nil {
+		return nil, err
+	}
+	bus := shard.Dngine.EventBus()
+	subscriber := wsCtx.GetRemoteAddr()
+	if !h.node.reserveSubscription(subscriber) {
+		return nil, ErrTooManySubscriptions
+	}
+	sub, err := bus.Subscribe(subscriber, q, 0, pubsub.Cancel)
+	if err != nil {
+		h.node.releaseSubscription(subscriber)
+		return nil, err
+	}
+	id := fmt.Sprintf("%v#event", wsCtx.Request.ID)
+	go func() {
+		defer h.node.releaseSubscription(subscriber)
+		for {
+			select {
+			case msg, ok := <-sub.Out():
+				if !ok {
+					return
+				}
+				name := event
+				if !byName {
+					name = msg.Tags[types.EventTypeKey]
+				}
+				var res types.RPCResult = &types.ResultEvent{Name: name, Data: msg.Data.(types.TMEventData)}
+				if !wsCtx.TryWriteRPCResponse(rpctypes.NewRPCResponse(id, &res)) {
+					bus.Unsubscribe(subscriber, q)
+					return
+				}
+			case <-wsCtx.Done():
+				// the websocket manager only cleans up the bus of the main chain
+				bus.Unsubscribe(subscriber, q)
+				return
+			}
+		}
+	}()
+	return &types.ResultSubscribe{}, nil
+}
+
+// reserveSubscription counts a new subscription of the websocket at remoteAddr,
+// false when it already holds rpc_max_subscriptions_per_conn of them
+func (n *Node) reserveSubscription(remoteAddr string) bool {
+	max := n.config.GetInt("rpc_max_subscriptions_per_conn")
+	n.subsMtx.Lock()
+	defer n.subsMtx.Unlock()
+	if max > 0 && n.subs[remoteAddr] >= max {
+		return false
+	}
+	if n.subs == nil {
+		n.subs = make(map[string]int)
+	}
+	n.subs[remoteAddr]++
+	return true
+}
+
+// releaseSubscription uncounts a subscription once it has ended
+func (n *Node) releaseSubscription(remoteAddr string) {
+	n.subsMtx.Lock()
+	defer n.subsMtx.Unlock()
+	if n.subs[remoteAddr]--; n.subs[remoteAddr] <= 0 {
+		delete(n.subs, remoteAddr)
+	}
+}
+
+func (h *rpcHandler) Unsubscribe(wsCtx rpctypes.WSRPCContext, chainID, event string) (types.RPCResult, error) {
+	shard, err := h.getShard(chainID)
+	if err != nil {
+		return nil, ErrInvalidChainID
+	}
+	q, _, err := subscriptionQuery(event)
+	if err != nil {
+		return nil, err
+	}
+	if err := shard.Dngine.EventBus().Unsubscribe(wsCtx.GetRemoteAddr(), q); err != nil {
+		return nil, err
+	}
+	return &types.ResultUnsubscribe{}, nil
+}
+
 func (h *rpcHandler) RequestSpecialOP(chainID string, tx []byte) (types.RPCResult, error) {
 	shard, err := h.getShard(chainID)
 	if err != nil {
@@ -0,0 +1,125 @@
+// Copyright 2017 Delos Development Foundation and contributors. Licensed
+// under the Apache License, Version 2.0. See the COPYING file at the root
+// of this distribution or at http://www.apache.org/licenses/LICENSE-2.0
+package node
+
+import (
+	"io/ioutil"
+	"os"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/DelosIsland/core/dngine/testnet"
+	"github.com/DelosIsland/core/dngine/types"
+	cfg "github.com/DelosIsland/core/module/lib/go-config"
+	"github.com/DelosIsland/core/module/lib/go-events"
+	"github.com/DelosIsland/core/module/lib/go-pubsub"
+	rpctypes "github.com/DelosIsland/core/module/lib/go-rpc/types"
+)
+
+// testWSConnection records the responses written to a websocket
+type testWSConnection struct {
+	mtx   sync.Mutex
+	resps []rpctypes.RPCResponse
+	done  chan struct{}
+}
+
+func (c *testWSConnection) GetRemoteAddr() string              { return "127.0.0.1:26657" }
+func (c *testWSConnection) GetEventSwitch() events.EventSwitch { return nil }
+func (c *testWSConnection) Done() <-chan struct{}              { return c.done }
+
+func (c *testWSConnection) WriteRPCResponse(resp rpctypes.RPCResponse) {
+	c.TryWriteRPCResponse(resp)
+}
+
+func (c *testWSConnection) TryWriteRPCResponse(resp rpctypes.RPCResponse) bool {
+	c.mtx.Lock()
+	defer c.mtx.Unlock()
+	c.resps = append(c.resps, resp)
+	return true
+}
+
+func (c *testWSConnection) numResponses() int {
+	c.mtx.Lock()
+	defer c.mtx.Unlock()
+	return len(c.resps)
+}
+
+// TestSubscribeShard checks that the subscriptions to a shard end with the websocket,
+// the websocket manager only knows the EventSwitch of the main chain
+func TestSubscribeShard(t *testing.T) {
+	if testing.Short() {
+		t.Skip("runs a chain")
+	}
+	dir, err := ioutil.TempDir("", "subscribe")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	c, err := testnet.NewCluster(dir, 2, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Stop()
+	if err := c.Start(); err != nil {
+		t.Fatal(err)
+	}
+
+	// the second node stands for a shard of the first one
+	shard := &MyNode{Dngine: c.Nodes[1].Dngine}
+	app := &MyApp{Shards: map[string]*MyNode{"shard": shard}}
+	conf := cfg.NewMapConfig(nil)
+	conf.Set("rpc_max_subscriptions_per_conn", 1)
+	h := &rpcHandler{node: &Node{
+		MainChainID: c.Testnet.Genesis.ChainID,
+		MainShard:   &MyNode{Dngine: c.Nodes[0].Dngine, Application: app},
+		config:      conf,
+	}}
+
+	conn := &testWSConnection{done: make(chan struct{})}
+	wsCtx := rpctypes.WSRPCContext{Request: rpctypes.RPCRequest{ID: "1"}, WSRPCConnection: conn}
+	if _, err := h.Subscribe(wsCtx, "shard", types.EventStringNewBlock()); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := h.Subscribe(wsCtx, "shard", types.EventStringNewRound()); err != ErrTooManySubscriptions {
+		t.Fatalf("Expected the subscriptions of the connection to be capped, got %v", err)
+	}
+	deadline := time.Now().Add(30 * time.Second)
+	for conn.numResponses() == 0 && time.Now().Before(deadline) {
+		time.Sleep(50 * time.Millisecond)
+	}
+	if conn.numResponses() == 0 {
+		t.Fatal("Expected the blocks of the shard to be forwarded")
+	}
+
+	close(conn.done)
+	q, err := types.EventQuery(types.EventStringNewBlock())
+	if err != nil {
+		t.Fatal(err)
+	}
+	bus := shard.Dngine.EventBus()
+	deadline = time.Now().Add(time.Second)
+	for {
+		// succeeds once the subscription of the closed connection is gone
+		_, err := bus.Subscribe(conn.GetRemoteAddr(), q, 0, pubsub.Cancel)
+		if err == nil {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("Expected the shard subscription to end with the connection, got %v", err)
+		}
+		time.Sleep(20 * time.Millisecond)
+	}
+	for {
+		// the ended subscription no longer counts
+		if h.node.reserveSubscription(conn.GetRemoteAddr()) {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("Expected the subscription of the closed connection to be released")
+		}
+		time.Sleep(20 * time.Millisecond)
+	}
+}
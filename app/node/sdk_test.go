@@ -0,0 +1,90 @@
+// Copyright 2017 Delos Development Foundation and contributors. Licensed
+// under the Apache License, Version 2.0. See the COPYING file at the root
+// of this distribution or at http://www.apache.org/licenses/LICENSE-2.0
+package node
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"go.uber.org/zap"
+
+	"github.com/DelosIsland/core/client/sdk"
+	"github.com/DelosIsland/core/dngine/testnet"
+	"github.com/DelosIsland/core/dngine/types"
+	"github.com/DelosIsland/core/module/lib/go-config"
+	"github.com/DelosIsland/core/module/lib/go-crypto"
+	rpcserver "github.com/DelosIsland/core/module/lib/go-rpc/server"
+	rpctypes "github.com/DelosIsland/core/module/lib/go-rpc/types"
+)
+
+// TestSDKBroadcastTxSync sends txs with the sdk to the rpc routes of a node,
+// so that the client reads the results the handlers really return
+func TestSDKBroadcastTxSync(t *testing.T) {
+	if testing.Short() {
+		t.Skip("runs a chain")
+	}
+	dir, err := ioutil.TempDir("", "sdk")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	c, err := testnet.NewCluster(dir, 1, func(conf config.Config) types.Application {
+		return NewMyApp(zap.NewNop(), conf)
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Stop()
+	privKey := crypto.GenPrivKeyEd25519()
+	if err := fundCluster(c, map[string]Account{AddressFromPubKey(privKey.PubKey()): {Balance: testBalance}}); err != nil {
+		t.Fatal(err)
+	}
+	if err := c.Start(); err != nil {
+		t.Fatal(err)
+	}
+
+	chainID := c.Testnet.Genesis.ChainID
+	n := &Node{
+		MainChainID: chainID,
+		MainShard:   &MyNode{Dngine: c.Nodes[0].Dngine, Application: c.Nodes[0].App},
+		logger:      zap.NewNop(),
+	}
+	mux := http.NewServeMux()
+	rpcserver.RegisterRPCFuncs(n.logger, mux, n.rpcRoutes())
+	server := httptest.NewServer(mux)
+	defer server.Close()
+	client := sdk.NewClient(zap.NewNop(), "tcp://"+strings.TrimPrefix(server.URL, "http://"), chainID)
+
+	newTx := func(privKey crypto.PrivKey) []byte {
+		tx := &MyTx{ChainID: chainID, Time: time.Now(), DestAddress: testDest, Amount: 10}
+		tx.SignByPrivKey(privKey)
+		return TagMyTx(testTxJSON(tx))
+	}
+	res, err := client.BroadcastTxSync(newTx(privKey))
+	if err != nil {
+		t.Fatalf("Expected the tx to be accepted, got %v", err)
+	}
+	if res.Code != types.CodeType_OK {
+		t.Errorf("Expected code OK, got %+v", res)
+	}
+
+	_, err = client.BroadcastTxSync(newTx(crypto.GenPrivKeyEd25519()))
+	rpcErr, ok := err.(*rpctypes.RPCError)
+	if !ok {
+		t.Fatalf("Expected an rpc error for an unfunded sender, got %v", err)
+	}
+	if data, ok := types.AppErrorData(rpcErr); !ok || data.Code != types.CodeType_InsufficientFunds {
+		t.Errorf("Expected the app error InsufficientFunds, got %+v", rpcErr)
+	}
+
+	if _, err := client.WithChainID("nochain").BroadcastTxSync(newTx(privKey)); err == nil {
+		t.Error("Expected an unknown chain to be refused")
+	}
+}
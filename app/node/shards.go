@@ -0,0 +1,359 @@
+// Copyright 2017 Delos Development Foundation and contributors. Licensed
+// under the Apache License, Version 2.0. See the COPYING file at the root
+// of this distribution or at http://www.apache.org/licenses/LICENSE-2.0
+package node
+
+import (
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net"
+	"os"
+	"path"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"go.uber.org/zap"
+
+	"github.com/DelosIsland/core/dngine"
+	ac "github.com/DelosIsland/core/dngine/config"
+	"github.com/DelosIsland/core/dngine/types"
+	cmn "github.com/DelosIsland/core/module/lib/go-common"
+	"github.com/DelosIsland/core/module/lib/go-crypto"
+	"github.com/DelosIsland/core/module/lib/go-merkle"
+	"github.com/DelosIsland/core/module/lib/go-wire"
+)
+
+// shards are kept in the accounts tree under this prefix so that the AppHash covers them,
+// transfers can't use it as a destination.
+const shardKeyPrefix = "shard/"
+
+// shardIDPattern is what a shard chain id must match, it names the data directory of the shard
+var shardIDPattern = regexp.MustCompile(`^[A-Za-z0-9_-]{1,64}$`)
+
+// shards live in datadir/shards/<chainid>
+const shardsDir = "shards"
+
+// settings of the chain a shard tx may set, the same on every host.
+// The others are decided by each host for itself and ignored in tx configs.
+var shardChainKeys = map[string]bool{
+	"seeds":                     true,
+	"fast_sync":                 true,
+	"block_size":                true,
+	"block_part_size":           true,
+	"disable_data_hash":         true,
+	"timeout_propose":           true,
+	"timeout_propose_delta":     true,
+	"timeout_prevote":           true,
+	"timeout_prevote_delta":     true,
+	"timeout_precommit":         true,
+	"timeout_precommit_delta":   true,
+	"timeout_commit":            true,
+	"skip_timeout_commit":       true,
+	"cs_wal_light":              true,
+	"mempool_recheck":           true,
+	"mempool_recheck_empty":     true,
+	"mempool_broadcast":         true,
+	"mempool_enable_txs_limits": true,
+}
+
+// ShardInfo is what the main chain knows about a child chain
+type ShardInfo struct {
+	App     string   `json:"app"`
+	Owner   string   `json:"owner"`   // address of the creator, the only one allowed to delete it
+	Genesis []byte   `json:"genesis"` // JSON genesis doc of the child chain
+	Config  []byte   `json:"config"`  // JSON settings applied by every host
+	Members []string `json:"members"` // hex pubkeys of the nodes hosting it, the creator to begin with
+}
+
+func memberKey(pubKey crypto.PubKey) string {
+	return hex.EncodeToString(pubKey.Bytes())
+}
+
+func (s *ShardInfo) memberIndex(pubKey crypto.PubKey) int {
+	key := memberKey(pubKey)
+	for i, m := range s.Members {
+		if m == key {
+			return i
+		}
+	}
+	return -1
+}
+
+// HasMember tells whether the owner of pubKey hosts the shard
+func (s *ShardInfo) HasMember(pubKey crypto.PubKey) bool {
+	return s.memberIndex(pubKey) >= 0
+}
+
+// GetShard returns the registered shard with chainID, false if there is none
+func (s *AccountState) GetShard(chainID string) (ShardInfo, bool) {
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+
+	return readShard(s.tree, chainID)
+}
+
+// GetCommittedShard is GetShard on the last saved tree
+func (s *AccountState) GetCommittedShard(chainID string) (ShardInfo, bool) {
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+	return readShard(s.committed, chainID)
+}
+
+func readShard(tree *merkle.IAVLTree, chainID string) (ShardInfo, bool) {
+	var info ShardInfo
+	_, value, exists := tree.Get([]byte(shardKeyPrefix + chainID))
+	if !exists {
+		return info, false
+	}
+	if err := wire.ReadBinaryBytes(value, &info); err != nil {
+		return info, false
+	}
+	return info, true
+}
+
+// SetShard registers or updates a shard in the working tree
+func (s *AccountState) SetShard(chainID string, info ShardInfo) {
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+	s.tree.Set([]byte(shardKeyPrefix+chainID), wire.BinaryBytes(info))
+}
+
+// RemoveShard unregisters a shard from the working tree
+func (s *AccountState) RemoveShard(chainID string) {
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+	s.tree.Remove([]byte(shardKeyPrefix + chainID))
+}
+
+// ShardIDs lists the chain ids of the registered shards, sorted
+func (s *AccountState) ShardIDs() []string {
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+
+	var ids []string
+	// '0' follows '/' so the range covers exactly the prefix
+	start, end := []byte(shardKeyPrefix), []byte(strings.TrimSuffix(shardKeyPrefix, "/")+"0")
+	s.tree.IterateRange(start, end, true, func(key []byte, _ []byte) bool {
+		ids = append(ids, string(key[len(shardKeyPrefix):]))
+		return false
+	})
+	return ids
+}
+
+// GetShard returns the running shard with chainID
+func (app *MyApp) GetShard(chainID string) (*MyNode, error) {
+	app.Lock()
+	defer app.Unlock()
+	if shard, ok := app.Shards[chainID]; ok {
+		return shard, nil
+	}
+	return nil, ErrShardNotFound
+}
+
+// restoreShards starts the registered shards this node is a member of
+func (app *MyApp) restoreShards() {
+	app.queueShardSyncs(app.state.ShardIDs()...)
+}
+
+// queueShardSyncs hands the shards to syncShards, it never blocks
+func (app *MyApp) queueShardSyncs(chainIDs ...string) {
+	if len(chainIDs) == 0 {
+		return
+	}
+	app.syncMtx.Lock()
+	app.syncQueue = append(app.syncQueue, chainIDs...)
+	app.syncMtx.Unlock()
+	select {
+	case app.syncWake <- struct{}{}:
+	default:
+	}
+}
+
+// syncShards starts and stops the queued shards one after the other until the app stops
+func (app *MyApp) syncShards() {
+	defer close(app.syncDone)
+	for {
+		select {
+		case <-app.quit:
+			return
+		case <-app.syncWake:
+		}
+		app.syncMtx.Lock()
+		queue := app.syncQueue
+		app.syncQueue = nil
+		app.syncMtx.Unlock()
+		for _, chainID := range queue {
+			select {
+			case <-app.quit:
+				return
+			default:
+			}
+			app.syncShard(chainID)
+		}
+	}
+}
+
+// syncShard starts or stops the local copy of a shard to match the committed registry,
+// the data of deleted shards is removed.
+func (app *MyApp) syncShard(chainID string) {
+	if app.node == nil {
+		// replaying blocks before the node is up, restoreShards will catch up
+		return
+	}
+	root, err := app.shardDir(chainID)
+	if err != nil {
+		app.logger.Error("fail to sync shard", zap.String("chainid", chainID), zap.String("error", err.Error()))
+		return
+	}
+	info, registered := app.state.GetCommittedShard(chainID)
+	host := registered && info.HasMember(app.node.PrivValidator().PubKey)
+
+	app.Lock()
+	running := app.Shards[chainID]
+	app.Unlock()
+
+	switch {
+	case host && running == nil:
+		if err := app.startShard(chainID, root, info); err != nil {
+			app.logger.Error("fail to start shard", zap.String("chainid", chainID), zap.String("error", err.Error()))
+			return
+		}
+		app.logger.Info("shard started", zap.String("chainid", chainID), zap.String("app", info.App))
+	case !host && running != nil:
+		app.Lock()
+		delete(app.Shards, chainID)
+		delete(app.RunningChainIDs, chainID)
+		app.Unlock()
+		if !running.Stop() {
+			app.logger.Error(ErrFailToStop.Error(), zap.String("chainid", chainID))
+		}
+		app.logger.Info("shard stopped", zap.String("chainid", chainID))
+	}
+
+	if !registered {
+		if err := os.RemoveAll(root); err != nil {
+			app.logger.Error("fail to remove shard data", zap.String("chainid", chainID), zap.String("error", err.Error()))
+		}
+	}
+}
+
+// shardDir is the data directory of a shard, it must be a direct child of datadir/shards
+func (app *MyApp) shardDir(chainID string) (string, error) {
+	shards := filepath.Join(app.config.GetString("datadir"), shardsDir)
+	root := filepath.Join(shards, chainID)
+	if !shardIDPattern.MatchString(chainID) || filepath.Dir(root) != shards || filepath.Base(root) != chainID {
+		return "", fmt.Errorf("%v: %q can't name a directory in %s", ErrInvalidShard, chainID, shards)
+	}
+	return root, nil
+}
+
+func (app *MyApp) startShard(chainID, root string, info ShardInfo) error {
+	if !cmn.FileExists(path.Join(root, ac.CONFIGFILE)) {
+		if err := app.initShardDir(root, info); err != nil {
+			return err
+		}
+	}
+
+	shard := NewMyNode(app.logger, info.App, ac.GetConfig(root))
+	if shard == nil {
+		return fmt.Errorf("no such app: %s", info.App)
+	}
+	if err := shard.Start(); err != nil {
+		return err
+	}
+
+	app.Lock()
+	app.Shards[chainID] = shard
+	app.RunningChainIDs[chainID] = info.App
+	app.Unlock()
+	return nil
+}
+
+// initShardDir writes the genesis, a priv_validator with the node key and a config.toml
+// made of the shard settings and of the local ones inherited from the main chain.
+func (app *MyApp) initShardDir(root string, info ShardInfo) error {
+	if err := cmn.EnsureDir(root, 0700); err != nil {
+		return err
+	}
+	if err := cmn.WriteFile(path.Join(root, "genesis.json"), info.Genesis, 0644); err != nil {
+		return err
+	}
+
+	// same key, but the signing state is per chain.
+	// A key kept in the keystore stays there, the shard unlocks it too.
+	keyName := app.config.GetString("priv_validator_key")
+	if keyName == "" {
+		mainPV := app.node.PrivValidator()
+		pv := &types.PrivValidator{
+			Address: mainPV.Address,
+			PubKey:  mainPV.PubKey,
+			PrivKey: mainPV.PrivKey,
+		}
+		pv.SetFile(path.Join(root, "priv_validator.json"))
+		pv.Save()
+	}
+
+	settings := make(map[string]interface{})
+	if len(info.Config) > 0 {
+		if err := json.Unmarshal(info.Config, &settings); err != nil {
+			return err
+		}
+	}
+	for k := range settings {
+		if !shardChainKeys[k] {
+			delete(settings, k)
+		}
+	}
+	for _, k := range []string{"environment", "moniker", "log_path", "rpc_laddr"} {
+		settings[k] = app.config.GetString(k)
+	}
+	if keyName != "" {
+		settings["priv_validator_key"] = keyName
+		settings["keystore_dir"] = app.config.GetString("keystore_dir")
+	}
+	laddr, err := app.freeShardLaddr()
+	if err != nil {
+		return err
+	}
+	settings["node_laddr"] = laddr
+
+	return ioutil.WriteFile(path.Join(root, ac.CONFIGFILE), ac.ConfigTOML(settings), 0644)
+}
+
+// freeShardLaddr picks the first port after the main p2p port
+// which is neither used by a running shard nor by another process.
+func (app *MyApp) freeShardLaddr() (string, error) {
+	protocol, address := dngine.ProtocolAndAddress(app.config.GetString("node_laddr"))
+	host, portStr, err := net.SplitHostPort(address)
+	if err != nil {
+		return "", err
+	}
+	port, err := strconv.Atoi(portStr)
+	if err != nil {
+		return "", err
+	}
+
+	used := make(map[int]bool)
+	app.Lock()
+	for _, s := range app.Shards {
+		used[int(s.Dngine.P2PPort())] = true
+	}
+	app.Unlock()
+
+	for p := port + 1; p <= 65535; p++ {
+		if used[p] {
+			continue
+		}
+		ln, err := net.Listen("tcp", net.JoinHostPort(host, strconv.Itoa(p)))
+		if err != nil {
+			continue
+		}
+		ln.Close()
+		return fmt.Sprintf("%s://%s", protocol, net.JoinHostPort(host, strconv.Itoa(p))), nil
+	}
+	return "", fmt.Errorf("no free port for the shard")
+}
@@ -3,57 +3,208 @@
 // of this distribution or at http://www.apache.org/licenses/LICENSE-2.0
 package client
 
-
 import (
+	"encoding/hex"
+	"fmt"
+	"io/ioutil"
+	"path"
+
 	"gopkg.in/urfave/cli.v1"
-	cl "github.com/DelosIsland/core/module/lib/go-rpc/client"
-	"encoding/json"
+
+	"github.com/DelosIsland/core/app/node"
+	"github.com/DelosIsland/core/module/lib/go-crypto"
+	"github.com/DelosIsland/core/module/lib/go-crypto/keystore"
 )
 
+const keystoreDir = "keystore"
+
 var (
+	nameFlag = cli.StringFlag{
+		Name:  "name",
+		Usage: "name of the key",
+	}
+
 	AccountCommands = cli.Command{
 		Name:     "account",
 		Usage:    "operations for account",
 		Category: "Account",
 		Subcommands: []cli.Command{
 			{
-				Name:     "add",
-				Action:   addAccount,
-				Usage:    "create a new account",
-				Category: "Account",
+				Name:   "new",
+				Action: newAccount,
+				Usage:  "create a new account key in the keystore",
+				Flags: []cli.Flag{
+					nameFlag,
+					cli.StringFlag{
+						Name:  "type",
+						Value: keystore.KeyTypeEd25519,
+						Usage: "ed25519 or secp256k1",
+					},
+					passphraseFlag,
+				},
 			},
 			{
-				Name:     "getAccount",
-				Action:   getAccountAmount,
-				Usage:    "create a new account",
+				Name:   "list",
+				Action: listAccounts,
+				Usage:  "list the keys of the keystore",
+			},
+			{
+				Name:   "import",
+				Action: importAccount,
+				Usage:  "import an exported key file, or a raw private key encrypted with a new passphrase",
 				Flags: []cli.Flag{
+					nameFlag,
+					cli.StringFlag{
+						Name:  "file",
+						Usage: "key file written by export",
+					},
 					cli.StringFlag{
-						Name:  "addr",
-						Usage: "address",
+						Name:  "privkey",
+						Usage: "hex of a go-wire encoded private key, instead of file",
 					},
+					passphraseFlag,
 				},
 			},
+			{
+				Name:   "export",
+				Action: exportAccount,
+				Usage:  "print the encrypted key file",
+				Flags:  []cli.Flag{nameFlag},
+			},
+			{
+				Name:   "delete",
+				Action: deleteAccount,
+				Usage:  "remove a key from the keystore",
+				Flags:  []cli.Flag{nameFlag, passphraseFlag},
+			},
 		},
 	}
 )
 
-func addAccount() {
+type accountInfo struct {
+	Name    string `json:"name"`
+	Type    string `json:"type"`
+	Address string `json:"address"`
+}
+
+func newAccountInfo(info keystore.KeyInfo) accountInfo {
+	return accountInfo{Name: info.Name, Type: info.Type, Address: node.AddressFromPubKey(info.PubKey)}
+}
+
+func printAccounts(ctx *cli.Context, infos ...accountInfo) error {
+	return output(ctx, infos, func() {
+		for _, info := range infos {
+			fmt.Printf("%s (%s): %s\n", info.Name, info.Type, info.Address)
+		}
+	})
+}
+
+func keyStore(ctx *cli.Context) *keystore.KeyStore {
+	return keystore.NewKeyStore(path.Join(ctx.GlobalString("home"), keystoreDir))
+}
+
+// keyAddress returns the account address of a keystore key
+func keyAddress(ctx *cli.Context, name string) (string, error) {
+	info, err := keyStore(ctx).Get(name)
+	if err != nil {
+		return "", fmt.Errorf("key %s: %v", name, err)
+	}
+	return node.AddressFromPubKey(info.PubKey), nil
+}
+
+func newPassphrase(ctx *cli.Context) (string, error) {
+	pass, err := passphrase(ctx, "New passphrase: ")
+	if err == nil && pass == "" {
+		err = fmt.Errorf("empty passphrase")
+	}
+	return pass, err
+}
+
+// ./client account new --name=alice --type=ed25519
+func newAccount(ctx *cli.Context) error {
+	pass, err := newPassphrase(ctx)
+	if err != nil {
+		return err
+	}
+	info, err := keyStore(ctx).Create(ctx.String("name"), pass, ctx.String("type"))
+	if err != nil {
+		return err
+	}
+	return printAccounts(ctx, newAccountInfo(info))
+}
+
+// ./client account list
+func listAccounts(ctx *cli.Context) error {
+	infos, err := keyStore(ctx).List()
+	if err != nil {
+		return err
+	}
+	accounts := make([]accountInfo, 0, len(infos))
+	for _, info := range infos {
+		accounts = append(accounts, newAccountInfo(info))
+	}
+	return printAccounts(ctx, accounts...)
+}
 
+// ./client account import --name=alice --file=alice.key
+func importAccount(ctx *cli.Context) error {
+	var (
+		info keystore.KeyInfo
+		err  error
+	)
+	switch {
+	case ctx.String("file") != "":
+		info, err = importKeyFile(ctx)
+	case ctx.String("privkey") != "":
+		info, err = importPrivKey(ctx)
+	default:
+		err = fmt.Errorf("--file or --privkey is required")
+	}
+	if err != nil {
+		return err
+	}
+	return printAccounts(ctx, newAccountInfo(info))
 }
-//./client --backend "tcp://localhost:46657" account getAccount --addr=source1
-func getAccountAmount(ctx *cli.Context) error {
-	addr := ctx.String("addr")
-	clientJSON := cl.NewClientJSONRPC(logger, "tcp://localhost:46657")
-	tmResult := &TmResult{}
-	res, err := clientJSON.Call("get_account_amount", []interface{}{addr}, tmResult)
-	println(res)
-	rb,_ := json.Marshal(res)
-	println(string(rb))
-	println(tmResult.Amount)
-	return err
+
+func importKeyFile(ctx *cli.Context) (keystore.KeyInfo, error) {
+	armor, err := ioutil.ReadFile(ctx.String("file"))
+	if err != nil {
+		return keystore.KeyInfo{}, err
+	}
+	return keyStore(ctx).Import(ctx.String("name"), string(armor))
 }
 
+func importPrivKey(ctx *cli.Context) (keystore.KeyInfo, error) {
+	keyBytes, err := hex.DecodeString(ctx.String("privkey"))
+	if err != nil {
+		return keystore.KeyInfo{}, err
+	}
+	privKey, err := crypto.PrivKeyFromBytes(keyBytes)
+	if err != nil {
+		return keystore.KeyInfo{}, err
+	}
+	pass, err := newPassphrase(ctx)
+	if err != nil {
+		return keystore.KeyInfo{}, err
+	}
+	return keyStore(ctx).ImportPrivKey(ctx.String("name"), pass, privKey)
+}
+
+// ./client account export --name=alice > alice.key
+func exportAccount(ctx *cli.Context) error {
+	armor, err := keyStore(ctx).Export(ctx.String("name"))
+	if err != nil {
+		return err
+	}
+	fmt.Print(armor)
+	return nil
+}
 
-type TmResult struct {
-	Amount int `json:"amount"`
-}
\ No newline at end of file
+// ./client account delete --name=alice
+func deleteAccount(ctx *cli.Context) error {
+	pass, err := passphrase(ctx, "Passphrase: ")
+	if err != nil {
+		return err
+	}
+	return keyStore(ctx).Delete(ctx.String("name"), pass)
+}
@@ -0,0 +1,81 @@
+// Copyright 2017 Delos Development Foundation and contributors. Licensed
+// under the Apache License, Version 2.0. See the COPYING file at the root
+// of this distribution or at http://www.apache.org/licenses/LICENSE-2.0
+package client
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path"
+	"strings"
+
+	"gopkg.in/urfave/cli.v1"
+
+	"github.com/DelosIsland/core/client/sdk"
+	"github.com/DelosIsland/core/dngine/types"
+)
+
+const (
+	OutputText = "text"
+	OutputJSON = "json"
+)
+
+// DefaultHome is where the client keeps its keystore unless --home says otherwise
+func DefaultHome() string {
+	return path.Join(os.Getenv("HOME"), ".ann_client")
+}
+
+// rpcClient talks to the chain given by --chainid on the node given by --backend
+func rpcClient(ctx *cli.Context) (*sdk.Client, error) {
+	chainID := ctx.GlobalString("chainid")
+	if chainID == "" {
+		return nil, fmt.Errorf("--chainid is required")
+	}
+	return sdk.NewClient(logger, ctx.GlobalString("backend"), chainID), nil
+}
+
+// queryApp runs an application query on the last committed state
+func queryApp(ctx *cli.Context, path string, data []byte) (types.ResponseQuery, error) {
+	c, err := rpcClient(ctx)
+	if err != nil {
+		return types.ResponseQuery{}, err
+	}
+	return c.QueryApp(path, data)
+}
+
+// output prints v as json with --output json, text prints it otherwise
+func output(ctx *cli.Context, v interface{}, text func()) error {
+	switch ctx.GlobalString("output") {
+	case OutputJSON:
+		buf, err := json.MarshalIndent(v, "", "  ")
+		if err != nil {
+			return err
+		}
+		fmt.Println(string(buf))
+	case OutputText:
+		text()
+	default:
+		return fmt.Errorf("unknown output %s", ctx.GlobalString("output"))
+	}
+	return nil
+}
+
+// passphrase reads the --passphrase flag or asks for it on the terminal
+func passphrase(ctx *cli.Context, prompt string) (string, error) {
+	if p := ctx.String("passphrase"); p != "" {
+		return p, nil
+	}
+	fmt.Fprint(os.Stderr, prompt)
+	line, err := bufio.NewReader(os.Stdin).ReadString('\n')
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimRight(line, "\r\n"), nil
+}
+
+var passphraseFlag = cli.StringFlag{
+	Name:  "passphrase",
+	Usage: "passphrase of the key, asked on stdin if missing",
+}
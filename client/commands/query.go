@@ -0,0 +1,183 @@
+// Copyright 2017 Delos Development Foundation and contributors. Licensed
+// under the Apache License, Version 2.0. See the COPYING file at the root
+// of this distribution or at http://www.apache.org/licenses/LICENSE-2.0
+package client
+
+import (
+	"fmt"
+	"time"
+
+	"gopkg.in/urfave/cli.v1"
+
+	"github.com/DelosIsland/core/app/node"
+	"github.com/DelosIsland/core/module/lib/go-wire"
+)
+
+var (
+	QueryCommands = cli.Command{
+		Name:     "query",
+		Usage:    "read the state of a node",
+		Category: "Query",
+		Subcommands: []cli.Command{
+			{
+				Name:   "balance",
+				Usage:  "show the balance and nonce of an account",
+				Action: queryBalance,
+				Flags: []cli.Flag{
+					cli.StringFlag{
+						Name:  "addr",
+						Usage: "address",
+					},
+					cli.StringFlag{
+						Name:  "name",
+						Usage: "name of a keystore key, instead of addr",
+					},
+				},
+			},
+			{
+				Name:   "status",
+				Usage:  "show the node status",
+				Action: queryStatus,
+			},
+			{
+				Name:   "block",
+				Usage:  "show a block",
+				Action: queryBlock,
+				Flags: []cli.Flag{
+					cli.IntFlag{
+						Name:  "height",
+						Usage: "height of the block",
+					},
+				},
+			},
+			{
+				Name:   "validators",
+				Usage:  "show the validator set",
+				Action: queryValidators,
+			},
+			{
+				Name:   "net_info",
+				Usage:  "show the peers of the node",
+				Action: queryNetInfo,
+			},
+		},
+	}
+)
+
+func queryAccount(ctx *cli.Context, address string) (node.Account, error) {
+	var acc node.Account
+	query, err := queryApp(ctx, node.QueryPathAccount, []byte(address))
+	if err != nil {
+		return acc, err
+	}
+	err = wire.ReadBinaryBytes(query.Value, &acc)
+	return acc, err
+}
+
+type balanceInfo struct {
+	Address string `json:"address"`
+	node.Account
+}
+
+// ./client --chainid=dngine-test query balance --name=alice
+func queryBalance(ctx *cli.Context) error {
+	address := ctx.String("addr")
+	if name := ctx.String("name"); name != "" {
+		var err error
+		if address, err = keyAddress(ctx, name); err != nil {
+			return err
+		}
+	}
+	if address == "" {
+		return fmt.Errorf("--addr or --name is required")
+	}
+	acc, err := queryAccount(ctx, address)
+	if err != nil {
+		return err
+	}
+	info := balanceInfo{Address: address, Account: acc}
+	return output(ctx, info, func() {
+		fmt.Printf("address: %s\nbalance: %d\nnonce: %d\n", info.Address, info.Balance, info.Nonce)
+	})
+}
+
+// ./client --chainid=dngine-test query status
+func queryStatus(ctx *cli.Context) error {
+	c, err := rpcClient(ctx)
+	if err != nil {
+		return err
+	}
+	res, err := c.Status()
+	if err != nil {
+		return err
+	}
+	return output(ctx, res, func() {
+		fmt.Printf("node: %s (%s)\n", res.NodeInfo.Moniker, res.NodeInfo.ListenAddr)
+		fmt.Printf("chain: %s\n", res.NodeInfo.Network)
+		fmt.Printf("height: %d\n", res.LatestBlockHeight)
+		fmt.Printf("block hash: %X\napp hash: %X\n", res.LatestBlockHash, res.LatestAppHash)
+		fmt.Printf("block time: %v\n", time.Unix(0, res.LatestBlockTime))
+		if res.CatchingUp {
+			fmt.Printf("catching up: to height %d\n", res.SyncTargetHeight)
+		}
+		if res.VotingPower > 0 {
+			fmt.Printf("voting power: %d\nlast signed height: %d\n", res.VotingPower, res.LastSignedHeight)
+		}
+	})
+}
+
+// ./client --chainid=dngine-test query block --height=1
+func queryBlock(ctx *cli.Context) error {
+	c, err := rpcClient(ctx)
+	if err != nil {
+		return err
+	}
+	res, err := c.Block(ctx.Int("height"))
+	if err != nil {
+		return err
+	}
+	return output(ctx, res, func() {
+		header := res.Block.Header
+		fmt.Printf("height: %d\nhash: %X\ntime: %v\n", header.Height, res.BlockMeta.Hash, header.Time)
+		fmt.Printf("app hash: %X\ntxs: %d\n", header.AppHash, len(res.Block.Txs))
+		for _, tx := range res.Block.Txs {
+			fmt.Printf("  %X\n", tx.Hash())
+		}
+	})
+}
+
+// ./client --chainid=dngine-test query validators
+func queryValidators(ctx *cli.Context) error {
+	c, err := rpcClient(ctx)
+	if err != nil {
+		return err
+	}
+	res, err := c.Validators()
+	if err != nil {
+		return err
+	}
+	return output(ctx, res, func() {
+		fmt.Printf("height: %d\n", res.BlockHeight)
+		for _, v := range res.Validators {
+			fmt.Printf("%X power %d\n", v.Address, v.VotingPower)
+		}
+	})
+}
+
+// ./client --chainid=dngine-test query net_info
+func queryNetInfo(ctx *cli.Context) error {
+	c, err := rpcClient(ctx)
+	if err != nil {
+		return err
+	}
+	res, err := c.NetInfo()
+	if err != nil {
+		return err
+	}
+	return output(ctx, res, func() {
+		fmt.Printf("listening: %v %v\npeers: %d\n", res.Listening, res.Listeners, len(res.Peers))
+		for _, p := range res.Peers {
+			fmt.Printf("  %s %s outbound %v\n", p.NodeInfo.Moniker, p.NodeInfo.RemoteAddr, p.IsOutbound)
+		}
+	})
+}
@@ -4,11 +4,18 @@
 package client
 
 import (
-	"gopkg.in/urfave/cli.v1"
-	cl "github.com/DelosIsland/core/module/lib/go-rpc/client"
+	"encoding/hex"
 	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+
+	"gopkg.in/urfave/cli.v1"
+
 	"github.com/DelosIsland/core/app/node"
 	"github.com/DelosIsland/core/dngine/types"
+	"github.com/DelosIsland/core/module/lib/go-crypto"
+	"github.com/DelosIsland/core/module/lib/go-wire"
 )
 
 var (
@@ -19,32 +26,40 @@ var (
 		Subcommands: []cli.Command{
 			{
 				Name:   "send",
-				Usage:  "send a transaction",
+				Usage:  "sign a transfer with a keystore key and broadcast it",
 				Action: sendTx,
 				Flags: []cli.Flag{
 					cli.StringFlag{
-						Name:  "addr",
-						Usage: "address",
-					},
-					cli.StringFlag{
-						Name:  "payload",
-						Usage: "payload",
-					},
-					cli.StringFlag{
-						Name:  "privKey",
-						Usage: "privateKey",
+						Name:  "from",
+						Usage: "name of the sender key",
 					},
 					cli.StringFlag{
-						Name:  "dest",
-						Usage: "destAddress",
+						Name:  "to",
+						Usage: "destination address",
 					},
-					cli.StringFlag{
+					cli.Uint64Flag{
 						Name:  "amount",
 						Usage: "amount",
 					},
+					cli.Uint64Flag{
+						Name:  "nonce",
+						Usage: "nonce of the sender account, queried if missing",
+					},
+					cli.BoolFlag{
+						Name:  "commit",
+						Usage: "wait for the tx to be committed",
+					},
+					passphraseFlag,
+				},
+			},
+			{
+				Name:   "get",
+				Usage:  "show a committed tx",
+				Action: getTx,
+				Flags: []cli.Flag{
 					cli.StringFlag{
-						Name:  "chainid",
-						Usage: "chainid",
+						Name:  "hash",
+						Usage: "hex hash of the tx",
 					},
 				},
 			},
@@ -52,29 +67,147 @@ var (
 	}
 )
 
-// command params
-// --backend "tcp://localhost:46657" tx send --addr=source1 --dest=dest1 --amount=1000 --chainid=dngine-test
+type sentTx struct {
+	Hash   []byte         `json:"hash"`
+	Height int            `json:"height,omitempty"` // with --commit
+	Code   types.CodeType `json:"code"`
+	Data   []byte         `json:"data,omitempty"`
+	Log    string         `json:"log"`
+}
+
+// ./client --chainid=dngine-test tx send --from=alice --to=0x... --amount=1000
 func sendTx(ctx *cli.Context) error {
-	destAddr := ctx.String("dest")
-	sourceAddr := ctx.String("addr")
-	amount := ctx.Int64("amount")
-	chainID := ctx.String("chainid")
+	from := ctx.String("from")
+	address, err := keyAddress(ctx, from)
+	if err != nil {
+		return err
+	}
+	if ctx.String("to") == "" || ctx.Uint64("amount") == 0 {
+		return fmt.Errorf("--to and --amount are required")
+	}
+
+	nonce := ctx.Uint64("nonce")
+	if !ctx.IsSet("nonce") {
+		acc, err := queryAccount(ctx, address)
+		if err != nil {
+			return err
+		}
+		nonce = acc.Nonce
+	}
 
+	pass, err := passphrase(ctx, "Passphrase: ")
+	if err != nil {
+		return err
+	}
+	privKey, err := keyStore(ctx).Unlock(from, pass)
+	if err != nil {
+		return err
+	}
+
+	tx, err := signTransfer(privKey, ctx.GlobalString("chainid"), ctx.String("to"), ctx.Uint64("amount"), nonce)
+	if err != nil {
+		return err
+	}
+
+	c, err := rpcClient(ctx)
+	if err != nil {
+		return err
+	}
+	res := sentTx{Hash: types.Tx(tx).Hash()}
+	if ctx.Bool("commit") {
+		r, err := c.BroadcastTxCommit(tx)
+		if err != nil {
+			return err
+		}
+		phase := r.DeliverTx
+		if r.CheckTx.Code != types.CodeType_OK {
+			phase = r.CheckTx
+		}
+		res.Height, res.Code, res.Data, res.Log = r.Height, phase.Code, phase.Data, phase.Log
+	} else {
+		r, err := c.BroadcastTxSync(tx)
+		if err != nil {
+			return err
+		}
+		res.Code, res.Log = r.Code, r.Log
+	}
+
+	if err := output(ctx, res, func() {
+		fmt.Printf("hash: %X\ncode: %v\n", res.Hash, res.Code)
+		if res.Height > 0 {
+			fmt.Printf("height: %d\n", res.Height)
+		}
+		if len(res.Data) > 0 {
+			fmt.Printf("data: %s\n", res.Data)
+		}
+		if res.Log != "" {
+			fmt.Printf("log: %s\n", res.Log)
+		}
+	}); err != nil {
+		return err
+	}
+	if res.Code != types.CodeType_OK {
+		return fmt.Errorf("tx refused: %s", res.Log)
+	}
+	return nil
+}
+
+// signTransfer builds the tagged MyTx moving amount from the account of privKey to the address to
+func signTransfer(privKey crypto.PrivKey, chainID, to string, amount, nonce uint64) ([]byte, error) {
 	myTx := node.MyTx{
-		DestAddress:destAddr,
-		SourceAddress: sourceAddr,
-		Amount: int(amount),
+		ChainID:     chainID,
+		Time:        time.Now(),
+		DestAddress: to,
+		Amount:      amount,
+		Nonce:       nonce,
+	}
+	myTx.SignByPrivKey(privKey)
+	txJSON, err := json.Marshal(myTx)
+	if err != nil {
+		return nil, err
 	}
+	return node.TagMyTx(txJSON), nil
+}
+
+type committedTx struct {
+	node.TxLocation
+	Tx *node.MyTx `json:"tx"`
+}
 
-	txContent, _ := json.Marshal(myTx)
-	txTotal := []byte{'a', 'b', 'c', 'd'}
-	for _, v := range txContent {
-		txTotal = append(txTotal, v)
+// ./client --chainid=dngine-test tx get --hash=<hex>
+func getTx(ctx *cli.Context) error {
+	hash, err := hex.DecodeString(strings.TrimPrefix(ctx.String("hash"), "0x"))
+	if err != nil || len(hash) == 0 {
+		return fmt.Errorf("--hash must be hex")
+	}
+	query, err := queryApp(ctx, node.QueryPathTx, hash)
+	if err != nil {
+		return err
+	}
+	var res committedTx
+	if err := wire.ReadBinaryBytes(query.Value, &res.TxLocation); err != nil {
+		return err
 	}
 
-	clientJSON := cl.NewClientJSONRPC(logger, "tcp://localhost:46657")
-	res := &types.ResultBroadcastTx{}
-	_, err := clientJSON.Call("broadcast_tx_sync", []interface{}{chainID, txTotal}, res)
+	c, err := rpcClient(ctx)
+	if err != nil {
+		return err
+	}
+	result, err := c.Block(int(res.Height))
+	if err != nil {
+		return err
+	}
+	block := result.Block
+	if res.Index >= len(block.Txs) {
+		return fmt.Errorf("tx %X is not in block %d", hash, res.Height)
+	}
+	res.Tx = &node.MyTx{}
+	if err := json.Unmarshal(types.UnwrapTx(block.Txs[res.Index]), res.Tx); err != nil {
+		return err
+	}
 
-	return err
-}
\ No newline at end of file
+	return output(ctx, res, func() {
+		fmt.Printf("height: %d\nindex: %d\n", res.Height, res.Index)
+		fmt.Printf("from pubkey: %X\nto: %s\namount: %d\nnonce: %d\n", res.Tx.PubKey, res.Tx.DestAddress, res.Tx.Amount, res.Tx.Nonce)
+	})
+}
@@ -0,0 +1,81 @@
+// Copyright 2017 Delos Development Foundation and contributors. Licensed
+// under the Apache License, Version 2.0. See the COPYING file at the root
+// of this distribution or at http://www.apache.org/licenses/LICENSE-2.0
+package client
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path"
+	"testing"
+
+	"go.uber.org/zap"
+
+	"github.com/DelosIsland/core/app/node"
+	"github.com/DelosIsland/core/dngine/types"
+	"github.com/DelosIsland/core/module/lib/go-config"
+	"github.com/DelosIsland/core/module/lib/go-crypto/keystore"
+)
+
+// TestSignTransfer signs transfers with keystore keys as tx send does,
+// the app has to accept them from the funded accounts
+func TestSignTransfer(t *testing.T) {
+	dir, err := ioutil.TempDir("", "client_tx")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	ks := keystore.NewKeyStore(path.Join(dir, keystoreDir))
+	accounts := map[string]node.Account{}
+	for _, typ := range []string{keystore.KeyTypeEd25519, keystore.KeyTypeSecp256k1} {
+		info, err := ks.Create(typ, "secret", typ)
+		if err != nil {
+			t.Fatal(err)
+		}
+		accounts[node.AddressFromPubKey(info.PubKey)] = node.Account{Balance: 1000}
+	}
+
+	appState, err := json.Marshal(node.GenesisAppState{Accounts: accounts})
+	if err != nil {
+		t.Fatal(err)
+	}
+	genDoc := &types.GenesisDoc{ChainID: "test-chain", AppState: appState}
+	if genDoc.AppHash, err = node.GenesisAppHash(appState); err != nil {
+		t.Fatal(err)
+	}
+	genFile := path.Join(dir, "genesis.json")
+	if err := genDoc.SaveAs(genFile); err != nil {
+		t.Fatal(err)
+	}
+	app := node.NewMyApp(zap.NewNop(), config.NewMapConfig(map[string]interface{}{
+		"chain_id":         "test-chain",
+		"db_dir":           dir,
+		"genesis_file":     genFile,
+		"rollback_heights": 0,
+	}))
+
+	dest := "0x00112233445566778899aabbccddeeff00112233"
+	for _, typ := range []string{keystore.KeyTypeEd25519, keystore.KeyTypeSecp256k1} {
+		privKey, err := ks.Unlock(typ, "secret")
+		if err != nil {
+			t.Fatal(err)
+		}
+		tx, err := signTransfer(privKey, "test-chain", dest, 10, 0)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := app.CheckTx(tx); err != nil {
+			t.Errorf("%s: expected the signed transfer to be accepted, got %v", typ, err)
+		}
+
+		tx, err = signTransfer(privKey, "other-chain", dest, 10, 0)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if res, ok := app.CheckTx(tx).(types.Result); !ok || res.Log != node.ErrWrongChainID.Error() {
+			t.Errorf("%s: expected a transfer for another chain to be refused, got %v", typ, res)
+		}
+	}
+}
@@ -4,26 +4,49 @@
 package main
 
 import (
-	"gopkg.in/urfave/cli.v1"
+	"fmt"
 	"os"
+
+	"gopkg.in/urfave/cli.v1"
+
 	"github.com/DelosIsland/core/client/commands"
 )
 
 func main() {
 	app := cli.NewApp()
-	app.Name = "tool"
+	app.Name = "client"
+	app.Usage = "manage accounts, send txs and query a node"
 
 	app.Commands = []cli.Command{
 		client.AccountCommands,
 		client.TxCommands,
+		client.QueryCommands,
 	}
 
 	app.Flags = []cli.Flag{
 		cli.StringFlag{
-			Name:        "backend",
-			Value:       "tcp://localhost:46657",
+			Name:  "backend",
+			Value: "tcp://localhost:46657",
+			Usage: "rpc address of the node",
+		},
+		cli.StringFlag{
+			Name:  "chainid",
+			Usage: "chain id of the main chain or of a shard",
+		},
+		cli.StringFlag{
+			Name:  "output",
+			Value: client.OutputText,
+			Usage: "text or json",
+		},
+		cli.StringFlag{
+			Name:  "home",
+			Value: client.DefaultHome(),
+			Usage: "directory of the keystore",
 		},
 	}
 
-	_ = app.Run(os.Args)
+	if err := app.Run(os.Args); err != nil {
+		fmt.Fprintln(os.Stderr, "Error:", err)
+		os.Exit(1)
+	}
 }
@@ -0,0 +1,381 @@
+// Copyright 2017 Delos Development Foundation and contributors. Licensed
+// under the Apache License, Version 2.0. See the COPYING file at the root
+// of this distribution or at http://www.apache.org/licenses/LICENSE-2.0
+
+// Package sdk is a typed client of the node rpc.
+// A Client talks to one chain of a node, every call carries its chain id.
+package sdk
+
+import (
+	"context"
+	"crypto/tls"
+	"fmt"
+	"net"
+	"net/url"
+	"reflect"
+	"time"
+
+	"go.uber.org/zap"
+
+	"github.com/DelosIsland/core/dngine/types"
+	"github.com/DelosIsland/core/module/lib/go-crypto"
+	rpcclient "github.com/DelosIsland/core/module/lib/go-rpc/client"
+)
+
+const (
+	// DefaultTimeout bounds a call, retries included
+	DefaultTimeout = 10 * time.Second
+	// DefaultCommitTimeout bounds broadcast_tx_commit, the node itself waits timeout_broadcast_tx_commit, 2 minutes by default
+	DefaultCommitTimeout = 150 * time.Second
+	// DefaultRetries is how many times a call failing to reach the node is tried again
+	DefaultRetries = 2
+	// DefaultRetryInterval is the wait before trying again
+	DefaultRetryInterval = 500 * time.Millisecond
+)
+
+// methods which must not be sent twice once the node may have received them,
+// they are only retried when the connection could not be made
+var notIdempotent = map[string]bool{
+	"broadcast_tx_commit":  true,
+	"broadcast_tx_sync":    true,
+	"request_special_op":   true,
+	"dial_seeds":           true,
+	"unsafe_dial_peers":    true,
+	"unsafe_flush_mempool": true,
+}
+
+type Client struct {
+	logger  *zap.Logger
+	remote  string
+	chainID string
+	rpc     *rpcclient.ClientJSONRPC
+
+	authToken string
+	tlsConfig *tls.Config
+
+	timeout       time.Duration
+	commitTimeout time.Duration
+	retries       int
+	retryInterval time.Duration
+}
+
+// NewClient returns a client of the chain chainID served by the node at remote, e.g. tcp://127.0.0.1:46657
+func NewClient(logger *zap.Logger, remote, chainID string) *Client {
+	return &Client{
+		logger:        logger,
+		remote:        remote,
+		chainID:       chainID,
+		rpc:           rpcclient.NewClientJSONRPC(logger, remote),
+		timeout:       DefaultTimeout,
+		commitTimeout: DefaultCommitTimeout,
+		retries:       DefaultRetries,
+		retryInterval: DefaultRetryInterval,
+	}
+}
+
+func (c *Client) ChainID() string {
+	return c.chainID
+}
+
+func (c *Client) Remote() string {
+	return c.remote
+}
+
+// WithChainID returns a client of another chain of the same node, e.g. a shard,
+// sharing the connection settings.
+func (c *Client) WithChainID(chainID string) *Client {
+	shard := *c
+	shard.chainID = chainID
+	return &shard
+}
+
+// SetAuthToken sends the API token with every request
+func (c *Client) SetAuthToken(token string) {
+	c.authToken = token
+	c.rpc.SetAuthToken(token)
+}
+
+// SetSigner signs every request with the key
+func (c *Client) SetSigner(privKey crypto.PrivKeyEd25519) {
+	c.rpc.SetSigner(privKey)
+}
+
+// SetTLSConfig is used for https:// remotes
+func (c *Client) SetTLSConfig(tlsConfig *tls.Config) {
+	c.tlsConfig = tlsConfig
+	c.rpc.SetTLSConfig(tlsConfig)
+}
+
+// SetTimeout bounds every call but broadcast_tx_commit, 0 means no limit
+func (c *Client) SetTimeout(timeout time.Duration) {
+	c.timeout = timeout
+}
+
+// SetCommitTimeout bounds broadcast_tx_commit, 0 means no limit
+func (c *Client) SetCommitTimeout(timeout time.Duration) {
+	c.commitTimeout = timeout
+}
+
+// SetRetries sets how many times and how often a failed call is tried again
+func (c *Client) SetRetries(retries int, interval time.Duration) {
+	c.retries, c.retryInterval = retries, interval
+}
+
+// Call runs method with the chain id prepended to params.
+// Errors of the node are *rpctypes.RPCError, types.AppErrorData tells the code of a refused tx.
+func (c *Client) Call(method string, params ...interface{}) (types.RPCResult, error) {
+	return c.call(method, append([]interface{}{c.chainID}, params...))
+}
+
+func (c *Client) call(method string, params []interface{}) (types.RPCResult, error) {
+	timeout := c.timeout
+	if method == "broadcast_tx_commit" {
+		timeout = c.commitTimeout
+	}
+	ctx := context.Background()
+	if timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, timeout)
+		defer cancel()
+	}
+
+	for attempt := 0; ; attempt++ {
+		tmResult := new(types.RPCResult)
+		_, err := c.rpc.CallContext(ctx, method, params, tmResult)
+		if err == nil {
+			return *tmResult, nil
+		}
+		if attempt >= c.retries || !retryable(method, err) {
+			return nil, err
+		}
+		c.logger.Debug("retrying rpc call", zap.String("method", method), zap.String("error", err.Error()))
+		select {
+		case <-ctx.Done():
+			return nil, err
+		case <-time.After(c.retryInterval):
+		}
+	}
+}
+
+// retryable tells whether err comes from the transport rather than from the node,
+// calls with side effects are retried only if they could not be sent at all.
+func retryable(method string, err error) bool {
+	urlErr, ok := err.(*url.Error)
+	if !ok {
+		return false
+	}
+	if opErr, ok := urlErr.Err.(*net.OpError); ok && opErr.Op == "dial" {
+		return true
+	}
+	return !notIdempotent[method] && !urlErr.Timeout()
+}
+
+// callInto runs method and stores its result in the pointer result,
+// whose element type must be the result type of the route.
+func (c *Client) callInto(result interface{}, method string, params ...interface{}) error {
+	res, err := c.Call(method, params...)
+	if err != nil {
+		return err
+	}
+	return setResult(result, method, res)
+}
+
+func setResult(result interface{}, method string, res types.RPCResult) error {
+	rv := reflect.ValueOf(result).Elem()
+	v := reflect.ValueOf(res)
+	if !v.IsValid() || !v.Type().AssignableTo(rv.Type()) {
+		return fmt.Errorf("unexpected %s result %T", method, res)
+	}
+	rv.Set(v)
+	return nil
+}
+
+//----------------------------------------
+// info API
+
+// Shards lists the chains running on the node, it is the only call without chain id
+func (c *Client) Shards() (*types.ResultShards, error) {
+	res, err := c.call("shards", []interface{}{})
+	if err != nil {
+		return nil, err
+	}
+	var shards *types.ResultShards
+	err = setResult(&shards, "shards", res)
+	return shards, err
+}
+
+func (c *Client) Status() (*types.ResultStatus, error) {
+	var res *types.ResultStatus
+	err := c.callInto(&res, "status")
+	return res, err
+}
+
+func (c *Client) NetInfo() (*types.ResultNetInfo, error) {
+	var res *types.ResultNetInfo
+	err := c.callInto(&res, "net_info")
+	return res, err
+}
+
+func (c *Client) P2PMetrics() (*types.ResultP2PMetrics, error) {
+	var res *types.ResultP2PMetrics
+	err := c.callInto(&res, "p2p_metrics")
+	return res, err
+}
+
+// Blockchain returns the metas of the blocks from minHeight to maxHeight, 0 picks the last 20
+func (c *Client) Blockchain(minHeight, maxHeight int) (*types.ResultBlockchainInfo, error) {
+	var res *types.ResultBlockchainInfo
+	err := c.callInto(&res, "blockchain", minHeight, maxHeight)
+	return res, err
+}
+
+func (c *Client) Genesis() (*types.ResultGenesis, error) {
+	var res *types.ResultGenesis
+	err := c.callInto(&res, "genesis")
+	return res, err
+}
+
+func (c *Client) Block(height int) (*types.ResultBlock, error) {
+	var res *types.ResultBlock
+	err := c.callInto(&res, "block", height)
+	return res, err
+}
+
+func (c *Client) Validators() (*types.ResultValidators, error) {
+	var res *types.ResultValidators
+	err := c.callInto(&res, "validators")
+	return res, err
+}
+
+func (c *Client) DumpConsensusState() (*types.ResultDumpConsensusState, error) {
+	var res *types.ResultDumpConsensusState
+	err := c.callInto(&res, "dump_consensus_state")
+	return res, err
+}
+
+func (c *Client) UnconfirmedTxs() (*types.ResultUnconfirmedTxs, error) {
+	var res *types.ResultUnconfirmedTxs
+	err := c.callInto(&res, "unconfirmed_txs")
+	return res, err
+}
+
+func (c *Client) NumUnconfirmedTxs() (*types.ResultUnconfirmedTxs, error) {
+	var res *types.ResultUnconfirmedTxs
+	err := c.callInto(&res, "num_unconfirmed_txs")
+	return res, err
+}
+
+func (c *Client) ZaSurveillance() (*types.ResultSurveillance, error) {
+	var res *types.ResultSurveillance
+	err := c.callInto(&res, "za_surveillance")
+	return res, err
+}
+
+func (c *Client) CoreVersion() (*types.ResultCoreVersion, error) {
+	var res *types.ResultCoreVersion
+	err := c.callInto(&res, "core_version")
+	return res, err
+}
+
+func (c *Client) Blacklist() (*types.ResultRefuseList, error) {
+	var res *types.ResultRefuseList
+	err := c.callInto(&res, "blacklist")
+	return res, err
+}
+
+//----------------------------------------
+// broadcast API
+
+// BroadcastTxSync returns once the tx is in the mempool
+func (c *Client) BroadcastTxSync(tx []byte) (*types.ResultBroadcastTx, error) {
+	var res *types.ResultBroadcastTx
+	err := c.callInto(&res, "broadcast_tx_sync", tx)
+	return res, err
+}
+
+// BroadcastTxCommit returns once the tx is committed, or refused by CheckTx.
+// A tx which was committed but failed has a DeliverTx code.
+func (c *Client) BroadcastTxCommit(tx []byte) (*types.ResultBroadcastTxCommit, error) {
+	var res *types.ResultBroadcastTxCommit
+	err := c.callInto(&res, "broadcast_tx_commit", tx)
+	return res, err
+}
+
+//----------------------------------------
+// query API
+
+// Query runs an application query, height 0 is the last committed state
+func (c *Client) Query(path string, data []byte, height uint64, prove bool) (*types.ResultQuery, error) {
+	var res *types.ResultQuery
+	err := c.callInto(&res, "query", path, data, height, prove)
+	return res, err
+}
+
+// QueryApp is Query on the last committed state without proof,
+// a response with an error code is returned as an error.
+func (c *Client) QueryApp(path string, data []byte) (types.ResponseQuery, error) {
+	res, err := c.Query(path, data, 0, false)
+	if err != nil {
+		return types.ResponseQuery{}, err
+	}
+	if res.Result.IsErr() {
+		return res.Result, fmt.Errorf("%s", res.Result.Log)
+	}
+	return res.Result, nil
+}
+
+func (c *Client) Info() (*types.ResultInfo, error) {
+	var res *types.ResultInfo
+	err := c.callInto(&res, "info")
+	return res, err
+}
+
+//----------------------------------------
+// control API
+
+func (c *Client) DialSeeds(seeds []string) (*types.ResultDialSeeds, error) {
+	var res *types.ResultDialSeeds
+	err := c.callInto(&res, "dial_seeds", seeds)
+	return res, err
+}
+
+func (c *Client) DialPeers(peers []string, persistent bool) (*types.ResultDialPeers, error) {
+	var res *types.ResultDialPeers
+	err := c.callInto(&res, "unsafe_dial_peers", peers, persistent)
+	return res, err
+}
+
+func (c *Client) FlushMempool() (*types.ResultUnsafeFlushMempool, error) {
+	var res *types.ResultUnsafeFlushMempool
+	err := c.callInto(&res, "unsafe_flush_mempool")
+	return res, err
+}
+
+// UnbanPeer lifts the temporary ban of the peer with the key listed in NetInfo
+func (c *Client) UnbanPeer(key string) (*types.ResultUnsafeUnbanPeer, error) {
+	var res *types.ResultUnsafeUnbanPeer
+	err := c.callInto(&res, "unsafe_unban_peer", key)
+	return res, err
+}
+
+//----------------------------------------
+// specialOP API
+
+func (c *Client) RequestSpecialOP(tx []byte) (*types.ResultRequestSpecialOP, error) {
+	var res *types.ResultRequestSpecialOP
+	err := c.callInto(&res, "request_special_op", tx)
+	return res, err
+}
+
+// VoteSpecialOP asks a validator for its vote on a special op,
+// the vote is returned only if the validator accepted the op.
+func (c *Client) VoteSpecialOP(tx []byte) ([]byte, error) {
+	var res *types.ResultRequestSpecialOP
+	if err := c.callInto(&res, "vote_special_op", tx); err != nil {
+		return nil, err
+	}
+	if res.Code != types.CodeType_OK {
+		return nil, fmt.Errorf("%s", res.Log)
+	}
+	return res.Data, nil
+}
@@ -0,0 +1,88 @@
+package sdk
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"go.uber.org/zap"
+
+	"github.com/DelosIsland/core/dngine/types"
+	rpcserver "github.com/DelosIsland/core/module/lib/go-rpc/server"
+	rpctypes "github.com/DelosIsland/core/module/lib/go-rpc/types"
+)
+
+// newTestNode serves status results, the first failing requests drop the connection
+func newTestNode(t *testing.T, failures int32) (*httptest.Server, *int32) {
+	var calls int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if atomic.AddInt32(&calls, 1) <= failures {
+			conn, _, _ := w.(http.Hijacker).Hijack()
+			conn.Close()
+			return
+		}
+		body, _ := ioutil.ReadAll(r.Body)
+		var req rpctypes.RPCRequest
+		if err := json.Unmarshal(body, &req); err != nil {
+			t.Error(err)
+		}
+		if params, _ := req.Params.([]interface{}); len(params) == 0 || params[0] != "test-chain" {
+			rpcserver.WriteRPCResponseHTTP(w, rpctypes.NewRPCErrorResponse(req.ID, rpctypes.NewRPCError(rpctypes.CodeInvalidParams, "no such chain id")))
+			return
+		}
+		var res types.RPCResult = &types.ResultStatus{LatestBlockHeight: 5}
+		rpcserver.WriteRPCResponseHTTP(w, rpctypes.NewRPCResponse(req.ID, &res))
+	}))
+	return server, &calls
+}
+
+func newTestClient(server *httptest.Server, chainID string) *Client {
+	c := NewClient(zap.NewNop(), "tcp://"+strings.TrimPrefix(server.URL, "http://"), chainID)
+	c.SetRetries(DefaultRetries, time.Millisecond)
+	return c
+}
+
+func TestClientStatus(t *testing.T) {
+	server, _ := newTestNode(t, 0)
+	defer server.Close()
+
+	res, err := newTestClient(server, "test-chain").Status()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if res.LatestBlockHeight != 5 {
+		t.Errorf("Expected height 5, got %d", res.LatestBlockHeight)
+	}
+
+	if _, err := newTestClient(server, "other-chain").Status(); err == nil {
+		t.Error("Expected the node error for an unknown chain")
+	}
+	if _, err := newTestClient(server, "test-chain").Block(1); err == nil {
+		t.Error("Expected a result of the wrong type to be an error")
+	}
+}
+
+func TestClientRetries(t *testing.T) {
+	server, calls := newTestNode(t, DefaultRetries)
+	defer server.Close()
+
+	if _, err := newTestClient(server, "test-chain").Status(); err != nil {
+		t.Fatalf("Expected status to be retried, got %v", err)
+	}
+	if n := atomic.LoadInt32(calls); n != DefaultRetries+1 {
+		t.Errorf("Expected %d calls, got %d", DefaultRetries+1, n)
+	}
+
+	atomic.StoreInt32(calls, 0)
+	if _, err := newTestClient(server, "test-chain").BroadcastTxSync([]byte("tx")); err == nil {
+		t.Error("Expected a broadcast sent once to fail")
+	}
+	if n := atomic.LoadInt32(calls); n != 1 {
+		t.Errorf("Expected a broadcast not to be retried, got %d calls", n)
+	}
+}
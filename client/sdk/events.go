@@ -0,0 +1,113 @@
+// Copyright 2017 Delos Development Foundation and contributors. Licensed
+// under the Apache License, Version 2.0. See the COPYING file at the root
+// of this distribution or at http://www.apache.org/licenses/LICENSE-2.0
+package sdk
+
+import (
+	"fmt"
+	"net/http"
+
+	"github.com/DelosIsland/core/dngine/types"
+	rpcclient "github.com/DelosIsland/core/module/lib/go-rpc/client"
+	"github.com/DelosIsland/core/module/lib/go-wire"
+)
+
+const (
+	websocketEndpoint = "/websocket"
+
+	eventsChannelCapacity = 100
+)
+
+// EventClient receives the events of a chain over the node websocket,
+// e.g. types.EventStringNewBlock() or types.EventStringTx(tx).
+type EventClient struct {
+	ws      *rpcclient.WSClient
+	chainID string
+
+	events chan *types.ResultEvent
+	errors chan error
+}
+
+// Events opens a websocket to the node, Subscribe then chooses the events.
+// Only the auth token is sent with the handshake, signed requests are not supported.
+func (c *Client) Events() (*EventClient, error) {
+	ws := rpcclient.NewWSClient(c.logger, c.remote, websocketEndpoint)
+	if c.authToken != "" {
+		ws.Header = http.Header{"Authorization": []string{"Bearer " + c.authToken}}
+	}
+	if _, err := ws.Start(); err != nil {
+		return nil, err
+	}
+	ec := &EventClient{
+		ws:      ws,
+		chainID: c.chainID,
+		events:  make(chan *types.ResultEvent, eventsChannelCapacity),
+		errors:  make(chan error, 1),
+	}
+	go ec.receiveRoutine()
+	return ec, nil
+}
+
+func (ec *EventClient) Subscribe(event string) error {
+	return ec.ws.Call("subscribe", []interface{}{ec.chainID, event})
+}
+
+func (ec *EventClient) Unsubscribe(event string) error {
+	return ec.ws.Call("unsubscribe", []interface{}{ec.chainID, event})
+}
+
+// EventsCh delivers the subscribed events, it is closed with the connection
+func (ec *EventClient) EventsCh() <-chan *types.ResultEvent {
+	return ec.events
+}
+
+// ErrorsCh delivers the errors returned by the node, e.g. a refused subscription.
+// Errors are dropped while the previous one is unread.
+func (ec *EventClient) ErrorsCh() <-chan error {
+	return ec.errors
+}
+
+// Close the connection, the subscriptions go with it
+func (ec *EventClient) Close() {
+	ec.ws.Stop()
+}
+
+func (ec *EventClient) receiveRoutine() {
+	defer close(ec.events)
+	defer close(ec.errors)
+
+	results, errs := ec.ws.ResultsCh, ec.ws.ErrorsCh
+	for results != nil || errs != nil {
+		select {
+		case raw, ok := <-results:
+			if !ok {
+				results = nil
+				continue
+			}
+			var err error
+			res := new(types.RPCResult)
+			wire.ReadJSONPtr(res, raw, &err)
+			if err != nil {
+				ec.sendError(fmt.Errorf("Error unmarshalling event: %v", err))
+				continue
+			}
+			// subscribe and unsubscribe acknowledgements are skipped
+			if event, ok := (*res).(*types.ResultEvent); ok {
+				ec.events <- event
+			}
+		case err, ok := <-errs:
+			if !ok {
+				errs = nil
+				continue
+			}
+			ec.sendError(err)
+		}
+	}
+}
+
+func (ec *EventClient) sendError(err error) {
+	select {
+	case ec.errors <- err:
+	default:
+	}
+}
@@ -12,8 +12,10 @@ import (
 	"path"
 	"strings"
 	"sync"
+	"sync/atomic"
 	"time"
 
+	"github.com/prometheus/client_golang/prometheus"
 	"go.uber.org/zap"
 
 	"github.com/DelosIsland/core/dngine/blockchain"
@@ -28,21 +30,35 @@ import (
 	cmn "github.com/DelosIsland/core/module/lib/go-common"
 	cfg "github.com/DelosIsland/core/module/lib/go-config"
 	crypto "github.com/DelosIsland/core/module/lib/go-crypto"
+	"github.com/DelosIsland/core/module/lib/go-crypto/keystore"
 	dbm "github.com/DelosIsland/core/module/lib/go-db"
-	"github.com/DelosIsland/core/module/lib/go-events"
 	p2p "github.com/DelosIsland/core/module/lib/go-p2p"
+	"github.com/DelosIsland/core/module/lib/go-pubsub"
 	"github.com/DelosIsland/core/module/lib/go-wire"
 )
 
 const version = "0.6.0"
 
+// ErrBroadcastTxTimeout is returned when a tx accepted by the mempool wasn't committed in time
+var ErrBroadcastTxTimeout = errors.New("timed out waiting for the tx to be committed")
+
+// ErrBroadcastTxCanceled is returned when the wait for a tx accepted by the mempool ended early,
+// like when the event bus drops the subscription
+var ErrBroadcastTxCanceled = errors.New("stopped waiting for the tx to be committed")
+
+// ValidatorPassphraseEnv holds the passphrase of the priv_validator_key
+const ValidatorPassphraseEnv = "ANN_VALIDATOR_PASSPHRASE"
+
 type (
 	// Dngine is a high level abstraction of all the state, consensus, mempool blah blah...
 	Dngine struct {
-		mtx     sync.Mutex
-		tune    *DngineTunes
-		hooked  bool
-		started bool
+		txCommitWaiters uint64 // atomic, first to be 64-bit aligned
+
+		mtx      sync.Mutex
+		tune     *DngineTunes
+		hooked   bool
+		started  bool
+		seedMode bool
 
 		statedb       dbm.DB
 		blockdb       dbm.DB
@@ -50,10 +66,15 @@ type (
 		blockstore    *blockchain.BlockStore
 		mempool       *mempool.Mempool
 		consensus     *consensus.ConsensusState
+		bcReactor     *blockchain.BlockchainReactor
+		conReactor    *consensus.ConsensusReactor
 		stateMachine  *state.State
 		p2pSwitch     *p2p.Switch
 		eventSwitch   *types.EventSwitch
+		eventBus      *types.EventBus
 		refuseList    *refuse_list.RefuseList
+		metrics       *metrics
+		registry      *prometheus.Registry
 		p2pHost       string
 		p2pPort       uint16
 		genesis       *types.GenesisDoc
@@ -114,7 +135,8 @@ func NewDngine(tune *DngineTunes) *Dngine {
 	apphash := []byte{}
 	dbBackend := conf.GetString("db_backend")
 	dbDir := conf.GetString("db_dir")
-	stateDB := dbm.NewDB("state", dbBackend, dbDir)
+	dngineMetrics := newMetrics()
+	stateDB := newMeteredDB(dbm.NewDB("state", dbBackend, dbDir), "state", dngineMetrics.dbWriteDuration)
 	stateM := state.GetState(conf, stateDB)
 	genesis := getGenesisFileMust(conf)
 	if stateM == nil {
@@ -122,6 +144,7 @@ func NewDngine(tune *DngineTunes) *Dngine {
 			cmn.Exit(cmn.Fmt("Fail to get genesis state"))
 		}
 	}
+	stateM.SetRollbackHeights(conf.GetInt("rollback_heights"))
 	conf.Set("chain_id", stateM.ChainID)
 
 	logpath := conf.GetString("log_path")
@@ -132,54 +155,95 @@ func NewDngine(tune *DngineTunes) *Dngine {
 	cmn.EnsureDir(logpath, 0700)
 	logger := InitializeLog(conf.GetString("environment"), logpath)
 	stateM.SetLogger(logger)
-	privValidator := types.LoadOrGenPrivValidator(logger, conf.GetString("priv_validator_file"))
+	privValidator := loadPrivValidator(logger, conf)
 	refuseList := refuse_list.NewRefuseList(dbBackend, dbDir)
-	eventSwitch := types.NewEventSwitch(logger)
+	eventBus := types.NewEventBus(logger)
+	eventSwitch := eventBus.EventSwitch()
 	fastSync := fastSyncable(conf, privValidator.GetAddress(), stateM.Validators)
 	if _, err := eventSwitch.Start(); err != nil {
 		cmn.PanicSanity(cmn.Fmt("Fail to start event switch: %v", err))
 	}
 
-	blockStoreDB := dbm.NewDB("blockstore", dbBackend, dbDir)
+	blockStoreDB := newMeteredDB(dbm.NewDB("blockstore", dbBackend, dbDir), "blockstore", dngineMetrics.dbWriteDuration)
 	blockStore := blockchain.NewBlockStore(blockStoreDB)
 	if block := blockStore.LoadBlock(blockStore.Height()); block != nil {
 		apphash = block.AppHash
 	}
 	_ = apphash // just bypass golint
-	_, stateLastHeight, _ := stateM.GetLastBlockInfo()
-	bcReactor := blockchain.NewBlockchainReactor(logger, conf, stateLastHeight, blockStore, fastSync)
-	mem := mempool.NewMempool(logger, conf)
-	for _, p := range stateM.Plugins {
-		mem.RegisterFilter(NewMempoolFilter(p.CheckTx))
-	}
-	memReactor := mempool.NewMempoolReactor(logger, conf, mem)
-
-	consensusState := consensus.NewConsensusState(logger, conf, stateM, blockStore, mem)
-	consensusState.SetPrivValidator(privValidator)
-	consensusReactor := consensus.NewConsensusReactor(logger, consensusState, fastSync)
 
-	bcReactor.SetBlockVerifier(func(bID types.BlockID, h int, lc *types.Commit) error {
-		return stateM.Validators.VerifyCommit(stateM.ChainID, bID, h, lc)
-	})
-	bcReactor.SetBlockExecuter(func(blk *types.Block, pst *types.PartSet, c *types.Commit) error {
-		blockStore.SaveBlock(blk, pst, c)
-		if err := stateM.ApplyBlock(eventSwitch, blk, pst.Header(), MockMempool{}, -1); err != nil {
-			return err
+	// every metric of the chain carries its id, the shards of a node are gathered together
+	registry := prometheus.NewRegistry()
+	registerer := prometheus.WrapRegistererWith(prometheus.Labels{"chain_id": stateM.ChainID}, registry)
+	registerer.MustRegister(dngineMetrics.hookDuration, dngineMetrics.dbWriteDuration)
+
+	// a seed only crawls the network and hands out addresses,
+	// it never syncs blocks nor takes part in consensus
+	seedMode := conf.GetBool("seed_mode")
+	var (
+		bcReactor        *blockchain.BlockchainReactor
+		mem              *mempool.Mempool
+		memReactor       *mempool.MempoolReactor
+		consensusState   *consensus.ConsensusState
+		consensusReactor *consensus.ConsensusReactor
+	)
+	if !seedMode {
+		_, stateLastHeight, _ := stateM.GetLastBlockInfo()
+		bcReactor = blockchain.NewBlockchainReactor(logger, conf, stateLastHeight, blockStore, fastSync)
+		mem = mempool.NewMempool(logger, conf)
+		mem.SetMetrics(mempool.NewMetrics(registerer))
+		for _, p := range stateM.Plugins {
+			mem.RegisterFilter(NewMempoolFilter(p.CheckTx))
 		}
-		stateM.Save()
-		return nil
-	})
+		memReactor = mempool.NewMempoolReactor(logger, conf, mem)
+
+		consensusState = consensus.NewConsensusState(logger, conf, stateM, blockStore, mem)
+		consensusState.SetPrivValidator(privValidator)
+		consensusState.SetMetrics(consensus.NewMetrics(registerer))
+		consensusReactor = consensus.NewConsensusReactor(logger, consensusState, fastSync)
+
+		bcReactor.SetMetrics(blockchain.NewMetrics(registerer))
+		chainID := stateM.ChainID
+		bcReactor.SetBlockVerifier(func(valSet *types.ValidatorSet, bID types.BlockID, h int, lc *types.Commit) error {
+			return valSet.VerifyCommit(chainID, bID, h, lc)
+		})
+		bcReactor.SetValidators(func() *types.ValidatorSet {
+			return stateM.Validators.Copy()
+		})
+		bcReactor.SetBlockExecuter(func(blk *types.Block, pst *types.PartSet, c *types.Commit, lastCommitVerified bool) error {
+			// a refused block is requested again, nothing is saved before it is validated
+			validate := stateM.ValidateBlock
+			if lastCommitVerified {
+				validate = stateM.ValidateBlockVerified
+			}
+			if err := validate(blk); err != nil {
+				return err
+			}
+			// and neither is a block the app fails to execute
+			err := stateM.ApplyValidatedBlock(eventSwitch, blk, pst.Header(), MockMempool{}, -1, func() {
+				blockStore.SaveBlock(blk, pst, c)
+			})
+			if err != nil {
+				return err
+			}
+			stateM.Save()
+			return nil
+		})
+	}
 
 	privKey := privValidator.GetPrivateKey()
 	p2psw := p2p.NewSwitch(logger, conf.GetConfig("p2p"))
-	p2psw.AddReactor("MEMPOOL", memReactor)
-	p2psw.AddReactor("BLOCKCHAIN", bcReactor)
-	p2psw.AddReactor("CONSENSUS", consensusReactor)
+	registerer.MustRegister(newP2PCollector(p2psw))
+	if !seedMode {
+		p2psw.AddReactor("MEMPOOL", memReactor)
+		p2psw.AddReactor("BLOCKCHAIN", bcReactor)
+		p2psw.AddReactor("CONSENSUS", consensusReactor)
+	}
 
-	if conf.GetBool("pex_reactor") {
+	if seedMode || conf.GetBool("pex_reactor") {
 		addrBook := p2p.NewAddrBook(logger, conf.GetString("addrbook_file"), conf.GetBool("addrbook_strict"))
 		addrBook.Start()
 		pexReactor := p2p.NewPEXReactor(logger, addrBook)
+		pexReactor.SetSeedMode(seedMode)
 		p2psw.AddReactor("PEX", pexReactor)
 	}
 
@@ -200,21 +264,29 @@ func NewDngine(tune *DngineTunes) *Dngine {
 	}
 	p2psw.SetNodeInfo(dngineNodeInfo)
 
-	setEventSwitch(eventSwitch, bcReactor, memReactor, consensusReactor)
-	initCorePlugins(stateM, privKey.(crypto.PrivKeyEd25519), p2psw, &stateM.Validators, refuseList)
+	if !seedMode {
+		setEventSwitch(eventSwitch, bcReactor, memReactor, consensusReactor)
+		initCorePlugins(stateM, privKey.(crypto.PrivKeyEd25519), p2psw, &stateM.Validators, refuseList)
+	}
 
 	return &Dngine{
+		seedMode:      seedMode,
 		statedb:       stateDB,
 		blockdb:       blockStoreDB,
 		tune:          tune,
 		stateMachine:  stateM,
 		p2pSwitch:     p2psw,
 		eventSwitch:   &eventSwitch,
+		eventBus:      eventBus,
 		refuseList:    refuseList,
+		metrics:       dngineMetrics,
+		registry:      registry,
 		privValidator: privValidator,
 		blockstore:    blockStore,
 		mempool:       mem,
 		consensus:     consensusState,
+		bcReactor:     bcReactor,
+		conReactor:    consensusReactor,
 		p2pHost:       defaultListener.ExternalAddress().IP.String(),
 		p2pPort:       defaultListener.ExternalAddress().Port,
 		genesis:       genesis,
@@ -240,7 +312,9 @@ func (e *Dngine) ConnectApp(app types.Application) {
 			data.ResCh <- types.NewRoundResult{}
 			return
 		}
+		start := time.Now()
 		hooks.OnNewRound.Sync(data.Height, data.Round, nil)
+		e.metrics.observeHook("new_round", start)
 		result := hooks.OnNewRound.Result()
 		if r, ok := result.(types.NewRoundResult); ok {
 			data.ResCh <- r
@@ -251,30 +325,38 @@ func (e *Dngine) ConnectApp(app types.Application) {
 	if hooks.OnPropose != nil {
 		types.AddListenerForEvent(*e.eventSwitch, "dngine", types.EventStringHookPropose(), func(ed types.TMEventData) {
 			data := ed.(types.EventDataHookPropose)
-			hooks.OnPropose.Async(data.Height, data.Round, nil, nil, nil)
+			start := time.Now()
+			done := func(interface{}) { e.metrics.observeHook("propose", start) }
+			hooks.OnPropose.Async(data.Height, data.Round, nil, done, func(error) { done(nil) })
 		})
 	}
 	if hooks.OnPrevote != nil {
 		types.AddListenerForEvent(*e.eventSwitch, "dngine", types.EventStringHookPrevote(), func(ed types.TMEventData) {
 			data := ed.(types.EventDataHookPrevote)
-			hooks.OnPrevote.Async(data.Height, data.Round, data.Block, nil, nil)
+			start := time.Now()
+			done := func(interface{}) { e.metrics.observeHook("prevote", start) }
+			hooks.OnPrevote.Async(data.Height, data.Round, data.Block, done, func(error) { done(nil) })
 		})
 	}
 	if hooks.OnPrecommit != nil {
 		types.AddListenerForEvent(*e.eventSwitch, "dngine", types.EventStringHookPrecommit(), func(ed types.TMEventData) {
 			data := ed.(types.EventDataHookPrecommit)
-			hooks.OnPrecommit.Async(data.Height, data.Round, data.Block, nil, nil)
+			start := time.Now()
+			done := func(interface{}) { e.metrics.observeHook("precommit", start) }
+			hooks.OnPrecommit.Async(data.Height, data.Round, data.Block, done, func(error) { done(nil) })
 		})
 	}
 	types.AddListenerForEvent(*e.eventSwitch, "dngine", types.EventStringHookExecute(), func(ed types.TMEventData) {
 		data := ed.(types.EventDataHookExecute)
-		hooks.OnExecute.Sync(data.Height, data.Round, data.Block)
+		start := time.Now()
+		_, err := hooks.OnExecute.Sync(data.Height, data.Round, data.Block)
+		e.metrics.observeHook("execute", start)
 		result := hooks.OnExecute.Result()
-		if r, ok := result.(types.ExecuteResult); ok {
-			data.ResCh <- r
-		} else {
-			data.ResCh <- types.ExecuteResult{}
+		r, _ := result.(types.ExecuteResult)
+		if r.Error == nil {
+			r.Error = err
 		}
+		data.ResCh <- r
 
 	})
 	types.AddListenerForEvent(*e.eventSwitch, "dngine", types.EventStringHookCommit(), func(ed types.TMEventData) {
@@ -283,7 +365,9 @@ func (e *Dngine) ConnectApp(app types.Application) {
 			data.ResCh <- types.CommitResult{}
 			return
 		}
+		start := time.Now()
 		hooks.OnCommit.Sync(data.Height, data.Round, data.Block)
+		e.metrics.observeHook("commit", start)
 		result := hooks.OnCommit.Result()
 		if cs, ok := result.(types.CommitResult); ok {
 			data.ResCh <- cs
@@ -302,10 +386,31 @@ func (e *Dngine) PrivValidator() *types.PrivValidator {
 	return e.privValidator
 }
 
+// EventSwitch is where the consensus, mempool and block events are fired
+func (e *Dngine) EventSwitch() types.EventSwitch {
+	return *e.eventSwitch
+}
+
+// EventBus delivers the events fired on the EventSwitch to the subscriptions matching them
+func (e *Dngine) EventBus() *types.EventBus {
+	return e.eventBus
+}
+
+// Metrics gathers the metrics of the chain, labelled with its chain_id
+func (e *Dngine) Metrics() prometheus.Gatherer {
+	return e.registry
+}
+
 func (e *Dngine) Genesis() *types.GenesisDoc {
 	return e.genesis
 }
 
+// IsSeed tells whether the dngine runs as a seed node,
+// in which case there is no mempool, consensus nor block sync.
+func (e *Dngine) IsSeed() bool {
+	return e.seedMode
+}
+
 func (e *Dngine) P2PHost() string {
 	return e.p2pHost
 }
@@ -314,8 +419,28 @@ func (e *Dngine) P2PPort() uint16 {
 	return e.p2pPort
 }
 
-func (e *Dngine) DialSeeds(seeds []string) {
-	e.p2pSwitch.DialSeeds(seeds)
+func (e *Dngine) DialSeeds(seeds []string) error {
+	return e.p2pSwitch.DialSeeds(seeds)
+}
+
+// DialPeers dials the given peers once, or keeps redialing them
+// whenever the connection drops if persistent is set
+func (e *Dngine) DialPeers(peers []string, persistent bool) error {
+	if persistent {
+		return e.p2pSwitch.DialPersistentPeers(peers)
+	}
+	addrs := make([]*p2p.NetAddress, 0, len(peers))
+	for _, p := range peers {
+		addr, err := p2p.ParseNetAddress(strings.TrimSpace(p))
+		if err != nil {
+			return err
+		}
+		addrs = append(addrs, addr)
+	}
+	for _, addr := range addrs {
+		go e.p2pSwitch.DialPeerWithAddress(addr)
+	}
+	return nil
 }
 
 func (e *Dngine) Start() error {
@@ -335,7 +460,15 @@ func (e *Dngine) Start() error {
 
 	seeds := e.tune.Conf.GetString("seeds")
 	if seeds != "" {
-		e.DialSeeds(strings.Split(seeds, ","))
+		if err := e.DialSeeds(strings.Split(seeds, ",")); err != nil {
+			return err
+		}
+	}
+	persistentPeers := e.tune.Conf.GetString("persistent_peers")
+	if persistentPeers != "" {
+		if err := e.DialPeers(strings.Split(persistentPeers, ","), true); err != nil {
+			return err
+		}
 	}
 
 	return nil
@@ -372,24 +505,36 @@ func (e *Dngine) BroadcastTx(tx []byte) error {
 	return e.mempool.CheckTx(tx)
 }
 
-func (e *Dngine) BroadcastTxCommit(tx []byte) error {
+// BroadcastTxCommit adds tx to the mempool and waits for the execution of the block including it,
+// for at most timeout_broadcast_tx_commit milliseconds.
+// An error other than ErrBroadcastTxTimeout and ErrBroadcastTxCanceled means the mempool refused the tx.
+func (e *Dngine) BroadcastTxCommit(tx []byte) (types.EventDataTx, error) {
+	q, err := types.EventQuery(types.EventStringTx(tx))
+	if err != nil {
+		return types.EventDataTx{}, err
+	}
+	// each call subscribes on its own, the same tx may be waited for twice
+	subscriber := fmt.Sprintf("dngine#%d", atomic.AddUint64(&e.txCommitWaiters, 1))
+	// subscribing before the tx is in the mempool, it could be committed right away
+	sub, err := e.eventBus.Subscribe(subscriber, q, 1, pubsub.DropNewest)
+	if err != nil {
+		return types.EventDataTx{}, err
+	}
+	defer e.eventBus.UnsubscribeAll(subscriber)
+
 	if err := e.mempool.CheckTx(tx); err != nil {
-		return err
+		return types.EventDataTx{}, err
 	}
-	committed := make(chan types.EventDataTx, 1)
-	eventString := types.EventStringTx(tx)
-	timer := time.NewTimer(60 * 2 * time.Second)
-	types.AddListenerForEvent(*e.eventSwitch, "dngine", eventString, func(data types.TMEventData) {
-		committed <- data.(types.EventDataTx)
-	})
-	defer func() {
-		(*e.eventSwitch).(events.EventSwitch).RemoveListenerForEvent(eventString, "dngine")
-	}()
+	timer := time.NewTimer(time.Duration(e.tune.Conf.GetInt("timeout_broadcast_tx_commit")) * time.Millisecond)
+	defer timer.Stop()
 	select {
-	case <-committed:
-		return nil
+	case msg, ok := <-sub.Out():
+		if !ok {
+			return types.EventDataTx{}, ErrBroadcastTxCanceled
+		}
+		return msg.Data.(types.EventDataTx), nil
 	case <-timer.C:
-		return fmt.Errorf("Timed out waiting for transaction to be included in a block")
+		return types.EventDataTx{}, ErrBroadcastTxTimeout
 	}
 }
 
@@ -413,11 +558,34 @@ func (e *Dngine) GetP2PNetInfo() (bool, []string, []*types.Peer) {
 			NodeInfo:         *p.NodeInfo,
 			IsOutbound:       p.IsOutbound(),
 			ConnectionStatus: p.Connection().Status(),
+			Score:            e.p2pSwitch.PeerScore(p.Key),
 		})
 	}
 	return listening, listeners, peers
 }
 
+// GetBannedPeers returns the peers temporarily banned for misbehaving
+func (e *Dngine) GetBannedPeers() []p2p.PeerScore {
+	return e.p2pSwitch.BannedPeers()
+}
+
+// UnbanPeer lifts the ban of the peer with the given key and returns its score,
+// false if the peer is neither connected with a score nor banned
+func (e *Dngine) UnbanPeer(peerKey string) (p2p.PeerScore, bool) {
+	e.p2pSwitch.UnbanPeer(peerKey)
+	for _, s := range e.p2pSwitch.PeerScores() {
+		if s.Key == peerKey {
+			return s, true
+		}
+	}
+	return p2p.PeerScore{}, false
+}
+
+// GetChannelMetrics returns the traffic of every p2p channel summed over all peers
+func (e *Dngine) GetChannelMetrics() []p2p.ChannelMetrics {
+	return e.p2pSwitch.ChannelMetrics()
+}
+
 func (e *Dngine) GetNumPeers() int {
 	o, i, d := e.p2pSwitch.NumPeers()
 	return o + i + d
@@ -454,6 +622,69 @@ func (e *Dngine) IsNodeValidator(pub crypto.PubKey) bool {
 	return false
 }
 
+// IsCatchingUp tells whether the node is still fast syncing the blocks of the chain
+func (e *Dngine) IsCatchingUp() bool {
+	if e.seedMode {
+		return false
+	}
+	return e.bcReactor.IsSyncing() || e.conReactor.FastSync()
+}
+
+// GetSyncTarget is the height the fast sync is catching up with, 0 when it isn't running
+func (e *Dngine) GetSyncTarget() int {
+	if e.seedMode || !e.bcReactor.IsSyncing() {
+		return 0
+	}
+	return e.bcReactor.SyncTarget()
+}
+
+// GetValidatorStatus returns the voting power of the node, 0 when it isn't a validator,
+// and the height of the last vote or proposal it signed
+func (e *Dngine) GetValidatorStatus() (votingPower int64, lastSignedHeight int) {
+	if e.seedMode {
+		return 0, 0
+	}
+	_, vals := e.consensus.GetValidators()
+	for _, v := range vals {
+		if bytes.Equal(v.Address, e.privValidator.GetAddress()) {
+			votingPower = v.VotingPower
+			break
+		}
+	}
+	return votingPower, e.privValidator.LastSignedHeight()
+}
+
+// CheckHealth returns why the node doesn't work, nil when the reactors it needs are running
+func (e *Dngine) CheckHealth() error {
+	e.mtx.Lock()
+	started := e.started
+	e.mtx.Unlock()
+	if !started || !e.p2pSwitch.IsRunning() {
+		return errors.New("not started")
+	}
+	if e.seedMode {
+		return nil
+	}
+	if !e.bcReactor.IsRunning() || !e.conReactor.IsRunning() {
+		return errors.New("the reactors are stopped")
+	}
+	if !e.conReactor.FastSync() && !e.consensus.IsRunning() {
+		return errors.New("the consensus is stopped")
+	}
+	return nil
+}
+
+// CheckReady returns why the node can't serve its chain yet, nil when it is healthy and caught up
+func (e *Dngine) CheckReady() error {
+	if err := e.CheckHealth(); err != nil {
+		return err
+	}
+	if e.IsCatchingUp() {
+		return fmt.Errorf("catching up, at height %d of %d", e.Height(), e.GetSyncTarget())
+	}
+	return nil
+}
+
 func (e *Dngine) GetBlacklist() []string {
 	return e.refuseList.ListAllKey()
 }
@@ -510,6 +741,21 @@ func (e *Dngine) RecoverFromCrash(appHash []byte, appBlockHeight int) error {
 		// h.nBlocks++
 		// replay the latest block
 		return e.stateMachine.ApplyBlock(*e.eventSwitch, block, blockMeta.PartsHeader, MockMempool{}, 0)
+	} else if stateBlockHeight == appBlockHeight && storeBlockHeight > stateBlockHeight {
+		// the state and the app were rolled back below the blocks kept in the store,
+		// so replay them all
+		for h := stateBlockHeight + 1; h <= storeBlockHeight; h++ {
+			block := e.blockstore.LoadBlock(h)
+			blockMeta := e.blockstore.LoadBlockMeta(h)
+			if err := e.stateMachine.ApplyBlock(*e.eventSwitch, block, blockMeta.PartsHeader, MockMempool{}, 0); err != nil {
+				return err
+			}
+			e.stateMachine.Save()
+		}
+		if e.consensus != nil {
+			e.consensus.ResetToState(e.stateMachine)
+		}
+		return nil
 	} else if storeBlockHeight != stateBlockHeight {
 		// unless we failed before committing or saving state (previous 2 case),
 		// the store and state should be at the same height!
@@ -627,6 +873,21 @@ func fastSyncable(conf cfg.Config, selfAddress []byte, validators *types.Validat
 	return fastSync
 }
 
+// loadPrivValidator unlocks the validator key from the keystore when priv_validator_key is set,
+// priv_validator_file then only keeps the signing state.
+func loadPrivValidator(logger *zap.Logger, conf cfg.Config) *types.PrivValidator {
+	keyName := conf.GetString("priv_validator_key")
+	if keyName == "" {
+		return types.LoadOrGenPrivValidator(logger, conf.GetString("priv_validator_file"))
+	}
+	ks := keystore.NewKeyStore(conf.GetString("keystore_dir"))
+	privValidator, err := types.LoadPrivValidatorFromKeyStore(logger, conf.GetString("priv_validator_file"), ks, keyName, os.Getenv(ValidatorPassphraseEnv))
+	if err != nil {
+		cmn.Exit(cmn.Fmt("Failed to unlock validator key %s: %v", keyName, err))
+	}
+	return privValidator
+}
+
 func getGenesisFileMust(conf cfg.Config) *types.GenesisDoc {
 	genDocFile := conf.GetString("genesis_file")
 	if !cmn.FileExists(genDocFile) {
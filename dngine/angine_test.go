@@ -0,0 +1,76 @@
+// Copyright 2017 Delos Development Foundation and contributors. Licensed
+// under the Apache License, Version 2.0. See the COPYING file at the root
+// of this distribution or at http://www.apache.org/licenses/LICENSE-2.0
+package dngine
+
+import (
+	"io/ioutil"
+	"os"
+	"sort"
+	"testing"
+
+	ac "github.com/DelosIsland/core/dngine/config"
+	"github.com/DelosIsland/core/module/lib/go-p2p"
+)
+
+func newTestDngine(dir string, seedMode bool) *Dngine {
+	conf := ac.GetConfig(dir)
+	conf.Set("node_laddr", "tcp://127.0.0.1:0")
+	conf.Set("skip_upnp", true)
+	conf.Set("log_path", dir)
+	conf.Set("seed_mode", seedMode)
+	conf.Set("pex_reactor", true)
+	Initialize(&DngineTunes{Conf: conf})
+	return NewDngine(&DngineTunes{Conf: conf})
+}
+
+func reactorNames(e *Dngine) []string {
+	names := []string{}
+	for name := range e.p2pSwitch.Reactors() {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+func TestNewDngineSeedMode(t *testing.T) {
+	dir, err := ioutil.TempDir("", "dngine")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	e := newTestDngine(dir, true)
+	if names := reactorNames(e); len(names) != 1 || names[0] != "PEX" {
+		t.Fatalf("Expected a seed to register the PEX reactor only, got %v", names)
+	}
+	if pexR, ok := e.p2pSwitch.Reactor("PEX").(*p2p.PEXReactor); !ok || !pexR.IsSeedMode() {
+		t.Error("Expected the PEX reactor of a seed to crawl")
+	}
+	if !e.IsSeed() || e.mempool != nil || e.consensus != nil || e.bcReactor != nil {
+		t.Error("Expected a seed to run without mempool, consensus nor block sync")
+	}
+}
+
+func TestNewDngineReactors(t *testing.T) {
+	dir, err := ioutil.TempDir("", "dngine")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	e := newTestDngine(dir, false)
+	expected := []string{"BLOCKCHAIN", "CONSENSUS", "MEMPOOL", "PEX"}
+	names := reactorNames(e)
+	if len(names) != len(expected) {
+		t.Fatalf("Expected the reactors %v, got %v", expected, names)
+	}
+	for i := range expected {
+		if names[i] != expected[i] {
+			t.Fatalf("Expected the reactors %v, got %v", expected, names)
+		}
+	}
+	if pexR := e.p2pSwitch.Reactor("PEX").(*p2p.PEXReactor); pexR.IsSeedMode() {
+		t.Error("Expected the PEX reactor of a validator not to crawl")
+	}
+}
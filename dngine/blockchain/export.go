@@ -0,0 +1,192 @@
+// Copyright 2017 Delos Development Foundation and contributors. Licensed
+// under the Apache License, Version 2.0. See the COPYING file at the root
+// of this distribution or at http://www.apache.org/licenses/LICENSE-2.0
+package blockchain
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+
+	"github.com/DelosIsland/core/dngine/types"
+	"github.com/DelosIsland/core/module/lib/go-wire"
+)
+
+/*
+An export is a chain in a portable file, read back by a node which replays it.
+
+	"ANNEXPORT" | version (4 bytes) | records
+
+The records are wire records of ExportRecord, framed as the consensus WAL,
+the genesis comes first, then the blocks from height 1 with their seen commits.
+*/
+
+const (
+	exportMagic = "ANNEXPORT"
+
+	// ExportVersion is the version of the exports written,
+	// a reader refuses the others
+	ExportVersion = 1
+
+	maxExportRecordSize = 100 * 1024 * 1024
+)
+
+type ExportRecord interface{}
+
+// ExportGenesis holds the genesis doc as its JSON, which is the format it is read from
+type ExportGenesis struct {
+	JSON []byte
+}
+
+// ExportBlock is a block as it was stored, in parts, with the +2/3 precommits seen for it
+type ExportBlock struct {
+	Height      int
+	PartsHeader types.PartSetHeader
+	Parts       []*types.Part
+	SeenCommit  *types.Commit
+}
+
+const (
+	exportTypeGenesis = byte(0x01)
+	exportTypeBlock   = byte(0x02)
+)
+
+var _ = wire.RegisterInterface(
+	struct{ ExportRecord }{},
+	wire.ConcreteType{&ExportGenesis{}, exportTypeGenesis},
+	wire.ConcreteType{&ExportBlock{}, exportTypeBlock},
+)
+
+// LoadExportBlock returns the block at height as it is exported, nil if there's none
+func (bs *BlockStore) LoadExportBlock(height int) *ExportBlock {
+	meta := bs.LoadBlockMeta(height)
+	if meta == nil {
+		return nil
+	}
+	eb := &ExportBlock{
+		Height:      height,
+		PartsHeader: meta.PartsHeader,
+		Parts:       make([]*types.Part, meta.PartsHeader.Total),
+		SeenCommit:  bs.LoadSeenCommit(height),
+	}
+	for i := range eb.Parts {
+		eb.Parts[i] = bs.LoadBlockPart(height, i)
+	}
+	return eb
+}
+
+// Block puts the block back together from its parts, checking their proofs
+func (eb *ExportBlock) Block() (*types.Block, *types.PartSet, error) {
+	parts := types.NewPartSetFromHeader(eb.PartsHeader)
+	for _, part := range eb.Parts {
+		if part == nil {
+			return nil, nil, errors.New("missing block part")
+		}
+		if _, err := parts.AddPart(part, true); err != nil {
+			return nil, nil, err
+		}
+	}
+	if !parts.IsComplete() {
+		return nil, nil, errors.New("missing block part")
+	}
+	var n int
+	var err error
+	block := wire.ReadBinary(&types.Block{}, parts.GetReader(), types.MaxBlockSize, &n, &err).(*types.Block)
+	if err != nil {
+		return nil, nil, err
+	}
+	if block.Height != eb.Height {
+		return nil, nil, fmt.Errorf("the block of height %d is exported as %d", block.Height, eb.Height)
+	}
+	if eb.SeenCommit == nil {
+		return nil, nil, errors.New("missing seen commit")
+	}
+	return block, parts, nil
+}
+
+// ExportWriter writes an export, the genesis is written when it is made
+type ExportWriter struct {
+	wr *wire.RecordWriter
+}
+
+func NewExportWriter(wr io.Writer, genesis *types.GenesisDoc) (*ExportWriter, error) {
+	header := make([]byte, len(exportMagic)+4)
+	copy(header, exportMagic)
+	binary.BigEndian.PutUint32(header[len(exportMagic):], ExportVersion)
+	if _, err := wr.Write(header); err != nil {
+		return nil, err
+	}
+	w := &ExportWriter{wire.NewRecordWriter(wr, maxExportRecordSize)}
+	return w, w.write(&ExportGenesis{JSON: wire.JSONBytes(genesis)})
+}
+
+// WriteBlock must be called for every height from 1 on
+func (w *ExportWriter) WriteBlock(eb *ExportBlock) error {
+	return w.write(eb)
+}
+
+func (w *ExportWriter) write(rec ExportRecord) error {
+	return w.wr.WriteRecord(struct{ ExportRecord }{rec})
+}
+
+// ExportReader reads an export written by ExportWriter
+type ExportReader struct {
+	rd      *wire.RecordReader
+	genesis *types.GenesisDoc
+}
+
+// NewExportReader checks the version of the export and reads its genesis
+func NewExportReader(rd io.Reader) (*ExportReader, error) {
+	header := make([]byte, len(exportMagic)+4)
+	if _, err := io.ReadFull(rd, header); err != nil || string(header[:len(exportMagic)]) != exportMagic {
+		return nil, errors.New("not an export")
+	}
+	if version := binary.BigEndian.Uint32(header[len(exportMagic):]); version != ExportVersion {
+		return nil, fmt.Errorf("the export is of version %d, only %d is supported", version, ExportVersion)
+	}
+
+	r := &ExportReader{rd: wire.NewRecordReader(rd, maxExportRecordSize)}
+	rec, err := r.read()
+	if err == io.EOF {
+		return nil, errors.New("the export has no genesis")
+	} else if err != nil {
+		return nil, err
+	}
+	eg, ok := rec.(*ExportGenesis)
+	if !ok {
+		return nil, errors.New("the export doesn't start with the genesis")
+	}
+	if r.genesis, err = types.ReadGenesisDoc(eg.JSON); err != nil {
+		return nil, fmt.Errorf("the genesis of the export is unreadable: %v", err)
+	}
+	return r, nil
+}
+
+func (r *ExportReader) Genesis() *types.GenesisDoc {
+	return r.genesis
+}
+
+// Next returns the next block, io.EOF after the last one
+func (r *ExportReader) Next() (*ExportBlock, error) {
+	rec, err := r.read()
+	if err != nil {
+		return nil, err
+	}
+	eb, ok := rec.(*ExportBlock)
+	if !ok {
+		return nil, fmt.Errorf("unexpected %T among the blocks", rec)
+	}
+	return eb, nil
+}
+
+func (r *ExportReader) read() (ExportRecord, error) {
+	var rec struct{ ExportRecord }
+	if err := r.rd.ReadRecord(&rec); err != nil {
+		if c, ok := err.(*wire.RecordCorruption); ok {
+			return nil, fmt.Errorf("corrupted export record: %s", c.Reason)
+		}
+		return nil, err
+	}
+	return rec.ExportRecord, nil
+}
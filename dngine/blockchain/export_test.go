@@ -0,0 +1,85 @@
+// Copyright 2017 Delos Development Foundation and contributors. Licensed
+// under the Apache License, Version 2.0. See the COPYING file at the root
+// of this distribution or at http://www.apache.org/licenses/LICENSE-2.0
+package blockchain
+
+import (
+	"bytes"
+	"io"
+	"testing"
+
+	"github.com/DelosIsland/core/dngine/types"
+	"github.com/DelosIsland/core/module/lib/go-crypto"
+	dbm "github.com/DelosIsland/core/module/lib/go-db"
+)
+
+func TestExport(t *testing.T) {
+	store := NewBlockStore(dbm.NewMemDB())
+	lastCommit := &types.Commit{}
+	for h := 1; h <= 3; h++ {
+		block, parts := types.MakeBlock(h, "test-chain", []types.Tx{types.Tx("tx")}, lastCommit, types.BlockID{}, nil, nil, nil, 64)
+		seenCommit := &types.Commit{BlockID: types.BlockID{Hash: block.Hash(), PartsHeader: parts.Header()}}
+		store.SaveBlock(block, parts, seenCommit)
+		lastCommit = seenCommit
+	}
+
+	buf := new(bytes.Buffer)
+	genesis := &types.GenesisDoc{
+		ChainID:    "test-chain",
+		Validators: []types.GenesisValidator{{PubKey: crypto.GenPrivKeyEd25519().PubKey(), Amount: 10, Name: "v"}},
+	}
+	w, err := NewExportWriter(buf, genesis)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for h := 1; h <= 3; h++ {
+		if err := w.WriteBlock(store.LoadExportBlock(h)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	data := buf.Bytes()
+
+	r, err := NewExportReader(bytes.NewReader(data))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if g := r.Genesis(); g.ChainID != "test-chain" || len(g.Validators) != 1 || !g.Validators[0].PubKey.Equals(genesis.Validators[0].PubKey) {
+		t.Errorf("Unexpected genesis %v", g)
+	}
+	for h := 1; h <= 3; h++ {
+		eb, err := r.Next()
+		if err != nil {
+			t.Fatal(err)
+		}
+		block, parts, err := eb.Block()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !bytes.Equal(block.Hash(), store.LoadBlock(h).Hash()) || !parts.Header().Equals(store.LoadBlockMeta(h).PartsHeader) {
+			t.Errorf("Expected block %d to be exported as stored", h)
+		}
+		if !bytes.Equal(eb.SeenCommit.BlockID.Hash, block.Hash()) {
+			t.Errorf("Expected the seen commit of block %d", h)
+		}
+	}
+	if _, err := r.Next(); err != io.EOF {
+		t.Errorf("Expected io.EOF after the last block, got %v", err)
+	}
+
+	// a flipped bit
+	flipped := append([]byte{}, data...)
+	flipped[len(flipped)-1] ^= 1
+	r, _ = NewExportReader(bytes.NewReader(flipped))
+	r.Next()
+	r.Next()
+	if _, err := r.Next(); err == nil {
+		t.Error("Expected the corrupted block to be refused")
+	}
+
+	// another version
+	other := append([]byte{}, data...)
+	other[len(exportMagic)+3] = ExportVersion + 1
+	if _, err := NewExportReader(bytes.NewReader(other)); err == nil {
+		t.Error("Expected another version to be refused")
+	}
+}
@@ -0,0 +1,44 @@
+// Copyright 2017 Delos Development Foundation and contributors. Licensed
+// under the Apache License, Version 2.0. See the COPYING file at the root
+// of this distribution or at http://www.apache.org/licenses/LICENSE-2.0
+package blockchain
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+const metricsSubsystem = "fastsync"
+
+// Metrics of the fast sync
+type Metrics struct {
+	Syncing       prometheus.Gauge
+	Height        prometheus.Gauge // next block to sync
+	MaxPeerHeight prometheus.Gauge
+	SyncedBlocks  prometheus.Counter
+}
+
+// NewMetrics makes the metrics of the fast sync and registers them with reg, unless it is nil
+func NewMetrics(reg prometheus.Registerer) *Metrics {
+	m := &Metrics{
+		Syncing: prometheus.NewGauge(prometheus.GaugeOpts{
+			Namespace: "dngine", Subsystem: metricsSubsystem, Name: "syncing",
+			Help: "1 while the node is fast syncing.",
+		}),
+		Height: prometheus.NewGauge(prometheus.GaugeOpts{
+			Namespace: "dngine", Subsystem: metricsSubsystem, Name: "height",
+			Help: "Height of the next block to sync.",
+		}),
+		MaxPeerHeight: prometheus.NewGauge(prometheus.GaugeOpts{
+			Namespace: "dngine", Subsystem: metricsSubsystem, Name: "max_peer_height",
+			Help: "Highest height reported by the peers.",
+		}),
+		SyncedBlocks: prometheus.NewCounter(prometheus.CounterOpts{
+			Namespace: "dngine", Subsystem: metricsSubsystem, Name: "blocks_total",
+			Help: "Blocks synced since the node started.",
+		}),
+	}
+	if reg != nil {
+		reg.MustRegister(m.Syncing, m.Height, m.MaxPeerHeight, m.SyncedBlocks)
+	}
+	return m
+}
@@ -17,8 +17,7 @@ import (
 
 const (
 	requestIntervalMS         = 250
-	maxTotalRequesters        = 300
-	maxPendingRequests        = maxTotalRequesters
+	defaultRequestWindow      = 300 // max number of requested blocks ahead of the pool height
 	maxPendingRequestsPerPeer = 75
 	minRecvRate               = 10240 // 10Kb/s
 )
@@ -45,6 +44,7 @@ type BlockPool struct {
 	requesters map[int]*bpRequester
 	height     int   // the lowest key in requesters.
 	numPending int32 // number of requests pending assignment or block response
+	window     int   // max number of requesters, pending or not
 	// peers
 	peers map[string]*bpPeer
 
@@ -54,13 +54,18 @@ type BlockPool struct {
 	logger *zap.Logger
 }
 
-func NewBlockPool(logger *zap.Logger, start int, requestsCh chan<- BlockRequest, timeoutsCh chan<- string) *BlockPool {
+// NewBlockPool requests the blocks from start on, at most window of them at a time, 0 means the default
+func NewBlockPool(logger *zap.Logger, start, window int, requestsCh chan<- BlockRequest, timeoutsCh chan<- string) *BlockPool {
+	if window <= 0 {
+		window = defaultRequestWindow
+	}
 	bp := &BlockPool{
 		peers: make(map[string]*bpPeer),
 
 		requesters: make(map[int]*bpRequester),
 		height:     start,
 		numPending: 0,
+		window:     window,
 
 		requestsCh: requestsCh,
 		timeoutsCh: timeoutsCh,
@@ -89,12 +94,12 @@ func (pool *BlockPool) makeRequestersRoutine() {
 			break
 		}
 		_, numPending, lenRequesters := pool.GetStatus()
-		if numPending >= maxPendingRequests {
+		if int(numPending) >= pool.window {
 			// sleep for a bit.
 			time.Sleep(requestIntervalMS * time.Millisecond)
 			// check for timed out peers
 			pool.removeTimedoutPeers()
-		} else if lenRequesters >= maxTotalRequesters {
+		} else if lenRequesters >= pool.window {
 			// sleep for a bit.
 			time.Sleep(requestIntervalMS * time.Millisecond)
 			// check for timed out peers
@@ -133,6 +138,18 @@ func (pool *BlockPool) GetStatus() (height int, numPending int32, lenRequesters
 	return pool.height, pool.numPending, len(pool.requesters)
 }
 
+// MaxPeerHeight is the highest height reported by the peers, 0 without peers
+func (pool *BlockPool) MaxPeerHeight() int {
+	pool.mtx.Lock()
+	defer pool.mtx.Unlock()
+
+	maxPeerHeight := 0
+	for _, peer := range pool.peers {
+		maxPeerHeight = MaxInt(maxPeerHeight, peer.height)
+	}
+	return maxPeerHeight
+}
+
 // TODO: relax conditions, prevent abuse.
 func (pool *BlockPool) IsCaughtUp() bool {
 	pool.mtx.Lock()
@@ -172,6 +189,28 @@ func (pool *BlockPool) PeekTwoBlocks() (first *types.Block, second *types.Block)
 	return
 }
 
+// PeekBlock returns the block received for height, nil if there is none yet
+func (pool *BlockPool) PeekBlock(height int) *types.Block {
+	pool.mtx.Lock()
+	defer pool.mtx.Unlock()
+
+	if r := pool.requesters[height]; r != nil {
+		return r.getBlock()
+	}
+	return nil
+}
+
+// PeekPeerID returns the peer the block at height was requested from.
+func (pool *BlockPool) PeekPeerID(height int) string {
+	pool.mtx.Lock()
+	defer pool.mtx.Unlock()
+
+	if r := pool.requesters[height]; r != nil {
+		return r.getPeerID()
+	}
+	return ""
+}
+
 // Pop the first block at pool.height
 // It must have been validated by 'second'.Commit from PeekTwoBlocks().
 func (pool *BlockPool) PopRequest() {
@@ -192,19 +231,22 @@ func (pool *BlockPool) PopRequest() {
 	}
 }
 
-// Invalidates the block at pool.height,
+// Invalidates the block at height,
 // Remove the peer and redo request from others.
-func (pool *BlockPool) RedoRequest(height int) {
+// Returns the peer which sent the bad block so the caller can punish it.
+func (pool *BlockPool) RedoRequest(height int) string {
 	pool.mtx.Lock()
 	request := pool.requesters[height]
 	pool.mtx.Unlock()
 
-	if request.block == nil {
-		PanicSanity("Expected block to be non-nil")
+	if request == nil || request.getBlock() == nil {
+		// the peer was removed meanwhile, the block is already requested again
+		return ""
 	}
 	// RemovePeer will redo all requesters associated with this peer.
-	// TODO: record this malfeasance
-	pool.RemovePeer(request.peerID)
+	peerID := request.getPeerID()
+	pool.RemovePeer(peerID)
+	return peerID
 }
 
 // TODO: ensure that blocks come in order for each peer.
@@ -8,6 +8,7 @@ import (
 	"errors"
 	"fmt"
 	"reflect"
+	"sync/atomic"
 	"time"
 
 	"go.uber.org/zap"
@@ -45,15 +46,25 @@ type BlockchainReactor struct {
 	store      *BlockStore
 	pool       *BlockPool
 	fastSync   bool
+	syncing    int32 // atomic, 1 from the start of the fast sync to the switch to consensus
 	requestsCh chan BlockRequest
 	timeoutsCh chan string
 	lastBlock  *types.Block
 
-	blockVerifier func(types.BlockID, int, *types.Commit) error
-	blockExecuter func(*types.Block, *types.PartSet, *types.Commit) error
+	blockVerifier BlockVerifier
+	blockExecuter BlockExecuter
+	validators    func() *types.ValidatorSet
+
+	// set by the pool routine
+	pipeline      *verifyPipeline
+	valSet        *types.ValidatorSet
+	valSetHash    []byte
+	lastCommitted *types.Commit // commit of the last executed block, verified by the pipeline
 
 	evsw types.EventSwitch
 
+	metrics *Metrics
+
 	logger *zap.Logger
 }
 
@@ -61,7 +72,8 @@ func NewBlockchainReactor(logger *zap.Logger, config cfg.Config, lastBlockHeight
 	if lastBlockHeight == store.Height()-1 {
 		store.height -= 1 // XXX HACK, make this better
 	}
-	if lastBlockHeight != store.Height() {
+	// blocks kept above a rolled back state are replayed when the app connects
+	if lastBlockHeight > store.Height() {
 		PanicSanity(Fmt("state (%v) and store (%v) height mismatch", lastBlockHeight, store.Height()))
 	}
 	requestsCh := make(chan BlockRequest, defaultChannelCapacity)
@@ -69,6 +81,7 @@ func NewBlockchainReactor(logger *zap.Logger, config cfg.Config, lastBlockHeight
 	pool := NewBlockPool(
 		logger,
 		store.Height()+1,
+		config.GetInt("fast_sync_window"),
 		requestsCh,
 		timeoutsCh,
 	)
@@ -79,6 +92,7 @@ func NewBlockchainReactor(logger *zap.Logger, config cfg.Config, lastBlockHeight
 		fastSync:   fastSync,
 		requestsCh: requestsCh,
 		timeoutsCh: timeoutsCh,
+		metrics:    NewMetrics(nil),
 
 		logger: logger,
 	}
@@ -86,14 +100,25 @@ func NewBlockchainReactor(logger *zap.Logger, config cfg.Config, lastBlockHeight
 	return bcR
 }
 
-func (bcR *BlockchainReactor) SetBlockVerifier(v func(types.BlockID, int, *types.Commit) error) {
+func (bcR *BlockchainReactor) SetBlockVerifier(v BlockVerifier) {
 	bcR.blockVerifier = v
 }
 
-func (bcR *BlockchainReactor) SetBlockExecuter(x func(*types.Block, *types.PartSet, *types.Commit) error) {
+func (bcR *BlockchainReactor) SetBlockExecuter(x BlockExecuter) {
 	bcR.blockExecuter = x
 }
 
+// SetMetrics sets the metrics updated by the fast sync, call it before Start
+func (bcR *BlockchainReactor) SetMetrics(m *Metrics) {
+	bcR.metrics = m
+}
+
+// SetValidators gives the validators of the next block to execute,
+// it is called between executions only and must return a copy.
+func (bcR *BlockchainReactor) SetValidators(v func() *types.ValidatorSet) {
+	bcR.validators = v
+}
+
 func (bcR *BlockchainReactor) OnStart() error {
 	bcR.BaseReactor.OnStart()
 	if bcR.fastSync {
@@ -101,6 +126,11 @@ func (bcR *BlockchainReactor) OnStart() error {
 		if err != nil {
 			return err
 		}
+		bcR.pipeline = newVerifyPipeline(bcR.blockVerifier, bcR.config.GetInt("block_part_size"),
+			bcR.config.GetInt("fast_sync_verify_workers"), bcR.pool.window)
+		bcR.refreshValidators()
+		atomic.StoreInt32(&bcR.syncing, 1)
+		bcR.metrics.Syncing.Set(1)
 		go bcR.poolRoutine()
 	}
 	return nil
@@ -139,6 +169,7 @@ func (bcR *BlockchainReactor) Receive(chID byte, src *p2p.Peer, msgBytes []byte)
 	_, msg, err := DecodeMessage(msgBytes)
 	if err != nil {
 		bcR.logger.Warn("Error decoding message", zap.String("error", err.Error()))
+		bcR.Switch.ReportPeerBehaviour(src, p2p.PeerBehaviourBadMessage, err)
 		return
 	}
 
@@ -174,10 +205,22 @@ func (bcR *BlockchainReactor) Receive(chID byte, src *p2p.Peer, msgBytes []byte)
 	}
 }
 
+// IsSyncing tells whether the node is fast syncing, it stops once caught up with its peers
+func (bcR *BlockchainReactor) IsSyncing() bool {
+	return atomic.LoadInt32(&bcR.syncing) == 1
+}
+
+// SyncTarget is the highest height reported by the peers during the fast sync
+func (bcR *BlockchainReactor) SyncTarget() int {
+	return bcR.pool.MaxPeerHeight()
+}
+
 // Handle messages from the poolReactor telling the reactor what to do.
 // NOTE: Don't sleep in the FOR_LOOP or otherwise slow it down!
-// (Except for the SYNC_LOOP, which is the primary purpose and must be synchronous.)
+// (Except for trySync, which is the primary purpose and must be synchronous.)
 func (bcR *BlockchainReactor) poolRoutine() {
+	defer bcR.pipeline.stop()
+
 	trySyncTicker := time.NewTicker(trySyncIntervalMS * time.Millisecond)
 	statusUpdateTicker := time.NewTicker(statusUpdateIntervalSeconds * time.Second)
 	switchToConsensusTicker := time.NewTicker(switchToConsensusIntervalSeconds * time.Second)
@@ -201,6 +244,7 @@ FOR_LOOP:
 			// Peer timed out.
 			peer := bcR.Switch.Peers().Get(peerID)
 			if peer != nil {
+				bcR.Switch.ReportPeerBehaviour(peer, p2p.PeerBehaviourTimeout, nil)
 				bcR.Switch.StopPeerForError(peer, errors.New("BlockchainReactor Timeout"))
 			}
 		case _ = <-statusUpdateTicker.C:
@@ -208,51 +252,97 @@ FOR_LOOP:
 			go bcR.BroadcastStatusRequest()
 		case _ = <-switchToConsensusTicker.C:
 			height, numPending, _ := bcR.pool.GetStatus()
+			bcR.metrics.Height.Set(float64(height))
+			bcR.metrics.MaxPeerHeight.Set(float64(bcR.pool.MaxPeerHeight()))
 			outbound, inbound, _ := bcR.Switch.NumPeers()
 			bcR.logger.Debug("Consensus ticker", zap.Int32("numPending", numPending), zap.Int("total", len(bcR.pool.requesters)),
 				zap.Int("outbound", outbound), zap.Int("inbound", inbound))
 			if bcR.pool.IsCaughtUp() {
 				bcR.logger.Info("Time to switch to consensus reactor!", zap.Int("height", height))
 				bcR.pool.Stop()
+				atomic.StoreInt32(&bcR.syncing, 0)
+				bcR.metrics.Syncing.Set(0)
 				types.FireEventSwitchToConsensus(bcR.evsw)
 				break FOR_LOOP
 			}
+		case job := <-bcR.pipeline.results:
+			bcR.pipeline.done(job)
+			bcR.trySync()
 		case _ = <-trySyncTicker.C: // chan time
-			// This loop can be slow as long as it's doing syncing work.
-		SYNC_LOOP:
-			for i := 0; i < 10; i++ {
-				// See if there are any blocks to sync.
-				first, second := bcR.pool.PeekTwoBlocks()
-				if first == nil || second == nil {
-					// We need both to sync the first block.
-					break SYNC_LOOP
-				}
-				firstParts := first.MakePartSet(bcR.config.GetInt("block_part_size")) // TODO: put part size in parts header?
-				firstPartsHeader := firstParts.Header()
-				// Finally, verify the first block using the second's commit
-				// NOTE: we can probably make this more efficient, but note that calling
-				// first.Hash() doesn't verify the tx contents, so MakePartSet() is
-				// currently necessary.
-
-				if err := bcR.blockVerifier(types.BlockID{Hash: first.Hash(), PartsHeader: firstPartsHeader}, first.Height, second.LastCommit); err != nil {
-					bcR.logger.Error("error in validation", zap.String("error", err.Error()))
-					bcR.pool.RedoRequest(first.Height)
-					break SYNC_LOOP
-				} else {
-					bcR.pool.PopRequest()
-					if err := bcR.blockExecuter(first, firstParts, second.LastCommit); err != nil {
-						// TODO This is bad, are we zombie?
-						PanicQ(Fmt("Failed to process committed block (%d:%X): %v", first.Height, first.Hash(), err))
-					}
-				}
-			}
-			continue FOR_LOOP
+			bcR.trySync()
 		case <-bcR.Quit:
 			break FOR_LOOP
 		}
 	}
 }
 
+// trySync executes the verified blocks in order and hands the next ones to the verifiers.
+// It yields after a tick so that the requests keep flowing.
+func (bcR *BlockchainReactor) trySync() {
+	deadline := time.Now().Add(trySyncIntervalMS * time.Millisecond)
+	for time.Now().Before(deadline) {
+		height, _, _ := bcR.pool.GetStatus()
+		first, second := bcR.pool.PeekTwoBlocks()
+		if first == nil || second == nil {
+			// We need both to sync the first block.
+			break
+		}
+		job := bcR.pipeline.next(height, first, second, bcR.valSetHash)
+		if job == nil {
+			break
+		}
+
+		if job.err != nil {
+			bcR.logger.Error("error in validation", zap.Int("height", height), zap.String("error", job.err.Error()))
+			peerID := bcR.pool.RedoRequest(height)
+			bcR.reportPeer(peerID, p2p.PeerBehaviourBadBlock, job.err)
+			break
+		}
+
+		// the commit carried by first was verified as the one of the previous block
+		lastCommitVerified := bcR.lastCommitted != nil && first.LastCommit == bcR.lastCommitted
+		if err := bcR.blockExecuter(first, job.parts, second.LastCommit, lastCommitVerified); err != nil {
+			bcR.logger.Error("error in execution", zap.Int("height", height), zap.String("error", err.Error()))
+			peerID := bcR.pool.RedoRequest(height)
+			bcR.reportPeer(peerID, p2p.PeerBehaviourBadBlock, err)
+			bcR.lastCommitted = nil
+			break
+		}
+		bcR.reportPeer(bcR.pool.PeekPeerID(height), p2p.PeerBehaviourGoodBlock, nil)
+		bcR.pool.PopRequest()
+		bcR.metrics.SyncedBlocks.Inc()
+		bcR.metrics.Height.Set(float64(height + 1))
+		bcR.pipeline.prune(height + 1)
+		bcR.lastCommitted = second.LastCommit
+		bcR.refreshValidators()
+	}
+
+	height, _, _ := bcR.pool.GetStatus()
+	bcR.pipeline.dispatch(bcR.pool, height, bcR.valSet, bcR.valSetHash)
+}
+
+// refreshValidators keeps the validator set of the next block,
+// the verifications done with another one don't count anymore.
+func (bcR *BlockchainReactor) refreshValidators() {
+	valSet := bcR.validators()
+	hash := valSet.Hash()
+	if bytes.Equal(hash, bcR.valSetHash) {
+		return
+	}
+	bcR.valSet, bcR.valSetHash = valSet, hash
+	bcR.lastCommitted = nil
+}
+
+// reportPeer forwards the behaviour of a still connected peer to the switch
+func (bcR *BlockchainReactor) reportPeer(peerID string, b p2p.PeerBehaviour, reason interface{}) {
+	if peerID == "" {
+		return
+	}
+	if peer := bcR.Switch.Peers().Get(peerID); peer != nil {
+		bcR.Switch.ReportPeerBehaviour(peer, b, reason)
+	}
+}
+
 func (bcR *BlockchainReactor) BroadcastStatusResponse() error {
 	bcR.Switch.Broadcast(BlockchainChannel, struct{ BlockchainMessage }{&bcStatusResponseMessage{bcR.store.Height()}})
 	return nil
@@ -187,6 +187,33 @@ func (bs *BlockStore) SaveBlock(block *types.Block, blockParts *types.PartSet, s
 	bs.db.SetSync(nil, nil)
 }
 
+// DeleteBlocksAbove removes the blocks above height with their commits,
+// the store goes back to that height. The node must be stopped.
+func (bs *BlockStore) DeleteBlocksAbove(height int) {
+	top := bs.Height()
+	if height < 0 || height >= top {
+		return
+	}
+	// the height goes first, the blocks an interruption would leave above it
+	// are overwritten as the store grows again
+	BlockStoreStateJSON{Height: height}.Save(bs.db)
+	bs.mtx.Lock()
+	bs.height = height
+	bs.mtx.Unlock()
+
+	for h := top; h > height; h-- {
+		if meta := bs.LoadBlockMeta(h); meta != nil {
+			for i := 0; i < meta.PartsHeader.Total; i++ {
+				bs.db.Delete(calcBlockPartKey(h, i))
+			}
+		}
+		bs.db.Delete(calcBlockMetaKey(h))
+		bs.db.Delete(calcBlockCommitKey(h - 1))
+		bs.db.Delete(calcSeenCommitKey(h))
+	}
+	bs.db.SetSync(nil, nil)
+}
+
 func (bs *BlockStore) saveBlockPart(height int, index int, part *types.Part) {
 	if height != bs.Height()+1 {
 		PanicSanity(Fmt("BlockStore can only save contiguous blocks. Wanted %v, got %v", bs.Height()+1, height))
@@ -0,0 +1,150 @@
+// Copyright 2017 Delos Development Foundation and contributors. Licensed
+// under the Apache License, Version 2.0. See the COPYING file at the root
+// of this distribution or at http://www.apache.org/licenses/LICENSE-2.0
+package blockchain
+
+import (
+	"bytes"
+	"runtime"
+
+	"github.com/DelosIsland/core/dngine/types"
+)
+
+// BlockVerifier checks that commit signs the block blockID at height with the validators valSet
+type BlockVerifier func(valSet *types.ValidatorSet, blockID types.BlockID, height int, commit *types.Commit) error
+
+// BlockExecuter saves and applies a block. lastCommitVerified tells that the signatures
+// of block.LastCommit were checked by the pipeline already.
+// An error must leave no trace, the block is requested again from another peer.
+type BlockExecuter func(block *types.Block, parts *types.PartSet, commit *types.Commit, lastCommitVerified bool) error
+
+// verifyJob is the verification of the block at height with the commit of the next one.
+// The part set is built by the worker too, the block is saved with it.
+type verifyJob struct {
+	height        int
+	first, second *types.Block
+	valSet        *types.ValidatorSet
+	valSetHash    []byte
+
+	parts *types.PartSet
+	err   error
+}
+
+// matches tells whether the job still stands for the blocks in the pool
+func (j *verifyJob) matches(first, second *types.Block, valSetHash []byte) bool {
+	return j.first == first && j.second == second && bytes.Equal(j.valSetHash, valSetHash)
+}
+
+// verifyPipeline verifies the commits of the blocks ahead of the pool height on workers,
+// the blocks are then executed in order by the pool routine.
+// Jobs are verified with the validators of the pool height, they are dropped
+// and verified again if a block changes them.
+type verifyPipeline struct {
+	verifier BlockVerifier
+	partSize int
+
+	jobs    chan *verifyJob
+	results chan *verifyJob
+	quit    chan struct{}
+
+	pending  map[int]*verifyJob // sent to the workers
+	verified map[int]*verifyJob
+}
+
+func newVerifyPipeline(verifier BlockVerifier, partSize, workers, window int) *verifyPipeline {
+	if workers <= 0 {
+		workers = runtime.NumCPU()
+	}
+	p := &verifyPipeline{
+		verifier: verifier,
+		partSize: partSize,
+		jobs:     make(chan *verifyJob, window),
+		results:  make(chan *verifyJob, window),
+		quit:     make(chan struct{}),
+		pending:  make(map[int]*verifyJob),
+		verified: make(map[int]*verifyJob),
+	}
+	for i := 0; i < workers; i++ {
+		go p.worker()
+	}
+	return p
+}
+
+func (p *verifyPipeline) worker() {
+	for {
+		select {
+		case job := <-p.jobs:
+			job.parts = job.first.MakePartSet(p.partSize)
+			blockID := types.BlockID{Hash: job.first.Hash(), PartsHeader: job.parts.Header()}
+			job.err = p.verifier(job.valSet, blockID, job.first.Height, job.second.LastCommit)
+			select {
+			case p.results <- job:
+			case <-p.quit:
+				return
+			}
+		case <-p.quit:
+			return
+		}
+	}
+}
+
+// stop the workers
+func (p *verifyPipeline) stop() {
+	close(p.quit)
+}
+
+// dispatch sends the blocks from height on which have their successor to the workers,
+// until a missing block or a full queue. A block is never verified by two workers at once.
+func (p *verifyPipeline) dispatch(pool *BlockPool, height int, valSet *types.ValidatorSet, valSetHash []byte) {
+	for h := height; ; h++ {
+		first, second := pool.PeekBlock(h), pool.PeekBlock(h+1)
+		if first == nil || second == nil {
+			return
+		}
+		if job, ok := p.pending[h]; ok {
+			if job.first == first {
+				continue
+			}
+			// the block was requested again meanwhile, wait for the worker to be done with it
+			return
+		}
+		if job, ok := p.verified[h]; ok && job.matches(first, second, valSetHash) {
+			continue
+		}
+		// the hashes are cached in the block, fill them here so that the workers only read it
+		first.FillHeader()
+		job := &verifyJob{height: h, first: first, second: second, valSet: valSet, valSetHash: valSetHash}
+		select {
+		case p.jobs <- job:
+			p.pending[h] = job
+		default:
+			return
+		}
+	}
+}
+
+// done records the result of a worker
+func (p *verifyPipeline) done(job *verifyJob) {
+	if p.pending[job.height] == job {
+		delete(p.pending, job.height)
+	}
+	p.verified[job.height] = job
+}
+
+// next returns the verification of the block at height if it is still valid
+func (p *verifyPipeline) next(height int, first, second *types.Block, valSetHash []byte) *verifyJob {
+	job, ok := p.verified[height]
+	if !ok || !job.matches(first, second, valSetHash) {
+		return nil
+	}
+	return job
+}
+
+// prune forgets the heights below height
+func (p *verifyPipeline) prune(height int) {
+	for h := range p.verified {
+		if h < height {
+			delete(p.verified, h)
+		}
+	}
+}
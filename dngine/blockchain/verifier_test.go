@@ -0,0 +1,106 @@
+// Copyright 2017 Delos Development Foundation and contributors. Licensed
+// under the Apache License, Version 2.0. See the COPYING file at the root
+// of this distribution or at http://www.apache.org/licenses/LICENSE-2.0
+package blockchain
+
+import (
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/DelosIsland/core/dngine/types"
+)
+
+func makeTestPool(start, end int) *BlockPool {
+	pool := NewBlockPool(nil, start, defaultRequestWindow, nil, nil)
+	lastCommit := &types.Commit{}
+	for h := start; h <= end; h++ {
+		block, _ := types.MakeBlock(h, "test-chain", nil, lastCommit, types.BlockID{}, nil, nil, nil, 64)
+		pool.requesters[h] = &bpRequester{pool: pool, height: h, block: block}
+		lastCommit = &types.Commit{BlockID: types.BlockID{Hash: block.Hash()}}
+	}
+	return pool
+}
+
+func waitResults(t *testing.T, p *verifyPipeline, n int) {
+	for i := 0; i < n; i++ {
+		select {
+		case job := <-p.results:
+			p.done(job)
+		case <-time.After(5 * time.Second):
+			t.Fatalf("Timed out waiting for %d verifications, got %d", n, i)
+		}
+	}
+}
+
+func TestVerifyPipeline(t *testing.T) {
+	var (
+		mtx      sync.Mutex
+		verified = make(map[int]int)
+	)
+	verifier := func(valSet *types.ValidatorSet, blockID types.BlockID, height int, commit *types.Commit) error {
+		mtx.Lock()
+		defer mtx.Unlock()
+		verified[height]++
+		if height == 5 {
+			return errors.New("bad commit")
+		}
+		return nil
+	}
+	p := newVerifyPipeline(verifier, 64, 4, 16)
+	defer p.stop()
+
+	// the last block has no successor, it can't be verified yet
+	pool := makeTestPool(1, 8)
+	p.dispatch(pool, 1, nil, nil)
+	if len(p.pending) != 7 {
+		t.Fatalf("Expected 7 pending verifications, got %d", len(p.pending))
+	}
+	waitResults(t, p, 7)
+
+	for h := 1; h <= 7; h++ {
+		job := p.next(h, pool.PeekBlock(h), pool.PeekBlock(h+1), nil)
+		if job == nil {
+			t.Fatalf("Expected block %d to be verified", h)
+		}
+		if job.parts == nil || (job.err != nil) != (h == 5) {
+			t.Errorf("Unexpected verification of block %d: %v", h, job.err)
+		}
+	}
+
+	// nothing is verified twice
+	p.dispatch(pool, 1, nil, nil)
+	if len(p.pending) != 0 {
+		t.Errorf("Expected no pending verification, got %d", len(p.pending))
+	}
+
+	// a block received again is verified again with its predecessor, the stale verifications aren't used
+	pool.requesters[5].block, _ = types.MakeBlock(5, "test-chain", nil, &types.Commit{}, types.BlockID{}, nil, nil, nil, 64)
+	if p.next(5, pool.PeekBlock(5), pool.PeekBlock(6), nil) != nil {
+		t.Error("Expected the verification of the replaced block to be dropped")
+	}
+	p.dispatch(pool, 1, nil, nil)
+	waitResults(t, p, 2)
+	if p.next(4, pool.PeekBlock(4), pool.PeekBlock(5), nil) == nil || p.next(5, pool.PeekBlock(5), pool.PeekBlock(6), nil) == nil {
+		t.Error("Expected the replaced block and its predecessor to be verified")
+	}
+
+	// another validator set makes the verifications stale
+	if p.next(3, pool.PeekBlock(3), pool.PeekBlock(4), []byte("other")) != nil {
+		t.Error("Expected the verification with another validator set to be dropped")
+	}
+
+	p.prune(4)
+	if p.next(3, pool.PeekBlock(3), pool.PeekBlock(4), nil) != nil || p.next(4, pool.PeekBlock(4), pool.PeekBlock(5), nil) == nil {
+		t.Error("Expected only the heights below 4 to be pruned")
+	}
+
+	mtx.Lock()
+	defer mtx.Unlock()
+	for h := 1; h <= 7; h++ {
+		if expected := map[bool]int{true: 2, false: 1}[h == 4 || h == 5]; verified[h] != expected {
+			t.Errorf("Expected block %d to be verified %d times, got %d", h, expected, verified[h])
+		}
+	}
+}
@@ -4,8 +4,12 @@
 package config
 
 import (
+	"bytes"
+	"fmt"
 	"os"
 	"path"
+	"sort"
+	"strconv"
 	"strings"
 
 	"github.com/DelosIsland/core/module/lib/go-common"
@@ -78,20 +82,40 @@ func FillInDefaults(root string, conf *config.MapConfig) *config.MapConfig {
 	conf.SetDefault("moniker", "anonymous")
 	conf.SetDefault("node_laddr", "tcp://0.0.0.0:46656")
 	conf.SetDefault("seeds", "")
+	conf.SetDefault("persistent_peers", "")
 	conf.SetDefault("fast_sync", true)
+	conf.SetDefault("fast_sync_window", 300)       // max number of blocks requested ahead
+	conf.SetDefault("fast_sync_verify_workers", 0) // goroutines verifying commits ahead, 0 means one per cpu
 	conf.SetDefault("skip_upnp", false)
 	conf.SetDefault("addrbook_file", path.Join(root, "addrbook.json"))
 	conf.SetDefault("addrbook_strict", false) // disable to allow connections locally
 	conf.SetDefault("pex_reactor", false)     // enable for peer exchange
+	conf.SetDefault("seed_mode", false)       // crawl the network and serve addresses only
 	conf.SetDefault("priv_validator_file", path.Join(root, "priv_validator.json"))
+	conf.SetDefault("priv_validator_key", "") // keystore key signing for the validator, its passphrase is read from ANN_VALIDATOR_PASSPHRASE
+	conf.SetDefault("keystore_dir", path.Join(root, "keystore"))
 	conf.SetDefault("db_backend", "leveldb")
 	conf.SetDefault("db_dir", path.Join(root, DATADIR))
 	conf.SetDefault("rpc_laddr", "tcp://0.0.0.0:46657")
+	conf.SetDefault("rpc_tls_cert_file", "")
+	conf.SetDefault("rpc_tls_key_file", "")
+	conf.SetDefault("rpc_tls_client_ca_file", "") // require client certificates signed by this CA
+	conf.SetDefault("rpc_auth_tokens", "")        // comma separated API tokens
+	conf.SetDefault("rpc_auth_pubkeys", "")       // comma separated hex ed25519 keys allowed to sign requests
+	// method groups (info,broadcast,unsafe,specialop) callable without and with authentication
+	conf.SetDefault("rpc_public_groups", "info,broadcast")
+	conf.SetDefault("rpc_auth_groups", "info,broadcast,unsafe,specialop")
+	conf.SetDefault("rpc_max_body_bytes", 1048576) // largest request body read before the caller is authenticated
 	conf.SetDefault("grpc_laddr", "")
 	conf.SetDefault("api_laddr", "")
+	conf.SetDefault("api_max_body_bytes", 1048576)         // largest request body read by the api_laddr gateway
+	conf.SetDefault("metrics_laddr", "")                   // serves the prometheus metrics on /metrics
+	conf.SetDefault("timeout_broadcast_tx_commit", 120000) // ms broadcast_tx_commit waits for the tx to be committed
+	conf.SetDefault("rpc_max_subscriptions_per_conn", 100) // 0 means no limit
 	conf.SetDefault("revision_file", path.Join(root, "revision"))
 	conf.SetDefault("cs_wal_dir", path.Join(root, DATADIR, "cs.wal"))
 	conf.SetDefault("cs_wal_light", false)
+	conf.SetDefault("rollback_heights", 100) // how many heights the rollback command can go back, the state of each is kept
 	conf.SetDefault("filter_peers", false)
 
 	conf.SetDefault("block_size", 3000)       // max number of txs
@@ -123,3 +147,27 @@ func FillInDefaults(root string, conf *config.MapConfig) *config.MapConfig {
 
 	return conf
 }
+
+// ConfigTOML writes the scalar settings as toml, sorted by key
+func ConfigTOML(settings map[string]interface{}) []byte {
+	keys := make([]string, 0, len(settings))
+	for k := range settings {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	var buf bytes.Buffer
+	for _, k := range keys {
+		switch v := settings[k].(type) {
+		case string:
+			fmt.Fprintf(&buf, "%s = %q\n", k, v)
+		case bool:
+			fmt.Fprintf(&buf, "%s = %v\n", k, v)
+		case int:
+			fmt.Fprintf(&buf, "%s = %d\n", k, v)
+		case float64:
+			fmt.Fprintf(&buf, "%s = %s\n", k, strconv.FormatFloat(v, 'f', -1, 64))
+		}
+	}
+	return buf.Bytes()
+}
@@ -11,10 +11,11 @@ proxy_app = "tcp://127.0.0.1:46658"
 moniker = "__MONIKER__"
 node_laddr = "tcp://0.0.0.0:46656"
 seeds = ""
+persistent_peers = ""
 fast_sync = true
 db_backend = "leveldb"
 rpc_laddr = "tcp://0.0.0.0:46657"
-api_laddr = "tcp://0.0.0.0:46659"
+api_laddr = ""
 signbyCA = ""
 
 #log_level:
@@ -0,0 +1,126 @@
+// Copyright 2017 Delos Development Foundation and contributors. Licensed
+// under the Apache License, Version 2.0. See the COPYING file at the root
+// of this distribution or at http://www.apache.org/licenses/LICENSE-2.0
+package consensus
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+
+	"github.com/DelosIsland/core/dngine/types"
+)
+
+const metricsSubsystem = "consensus"
+
+// Metrics of the consensus, updated as the state machine steps and commits
+type Metrics struct {
+	Height prometheus.Gauge
+	// rounds the last committed block took, 0 when it was committed in the first one
+	Rounds       prometheus.Gauge
+	StepDuration *prometheus.HistogramVec
+
+	Validators      prometheus.Gauge
+	ValidatorsPower prometheus.Gauge
+	// the validators whose precommit is missing from the last commit of the last block
+	MissingValidators      prometheus.Gauge
+	MissingValidatorsPower prometheus.Gauge
+	MissedSignatures       *prometheus.CounterVec
+
+	NumTxs        prometheus.Gauge
+	TotalTxs      prometheus.Counter
+	BlockInterval prometheus.Histogram
+}
+
+// NewMetrics makes the metrics of the consensus and registers them with reg, unless it is nil
+func NewMetrics(reg prometheus.Registerer) *Metrics {
+	m := &Metrics{
+		Height: prometheus.NewGauge(prometheus.GaugeOpts{
+			Namespace: "dngine", Subsystem: metricsSubsystem, Name: "height",
+			Help: "Height of the chain.",
+		}),
+		Rounds: prometheus.NewGauge(prometheus.GaugeOpts{
+			Namespace: "dngine", Subsystem: metricsSubsystem, Name: "rounds",
+			Help: "Round at which the last block was committed.",
+		}),
+		StepDuration: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Namespace: "dngine", Subsystem: metricsSubsystem, Name: "step_duration_seconds",
+			Help:    "Time spent in each step of a round.",
+			Buckets: prometheus.ExponentialBuckets(0.01, 2, 12),
+		}, []string{"step"}),
+		Validators: prometheus.NewGauge(prometheus.GaugeOpts{
+			Namespace: "dngine", Subsystem: metricsSubsystem, Name: "validators",
+			Help: "Number of validators.",
+		}),
+		ValidatorsPower: prometheus.NewGauge(prometheus.GaugeOpts{
+			Namespace: "dngine", Subsystem: metricsSubsystem, Name: "validators_power",
+			Help: "Total voting power of the validators.",
+		}),
+		MissingValidators: prometheus.NewGauge(prometheus.GaugeOpts{
+			Namespace: "dngine", Subsystem: metricsSubsystem, Name: "missing_validators",
+			Help: "Number of validators who didn't sign the last commit.",
+		}),
+		MissingValidatorsPower: prometheus.NewGauge(prometheus.GaugeOpts{
+			Namespace: "dngine", Subsystem: metricsSubsystem, Name: "missing_validators_power",
+			Help: "Voting power of the validators who didn't sign the last commit.",
+		}),
+		MissedSignatures: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: "dngine", Subsystem: metricsSubsystem, Name: "missed_signatures_total",
+			Help: "Commits missing the precommit of a validator.",
+		}, []string{"validator"}),
+		NumTxs: prometheus.NewGauge(prometheus.GaugeOpts{
+			Namespace: "dngine", Subsystem: metricsSubsystem, Name: "num_txs",
+			Help: "Number of txs in the last block.",
+		}),
+		TotalTxs: prometheus.NewCounter(prometheus.CounterOpts{
+			Namespace: "dngine", Subsystem: metricsSubsystem, Name: "txs_total",
+			Help: "Txs committed since the node started.",
+		}),
+		BlockInterval: prometheus.NewHistogram(prometheus.HistogramOpts{
+			Namespace: "dngine", Subsystem: metricsSubsystem, Name: "block_interval_seconds",
+			Help:    "Time between the last block and the one before.",
+			Buckets: prometheus.ExponentialBuckets(0.25, 2, 10),
+		}),
+	}
+	if reg != nil {
+		reg.MustRegister(m.Height, m.Rounds, m.StepDuration, m.Validators, m.ValidatorsPower,
+			m.MissingValidators, m.MissingValidatorsPower, m.MissedSignatures, m.NumTxs, m.TotalTxs, m.BlockInterval)
+	}
+	return m
+}
+
+func stepLabel(step RoundStepType) string {
+	return strings.ToLower(strings.TrimPrefix(step.String(), "RoundStep"))
+}
+
+// recordCommit updates the metrics with a block committed at round,
+// lastValidators are the ones who signed its LastCommit.
+func (m *Metrics) recordCommit(block *types.Block, round int, lastBlockTime time.Time, lastValidators, validators *types.ValidatorSet) {
+	m.Height.Set(float64(block.Height))
+	m.Rounds.Set(float64(round))
+	m.NumTxs.Set(float64(block.NumTxs))
+	m.TotalTxs.Add(float64(block.NumTxs))
+	if !lastBlockTime.IsZero() {
+		m.BlockInterval.Observe(block.Time.Sub(lastBlockTime).Seconds())
+	}
+	m.Validators.Set(float64(validators.Size()))
+	m.ValidatorsPower.Set(float64(validators.TotalVotingPower()))
+
+	if block.LastCommit == nil || len(block.LastCommit.Precommits) == 0 || lastValidators == nil {
+		return
+	}
+	missing, missingPower := 0, int64(0)
+	lastValidators.Iterate(func(i int, val *types.Validator) bool {
+		if i < len(block.LastCommit.Precommits) && block.LastCommit.Precommits[i] != nil {
+			return false
+		}
+		missing++
+		missingPower += val.VotingPower
+		m.MissedSignatures.WithLabelValues(fmt.Sprintf("%X", val.Address)).Inc()
+		return false
+	})
+	m.MissingValidators.Set(float64(missing))
+	m.MissingValidatorsPower.Set(float64(missingPower))
+}
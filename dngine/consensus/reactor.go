@@ -36,6 +36,7 @@ type ConsensusReactor struct {
 	p2p.BaseReactor // BaseService + p2p.Switch
 
 	conS     *ConsensusState
+	mtx      sync.RWMutex
 	fastSync bool
 	evsw     types.EventSwitch
 
@@ -52,18 +53,29 @@ func NewConsensusReactor(logger *zap.Logger, consensusState *ConsensusState, fas
 		slogger: logger.Sugar(),
 	}
 	conR.BaseReactor = *p2p.NewBaseReactor(logger, "ConsensusReactor", conR)
+	consensusState.SetPeerReporter(conR.reportPeer)
 	return conR
 }
 
+// reportPeer forwards the behaviour of a still connected peer to the switch
+func (conR *ConsensusReactor) reportPeer(peerKey string, b p2p.PeerBehaviour, reason interface{}) {
+	if conR.Switch == nil {
+		return
+	}
+	if peer := conR.Switch.Peers().Get(peerKey); peer != nil {
+		conR.Switch.ReportPeerBehaviour(peer, b, reason)
+	}
+}
+
 func (conR *ConsensusReactor) OnStart() error {
-	conR.logger.Info("ConsensusReactor ", zap.Bool("fastSync", conR.fastSync))
+	conR.logger.Info("ConsensusReactor ", zap.Bool("fastSync", conR.FastSync()))
 	conR.BaseReactor.OnStart()
 
 	// callbacks for broadcasting new steps and votes to peers
 	// upon their respective events (ie. uses evsw)
 	conR.registerEventCallbacks()
 
-	if !conR.fastSync {
+	if !conR.FastSync() {
 		_, err := conR.conS.Start()
 		if err != nil {
 			return err
@@ -80,34 +92,18 @@ func (conR *ConsensusReactor) OnStop() {
 // Switch from the fast_sync to the consensus:
 // reset the state, turn off fast_sync, start the consensus-state-machine
 func (conR *ConsensusReactor) SwitchToConsensus(state *sm.State) {
-	cs := conR.conS
-
-	// Reset fields based on state.
-	validators := state.Validators
-	height := state.LastBlockHeight + 1 // Next desired block height
-
-	// RoundState fields
-	cs.updateHeight(height)
-	cs.updateRoundStep(0, RoundStepNewHeight)
-	cs.StartTime = cs.timeoutParams.Commit(time.Now())
-	cs.Validators = validators
-	cs.Proposal = nil
-	cs.ProposalBlock = nil
-	cs.ProposalBlockParts = nil
-	cs.LockedRound = 0
-	cs.LockedBlock = nil
-	cs.LockedBlockParts = nil
-	cs.Votes = NewHeightVoteSet(cs.config.GetString("chain_id"), height, validators)
-	cs.CommitRound = -1
-	cs.reconstructLastCommit(state)
-	cs.LastValidators = state.LastValidators
-	cs.state = state
-
-	// Finally, broadcast RoundState
-	cs.newStep()
-
+	conR.conS.ResetToState(state)
+	conR.mtx.Lock()
 	conR.fastSync = false
-	cs.Start()
+	conR.mtx.Unlock()
+	conR.conS.Start()
+}
+
+// FastSync tells whether the blocks are still fast synced, the consensus waits for them
+func (conR *ConsensusReactor) FastSync() bool {
+	conR.mtx.RLock()
+	defer conR.mtx.RUnlock()
+	return conR.fastSync
 }
 
 // Implements Reactor
@@ -157,7 +153,7 @@ func (conR *ConsensusReactor) AddPeer(peer *p2p.Peer) {
 
 	// Send our state to peer.
 	// If we're fast_syncing, broadcast a RoundStepMessage later upon SwitchToConsensus().
-	if !conR.fastSync {
+	if !conR.FastSync() {
 		conR.sendNewRoundStepMessage(peer)
 	}
 }
@@ -186,7 +182,7 @@ func (conR *ConsensusReactor) Receive(chID byte, src *p2p.Peer, msgBytes []byte)
 	_, msg, err := DecodeMessage(msgBytes)
 	if err != nil {
 		conR.slogger.Warnw("Error decoding message", "src", src, "chId", chID, "msg", msg, "error", err, "bytes", msgBytes)
-		// TODO punish peer?
+		conR.Switch.ReportPeerBehaviour(src, p2p.PeerBehaviourBadMessage, err)
 		return
 	}
 	conR.slogger.Debugw("Receive", "src", src, "chId", chID, "msg", msg)
@@ -238,7 +234,7 @@ func (conR *ConsensusReactor) Receive(chID byte, src *p2p.Peer, msgBytes []byte)
 		}
 
 	case DataChannel:
-		if conR.fastSync {
+		if conR.FastSync() {
 			conR.slogger.Warnw("Ignoring message received during fastSync", "msg", msg)
 			return
 		}
@@ -256,7 +252,7 @@ func (conR *ConsensusReactor) Receive(chID byte, src *p2p.Peer, msgBytes []byte)
 		}
 
 	case VoteChannel:
-		if conR.fastSync {
+		if conR.FastSync() {
 			conR.slogger.Warnw("Ignoring message received during fastSync", "msg", msg)
 			return
 		}
@@ -278,7 +274,7 @@ func (conR *ConsensusReactor) Receive(chID byte, src *p2p.Peer, msgBytes []byte)
 		}
 
 	case VoteSetBitsChannel:
-		if conR.fastSync {
+		if conR.FastSync() {
 			conR.slogger.Warnw("Ignoring message received during fastSync", "msg", msg)
 			return
 		}
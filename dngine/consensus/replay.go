@@ -18,30 +18,18 @@ import (
 
 	sm "github.com/DelosIsland/core/dngine/state"
 	"github.com/DelosIsland/core/dngine/types"
-	auto "github.com/DelosIsland/core/module/lib/go-autofile"
 	. "github.com/DelosIsland/core/module/lib/go-common"
-	"github.com/DelosIsland/core/module/lib/go-wire"
 )
 
-// Unmarshal and apply a single message to the consensus state
+// Apply a single message to the consensus state
 // as if it were received in receiveRoutine
-// Lines that start with "#" are ignored.
+// Height markers are ignored.
 // NOTE: receiveRoutine should not be running
-func (cs *ConsensusState) readReplayMessage(msgBytes []byte, newStepCh chan interface{}) error {
-	// Skip over empty and meta lines
-	if len(msgBytes) == 0 || msgBytes[0] == '#' {
-		return nil
-	}
-	var err error
-	var msg TimedWALMessage
-	wire.ReadJSON(&msg, msgBytes, &err)
-	if err != nil {
-		fmt.Println("MsgBytes:", msgBytes, string(msgBytes))
-		return fmt.Errorf("Error reading json data: %v", err)
-	}
-
+func (cs *ConsensusState) readReplayMessage(msg *TimedWALMessage, newStepCh chan interface{}) error {
 	// for logging
 	switch m := msg.Msg.(type) {
+	case heightMarker:
+		return nil
 	case types.EventDataRoundState:
 		cs.logger.Info("Replay: New Step", zap.Int("height", m.Height), zap.Int("round", m.Round), zap.String("step", m.Step))
 		// these are playback checks
@@ -98,31 +86,29 @@ func (cs *ConsensusState) catchupReplay(csHeight int) error {
 	defer func() { cs.replayMode = false }()
 
 	// Ensure that height+1 doesn't exist
-	gr, found, err := cs.wal.group.Search("#HEIGHT: ", makeHeightSearchFunc(csHeight+1))
+	dec, found, err := cs.wal.SearchForHeight(csHeight + 1)
+	if err != nil {
+		return err
+	}
 	if found {
+		dec.Close()
 		return errors.New(Fmt("WAL should not contain height %d.", csHeight+1))
 	}
-	if gr != nil {
-		gr.Close()
-	}
 
 	// Search for height marker
-	gr, found, err = cs.wal.group.Search("#HEIGHT: ", makeHeightSearchFunc(csHeight))
-	if err == io.EOF {
-		cs.logger.Warn("Replay: wal.group.Search returned EOF", zap.Int("height", csHeight))
-		return nil
-	} else if err != nil {
+	dec, found, err = cs.wal.SearchForHeight(csHeight)
+	if err != nil {
 		return err
 	}
 	if !found {
 		return errors.New(Fmt("WAL does not contain height %d.", csHeight))
 	}
-	defer gr.Close()
+	defer dec.Close()
 
 	cs.logger.Info("Catchup by replaying consensus messages", zap.Int("height", csHeight))
 
 	for {
-		line, err := gr.ReadLine()
+		msg, err := dec.Decode()
 		if err != nil {
 			if err == io.EOF {
 				break
@@ -133,7 +119,7 @@ func (cs *ConsensusState) catchupReplay(csHeight int) error {
 		// NOTE: since the priv key is set when the msgs are received
 		// it will attempt to eg double sign but we can just ignore it
 		// since the votes will be replayed and we'll get to the next step
-		if err := cs.readReplayMessage([]byte(line), nil); err != nil {
+		if err := cs.readReplayMessage(msg, nil); err != nil {
 			return err
 		}
 	}
@@ -178,12 +164,18 @@ func (cs *ConsensusState) replay(file string, console bool) error {
 	defer pb.fp.Close()
 
 	var nextN int // apply N msgs in a row
-	for pb.scanner.Scan() {
+	for {
+		msg, err := pb.dec.Decode()
+		if err == io.EOF {
+			break
+		} else if err != nil {
+			return err
+		}
 		if nextN == 0 && console {
 			nextN = pb.replayConsoleLoop()
 		}
 
-		if err := pb.cs.readReplayMessage(pb.scanner.Bytes(), newStepCh); err != nil {
+		if err := pb.cs.readReplayMessage(msg, newStepCh); err != nil {
 			return err
 		}
 
@@ -201,9 +193,9 @@ func (cs *ConsensusState) replay(file string, console bool) error {
 type playback struct {
 	cs *ConsensusState
 
-	fp      *os.File
-	scanner *bufio.Scanner
-	count   int // how many lines/msgs into the file are we
+	fp    *os.File
+	dec   *WALDecoder
+	count int // how many msgs into the file are we
 
 	// replays can be reset to beginning
 	fileName     string    // so we can close/reopen the file
@@ -218,7 +210,7 @@ func newPlayback(slogger *zap.SugaredLogger, fileName string, fp *os.File, cs *C
 		fp:           fp,
 		fileName:     fileName,
 		genesisState: genState,
-		scanner:      bufio.NewScanner(fp),
+		dec:          NewWALDecoder(fp),
 		slogger:      slogger,
 	}
 }
@@ -239,13 +231,19 @@ func (pb *playback) replayReset(count int, newStepCh chan interface{}) error {
 		return err
 	}
 	pb.fp = fp
-	pb.scanner = bufio.NewScanner(fp)
+	pb.dec = NewWALDecoder(fp)
 	count = pb.count - count
 	pb.slogger.Infof("Reseting from %d to %d", pb.count, count)
 	pb.count = 0
 	pb.cs = newCS
-	for i := 0; pb.scanner.Scan() && i < count; i++ {
-		if err := pb.cs.readReplayMessage(pb.scanner.Bytes(), newStepCh); err != nil {
+	for i := 0; i < count; i++ {
+		msg, err := pb.dec.Decode()
+		if err == io.EOF {
+			break
+		} else if err != nil {
+			return err
+		}
+		if err := pb.cs.readReplayMessage(msg, newStepCh); err != nil {
 			return err
 		}
 		pb.count += 1
@@ -361,28 +359,3 @@ func (pb *playback) replayConsoleLoop() int {
 	}
 	return 0
 }
-
-//--------------------------------------------------------------------------------
-
-// Parses marker lines of the form:
-// #HEIGHT: 12345
-func makeHeightSearchFunc(height int) auto.SearchFunc {
-	return func(line string) (int, error) {
-		line = strings.TrimRight(line, "\n")
-		parts := strings.Split(line, " ")
-		if len(parts) != 2 {
-			return -1, errors.New("Line did not have 2 parts")
-		}
-		i, err := strconv.Atoi(parts[1])
-		if err != nil {
-			return -1, errors.New("Failed to parse INFO: " + err.Error())
-		}
-		if height < i {
-			return 1, nil
-		} else if height == i {
-			return 0, nil
-		} else {
-			return -1, nil
-		}
-	}
-}
@@ -7,7 +7,6 @@ import (
 	"bytes"
 	"errors"
 	"fmt"
-	"io"
 	"reflect"
 	"sync"
 	"time"
@@ -21,6 +20,7 @@ import (
 	"github.com/DelosIsland/core/dngine/types"
 	. "github.com/DelosIsland/core/module/lib/go-common"
 	cfg "github.com/DelosIsland/core/module/lib/go-config"
+	"github.com/DelosIsland/core/module/lib/go-p2p"
 	"github.com/DelosIsland/core/module/lib/go-wire"
 )
 
@@ -248,9 +248,15 @@ type ConsensusState struct {
 
 	evsw types.EventSwitch
 
+	// tells the switch how peers behave, set by the reactor
+	reportPeer func(peerKey string, b p2p.PeerBehaviour, reason interface{})
+
 	wal        *WAL
 	replayMode bool // so we don't log signing errors during replay
 
+	metrics   *Metrics
+	stepStart time.Time // when the current step began, for the step durations
+
 	nSteps int // used for testing to limit the number of transitions the state makes
 
 	// allow certain function to be overwritten for testing
@@ -274,6 +280,7 @@ func NewConsensusState(logger *zap.Logger, config cfg.Config, state *sm.State, b
 		timeoutTicker:    NewTimeoutTicker(logger),
 		timeoutParams:    InitTimeoutParamsFromConfig(config),
 		done:             make(chan struct{}),
+		metrics:          NewMetrics(nil),
 		logger:           logger,
 		slogger:          logger.Sugar(),
 	}
@@ -311,6 +318,16 @@ func (cs *ConsensusState) SetEventSwitch(evsw types.EventSwitch) {
 	cs.evsw = evsw
 }
 
+// SetMetrics sets the metrics updated by the state machine, call it before Start
+func (cs *ConsensusState) SetMetrics(m *Metrics) {
+	cs.metrics = m
+	m.Height.Set(float64(cs.state.LastBlockHeight))
+}
+
+func (cs *ConsensusState) SetPeerReporter(f func(peerKey string, b p2p.PeerBehaviour, reason interface{})) {
+	cs.reportPeer = f
+}
+
 func (cs *ConsensusState) String() string {
 	// better not to access shared variables
 	return Fmt("ConsensusState") //(H:%v R:%v S:%v", cs.Height, cs.Round, cs.Step)
@@ -376,16 +393,16 @@ func (cs *ConsensusState) OnStart() error {
 	// so check here and write it if not found.
 	// TODO: remove this and run the handhsake/replay
 	// through the consensus state with a mock app
-	gr, found, err := cs.wal.group.Search("#HEIGHT: ", makeHeightSearchFunc(cs.Height))
-	if (err == io.EOF || !found) && cs.Step == RoundStepNewHeight {
+	dec, found, err := cs.wal.SearchForHeight(cs.Height)
+	if err != nil {
+		return err
+	}
+	if found {
+		dec.Close()
+	} else if cs.Step == RoundStepNewHeight {
 		cs.logger.Warn("Height not found in wal. Writing new height", zap.Int("height", cs.Height))
 		rs := cs.RoundStateEvent()
 		cs.wal.Save(rs)
-	} else if err != nil {
-		return err
-	}
-	if gr != nil {
-		gr.Close()
 	}
 
 	// we need the timeoutRoutine for replay so
@@ -509,6 +526,12 @@ func (cs *ConsensusState) updateHeight(height int) {
 }
 
 func (cs *ConsensusState) updateRoundStep(round int, step RoundStepType) {
+	if now := time.Now(); round != cs.Round || step != cs.Step {
+		if !cs.stepStart.IsZero() && !cs.replayMode {
+			cs.metrics.StepDuration.WithLabelValues(stepLabel(cs.Step)).Observe(now.Sub(cs.stepStart).Seconds())
+		}
+		cs.stepStart = now
+	}
 	cs.Round = round
 	cs.Step = step
 }
@@ -538,6 +561,34 @@ func (cs *ConsensusState) sendInternalMessage(mi msgInfo) {
 	}
 }
 
+// ResetToState moves the stopped consensus to the height after the state,
+// which may have been taken several blocks further meanwhile
+func (cs *ConsensusState) ResetToState(state *sm.State) {
+	// Reset fields based on state.
+	validators := state.Validators
+	height := state.LastBlockHeight + 1 // Next desired block height
+
+	// RoundState fields
+	cs.updateHeight(height)
+	cs.updateRoundStep(0, RoundStepNewHeight)
+	cs.StartTime = cs.timeoutParams.Commit(time.Now())
+	cs.Validators = validators
+	cs.Proposal = nil
+	cs.ProposalBlock = nil
+	cs.ProposalBlockParts = nil
+	cs.LockedRound = 0
+	cs.LockedBlock = nil
+	cs.LockedBlockParts = nil
+	cs.Votes = NewHeightVoteSet(cs.config.GetString("chain_id"), height, validators)
+	cs.CommitRound = -1
+	cs.reconstructLastCommit(state)
+	cs.LastValidators = state.LastValidators
+	cs.state = state
+
+	// Finally, broadcast RoundState
+	cs.newStep()
+}
+
 // Reconstruct LastCommit from SeenCommit, which we saved along with the block,
 // (which happens even before saving the state)
 func (cs *ConsensusState) reconstructLastCommit(state *sm.State) {
@@ -711,9 +762,14 @@ func (cs *ConsensusState) handleMsg(mi msgInfo, rs RoundState) {
 	case *VoteMessage:
 		// attempt to add the vote and dupeout the validator if its a duplicate signature
 		// if the vote gives us a 2/3-any or 2/3-one, we transition
-		err := cs.tryAddVote(msg.Vote, peerKey)
-		if err == ErrAddingVote {
-			// TODO: punish peer
+		added, err := cs.tryAddVote(msg.Vote, peerKey)
+		if peerKey != "" && cs.reportPeer != nil {
+			if err == ErrAddingVote {
+				cs.reportPeer(peerKey, p2p.PeerBehaviourBadVote, err)
+			} else if added {
+				// the votes we already had are just gossip
+				cs.reportPeer(peerKey, p2p.PeerBehaviourGoodVote, nil)
+			}
 		}
 
 		// NOTE: the vote is broadcast to peers by the reactor listening
@@ -1291,6 +1347,8 @@ func (cs *ConsensusState) finalizeCommit(height int) {
 		// TODO!
 	}
 
+	cs.metrics.recordCommit(block, cs.CommitRound, cs.state.LastBlockTime, cs.LastValidators, stateCopy.Validators)
+
 	// Fire off event for new block.
 	// TODO: Handle app failure.  See #177
 	types.FireEventNewBlock(cs.evsw, types.EventDataNewBlock{block})
@@ -1382,19 +1440,19 @@ func (cs *ConsensusState) addProposalBlockPart(height int, part *types.Part, ver
 	return added, nil
 }
 
-// Attempt to add the vote. if its a duplicate signature, dupeout the validator
-func (cs *ConsensusState) tryAddVote(vote *types.Vote, peerKey string) error {
-	_, err := cs.addVote(vote, peerKey)
+// Attempt to add the vote, tells whether it is new. if its a duplicate signature, dupeout the validator
+func (cs *ConsensusState) tryAddVote(vote *types.Vote, peerKey string) (bool, error) {
+	added, err := cs.addVote(vote, peerKey)
 	if err != nil {
 		// If the vote height is off, we'll just ignore it,
 		// But if it's a conflicting sig, broadcast evidence tx for slashing.
 		// If it's otherwise invalid, punish peer.
 		if err == ErrVoteHeightMismatch {
-			return err
+			return false, err
 		} else if _, ok := err.(*types.ErrVoteConflictingVotes); ok {
 			if peerKey == "" {
 				cs.logger.Warn("Found conflicting vote from ourselves. Did you unsafe_reset a validator?", zap.Int("height", vote.Height), zap.Int("round", vote.Round), zap.Binary("type", []byte{vote.Type}))
-				return err
+				return false, err
 			}
 			cs.logger.Warn("Found conflicting vote. Publish evidence (TODO)")
 			/* TODO
@@ -1405,14 +1463,14 @@ func (cs *ConsensusState) tryAddVote(vote *types.Vote, peerKey string) error {
 			}
 			cs.mempool.BroadcastTx(struct{???}{evidenceTx}) // shouldn't need to check returned err
 			*/
-			return err
+			return false, err
 		} else {
 			// Probably an invalid signature. Bad peer.
 			cs.logger.Warn("Error attempting to add vote", zap.String("error", err.Error()))
-			return ErrAddingVote
+			return false, ErrAddingVote
 		}
 	}
-	return nil
+	return added, nil
 }
 
 //-----------------------------------------------------------------------------
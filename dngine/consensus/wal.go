@@ -4,6 +4,7 @@
 package consensus
 
 import (
+	"io"
 	"time"
 
 	"go.uber.org/zap"
@@ -24,36 +25,113 @@ type TimedWALMessage struct {
 
 type WALMessage interface{}
 
+// heightMarker is written before the messages of a height
+type heightMarker struct {
+	Height int `json:"height"`
+}
+
 var _ = wire.RegisterInterface(
 	struct{ WALMessage }{},
 	wire.ConcreteType{types.EventDataRoundState{}, 0x01},
 	wire.ConcreteType{msgInfo{}, 0x02},
 	wire.ConcreteType{timeoutInfo{}, 0x03},
+	wire.ConcreteType{heightMarker{}, 0x04},
 )
 
+//--------------------------------------------------------
+// the WAL is a series of wire records of TimedWALMessage
+
+const maxWALRecordSize = 10 * 1024 * 1024 // a larger length is taken for a corruption
+
+// WALEncoder writes length prefixed, checksummed records
+type WALEncoder struct {
+	wr *wire.RecordWriter
+}
+
+func NewWALEncoder(wr io.Writer) *WALEncoder {
+	return &WALEncoder{wire.NewRecordWriter(wr, maxWALRecordSize)}
+}
+
+// Encode writes the record with a single Write so that it is never interleaved
+func (enc *WALEncoder) Encode(msg *TimedWALMessage) error {
+	return enc.wr.WriteRecord(*msg)
+}
+
+// WALDecoder reads the records written by WALEncoder
+type WALDecoder struct {
+	src io.Reader
+	rd  *wire.RecordReader
+}
+
+func NewWALDecoder(rd io.Reader) *WALDecoder {
+	return &WALDecoder{src: rd, rd: wire.NewRecordReader(rd, maxWALRecordSize)}
+}
+
+// Offset of the next record from the start of the reader
+func (dec *WALDecoder) Offset() int64 {
+	return dec.rd.Offset()
+}
+
+// Decode returns io.EOF at the end of a sound WAL, a *WALCorruption
+// with the offset of the record when it is torn or doesn't match its checksum.
+func (dec *WALDecoder) Decode() (*TimedWALMessage, error) {
+	var msg TimedWALMessage
+	if err := dec.rd.ReadRecord(&msg); err != nil {
+		if c, ok := err.(*wire.RecordCorruption); ok {
+			return nil, &WALCorruption{Offset: c.Offset, Reason: c.Reason}
+		}
+		return nil, err
+	}
+	return &msg, nil
+}
+
+// Close closes the reader if it can be
+func (dec *WALDecoder) Close() error {
+	if c, ok := dec.src.(io.Closer); ok {
+		return c.Close()
+	}
+	return nil
+}
+
 //--------------------------------------------------------
 // Simple write-ahead logger
 
 // Write ahead logger writes msgs to disk before they are processed.
 // Can be used for crash-recovery and deterministic replay
+// The records are buffered and synced to the disk together every walSyncInterval,
+// except for the height markers and our own messages which are synced right away
+// since they are signed and sent once saved.
 // TODO: currently the wal is overwritten during replay catchup
 //   give it a mode so it's either reading or appending - must read to end to start appending again
 type WAL struct {
 	BaseService
 
 	group *auto.Group
+	enc   *WALEncoder
 	light bool // ignore block parts
 
 	logger *zap.Logger
 }
 
+// buffered records are synced at least that often
+const walSyncInterval = 2 * time.Second
+
 func NewWAL(logger *zap.Logger, walDir string, light bool) (*WAL, error) {
+	if err := migrateJSONWAL(logger, walDir); err != nil {
+		return nil, err
+	}
+	if c, err := repairWALHead(walDir); err != nil {
+		return nil, err
+	} else if c != nil {
+		logger.Warn("Truncated the corrupted end of the consensus wal", zap.Int64("offset", c.Offset), zap.String("reason", c.Reason))
+	}
 	group, err := auto.OpenGroup(walDir + "/wal")
 	if err != nil {
 		return nil, err
 	}
 	wal := &WAL{
 		group:  group,
+		enc:    NewWALEncoder(group),
 		light:  light,
 		logger: logger,
 	}
@@ -64,21 +142,35 @@ func NewWAL(logger *zap.Logger, walDir string, light bool) (*WAL, error) {
 
 func (wal *WAL) OnStart() error {
 	wal.BaseService.OnStart()
-	size, err := wal.group.Head.Size()
-	if err != nil {
-		return err
-	} else if size == 0 {
+	if wal.group.ReadGroupInfo().TotalSize == 0 {
 		wal.writeHeight(1)
 	}
-	_, err = wal.group.Start()
-	return err
+	if _, err := wal.group.Start(); err != nil {
+		return err
+	}
+	go wal.syncRoutine()
+	return nil
 }
 
 func (wal *WAL) OnStop() {
 	wal.BaseService.OnStop()
+	wal.sync()
 	wal.group.Stop()
 }
 
+func (wal *WAL) syncRoutine() {
+	ticker := time.NewTicker(walSyncInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			wal.sync()
+		case <-wal.Quit:
+			return
+		}
+	}
+}
+
 // called in newStep and for each pass in receiveRoutine
 func (wal *WAL) Save(wmsg WALMessage) {
 	if wal == nil {
@@ -92,29 +184,61 @@ func (wal *WAL) Save(wmsg WALMessage) {
 			}
 		}
 	}
-	// Write #HEIGHT: XYZ if new height
+	// Write the height marker if new height
 	if edrs, ok := wmsg.(types.EventDataRoundState); ok {
 		if edrs.Step == RoundStepNewHeight.String() {
 			wal.writeHeight(edrs.Height)
 		}
 	}
 	// Write the wal message
-	var wmsgBytes = wire.JSONBytes(TimedWALMessage{time.Now(), wmsg})
-	err := wal.group.WriteLine(string(wmsgBytes))
-	if err != nil {
+	if err := wal.enc.Encode(&TimedWALMessage{time.Now(), wmsg}); err != nil {
 		PanicQ(Fmt("Error writing msg to consensus wal. Error: %v \n\nMessage: %v", err, wmsg))
 	}
-	// TODO: only flush when necessary
-	if err := wal.group.Flush(); err != nil {
-		PanicQ(Fmt("Error flushing consensus wal buf to file. Error: %v \n", err))
+	if mi, ok := wmsg.(msgInfo); ok && mi.PeerKey == "" {
+		wal.sync()
 	}
 }
 
 func (wal *WAL) writeHeight(height int) {
-	wal.group.WriteLine(Fmt("#HEIGHT: %v", height))
+	if err := wal.enc.Encode(&TimedWALMessage{time.Now(), heightMarker{height}}); err != nil {
+		PanicQ(Fmt("Error writing height to consensus wal. Error: %v \n", err))
+	}
+	wal.sync()
+}
+
+func (wal *WAL) sync() {
+	if err := wal.group.FlushAndSync(); err != nil {
+		PanicQ(Fmt("Error syncing consensus wal to disk. Error: %v \n", err))
+	}
+}
 
-	// TODO: only flush when necessary
-	if err := wal.group.Flush(); err != nil {
-		PanicQ(Fmt("Error flushing consensus wal buf to file. Error: %v \n", err))
+// SearchForHeight returns a decoder reading the messages from the marker of height on.
+// The files are searched from the newest. A corrupted file is searched up to the corruption.
+// CONTRACT: the caller must close the returned decoder
+func (wal *WAL) SearchForHeight(height int) (*WALDecoder, bool, error) {
+	info := wal.group.ReadGroupInfo()
+	for index := info.MaxIndex; index >= info.MinIndex; index-- {
+		gr, err := wal.group.NewReader(index)
+		if err != nil {
+			return nil, false, err
+		}
+		dec := NewWALDecoder(gr)
+		for {
+			msg, err := dec.Decode()
+			if err == io.EOF || gr.CurIndex() > index {
+				break
+			} else if _, ok := err.(*WALCorruption); ok {
+				wal.logger.Warn("Corrupted consensus wal", zap.Int("file", index), zap.String("error", err.Error()))
+				break
+			} else if err != nil {
+				dec.Close()
+				return nil, false, err
+			}
+			if m, ok := msg.Msg.(heightMarker); ok && m.Height == height {
+				return dec, true, nil
+			}
+		}
+		dec.Close()
 	}
+	return nil, false, nil
 }
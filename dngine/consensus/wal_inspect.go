@@ -0,0 +1,206 @@
+// Copyright 2017 Delos Development Foundation and contributors. Licensed
+// under the Apache License, Version 2.0. See the COPYING file at the root
+// of this distribution or at http://www.apache.org/licenses/LICENSE-2.0
+package consensus
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"reflect"
+	"strconv"
+	"strings"
+
+	"github.com/DelosIsland/core/dngine/types"
+	auto "github.com/DelosIsland/core/module/lib/go-autofile"
+)
+
+//--------------------------------------------------------
+// offline reading and repair of the WAL, the node must be stopped
+
+const walHeightMarker = "#HEIGHT: "
+
+// WALRecord is a height marker or a message read back from the WAL
+type WALRecord struct {
+	Index  int   // file of the group holding the record
+	Offset int64 // of the record in its file
+	Height int   // of the marker, or of the last marker before the message
+	Marker bool
+	Msg    TimedWALMessage
+}
+
+// Kind names the type of the record
+func (r *WALRecord) Kind() string {
+	if r.Marker {
+		return "Height"
+	}
+	switch m := r.Msg.Msg.(type) {
+	case types.EventDataRoundState:
+		return "RoundState"
+	case timeoutInfo:
+		return "Timeout"
+	case msgInfo:
+		name := reflect.TypeOf(m.Msg).String()
+		return strings.TrimSuffix(name[strings.LastIndex(name, ".")+1:], "Message")
+	}
+	return reflect.TypeOf(r.Msg.Msg).String()
+}
+
+func (r *WALRecord) String() string {
+	if r.Marker {
+		return walHeightMarker + strconv.Itoa(r.Height)
+	}
+	var desc string
+	switch m := r.Msg.Msg.(type) {
+	case types.EventDataRoundState:
+		desc = fmt.Sprintf("[RoundState %v/%v %v]", m.Height, m.Round, m.Step)
+	case timeoutInfo:
+		desc = fmt.Sprintf("[Timeout %v]", &m)
+	case msgInfo:
+		peerKey := m.PeerKey
+		if peerKey == "" {
+			peerKey = "local"
+		}
+		if bp, ok := m.Msg.(*BlockPartMessage); ok {
+			// the part itself is unreadable
+			desc = fmt.Sprintf("[BlockPart H:%v R:%v #%v]", bp.Height, bp.Round, bp.Part.Index)
+		} else {
+			desc = fmt.Sprintf("%v", m.Msg)
+		}
+		desc += " peer:" + peerKey
+	default:
+		desc = fmt.Sprintf("%v", m)
+	}
+	return r.Msg.Time.Format("2006-01-02 15:04:05.000") + " " + desc
+}
+
+// WALCorruption is where the readable part of the WAL ends
+type WALCorruption struct {
+	Index  int
+	Offset int64
+	Path   string
+	Reason string
+}
+
+func (c *WALCorruption) Error() string {
+	return fmt.Sprintf("corrupted WAL record in %s at offset %d: %s", c.Path, c.Offset, c.Reason)
+}
+
+func openWALGroup(walDir string) (*auto.Group, error) {
+	headPath := walDir + "/wal"
+	// don't create an empty WAL where there was none
+	if _, err := os.Stat(headPath); err != nil {
+		return nil, err
+	}
+	return auto.OpenGroup(headPath)
+}
+
+// ScanWAL reads the records of the WAL in walDir in order until fn returns false.
+// It stops at the first record which can't be decoded with a *WALCorruption error,
+// a truncated last record is one too.
+func ScanWAL(walDir string, fn func(*WALRecord) bool) error {
+	if isJSONWAL(walDir) {
+		return fmt.Errorf("%s holds a WAL in the former JSON format, migrate it first", walDir)
+	}
+	group, err := openWALGroup(walDir)
+	if err != nil {
+		return err
+	}
+	defer group.Head.Close()
+
+	info := group.ReadGroupInfo()
+	height := 0
+	for index := info.MinIndex; index <= info.MaxIndex; index++ {
+		more, err := scanWALFile(group.FilePath(index), index, &height, fn)
+		if err != nil || !more {
+			return err
+		}
+	}
+	return nil
+}
+
+func scanWALFile(path string, index int, height *int, fn func(*WALRecord) bool) (bool, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return false, err
+	}
+	defer f.Close()
+
+	dec := NewWALDecoder(f)
+	for {
+		offset := dec.Offset()
+		msg, err := dec.Decode()
+		if err == io.EOF {
+			return true, nil
+		} else if c, ok := err.(*WALCorruption); ok {
+			c.Index, c.Path = index, path
+			return false, c
+		} else if err != nil {
+			return false, err
+		}
+
+		record := &WALRecord{Index: index, Offset: offset, Height: *height, Msg: *msg}
+		if m, ok := msg.Msg.(heightMarker); ok {
+			record.Height, record.Marker = m.Height, true
+		}
+		*height = record.Height
+		if !fn(record) {
+			return false, nil
+		}
+	}
+}
+
+// repairWALHead cuts the head file of the WAL in walDir back to the end of its last good record,
+// so that a record torn by a crash isn't followed by the new ones.
+// It returns the corruption which was removed, nil if the head was sound or missing.
+func repairWALHead(walDir string) (*WALCorruption, error) {
+	headPath := walDir + "/wal"
+	if _, err := os.Stat(headPath); os.IsNotExist(err) {
+		return nil, nil
+	}
+	height := 0
+	_, err := scanWALFile(headPath, 0, &height, func(*WALRecord) bool { return true })
+	corruption, ok := err.(*WALCorruption)
+	if !ok {
+		return nil, err
+	}
+	return corruption, os.Truncate(headPath, corruption.Offset)
+}
+
+// RepairWAL cuts the WAL in walDir back to the end of its last good record.
+// It returns the corruption which was removed, nil if the WAL was sound.
+func RepairWAL(walDir string) (*WALCorruption, error) {
+	err := ScanWAL(walDir, func(*WALRecord) bool { return true })
+	corruption, ok := err.(*WALCorruption)
+	if !ok {
+		return nil, err
+	}
+
+	group, err := openWALGroup(walDir)
+	if err != nil {
+		return nil, err
+	}
+	info := group.ReadGroupInfo()
+	headPath := group.FilePath(info.MaxIndex)
+	paths := make([]string, 0, info.MaxIndex-corruption.Index+1)
+	for index := corruption.Index; index <= info.MaxIndex; index++ {
+		paths = append(paths, group.FilePath(index))
+	}
+	group.Head.Close()
+
+	if err := os.Truncate(corruption.Path, corruption.Offset); err != nil {
+		return nil, err
+	}
+	if corruption.Path != headPath {
+		// the files written after are dropped, the truncated one becomes the head
+		for _, path := range paths[1:] {
+			if err := os.Remove(path); err != nil {
+				return nil, err
+			}
+		}
+		if err := os.Rename(corruption.Path, headPath); err != nil {
+			return nil, err
+		}
+	}
+	return corruption, nil
+}
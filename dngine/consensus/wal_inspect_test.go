@@ -0,0 +1,125 @@
+// Copyright 2017 Delos Development Foundation and contributors. Licensed
+// under the Apache License, Version 2.0. See the COPYING file at the root
+// of this distribution or at http://www.apache.org/licenses/LICENSE-2.0
+package consensus
+
+import (
+	"io/ioutil"
+	"os"
+	"testing"
+	"time"
+
+	"go.uber.org/zap"
+
+	"github.com/DelosIsland/core/dngine/types"
+)
+
+// writeTestWAL writes heights 1 to 3 with a rotation after height 2
+func writeTestWAL(t *testing.T) string {
+	dir, err := ioutil.TempDir("", "wal_inspect")
+	if err != nil {
+		t.Fatal(err)
+	}
+	wal, err := NewWAL(zap.NewNop(), dir, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for h := 1; h <= 3; h++ {
+		if h > 1 {
+			wal.Save(types.EventDataRoundState{Height: h, Step: RoundStepNewHeight.String()})
+		}
+		wal.Save(timeoutInfo{Duration: time.Second, Height: h, Step: RoundStepNewHeight})
+		wal.Save(msgInfo{Msg: &VoteMessage{&types.Vote{Height: h, Type: types.VoteTypePrevote}}})
+		if h == 2 {
+			wal.group.RotateFile()
+		}
+	}
+	wal.Stop()
+	wal.group.Head.Close()
+	return dir
+}
+
+func scanTestWAL(t *testing.T, dir string) ([]*WALRecord, error) {
+	var records []*WALRecord
+	err := ScanWAL(dir, func(r *WALRecord) bool {
+		records = append(records, r)
+		return true
+	})
+	return records, err
+}
+
+func appendTestWAL(t *testing.T, path, data string) {
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_APPEND, 0600)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	if _, err := f.WriteString(data); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestScanWAL(t *testing.T) {
+	dir := writeTestWAL(t)
+	defer os.RemoveAll(dir)
+
+	records, err := scanTestWAL(t, dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	kinds := []string{"Height", "Timeout", "Vote",
+		"Height", "RoundState", "Timeout", "Vote",
+		"Height", "RoundState", "Timeout", "Vote"}
+	if len(records) != len(kinds) {
+		t.Fatalf("Expected %d records, got %d", len(kinds), len(records))
+	}
+	for i, r := range records {
+		if r.Kind() != kinds[i] {
+			t.Errorf("Expected record %d to be a %s, got %s (%v)", i, kinds[i], r.Kind(), r)
+		}
+	}
+	if last := records[len(records)-1]; last.Height != 3 || last.Index != 1 {
+		t.Errorf("Expected the last record at height 3 in the head, got %d in %d", last.Height, last.Index)
+	}
+
+	// the scan stops when asked to
+	n := 0
+	if err := ScanWAL(dir, func(r *WALRecord) bool { n++; return r.Height < 2 }); err != nil || n != 4 {
+		t.Errorf("Expected the scan to stop at the first record of height 2, read %d: %v", n, err)
+	}
+}
+
+func TestRepairWAL(t *testing.T) {
+	dir := writeTestWAL(t)
+	defer os.RemoveAll(dir)
+
+	// a torn write at the end of the head
+	appendTestWAL(t, dir+"/wal", `{"time":"2017-01-01T00:00:00Z","msg":[2,{"msg"`)
+	if _, err := scanTestWAL(t, dir); err == nil {
+		t.Fatal("Expected the truncated record to be detected")
+	}
+	c, err := RepairWAL(dir)
+	if err != nil || c == nil || c.Index != 1 {
+		t.Fatalf("Expected the head to be repaired, got %v %v", c, err)
+	}
+	if records, err := scanTestWAL(t, dir); err != nil || len(records) != 11 {
+		t.Fatalf("Expected the 11 records to be kept, got %d: %v", len(records), err)
+	}
+	if c, err := RepairWAL(dir); c != nil || err != nil {
+		t.Errorf("Expected a sound WAL, got %v %v", c, err)
+	}
+
+	// garbage in a rotated file drops all that was written after
+	appendTestWAL(t, dir+"/wal.000", "garbage\n")
+	c, err = RepairWAL(dir)
+	if err != nil || c == nil || c.Index != 0 {
+		t.Fatalf("Expected the first file to be repaired, got %v %v", c, err)
+	}
+	records, err := scanTestWAL(t, dir)
+	if err != nil || len(records) != 7 || records[len(records)-1].Height != 2 {
+		t.Fatalf("Expected the records up to height 2 to be kept, got %d: %v", len(records), err)
+	}
+	if _, err := os.Stat(dir + "/wal.000"); !os.IsNotExist(err) {
+		t.Errorf("Expected the repaired file to become the head, %v", err)
+	}
+}
@@ -0,0 +1,179 @@
+// Copyright 2017 Delos Development Foundation and contributors. Licensed
+// under the Apache License, Version 2.0. See the COPYING file at the root
+// of this distribution or at http://www.apache.org/licenses/LICENSE-2.0
+package consensus
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+
+	"go.uber.org/zap"
+
+	auto "github.com/DelosIsland/core/module/lib/go-autofile"
+	"github.com/DelosIsland/core/module/lib/go-wire"
+)
+
+//--------------------------------------------------------
+// migration from the former WAL format: a JSON TimedWALMessage per line
+// and a "#HEIGHT: <height>" line before the messages of a height
+
+// the WAL in the former format is kept there after the migration
+const jsonWALBackupSuffix = ".json"
+
+// isJSONWAL tells whether the first file of the WAL in walDir is in the former format
+func isJSONWAL(walDir string) bool {
+	group, err := openWALGroup(walDir)
+	if err != nil {
+		return false
+	}
+	path := group.FilePath(group.ReadGroupInfo().MinIndex)
+	group.Head.Close()
+
+	f, err := os.Open(path)
+	if err != nil {
+		return false
+	}
+	defer f.Close()
+	start := make([]byte, len(walHeightMarker))
+	if _, err := io.ReadFull(f, start); err != nil {
+		return false
+	}
+	return bytes.Equal(start, []byte(walHeightMarker)) || bytes.HasPrefix(start, []byte(`{"time"`))
+}
+
+// MigrateWAL rewrites a WAL in the former JSON format in the binary one, the former is kept
+// next to walDir. A corrupted tail is dropped and returned. It does nothing to a binary WAL.
+func MigrateWAL(walDir string) (*WALCorruption, error) {
+	if !isJSONWAL(walDir) {
+		return nil, nil
+	}
+	backupDir := walDir + jsonWALBackupSuffix
+	if _, err := os.Stat(backupDir); err == nil {
+		return nil, fmt.Errorf("%s already exists, move it away to migrate the WAL", backupDir)
+	}
+
+	group, err := openWALGroup(walDir)
+	if err != nil {
+		return nil, err
+	}
+	info := group.ReadGroupInfo()
+	paths := make([]string, 0, info.MaxIndex-info.MinIndex+1)
+	for index := info.MinIndex; index <= info.MaxIndex; index++ {
+		paths = append(paths, group.FilePath(index))
+	}
+	group.Head.Close()
+
+	tmpDir := walDir + ".migrating"
+	if err := os.RemoveAll(tmpDir); err != nil {
+		return nil, err
+	}
+	if err := os.MkdirAll(tmpDir, 0700); err != nil {
+		return nil, err
+	}
+	newGroup, err := auto.OpenGroup(tmpDir + "/wal")
+	if err != nil {
+		return nil, err
+	}
+	enc := NewWALEncoder(newGroup)
+	var corruption *WALCorruption
+	for i, path := range paths {
+		if corruption, err = migrateJSONWALFile(path, info.MinIndex+i, enc); err != nil || corruption != nil {
+			break
+		}
+	}
+	if err == nil {
+		err = newGroup.FlushAndSync()
+	}
+	newGroup.Head.Close()
+	if err != nil {
+		return nil, err
+	}
+
+	if err := os.Rename(walDir, backupDir); err != nil {
+		return nil, err
+	}
+	return corruption, os.Rename(tmpDir, walDir)
+}
+
+func migrateJSONWALFile(path string, index int, enc *WALEncoder) (*WALCorruption, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	r := bufio.NewReader(f)
+	var offset int64
+	for {
+		line, err := r.ReadBytes('\n')
+		if err == io.EOF {
+			if len(line) > 0 {
+				return &WALCorruption{index, offset, path, "truncated record"}, nil
+			}
+			return nil, nil
+		} else if err != nil {
+			return nil, err
+		}
+
+		msg, reason := decodeJSONWALLine(line[:len(line)-1])
+		if reason != "" {
+			return &WALCorruption{index, offset, path, reason}, nil
+		}
+		if msg != nil {
+			if err := enc.Encode(msg); err != nil {
+				return nil, err
+			}
+		}
+		offset += int64(len(line))
+	}
+}
+
+// decodeJSONWALLine returns nil for the lines skipped by the replay, or the reason it is unreadable
+func decodeJSONWALLine(line []byte) (*TimedWALMessage, string) {
+	if len(line) == 0 {
+		return nil, ""
+	}
+	if line[0] == '#' {
+		if !strings.HasPrefix(string(line), walHeightMarker) {
+			return nil, ""
+		}
+		height, err := strconv.Atoi(string(line[len(walHeightMarker):]))
+		if err != nil {
+			return nil, "bad height marker"
+		}
+		return &TimedWALMessage{Msg: heightMarker{height}}, ""
+	}
+
+	var err error
+	var msg TimedWALMessage
+	wire.ReadJSON(&msg, line, &err)
+	if err != nil {
+		return nil, err.Error()
+	}
+	if msg.Msg == nil {
+		return nil, "empty message"
+	}
+	return &msg, ""
+}
+
+// migrateJSONWAL migrates the WAL when the node starts
+func migrateJSONWAL(logger *zap.Logger, walDir string) error {
+	if !isJSONWAL(walDir) {
+		return nil
+	}
+	logger.Info("Migrating the consensus wal to the binary format", zap.String("dir", walDir))
+	corruption, err := MigrateWAL(walDir)
+	if err != nil {
+		return err
+	}
+	if corruption != nil {
+		logger.Warn("Dropped the corrupted tail of the consensus wal", zap.String("error", corruption.Error()))
+	}
+	logger.Info("Migrated the consensus wal", zap.String("backup", walDir+jsonWALBackupSuffix))
+	return nil
+}
@@ -0,0 +1,172 @@
+// Copyright 2017 Delos Development Foundation and contributors. Licensed
+// under the Apache License, Version 2.0. See the COPYING file at the root
+// of this distribution or at http://www.apache.org/licenses/LICENSE-2.0
+package consensus
+
+import (
+	"bytes"
+	"io"
+	"io/ioutil"
+	"os"
+	"testing"
+	"time"
+
+	"go.uber.org/zap"
+
+	"github.com/DelosIsland/core/dngine/types"
+)
+
+func TestWALEncoder(t *testing.T) {
+	buf := new(bytes.Buffer)
+	enc := NewWALEncoder(buf)
+	msgs := []TimedWALMessage{
+		{time.Unix(1, 0), heightMarker{7}},
+		{time.Unix(2, 0), types.EventDataRoundState{Height: 7, Round: 1, Step: RoundStepPropose.String()}},
+		{time.Unix(3, 0), timeoutInfo{Duration: time.Second, Height: 7, Round: 1, Step: RoundStepPrevoteWait}},
+	}
+	for i := range msgs {
+		if err := enc.Encode(&msgs[i]); err != nil {
+			t.Fatal(err)
+		}
+	}
+	data := buf.Bytes()
+
+	dec := NewWALDecoder(bytes.NewReader(data))
+	for i := range msgs {
+		msg, err := dec.Decode()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !msg.Time.Equal(msgs[i].Time) || msg.Msg != msgs[i].Msg {
+			t.Errorf("Expected %v, got %v", msgs[i], *msg)
+		}
+	}
+	if _, err := dec.Decode(); err != io.EOF {
+		t.Errorf("Expected io.EOF at the end, got %v", err)
+	}
+	if dec.Offset() != int64(len(data)) {
+		t.Errorf("Expected the offset %d at the end, got %d", len(data), dec.Offset())
+	}
+
+	// a torn write
+	dec = NewWALDecoder(bytes.NewReader(data[:len(data)-3]))
+	dec.Decode()
+	dec.Decode()
+	if _, err := dec.Decode(); err == nil || err.(*WALCorruption).Offset != dec.Offset() {
+		t.Errorf("Expected a corruption at %d, got %v", dec.Offset(), err)
+	}
+
+	// a flipped bit
+	flipped := append([]byte{}, data...)
+	flipped[len(flipped)-1] ^= 1
+	dec = NewWALDecoder(bytes.NewReader(flipped))
+	dec.Decode()
+	dec.Decode()
+	if _, err := dec.Decode(); err == nil || err.(*WALCorruption).Reason != "checksum mismatch" {
+		t.Errorf("Expected a checksum mismatch, got %v", err)
+	}
+}
+
+func TestWALSearchForHeight(t *testing.T) {
+	dir := writeTestWAL(t)
+	defer os.RemoveAll(dir)
+	wal, err := NewWAL(zap.NewNop(), dir, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer wal.Stop()
+
+	for h := 1; h <= 3; h++ {
+		dec, found, err := wal.SearchForHeight(h)
+		if err != nil || !found {
+			t.Fatalf("Expected to find height %d, got %v", h, err)
+		}
+		msg, err := dec.Decode()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if ti, ok := msg.Msg.(timeoutInfo); h == 1 && (!ok || ti.Height != 1) {
+			t.Errorf("Expected the timeout of height 1 after its marker, got %v", msg.Msg)
+		} else if rs, ok := msg.Msg.(types.EventDataRoundState); h > 1 && (!ok || rs.Height != h) {
+			t.Errorf("Expected the round state of height %d after its marker, got %v", h, msg.Msg)
+		}
+		dec.Close()
+	}
+	if _, found, err := wal.SearchForHeight(4); found || err != nil {
+		t.Errorf("Expected height 4 not to be found, got %v %v", found, err)
+	}
+}
+
+func TestWALTornHead(t *testing.T) {
+	dir := writeTestWAL(t)
+	defer os.RemoveAll(dir)
+	appendTestWAL(t, dir+"/wal", "torn")
+
+	wal, err := NewWAL(zap.NewNop(), dir, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	wal.Save(timeoutInfo{Duration: time.Second, Height: 3, Round: 1, Step: RoundStepPropose})
+	wal.Stop()
+	wal.group.Head.Close()
+
+	records, err := scanTestWAL(t, dir)
+	if err != nil || len(records) != 12 {
+		t.Fatalf("Expected the new record to follow the 11 sound ones, got %d: %v", len(records), err)
+	}
+	if ti, ok := records[11].Msg.Msg.(timeoutInfo); !ok || ti.Round != 1 {
+		t.Errorf("Expected the new timeout last, got %v", records[11])
+	}
+}
+
+func TestMigrateWAL(t *testing.T) {
+	dir, err := ioutil.TempDir("", "wal_migrate")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	walDir := dir + "/cs.wal"
+	if err := os.MkdirAll(walDir, 0700); err != nil {
+		t.Fatal(err)
+	}
+	json := "#HEIGHT: 1\n" +
+		`{"time":"2017-01-01T00:00:00.000Z","msg":[1,{"height":1,"round":0,"step":"RoundStepNewHeight"}]}` + "\n" +
+		"#HEIGHT: 2\n" +
+		`{"time":"2017-01-01T00:00:01.000Z","msg":[3,{"duration":1000000000,"height":2,"round":0,"step":1}]}` + "\n" +
+		`{"time":"2017-01-01T00:00:02.000Z","msg":[2,{"msg"`
+	if err := ioutil.WriteFile(walDir+"/wal", []byte(json), 0600); err != nil {
+		t.Fatal(err)
+	}
+	if err := ScanWAL(walDir, func(*WALRecord) bool { return true }); err == nil {
+		t.Error("Expected the JSON WAL to be refused")
+	}
+
+	c, err := MigrateWAL(walDir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if c == nil || c.Offset != int64(len(json)-len(`{"time":"2017-01-01T00:00:02.000Z","msg":[2,{"msg"`)) {
+		t.Errorf("Expected the truncated record to be dropped, got %v", c)
+	}
+	records, err := scanTestWAL(t, walDir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	kinds := []string{"Height", "RoundState", "Height", "Timeout"}
+	if len(records) != len(kinds) {
+		t.Fatalf("Expected %d records, got %d", len(kinds), len(records))
+	}
+	for i, r := range records {
+		if r.Kind() != kinds[i] {
+			t.Errorf("Expected record %d to be a %s, got %s", i, kinds[i], r.Kind())
+		}
+	}
+	if _, err := os.Stat(walDir + jsonWALBackupSuffix + "/wal"); err != nil {
+		t.Errorf("Expected the JSON WAL to be kept, %v", err)
+	}
+
+	// the binary WAL is left alone
+	if c, err := MigrateWAL(walDir); c != nil || err != nil {
+		t.Errorf("Expected nothing to migrate, got %v %v", c, err)
+	}
+}
@@ -0,0 +1,105 @@
+// Copyright 2017 Delos Development Foundation and contributors. Licensed
+// under the Apache License, Version 2.0. See the COPYING file at the root
+// of this distribution or at http://www.apache.org/licenses/LICENSE-2.0
+package dngine
+
+import (
+	"fmt"
+	"io"
+	"io/ioutil"
+
+	"github.com/DelosIsland/core/dngine/blockchain"
+	"github.com/DelosIsland/core/dngine/state"
+	"github.com/DelosIsland/core/dngine/types"
+	cfg "github.com/DelosIsland/core/module/lib/go-config"
+	dbm "github.com/DelosIsland/core/module/lib/go-db"
+)
+
+// Export writes the genesis and the blocks up to height of a stopped node,
+// all the stored ones if height is 0. It returns the last height written.
+func Export(conf cfg.Config, w io.Writer, height int) (int, error) {
+	dbBackend := conf.GetString("db_backend")
+	dbDir := conf.GetString("db_dir")
+	stateDB := dbm.NewDB("state", dbBackend, dbDir)
+	defer stateDB.Close()
+	stateM := state.LoadState(stateDB)
+	if stateM == nil {
+		return 0, fmt.Errorf("no state in %s", dbDir)
+	}
+	blockStoreDB := dbm.NewDB("blockstore", dbBackend, dbDir)
+	defer blockStoreDB.Close()
+	blockStore := blockchain.NewBlockStore(blockStoreDB)
+
+	if height == 0 || height > blockStore.Height() {
+		height = blockStore.Height()
+	}
+	genDoc := stateM.GenesisDoc
+	// the state keeps no app_state, the genesis file has it
+	if buf, err := ioutil.ReadFile(conf.GetString("genesis_file")); err == nil {
+		if fileDoc, err := types.ReadGenesisDoc(buf); err == nil && fileDoc.ChainID == genDoc.ChainID {
+			withAppState := *genDoc
+			withAppState.AppState = fileDoc.AppState
+			genDoc = &withAppState
+		}
+	}
+	ew, err := blockchain.NewExportWriter(w, genDoc)
+	if err != nil {
+		return 0, err
+	}
+	for h := 1; h <= height; h++ {
+		eb := blockStore.LoadExportBlock(h)
+		if eb == nil {
+			return h - 1, fmt.Errorf("block %d is missing from the store", h)
+		}
+		if err := ew.WriteBlock(eb); err != nil {
+			return h - 1, err
+		}
+	}
+	return height, nil
+}
+
+// ImportBlocks replays the blocks of an export on the node, which must be connected to its app
+// and not started. Every commit is verified against the validators of its height and every block
+// is executed by the app before it is saved, the blocks the node already has are skipped so that
+// an interrupted import can be run again. It returns the height of the node after the import.
+func (e *Dngine) ImportBlocks(r *blockchain.ExportReader, progress func(height int)) (int, error) {
+	if chainID := r.Genesis().ChainID; chainID != e.genesis.ChainID {
+		return e.Height(), fmt.Errorf("the export is of chain %s, not %s", chainID, e.genesis.ChainID)
+	}
+	for {
+		eb, err := r.Next()
+		if err == io.EOF {
+			return e.Height(), nil
+		} else if err != nil {
+			return e.Height(), err
+		}
+		if eb.Height <= e.blockstore.Height() {
+			continue
+		}
+		if eb.Height != e.stateMachine.LastBlockHeight+1 {
+			return e.Height(), fmt.Errorf("expected block %d, the export has %d", e.stateMachine.LastBlockHeight+1, eb.Height)
+		}
+
+		block, parts, err := eb.Block()
+		if err != nil {
+			return e.Height(), fmt.Errorf("block %d: %v", eb.Height, err)
+		}
+		blockID := types.BlockID{Hash: block.Hash(), PartsHeader: parts.Header()}
+		if err := e.stateMachine.Validators.VerifyCommit(e.genesis.ChainID, blockID, block.Height, eb.SeenCommit); err != nil {
+			return e.Height(), fmt.Errorf("the commit of block %d doesn't verify: %v", block.Height, err)
+		}
+		if err := e.stateMachine.ValidateBlock(block); err != nil {
+			return e.Height(), fmt.Errorf("block %d: %v", block.Height, err)
+		}
+		err = e.stateMachine.ApplyValidatedBlock(*e.eventSwitch, block, parts.Header(), MockMempool{}, -1, func() {
+			e.blockstore.SaveBlock(block, parts, eb.SeenCommit)
+		})
+		if err != nil {
+			return e.Height(), err
+		}
+		e.stateMachine.Save()
+		if progress != nil {
+			progress(block.Height)
+		}
+	}
+}
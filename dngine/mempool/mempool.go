@@ -8,6 +8,7 @@ import (
 	"errors"
 	"sync"
 	"sync/atomic"
+	"time"
 
 	"go.uber.org/zap"
 
@@ -41,6 +42,8 @@ type Mempool struct {
 
 	txFilters []IFilter
 
+	metrics *Metrics
+
 	logger *zap.Logger
 }
 
@@ -52,12 +55,18 @@ func NewMempool(logger *zap.Logger, config cfg.Config) *Mempool {
 		height:  0,
 		cache:   newTxCache(cacheSize),
 		txLimit: config.GetInt("block_size") * 2,
+		metrics: NewMetrics(nil),
 		logger:  logger,
 	}
 	mempool.initWAL()
 	return mempool
 }
 
+// SetMetrics sets the metrics updated by the mempool
+func (mem *Mempool) SetMetrics(m *Metrics) {
+	mem.metrics = m
+}
+
 func (mem *Mempool) RegisterFilter(filter IFilter) {
 	mem.txFilters = append(mem.txFilters, filter)
 }
@@ -84,6 +93,7 @@ func (mem *Mempool) Flush() {
 		mem.txs.Remove(e)
 		e.DetachPrev()
 	}
+	mem.metrics.Size.Set(0)
 	mem.Unlock()
 }
 
@@ -100,12 +110,15 @@ func (mem *Mempool) TxsFrontWait() *clist.CElement {
 // CONTRACT: Either cb will get called, or err returned.
 func (mem *Mempool) CheckTx(tx types.Tx) (err error) {
 	if mem.cache.Exists(tx) {
+		mem.metrics.RejectedTxs.WithLabelValues(rejectDuplicate).Inc()
 		return errors.New("Duplicate transaction (ignored)")
 	}
 	if mem.config.GetBool("mempool_enable_txs_limits") && mem.txs.Len() > mem.txLimit {
+		mem.metrics.RejectedTxs.WithLabelValues(rejectFull).Inc()
 		return errors.New("Too many unsolved TX (rejected)")
 	}
 	if err := mem.checkTxWithFilters(tx); err != nil {
+		mem.metrics.RejectedTxs.WithLabelValues(rejectFilter).Inc()
 		return errors.New("plugin checktx failed with error: " + err.Error())
 	}
 	// TODO: remove this wal, mempool lost may be durable
@@ -123,6 +136,7 @@ func (mem *Mempool) CheckTx(tx types.Tx) (err error) {
 		tx:      tx,
 	}
 	mem.txs.PushBack(memTx)
+	mem.metrics.Size.Set(float64(mem.txs.Len()))
 
 	return nil
 }
@@ -152,7 +166,10 @@ func (mem *Mempool) Update(height int64, txs []types.Tx) {
 
 	mem.Lock()
 	// Remove transactions that are already in txs, also re-run txs through filters
+	start := time.Now()
 	mem.refreshMempoolTxs(txsMap)
+	mem.metrics.RecheckTime.Observe(time.Since(start).Seconds())
+	mem.metrics.Size.Set(float64(mem.txs.Len()))
 	mem.Unlock()
 }
 
@@ -187,6 +204,7 @@ func (mem *Mempool) refreshMempoolTxs(blockTxsMap map[string]struct{}) {
 		} else if err := mem.recheckTx(memTx.tx); err != nil {
 			mem.txs.Remove(e)
 			e.DetachPrev()
+			mem.metrics.EvictedTxs.Inc()
 			// mem.cache.Remove(memTx.tx)
 		}
 	}
@@ -0,0 +1,53 @@
+// Copyright 2017 Delos Development Foundation and contributors. Licensed
+// under the Apache License, Version 2.0. See the COPYING file at the root
+// of this distribution or at http://www.apache.org/licenses/LICENSE-2.0
+package mempool
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+const metricsSubsystem = "mempool"
+
+// the reasons a tx is rejected for, the label of Metrics.RejectedTxs
+const (
+	rejectDuplicate = "duplicate"
+	rejectFull      = "full"
+	rejectFilter    = "filter"
+)
+
+// Metrics of the mempool
+type Metrics struct {
+	Size        prometheus.Gauge
+	RejectedTxs *prometheus.CounterVec
+	// txs removed because they didn't pass the filters again after a block
+	EvictedTxs  prometheus.Counter
+	RecheckTime prometheus.Histogram
+}
+
+// NewMetrics makes the metrics of the mempool and registers them with reg, unless it is nil
+func NewMetrics(reg prometheus.Registerer) *Metrics {
+	m := &Metrics{
+		Size: prometheus.NewGauge(prometheus.GaugeOpts{
+			Namespace: "dngine", Subsystem: metricsSubsystem, Name: "size",
+			Help: "Number of uncommitted txs.",
+		}),
+		RejectedTxs: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: "dngine", Subsystem: metricsSubsystem, Name: "rejected_txs_total",
+			Help: "Txs refused by CheckTx, by reason.",
+		}, []string{"reason"}),
+		EvictedTxs: prometheus.NewCounter(prometheus.CounterOpts{
+			Namespace: "dngine", Subsystem: metricsSubsystem, Name: "evicted_txs_total",
+			Help: "Txs removed when rechecked after a block.",
+		}),
+		RecheckTime: prometheus.NewHistogram(prometheus.HistogramOpts{
+			Namespace: "dngine", Subsystem: metricsSubsystem, Name: "recheck_duration_seconds",
+			Help:    "Time taken to recheck the txs left after a block.",
+			Buckets: prometheus.ExponentialBuckets(0.001, 2, 14),
+		}),
+	}
+	if reg != nil {
+		reg.MustRegister(m.Size, m.RejectedTxs, m.EvictedTxs, m.RecheckTime)
+	}
+	return m
+}
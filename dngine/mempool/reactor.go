@@ -68,6 +68,7 @@ func (memR *MempoolReactor) Receive(chID byte, src *p2p.Peer, msgBytes []byte) {
 	_, msg, err := DecodeMessage(msgBytes)
 	if err != nil {
 		memR.logger.Warn("Error decoding message", zap.String("error", err.Error()))
+		memR.Switch.ReportPeerBehaviour(src, p2p.PeerBehaviourBadMessage, err)
 		return
 	}
 	memR.logger.Sugar().Debugw("Receive", "src", src, "chId", chID, "msg", msg)
@@ -0,0 +1,134 @@
+// Copyright 2017 Delos Development Foundation and contributors. Licensed
+// under the Apache License, Version 2.0. See the COPYING file at the root
+// of this distribution or at http://www.apache.org/licenses/LICENSE-2.0
+package dngine
+
+import (
+	"strconv"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+
+	dbm "github.com/DelosIsland/core/module/lib/go-db"
+	"github.com/DelosIsland/core/module/lib/go-p2p"
+)
+
+// metrics are those of a dngine which aren't kept by its reactors
+type metrics struct {
+	hookDuration    *prometheus.HistogramVec
+	dbWriteDuration *prometheus.HistogramVec
+}
+
+func newMetrics() *metrics {
+	return &metrics{
+		hookDuration: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Namespace: "dngine", Subsystem: "app", Name: "hook_duration_seconds",
+			Help:    "Time taken by the hooks of the app.",
+			Buckets: prometheus.ExponentialBuckets(0.001, 2, 14),
+		}, []string{"hook"}),
+		dbWriteDuration: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Namespace: "dngine", Subsystem: "db", Name: "write_duration_seconds",
+			Help:    "Time taken by the writes to the state and block databases.",
+			Buckets: prometheus.ExponentialBuckets(0.0001, 2, 16),
+		}, []string{"db", "op"}),
+	}
+}
+
+func (m *metrics) observeHook(hook string, start time.Time) {
+	m.hookDuration.WithLabelValues(hook).Observe(time.Since(start).Seconds())
+}
+
+// p2pCollector reads the peers and the traffic of the switch when the metrics are gathered
+type p2pCollector struct {
+	sw *p2p.Switch
+
+	peers     *prometheus.Desc
+	sentBytes *prometheus.Desc
+	recvBytes *prometheus.Desc
+	dropped   *prometheus.Desc
+}
+
+func newP2PCollector(sw *p2p.Switch) *p2pCollector {
+	channelLabels := []string{"channel", "reactor"}
+	return &p2pCollector{
+		sw:        sw,
+		peers:     prometheus.NewDesc("dngine_p2p_peers", "Number of connected peers.", []string{"direction"}, nil),
+		sentBytes: prometheus.NewDesc("dngine_p2p_sent_bytes_total", "Bytes sent on a channel.", channelLabels, nil),
+		recvBytes: prometheus.NewDesc("dngine_p2p_received_bytes_total", "Bytes received on a channel.", channelLabels, nil),
+		dropped:   prometheus.NewDesc("dngine_p2p_dropped_msgs_total", "Messages dropped on a channel.", channelLabels, nil),
+	}
+}
+
+func (c *p2pCollector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- c.peers
+	ch <- c.sentBytes
+	ch <- c.recvBytes
+	ch <- c.dropped
+}
+
+func (c *p2pCollector) Collect(ch chan<- prometheus.Metric) {
+	outbound, inbound, _ := c.sw.NumPeers()
+	ch <- prometheus.MustNewConstMetric(c.peers, prometheus.GaugeValue, float64(outbound), "outbound")
+	ch <- prometheus.MustNewConstMetric(c.peers, prometheus.GaugeValue, float64(inbound), "inbound")
+	for _, m := range c.sw.ChannelMetrics() {
+		id := "0x" + strconv.FormatUint(uint64(m.ID), 16)
+		ch <- prometheus.MustNewConstMetric(c.sentBytes, prometheus.CounterValue, float64(m.SentBytes), id, m.Reactor)
+		ch <- prometheus.MustNewConstMetric(c.recvBytes, prometheus.CounterValue, float64(m.RecvBytes), id, m.Reactor)
+		ch <- prometheus.MustNewConstMetric(c.dropped, prometheus.CounterValue, float64(m.DroppedMsgs), id, m.Reactor)
+	}
+}
+
+// meteredDB times the writes to a db
+type meteredDB struct {
+	dbm.DB
+	set, setSync, del, delSync, batch prometheus.Observer
+}
+
+func newMeteredDB(db dbm.DB, name string, durations *prometheus.HistogramVec) *meteredDB {
+	return &meteredDB{
+		DB:      db,
+		set:     durations.WithLabelValues(name, "set"),
+		setSync: durations.WithLabelValues(name, "set_sync"),
+		del:     durations.WithLabelValues(name, "delete"),
+		delSync: durations.WithLabelValues(name, "delete_sync"),
+		batch:   durations.WithLabelValues(name, "batch"),
+	}
+}
+
+func observeSince(o prometheus.Observer, start time.Time) {
+	o.Observe(time.Since(start).Seconds())
+}
+
+func (db *meteredDB) Set(key, value []byte) {
+	defer observeSince(db.set, time.Now())
+	db.DB.Set(key, value)
+}
+
+func (db *meteredDB) SetSync(key, value []byte) {
+	defer observeSince(db.setSync, time.Now())
+	db.DB.SetSync(key, value)
+}
+
+func (db *meteredDB) Delete(key []byte) {
+	defer observeSince(db.del, time.Now())
+	db.DB.Delete(key)
+}
+
+func (db *meteredDB) DeleteSync(key []byte) {
+	defer observeSince(db.delSync, time.Now())
+	db.DB.DeleteSync(key)
+}
+
+func (db *meteredDB) NewBatch() dbm.Batch {
+	return &meteredBatch{Batch: db.DB.NewBatch(), write: db.batch}
+}
+
+type meteredBatch struct {
+	dbm.Batch
+	write prometheus.Observer
+}
+
+func (b *meteredBatch) Write() {
+	defer observeSince(b.write, time.Now())
+	b.Batch.Write()
+}
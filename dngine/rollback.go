@@ -0,0 +1,65 @@
+// Copyright 2017 Delos Development Foundation and contributors. Licensed
+// under the Apache License, Version 2.0. See the COPYING file at the root
+// of this distribution or at http://www.apache.org/licenses/LICENSE-2.0
+package dngine
+
+import (
+	"fmt"
+
+	"github.com/DelosIsland/core/dngine/blockchain"
+	"github.com/DelosIsland/core/dngine/state"
+	"github.com/DelosIsland/core/dngine/types"
+	cfg "github.com/DelosIsland/core/module/lib/go-config"
+	dbm "github.com/DelosIsland/core/module/lib/go-db"
+)
+
+// RollbackResult tells where a rollback took the node
+type RollbackResult struct {
+	From, To    int
+	StoreHeight int // the blocks above To which were kept are replayed when the node starts
+}
+
+// Rollback rewinds the state of a stopped node by n heights, from what was kept of them
+// (see rollback_heights), and has the app roll back its own state to the same height.
+// The blocks above are removed from the store if removeBlocks is set, otherwise they are
+// executed again when the node starts, by an app which may have been fixed meanwhile.
+func Rollback(conf cfg.Config, app types.Application, n int, removeBlocks bool) (*RollbackResult, error) {
+	rollbacker, ok := app.(types.Rollbacker)
+	if !ok {
+		return nil, fmt.Errorf("the app can't roll back its state")
+	}
+	if n < 1 {
+		return nil, fmt.Errorf("can't roll back %d heights", n)
+	}
+
+	dbBackend := conf.GetString("db_backend")
+	dbDir := conf.GetString("db_dir")
+	stateDB := dbm.NewDB("state", dbBackend, dbDir)
+	defer stateDB.Close()
+	stateM := state.LoadState(stateDB)
+	if stateM == nil {
+		return nil, fmt.Errorf("no state in %s", dbDir)
+	}
+	blockStoreDB := dbm.NewDB("blockstore", dbBackend, dbDir)
+	defer blockStoreDB.Close()
+	blockStore := blockchain.NewBlockStore(blockStoreDB)
+
+	prev, err := stateM.StateAt(stateM.LastBlockHeight - n)
+	if err != nil {
+		return nil, err
+	}
+	if err := rollbacker.Rollback(prev.LastBlockHeight, prev.AppHash); err != nil {
+		return nil, fmt.Errorf("the app failed to roll back to height %d: %v", prev.LastBlockHeight, err)
+	}
+	prev.SetRollbackHeights(conf.GetInt("rollback_heights"))
+	prev.Save()
+
+	if removeBlocks {
+		blockStore.DeleteBlocksAbove(prev.LastBlockHeight)
+	}
+	return &RollbackResult{
+		From:        stateM.LastBlockHeight,
+		To:          prev.LastBlockHeight,
+		StoreHeight: blockStore.Height(),
+	}, nil
+}
@@ -0,0 +1,67 @@
+// Copyright 2017 Delos Development Foundation and contributors. Licensed
+// under the Apache License, Version 2.0. See the COPYING file at the root
+// of this distribution or at http://www.apache.org/licenses/LICENSE-2.0
+package state
+
+import (
+	"errors"
+	"testing"
+
+	"go.uber.org/zap"
+
+	"github.com/DelosIsland/core/dngine/types"
+	"github.com/DelosIsland/core/module/lib/go-crypto"
+	dbm "github.com/DelosIsland/core/module/lib/go-db"
+)
+
+type nopMempool struct{}
+
+func (nopMempool) Lock()                    {}
+func (nopMempool) Unlock()                  {}
+func (nopMempool) Update(int64, []types.Tx) {}
+
+func TestApplyValidatedBlockAppFailure(t *testing.T) {
+	s := MakeGenesisState(dbm.NewMemDB(), &types.GenesisDoc{
+		ChainID:    "test-chain",
+		Validators: []types.GenesisValidator{{PubKey: crypto.GenPrivKeyEd25519().PubKey(), Amount: 10}},
+	})
+	s.Save()
+
+	var execErr error
+	evsw := types.NewEventSwitch(zap.NewNop())
+	if _, err := evsw.Start(); err != nil {
+		t.Fatal(err)
+	}
+	defer evsw.Stop()
+	types.AddListenerForEvent(evsw, "test", types.EventStringHookExecute(), func(ed types.TMEventData) {
+		ed.(types.EventDataHookExecute).ResCh <- types.ExecuteResult{Error: execErr}
+	})
+	types.AddListenerForEvent(evsw, "test", types.EventStringHookCommit(), func(ed types.TMEventData) {
+		ed.(types.EventDataHookCommit).ResCh <- types.CommitResult{AppHash: []byte("apphash")}
+	})
+
+	block := &types.Block{
+		Header:     &types.Header{ChainID: "test-chain", Height: 1},
+		Data:       &types.Data{},
+		LastCommit: &types.Commit{},
+	}
+	saved := 0
+	saveBlock := func() { saved++ }
+
+	// the block can be fetched again, nothing was written
+	execErr = errors.New("app failure")
+	if err := s.ApplyValidatedBlock(evsw, block, types.PartSetHeader{}, nopMempool{}, -1, saveBlock); err == nil {
+		t.Fatal("Expected the failure of the app to be returned")
+	}
+	if saved != 0 || s.LastBlockHeight != 0 || LoadState(s.db).LastBlockHeight != 0 {
+		t.Fatalf("Expected nothing to be written, the block was saved %d times and the state is at %d", saved, s.LastBlockHeight)
+	}
+
+	execErr = nil
+	if err := s.ApplyValidatedBlock(evsw, block, types.PartSetHeader{}, nopMempool{}, -1, saveBlock); err != nil {
+		t.Fatal(err)
+	}
+	if saved != 1 || s.LastBlockHeight != 1 || string(s.AppHash) != "apphash" {
+		t.Errorf("Expected the block to be saved once and applied, got %d saves at height %d", saved, s.LastBlockHeight)
+	}
+}
@@ -21,17 +21,29 @@ import (
 // Validates block and then executes Data.Txs in the block.
 func (s *State) ExecBlock(eventSwitch types.EventSwitch, block *types.Block, blockPartsHeader types.PartSetHeader, round int) error {
 	// Validate the block.
-	if err := s.validateBlock(block); err != nil {
+	if err := s.validateBlock(block, true); err != nil {
 		return ErrInvalidBlock(err)
 	}
+	return s.execValidatedBlock(eventSwitch, block, blockPartsHeader, round)
+}
 
+func (s *State) execValidatedBlock(eventSwitch types.EventSwitch, block *types.Block, blockPartsHeader types.PartSetHeader, round int) error {
+	// the consensus goes on with a block the app failed, as it always did
+	valSet, nextValSet, _ := s.runValidatedBlock(eventSwitch, block, round)
+	s.moveToBlock(block, blockPartsHeader, valSet, nextValSet)
+	return nil
+}
+
+// runValidatedBlock executes the block on the plugins and the app and returns the validators
+// of the block and of the next one with the error of the app, the state itself isn't changed yet
+func (s *State) runValidatedBlock(eventSwitch types.EventSwitch, block *types.Block, round int) (valSet, nextValSet *types.ValidatorSet, err error) {
 	// compute bitarray of validators that signed
 	signed := commitBitArrayFromBlock(block)
 	_ = signed // TODO send on begin block
 
 	// copy the valset
-	valSet := s.Validators.Copy()
-	nextValSet := valSet.Copy()
+	valSet = s.Validators.Copy()
+	nextValSet = valSet.Copy()
 
 	s.execBeginBlockOnPlugins(block)
 
@@ -44,20 +56,22 @@ func (s *State) ExecBlock(eventSwitch types.EventSwitch, block *types.Block, blo
 	// }
 
 	changedValidators := make([]*types.ValidatorAttr, 0)
-	s.execBlockOnApp(eventSwitch, block, round)
+	_, err = s.execBlockOnApp(eventSwitch, block, round)
 	// plugins modify changedValidators inplace
 	s.execEndBlockOnPlugins(block, changedValidators, nextValSet)
 
-	// All good!
-	// Update validator accums and set state variables
+	// Update validator accums
 	nextValSet.IncrementAccum(1)
+	return valSet, nextValSet, err
+}
+
+// moveToBlock sets the state variables to the executed block
+func (s *State) moveToBlock(block *types.Block, blockPartsHeader types.PartSetHeader, valSet, nextValSet *types.ValidatorSet) {
 	s.SetBlockAndValidators(block.Header, blockPartsHeader, valSet, nextValSet)
 
 	// save state with updated height/blockhash/validators
 	// but stale apphash, in case we fail between Commit and Save
 	s.SaveIntermediate()
-
-	return nil
 }
 
 func (s *State) execBlockOnApp(eventSwitch types.EventSwitch, block *types.Block, round int) ([]*types.ValidatorAttr, error) {
@@ -69,18 +83,26 @@ func (s *State) execBlockOnApp(eventSwitch types.EventSwitch, block *types.Block
 	types.FireEventHookExecute(eventSwitch, ed) // Run Txs of block
 	res := <-ed.ResCh
 	eventCache := types.NewEventCache(eventSwitch)
-	for _, tx := range res.ValidTxs {
+	for i, tx := range res.ValidTxs {
 		txev := types.EventDataTx{
-			Tx:   tx,
-			Code: types.CodeType_OK,
+			Height: block.Height,
+			Tx:     tx,
+			Code:   types.CodeType_OK,
+		}
+		if i < len(res.ValidTxsData) {
+			txev.Data = res.ValidTxsData[i]
 		}
 		types.FireEventTx(eventCache, txev)
 	}
 	for _, invalid := range res.InvalidTxs {
 		txev := types.EventDataTx{
-			Tx:    invalid.Bytes,
-			Code:  types.CodeType_InvalidTx,
-			Error: invalid.Error.Error(),
+			Height: block.Height,
+			Tx:     invalid.Bytes,
+			Code:   types.CodeType_InvalidTx,
+			Error:  invalid.Error.Error(),
+		}
+		if appRes, ok := invalid.Error.(types.Result); ok && appRes.Code != types.CodeType_OK {
+			txev.Code, txev.Data, txev.Error = appRes.Code, appRes.Data, appRes.Log
 		}
 		types.FireEventTx(eventCache, txev)
 	}
@@ -118,10 +140,16 @@ func commitBitArrayFromBlock(block *types.Block) *cmn.BitArray {
 // Validate block
 
 func (s *State) ValidateBlock(block *types.Block) error {
-	return s.validateBlock(block)
+	return s.validateBlock(block, true)
 }
 
-func (s *State) validateBlock(block *types.Block) error {
+// ValidateBlockVerified is ValidateBlock for a block whose LastCommit signatures
+// were already verified with LastValidators, e.g. by the fast sync pipeline
+func (s *State) ValidateBlockVerified(block *types.Block) error {
+	return s.validateBlock(block, false)
+}
+
+func (s *State) validateBlock(block *types.Block, verifyLastCommit bool) error {
 	// Basic block validation.
 	err := block.ValidateBasic(s.ChainID, s.LastBlockHeight, s.LastBlockID, s.LastBlockTime, s.AppHash, s.ReceiptsHash)
 	if err != nil {
@@ -138,6 +166,9 @@ func (s *State) validateBlock(block *types.Block) error {
 			return errors.New(cmn.Fmt("Invalid block commit size. Expected %v, got %v",
 				s.LastValidators.Size(), len(block.LastCommit.Precommits)))
 		}
+		if !verifyLastCommit {
+			return nil
+		}
 		err := s.LastValidators.VerifyCommit(
 			s.ChainID, s.LastBlockID, block.Height-1, block.LastCommit)
 		if err != nil {
@@ -166,6 +197,24 @@ func (s *State) ApplyBlock(eventSwitch types.EventSwitch, block *types.Block, pa
 	return nil
 }
 
+// ApplyValidatedBlock is ApplyBlock for a block which already passed ValidateBlock.
+// When the app fails to execute the block nothing is written and the error is returned,
+// the app and the plugins must have dropped the changes of the block so that it can be applied again.
+// Otherwise saveBlock stores the block before the state moves on to it,
+// from there the block can't be applied again and a failure is fatal.
+func (s *State) ApplyValidatedBlock(eventSwitch types.EventSwitch, block *types.Block, partsHeader types.PartSetHeader, mempool types.IMempool, round int, saveBlock func()) error {
+	valSet, nextValSet, err := s.runValidatedBlock(eventSwitch, block, round)
+	if err != nil {
+		return errors.New(cmn.Fmt("Exec failed for application: %v", err))
+	}
+	saveBlock()
+	s.moveToBlock(block, partsHeader, valSet, nextValSet)
+	if err := s.CommitStateUpdateMempool(eventSwitch, block, mempool, round); err != nil {
+		cmn.PanicCrisis(cmn.Fmt("Commit failed for application on the stored block %d: %v", block.Height, err))
+	}
+	return nil
+}
+
 // mempool must be locked during commit and update
 // because state is typically reset on Commit and old txs must be replayed
 // against committed state before new txs are run in the mempool, lest they be invalid
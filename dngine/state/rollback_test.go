@@ -0,0 +1,107 @@
+// Copyright 2017 Delos Development Foundation and contributors. Licensed
+// under the Apache License, Version 2.0. See the COPYING file at the root
+// of this distribution or at http://www.apache.org/licenses/LICENSE-2.0
+package state
+
+import (
+	"bytes"
+	"testing"
+	"time"
+
+	"github.com/DelosIsland/core/dngine/types"
+	"github.com/DelosIsland/core/module/lib/go-crypto"
+	dbm "github.com/DelosIsland/core/module/lib/go-db"
+	"github.com/DelosIsland/core/module/lib/go-wire"
+)
+
+func TestStateAt(t *testing.T) {
+	db := dbm.NewMemDB()
+	s := MakeGenesisState(db, &types.GenesisDoc{
+		ChainID: "test-chain",
+		Validators: []types.GenesisValidator{
+			{PubKey: crypto.GenPrivKeyEd25519().PubKey(), Amount: 10},
+			{PubKey: crypto.GenPrivKeyEd25519().PubKey(), Amount: 20},
+		},
+	})
+	s.SetRollbackHeights(2)
+	s.Save()
+	var vals3 []byte
+	for h := 1; h <= 5; h++ {
+		valSet := s.Validators.Copy()
+		valSet.IncrementAccum(1)
+		s.setBlockAndValidators(h, types.BlockID{Hash: []byte{byte(h)}}, time.Unix(int64(h), 0), s.Validators, valSet)
+		s.AppHash = []byte{byte(h), byte(h)}
+		s.Save()
+		if h == 3 {
+			vals3 = wire.BinaryBytes(valSet)
+		}
+	}
+
+	prev, err := s.StateAt(3)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if prev.LastBlockHeight != 3 || !bytes.Equal(prev.LastBlockID.Hash, []byte{3}) || !bytes.Equal(prev.AppHash, []byte{3, 3}) ||
+		!prev.LastBlockTime.Equal(time.Unix(3, 0)) || prev.ChainID != "test-chain" {
+		t.Errorf("Unexpected state of height 3: %v %X %X %v", prev.LastBlockHeight, prev.LastBlockID.Hash, prev.AppHash, prev.LastBlockTime)
+	}
+	if !bytes.Equal(wire.BinaryBytes(prev.Validators), vals3) {
+		t.Errorf("Expected the validators of height 3")
+	}
+	for _, h := range []int{2, 5, 6} {
+		if _, err := s.StateAt(h); err == nil {
+			t.Errorf("Expected no state for height %d", h)
+		}
+	}
+
+	// saving the earlier state rolls back
+	prev.Save()
+	if loaded := LoadState(db); loaded.LastBlockHeight != 3 || !bytes.Equal(loaded.AppHash, []byte{3, 3}) {
+		t.Errorf("Expected the state of height 3 to be saved, got %d", loaded.LastBlockHeight)
+	}
+}
+
+func TestPruneHeightStates(t *testing.T) {
+	db := dbm.NewMemDB()
+	s := MakeGenesisState(db, &types.GenesisDoc{
+		ChainID:    "test-chain",
+		Validators: []types.GenesisValidator{{PubKey: crypto.GenPrivKeyEd25519().PubKey(), Amount: 10}},
+	})
+	// kept by a version which didn't record the oldest height
+	for h := 0; h < 3; h++ {
+		db.Set(calcHeightStateKey(h), []byte{1})
+	}
+	s.SetRollbackHeights(4)
+	saveHeights := func(from, to int) {
+		for h := from; h <= to; h++ {
+			s.setBlockAndValidators(h, types.BlockID{Hash: []byte{byte(h)}}, time.Unix(int64(h), 0), s.Validators, s.Validators.Copy())
+			s.Save()
+		}
+	}
+	kept := func() (heights []int) {
+		for h := 0; h <= s.LastBlockHeight; h++ {
+			if db.Get(calcHeightStateKey(h)) != nil {
+				heights = append(heights, h)
+			}
+		}
+		return heights
+	}
+
+	saveHeights(3, 6)
+	if got := kept(); len(got) != 5 || got[0] != 2 {
+		t.Fatalf("Expected the heights 2 to 6 to be kept, got %v", got)
+	}
+
+	// a smaller rollback_heights drops all the older states at once
+	s.SetRollbackHeights(1)
+	saveHeights(7, 7)
+	if got := kept(); len(got) != 2 || got[0] != 6 {
+		t.Errorf("Expected the heights 6 and 7 to be kept, got %v", got)
+	}
+
+	s.SetRollbackHeights(0)
+	saveHeights(8, 8)
+	if got := kept(); len(got) != 0 {
+		t.Errorf("Expected no state kept without rollbacks, got %v", got)
+	}
+}
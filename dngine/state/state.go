@@ -5,7 +5,9 @@ package state
 
 import (
 	"bytes"
+	"fmt"
 	"io/ioutil"
+	"strconv"
 	"strings"
 	"sync"
 	"time"
@@ -23,6 +25,7 @@ import (
 var (
 	stateKey             = []byte("stateKey")
 	stateIntermediateKey = []byte("stateIntermediateKey")
+	oldestHeightStateKey = []byte("oldestHeightState") // no heightState is kept below this height
 )
 
 //-----------------------------------------------------------------------------
@@ -53,6 +56,27 @@ type State struct {
 	ReceiptsHash []byte
 
 	Plugins []IPlugin
+
+	// number of heights below the last one whose state is kept for rollbacks
+	rollbackHeights int
+}
+
+// heightState is what changes of the state from one height to the next,
+// it is saved with every height so that the state can be rolled back
+type heightState struct {
+	LastBlockHeight int
+	LastBlockID     types.BlockID
+	LastBlockTime   time.Time
+	Validators      *types.ValidatorSet
+	LastValidators  *types.ValidatorSet
+	AppHash         []byte
+	ReceiptsHash    []byte
+}
+
+const heightStateKeyPrefix = "stateAt:"
+
+func calcHeightStateKey(height int) []byte {
+	return []byte(fmt.Sprintf("%s%v", heightStateKeyPrefix, height))
 }
 
 func LoadState(db dbm.DB) *State {
@@ -91,15 +115,97 @@ func (s *State) Copy() *State {
 		AppHash:         s.AppHash,
 		ReceiptsHash:    s.ReceiptsHash,
 		Plugins:         s.Plugins,
+		rollbackHeights: s.rollbackHeights,
 	}
 }
 
 func (s *State) Save() {
 	s.mtx.Lock()
 	defer s.mtx.Unlock()
+	if s.rollbackHeights > 0 {
+		s.db.Set(calcHeightStateKey(s.LastBlockHeight), wire.BinaryBytes(heightState{
+			LastBlockHeight: s.LastBlockHeight,
+			LastBlockID:     s.LastBlockID,
+			LastBlockTime:   s.LastBlockTime,
+			Validators:      s.Validators,
+			LastValidators:  s.LastValidators,
+			AppHash:         s.AppHash,
+			ReceiptsHash:    s.ReceiptsHash,
+		}))
+	}
+	s.pruneHeightStates(s.LastBlockHeight - s.rollbackHeights)
 	s.db.SetSync(stateKey, s.Bytes())
 }
 
+// pruneHeightStates deletes the heightStates below keep, including the ones
+// kept by a larger rollback_heights before
+func (s *State) pruneHeightStates(keep int) {
+	if s.rollbackHeights == 0 {
+		keep = s.LastBlockHeight + 1
+	}
+	oldest, known := s.oldestHeightState()
+	if known && oldest >= keep {
+		return
+	}
+	batch := s.db.NewBatch()
+	for h := oldest; h < keep; h++ {
+		batch.Delete(calcHeightStateKey(h))
+	}
+	if keep > oldest {
+		oldest = keep
+	}
+	batch.Set(oldestHeightStateKey, []byte(strconv.Itoa(oldest)))
+	batch.Write()
+}
+
+// oldestHeightState returns the height below which no heightState is kept,
+// known is false when it had to be looked up in a db saved without it
+func (s *State) oldestHeightState() (oldest int, known bool) {
+	if buf := s.db.Get(oldestHeightStateKey); len(buf) > 0 {
+		if h, err := strconv.Atoi(string(buf)); err == nil {
+			return h, true
+		}
+	}
+	oldest = s.LastBlockHeight
+	iter := s.db.Iterator()
+	for iter.Next() {
+		key := string(iter.Key())
+		if !strings.HasPrefix(key, heightStateKeyPrefix) {
+			continue
+		}
+		if h, err := strconv.Atoi(key[len(heightStateKeyPrefix):]); err == nil && h < oldest {
+			oldest = h
+		}
+	}
+	return oldest, false
+}
+
+// SetRollbackHeights sets how many heights below the last one Save keeps the state of
+func (s *State) SetRollbackHeights(n int) {
+	s.rollbackHeights = n
+}
+
+// StateAt returns a copy of the state rewound to the end of an earlier height,
+// from what Save kept of that height. Saving it rolls the state back.
+func (s *State) StateAt(height int) (*State, error) {
+	if height < 0 || height >= s.LastBlockHeight {
+		return nil, fmt.Errorf("the state is at height %d, it can't be rolled back to %d", s.LastBlockHeight, height)
+	}
+	buf := s.db.Get(calcHeightStateKey(height))
+	if len(buf) == 0 {
+		return nil, fmt.Errorf("the state of height %d was not kept, see rollback_heights", height)
+	}
+	var hs heightState
+	if err := wire.ReadBinaryBytes(buf, &hs); err != nil {
+		return nil, fmt.Errorf("the state of height %d is unreadable: %v", height, err)
+	}
+	prev := s.Copy()
+	prev.setBlockAndValidators(hs.LastBlockHeight, hs.LastBlockID, hs.LastBlockTime, hs.LastValidators, hs.Validators)
+	prev.AppHash = hs.AppHash
+	prev.ReceiptsHash = hs.ReceiptsHash
+	return prev, nil
+}
+
 func (s *State) SaveIntermediate() {
 	s.mtx.Lock()
 	defer s.mtx.Unlock()
@@ -0,0 +1,121 @@
+// Copyright 2017 Delos Development Foundation and contributors. Licensed
+// under the Apache License, Version 2.0. See the COPYING file at the root
+// of this distribution or at http://www.apache.org/licenses/LICENSE-2.0
+package testnet
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/DelosIsland/core/dngine"
+	ac "github.com/DelosIsland/core/dngine/config"
+	"github.com/DelosIsland/core/dngine/types"
+	cfg "github.com/DelosIsland/core/module/lib/go-config"
+)
+
+// Cluster runs the nodes of a testnet in the process, connected over loopback,
+// for the integration tests of the apps
+type Cluster struct {
+	Testnet *Testnet
+	Nodes   []*ClusterNode
+
+	newApp types.AppMaker
+}
+
+// ClusterNode is a node of the cluster, Dngine and App are set by Start
+type ClusterNode struct {
+	Conf   *cfg.MapConfig
+	Dngine *dngine.Dngine
+	App    types.Application // nil when the cluster runs without app
+}
+
+// NewCluster generates a testnet of n validators in dir and loads the config of its nodes,
+// which can be changed before Start. newApp makes the app of each node, the nodes
+// commit empty results when it is nil.
+func NewCluster(dir string, n int, newApp types.AppMaker) (*Cluster, error) {
+	tn, err := Generate(Config{Dir: dir, Nodes: n})
+	if err != nil {
+		return nil, err
+	}
+	c := &Cluster{
+		Testnet: tn,
+		Nodes:   make([]*ClusterNode, n),
+		newApp:  newApp,
+	}
+	for i, node := range tn.Nodes {
+		conf := ac.GetConfig(node.Dir)
+		// the listeners pick their ports, the nodes dial each other once started
+		conf.Set("node_laddr", "tcp://127.0.0.1:0")
+		conf.Set("rpc_laddr", "")
+		conf.Set("api_laddr", "")
+		conf.Set("seeds", "")
+		conf.Set("fast_sync", false)
+		conf.Set("timeout_propose", 1000)
+		conf.Set("timeout_commit", 100)
+		c.Nodes[i] = &ClusterNode{Conf: conf}
+	}
+	return c, nil
+}
+
+// Start makes the dngines and their apps, starts them and connects every node to the others
+func (c *Cluster) Start() error {
+	for _, node := range c.Nodes {
+		if c.newApp != nil {
+			node.App = c.newApp(node.Conf)
+		}
+		node.Dngine = dngine.NewDngine(&dngine.DngineTunes{Conf: node.Conf})
+		if node.App != nil {
+			node.Dngine.ConnectApp(node.App)
+		}
+	}
+	for i, node := range c.Nodes {
+		if node.App != nil {
+			node.App.Start()
+		}
+		if err := node.Dngine.Start(); err != nil {
+			return fmt.Errorf("node%d: %v", i, err)
+		}
+		peers := make([]string, 0, i)
+		for _, other := range c.Nodes[:i] {
+			peers = append(peers, fmt.Sprintf("127.0.0.1:%d", other.Dngine.P2PPort()))
+		}
+		if err := node.Dngine.DialPeers(peers, true); err != nil {
+			return fmt.Errorf("node%d: %v", i, err)
+		}
+	}
+	return nil
+}
+
+// Stop stops the started nodes and their apps
+func (c *Cluster) Stop() {
+	for _, node := range c.Nodes {
+		if node.Dngine == nil {
+			continue
+		}
+		if node.App != nil {
+			node.App.Stop()
+		}
+		node.Dngine.Stop()
+	}
+}
+
+// WaitForHeight waits until every node has stored the block at height
+func (c *Cluster) WaitForHeight(height int, timeout time.Duration) error {
+	deadline := time.Now().Add(timeout)
+	for {
+		lowest := -1
+		for i, node := range c.Nodes {
+			if node.Dngine.Height() < height {
+				lowest = i
+				break
+			}
+		}
+		if lowest < 0 {
+			return nil
+		}
+		if time.Now().After(deadline) {
+			return fmt.Errorf("node%d is at height %d after %v, expected %d", lowest, c.Nodes[lowest].Dngine.Height(), timeout, height)
+		}
+		time.Sleep(50 * time.Millisecond)
+	}
+}
@@ -0,0 +1,174 @@
+// Copyright 2017 Delos Development Foundation and contributors. Licensed
+// under the Apache License, Version 2.0. See the COPYING file at the root
+// of this distribution or at http://www.apache.org/licenses/LICENSE-2.0
+package testnet
+
+import (
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net"
+	"os"
+	"path"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	ac "github.com/DelosIsland/core/dngine/config"
+	"github.com/DelosIsland/core/dngine/types"
+	cmn "github.com/DelosIsland/core/module/lib/go-common"
+	"github.com/DelosIsland/core/module/lib/go-crypto"
+)
+
+// Config describes the testnet to generate
+type Config struct {
+	Dir        string // the nodes are generated in Dir/node0, Dir/node1...
+	Nodes      int
+	Validators int // the first nodes are the validators, all of them if 0
+	ChainID    string
+	Host       string // the nodes reach each other at this host, 127.0.0.1 if empty
+	BasePort   int    // p2p port of node0, 46656 if 0. Node i uses BasePort+10*i, its rpc that port +1 and its api +3, as the default ports do.
+	Power      int64  // of each validator, 100 if 0
+}
+
+// Node is a generated node
+type Node struct {
+	Dir      string
+	Moniker  string
+	PubKey   crypto.PubKeyEd25519
+	P2PAddr  string // host:port the other nodes dial
+	RPCAddr  string
+	APIAddr  string
+	SignByCA string
+}
+
+// Testnet is a generated testnet, node0 is the CA signing the nodes
+type Testnet struct {
+	Genesis *types.GenesisDoc
+	Nodes   []*Node
+}
+
+// NodeDir is the directory of node i in dir
+func NodeDir(dir string, i int) string {
+	return path.Join(dir, "node"+strconv.Itoa(i))
+}
+
+// SignNode signs the pubkey of a node for chainID as caKey does for signbyCA,
+// the peers of a chain only accept the nodes signed by one of its CA validators.
+func SignNode(caKey crypto.PrivKeyEd25519, pubKey crypto.PubKeyEd25519, chainID string) string {
+	sig := caKey.Sign(append(pubKey[:], chainID...)).(crypto.SignatureEd25519)
+	return hex.EncodeToString(sig[:])
+}
+
+// Generate writes the priv_validator, the shared genesis and the config of every node of the testnet.
+// Each node has the others as seeds and is signed by node0, the only CA.
+func Generate(conf Config) (*Testnet, error) {
+	if conf.Nodes <= 0 {
+		return nil, errors.New("a testnet needs at least one node")
+	}
+	if conf.Validators == 0 {
+		conf.Validators = conf.Nodes
+	}
+	if conf.Validators < 0 || conf.Validators > conf.Nodes {
+		return nil, fmt.Errorf("there can't be %d validators among %d nodes", conf.Validators, conf.Nodes)
+	}
+	if conf.ChainID == "" {
+		conf.ChainID = cmn.Fmt("annchain-%v", cmn.RandStr(6))
+	}
+	if conf.Host == "" {
+		conf.Host = "127.0.0.1"
+	}
+	if conf.BasePort == 0 {
+		conf.BasePort = 46656
+	}
+	if conf.Power == 0 {
+		conf.Power = 100
+	}
+	dir, err := filepath.Abs(conf.Dir)
+	if err != nil {
+		return nil, err
+	}
+	conf.Dir = dir
+	for i := 0; i < conf.Nodes; i++ {
+		if _, err := os.Stat(NodeDir(conf.Dir, i)); err == nil {
+			return nil, fmt.Errorf("%s already exists", NodeDir(conf.Dir, i))
+		}
+	}
+
+	tn := &Testnet{
+		Genesis: &types.GenesisDoc{
+			GenesisTime: time.Now(),
+			ChainID:     conf.ChainID,
+			Plugins:     "specialop",
+		},
+		Nodes: make([]*Node, conf.Nodes),
+	}
+	privVals := make([]*types.PrivValidator, conf.Nodes)
+	for i := range tn.Nodes {
+		dir := NodeDir(conf.Dir, i)
+		if err := cmn.EnsureDir(path.Join(dir, ac.DATADIR), 0700); err != nil {
+			return nil, err
+		}
+		privVals[i] = types.GenPrivValidator(nil)
+		privVals[i].SetFile(path.Join(dir, "priv_validator.json"))
+		privVals[i].Save()
+
+		port := conf.BasePort + 10*i
+		tn.Nodes[i] = &Node{
+			Dir:     dir,
+			Moniker: "node" + strconv.Itoa(i),
+			PubKey:  privVals[i].PubKey.(crypto.PubKeyEd25519),
+			P2PAddr: net.JoinHostPort(conf.Host, strconv.Itoa(port)),
+			RPCAddr: net.JoinHostPort(conf.Host, strconv.Itoa(port+1)),
+			APIAddr: net.JoinHostPort(conf.Host, strconv.Itoa(port+3)),
+		}
+		if i < conf.Validators {
+			tn.Genesis.Validators = append(tn.Genesis.Validators, types.GenesisValidator{
+				PubKey:     privVals[i].PubKey,
+				Amount:     conf.Power,
+				Name:       tn.Nodes[i].Moniker,
+				IsCA:       i == 0,
+				RPCAddress: "tcp://" + tn.Nodes[i].RPCAddr,
+			})
+		}
+	}
+	if err := tn.Genesis.Validate(); err != nil {
+		return nil, err
+	}
+
+	caKey := privVals[0].PrivKey.(crypto.PrivKeyEd25519)
+	for i, n := range tn.Nodes {
+		n.SignByCA = SignNode(caKey, n.PubKey, conf.ChainID)
+		if err := tn.Genesis.SaveAs(path.Join(n.Dir, "genesis.json")); err != nil {
+			return nil, err
+		}
+		seeds := make([]string, 0, len(tn.Nodes)-1)
+		for j, other := range tn.Nodes {
+			if j != i {
+				seeds = append(seeds, other.P2PAddr)
+			}
+		}
+		_, port, _ := net.SplitHostPort(n.P2PAddr)
+		_, rpcPort, _ := net.SplitHostPort(n.RPCAddr)
+		_, apiPort, _ := net.SplitHostPort(n.APIAddr)
+		settings := map[string]interface{}{
+			"environment": "development",
+			"moniker":     n.Moniker,
+			"node_laddr":  "tcp://0.0.0.0:" + port,
+			"rpc_laddr":   "tcp://0.0.0.0:" + rpcPort,
+			"api_laddr":   "tcp://0.0.0.0:" + apiPort,
+			"seeds":       strings.Join(seeds, ","),
+			"signbyCA":    n.SignByCA,
+			"fast_sync":   true,
+			"skip_upnp":   true,
+			"db_backend":  "leveldb",
+			"log_path":    n.Dir,
+		}
+		if err := ioutil.WriteFile(path.Join(n.Dir, ac.CONFIGFILE), ac.ConfigTOML(settings), 0644); err != nil {
+			return nil, err
+		}
+	}
+	return tn, nil
+}
@@ -0,0 +1,144 @@
+// Copyright 2017 Delos Development Foundation and contributors. Licensed
+// under the Apache License, Version 2.0. See the COPYING file at the root
+// of this distribution or at http://www.apache.org/licenses/LICENSE-2.0
+package testnet
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	dto "github.com/prometheus/client_model/go"
+
+	ac "github.com/DelosIsland/core/dngine/config"
+	"github.com/DelosIsland/core/dngine/types"
+	"github.com/DelosIsland/core/module/lib/ed25519"
+)
+
+func TestGenerate(t *testing.T) {
+	dir, err := ioutil.TempDir("", "testnet")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	tn, err := Generate(Config{Dir: dir, Nodes: 3, Validators: 2, ChainID: "testnet", BasePort: 30000})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(tn.Genesis.Validators) != 2 || !tn.Genesis.Validators[0].IsCA || tn.Genesis.Validators[1].IsCA {
+		t.Errorf("Expected 2 validators with node0 as the CA, got %v", tn.Genesis.Validators)
+	}
+
+	caKey := [32]byte(tn.Nodes[0].PubKey)
+	for i, node := range tn.Nodes {
+		genDoc, err := types.ReadGenesisDoc(mustRead(t, NodeDir(dir, i)+"/genesis.json"))
+		if err != nil || genDoc.ChainID != "testnet" || len(genDoc.Validators) != 2 {
+			t.Errorf("Expected node%d to have the shared genesis, got %v %v", i, genDoc, err)
+		}
+		sig, err := types.StringTo64byte(node.SignByCA)
+		if err != nil || !ed25519.Verify(&caKey, append(node.PubKey[:], "testnet"...), &sig) {
+			t.Errorf("Expected node%d to be signed by node0", i)
+		}
+
+		conf := ac.GetConfig(NodeDir(dir, i))
+		if conf.GetString("signbyCA") != node.SignByCA {
+			t.Errorf("Expected the signbyCA of node%d in its config", i)
+		}
+		seeds := strings.Split(conf.GetString("seeds"), ",")
+		if len(seeds) != 2 || strings.Contains(conf.GetString("seeds"), node.P2PAddr) {
+			t.Errorf("Expected the 2 other nodes as the seeds of node%d, got %v", i, seeds)
+		}
+	}
+	if tn.Nodes[2].P2PAddr != "127.0.0.1:30020" || tn.Nodes[2].RPCAddr != "127.0.0.1:30021" {
+		t.Errorf("Expected node2 on 30020, got %s and %s", tn.Nodes[2].P2PAddr, tn.Nodes[2].RPCAddr)
+	}
+
+	if _, err := Generate(Config{Dir: dir, Nodes: 1}); err == nil {
+		t.Error("Expected the existing nodes not to be overwritten")
+	}
+}
+
+func TestCluster(t *testing.T) {
+	if testing.Short() {
+		t.Skip("runs a 4 nodes chain")
+	}
+	dir, err := ioutil.TempDir("", "cluster")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	c, err := NewCluster(dir, 4, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Stop()
+	if err := c.Start(); err != nil {
+		t.Fatal(err)
+	}
+	if err := c.WaitForHeight(3, 60*time.Second); err != nil {
+		t.Fatal(err)
+	}
+
+	_, meta := c.Nodes[0].Dngine.GetBlock(2)
+	for i, node := range c.Nodes[1:] {
+		if _, other := node.Dngine.GetBlock(2); !bytes.Equal(other.Hash, meta.Hash) {
+			t.Errorf("Expected node%d to have the block 2 of node0", i+1)
+		}
+	}
+
+	for i, node := range c.Nodes {
+		if err := node.Dngine.CheckReady(); err != nil || node.Dngine.IsCatchingUp() {
+			t.Errorf("Expected node%d to be ready, got %v", i, err)
+		}
+		if power, lastSigned := node.Dngine.GetValidatorStatus(); power != 100 || lastSigned < 3 {
+			t.Errorf("Expected node%d to be a validator signing the blocks, got power %d last signed %d", i, power, lastSigned)
+		}
+	}
+
+	families, err := c.Nodes[0].Dngine.Metrics().Gather()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if height := gaugeValue(families, "dngine_consensus_height"); height < 3 {
+		t.Errorf("Expected the consensus height of node0 to be at least 3, got %v", height)
+	}
+	if validators := gaugeValue(families, "dngine_consensus_validators"); validators != 4 {
+		t.Errorf("Expected 4 validators in the metrics, got %v", validators)
+	}
+	for _, name := range []string{"dngine_consensus_step_duration_seconds", "dngine_p2p_sent_bytes_total", "dngine_db_write_duration_seconds"} {
+		if findFamily(families, name) == nil {
+			t.Errorf("Expected the metric %s", name)
+		}
+	}
+}
+
+func findFamily(families []*dto.MetricFamily, name string) *dto.MetricFamily {
+	for _, f := range families {
+		if f.GetName() == name {
+			return f
+		}
+	}
+	return nil
+}
+
+// gaugeValue is the value of the gauge of the chain, -1 when it is missing
+func gaugeValue(families []*dto.MetricFamily, name string) float64 {
+	f := findFamily(families, name)
+	if f == nil || len(f.Metric) != 1 || f.Metric[0].Label[0].GetName() != "chain_id" {
+		return -1
+	}
+	return f.Metric[0].GetGauge().GetValue()
+}
+
+func mustRead(t *testing.T, file string) []byte {
+	data, err := ioutil.ReadFile(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return data
+}
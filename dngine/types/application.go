@@ -11,10 +11,17 @@ type Application interface {
 	GetDngineHooks() Hooks
 	CompatibleWithDngine()
 	CheckTx([]byte) error
-	Query([]byte) Result
+	Query(RequestQuery) ResponseQuery
 	Info() ResultInfo
 	Start()
 	Stop()
 }
 
 type AppMaker func(config.Config) Application
+
+// Rollbacker is implemented by the applications which can go back to their state
+// at the end of an earlier height, appHash is what they returned on its commit.
+// The rollback command calls it with the node stopped.
+type Rollbacker interface {
+	Rollback(height int, appHash []byte) error
+}
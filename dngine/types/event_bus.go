@@ -0,0 +1,159 @@
+// Copyright 2017 Delos Development Foundation and contributors. Licensed
+// under the Apache License, Version 2.0. See the COPYING file at the root
+// of this distribution or at http://www.apache.org/licenses/LICENSE-2.0
+package types
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"go.uber.org/zap"
+
+	"github.com/DelosIsland/core/module/lib/go-events"
+	"github.com/DelosIsland/core/module/lib/go-pubsub"
+	"github.com/DelosIsland/core/module/lib/go-pubsub/query"
+)
+
+// the tags of the events on the bus, tm.event is the event type set on all of them
+const (
+	EventTypeKey = "tm.event"
+
+	TxHashKey   = "tx.hash"
+	TxHeightKey = "tx.height"
+	TxCodeKey   = "tx.code"
+
+	BlockHeightKey = "block.height"
+
+	RoundStateHeightKey = "rs.height"
+	RoundStateRoundKey  = "rs.round"
+	RoundStateStepKey   = "rs.step"
+
+	VoteHeightKey    = "vote.height"
+	VoteRoundKey     = "vote.round"
+	VoteTypeKey      = "vote.type"
+	VoteValidatorKey = "vote.validator"
+)
+
+// EventBus delivers the events of a chain to the subscribers whose query matches their tags,
+// like tm.event='Tx' AND tx.height>5. Every subscriber reads from its own buffered channel,
+// a slow one doesn't hold up the consensus, its overflow policy decides what it misses.
+//
+// The hook events carry reply channels for the app, they stay on the EventSwitch.
+type EventBus struct {
+	pubsub *pubsub.Server
+	evsw   events.EventSwitch
+}
+
+func NewEventBus(logger *zap.Logger) *EventBus {
+	return &EventBus{
+		pubsub: pubsub.NewServer(),
+		evsw:   events.NewEventSwitch(logger),
+	}
+}
+
+// Subscribe to the events matching q, see pubsub.Server.Subscribe. The data of the messages are TMEventData.
+func (b *EventBus) Subscribe(subscriber string, q pubsub.Query, capacity int, policy pubsub.OverflowPolicy) (*pubsub.Subscription, error) {
+	return b.pubsub.Subscribe(subscriber, q, capacity, policy)
+}
+
+func (b *EventBus) Unsubscribe(subscriber string, q pubsub.Query) error {
+	return b.pubsub.Unsubscribe(subscriber, q)
+}
+
+func (b *EventBus) UnsubscribeAll(subscriber string) error {
+	return b.pubsub.UnsubscribeAll(subscriber)
+}
+
+// Publish hands the event fired as event to the matching subscriptions
+func (b *EventBus) Publish(event string, data TMEventData) {
+	if tags := EventTags(event, data); tags != nil {
+		b.pubsub.Publish(data, tags)
+	}
+}
+
+// EventSwitch is the compatibility adapter of the bus: its listeners are called on the firing
+// goroutine for the exact event names as before, and every event fired is published on the bus.
+// Removing a listener ends the subscriptions of the same id too.
+func (b *EventBus) EventSwitch() EventSwitch {
+	return &busEventSwitch{EventSwitch: b.evsw, bus: b}
+}
+
+type busEventSwitch struct {
+	events.EventSwitch
+	bus *EventBus
+}
+
+func (s *busEventSwitch) FireEvent(event string, data events.EventData) {
+	s.EventSwitch.FireEvent(event, data)
+	if d, ok := data.(TMEventData); ok {
+		s.bus.Publish(event, d)
+	}
+}
+
+func (s *busEventSwitch) RemoveListener(listenerID string) {
+	s.EventSwitch.RemoveListener(listenerID)
+	s.bus.UnsubscribeAll(listenerID)
+}
+
+// EventTags are the tags of an event fired as event, nil for the events which aren't published
+func EventTags(event string, data TMEventData) map[string]string {
+	tags := make(map[string]string)
+	switch d := data.(type) {
+	case EventDataTx:
+		tags[EventTypeKey] = "Tx"
+		tags[TxHashKey] = fmt.Sprintf("%X", d.Tx.Hash())
+		tags[TxHeightKey] = strconv.Itoa(d.Height)
+		tags[TxCodeKey] = strconv.Itoa(int(d.Code))
+	case EventDataNewBlock:
+		tags[EventTypeKey] = event
+		if d.Block != nil {
+			tags[BlockHeightKey] = strconv.Itoa(d.Block.Height)
+		}
+	case EventDataNewBlockHeader:
+		tags[EventTypeKey] = event
+		if d.Header != nil {
+			tags[BlockHeightKey] = strconv.Itoa(d.Header.Height)
+		}
+	case EventDataRoundState:
+		tags[EventTypeKey] = event
+		tags[RoundStateHeightKey] = strconv.Itoa(d.Height)
+		tags[RoundStateRoundKey] = strconv.Itoa(d.Round)
+		tags[RoundStateStepKey] = d.Step
+	case EventDataVote:
+		tags[EventTypeKey] = event
+		if d.Vote != nil {
+			tags[VoteHeightKey] = strconv.Itoa(d.Vote.Height)
+			tags[VoteRoundKey] = strconv.Itoa(d.Vote.Round)
+			tags[VoteTypeKey] = voteTypeName(d.Vote.Type)
+			tags[VoteValidatorKey] = fmt.Sprintf("%X", d.Vote.ValidatorAddress)
+		}
+	case EventDataSwitchToConsensus:
+		tags[EventTypeKey] = event
+	default:
+		return nil
+	}
+	return tags
+}
+
+func voteTypeName(t byte) string {
+	switch t {
+	case VoteTypePrevote:
+		return "prevote"
+	case VoteTypePrecommit:
+		return "precommit"
+	}
+	return strconv.Itoa(int(t))
+}
+
+// EventQuery is the query of the events subscribed to by their former name,
+// Tx:<hash> becomes tm.event='Tx' AND tx.hash='<hash>'
+func EventQuery(event string) (*query.Query, error) {
+	if strings.HasPrefix(event, "Tx:") {
+		return query.New(fmt.Sprintf("%s='Tx' AND %s='%s'", EventTypeKey, TxHashKey, strings.TrimPrefix(event, "Tx:")))
+	}
+	if strings.ContainsAny(event, "'= ") {
+		return nil, fmt.Errorf("bad event name %s", event)
+	}
+	return query.New(fmt.Sprintf("%s='%s'", EventTypeKey, event))
+}
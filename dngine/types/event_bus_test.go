@@ -0,0 +1,61 @@
+// Copyright 2017 Delos Development Foundation and contributors. Licensed
+// under the Apache License, Version 2.0. See the COPYING file at the root
+// of this distribution or at http://www.apache.org/licenses/LICENSE-2.0
+package types
+
+import (
+	"testing"
+
+	"go.uber.org/zap"
+
+	"github.com/DelosIsland/core/module/lib/go-pubsub"
+	"github.com/DelosIsland/core/module/lib/go-pubsub/query"
+)
+
+func TestEventBusSwitchAdapter(t *testing.T) {
+	bus := NewEventBus(zap.NewNop())
+	evsw := bus.EventSwitch()
+	if _, err := evsw.Start(); err != nil {
+		t.Fatal(err)
+	}
+
+	tx := EventDataTx{Height: 6, Tx: Tx("tx")}
+	var heard []TMEventData
+	AddListenerForEvent(evsw, "listener", EventStringTx(tx.Tx), func(data TMEventData) {
+		heard = append(heard, data)
+	})
+	late, err := bus.Subscribe("client", query.MustParse("tm.event='Tx' AND tx.height>5"), 1, pubsub.DropNewest)
+	if err != nil {
+		t.Fatal(err)
+	}
+	byName, _ := EventQuery(EventStringTx(tx.Tx))
+	sameTx, err := bus.Subscribe("client", byName, 1, pubsub.DropNewest)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	FireEventTx(evsw, EventDataTx{Height: 5, Tx: Tx("other")})
+	FireEventTx(evsw, tx)
+	FireEventNewBlock(evsw, EventDataNewBlock{&Block{Header: &Header{Height: 6}}})
+	FireEventHookCommit(evsw, NewEventDataHookCommit(6, 0, nil))
+
+	if len(heard) != 1 {
+		t.Errorf("Expected the listener to hear the tx once, got %v", heard)
+	}
+	for _, sub := range []*pubsub.Subscription{late, sameTx} {
+		select {
+		case msg := <-sub.Out():
+			if msg.Data.(EventDataTx).Height != 6 {
+				t.Errorf("Expected the tx of height 6 for %s, got %v", sub.Query(), msg.Data)
+			}
+		default:
+			t.Errorf("Expected the tx for %s", sub.Query())
+		}
+	}
+
+	// the websockets remove their listeners when they close
+	evsw.RemoveListener("client")
+	if _, ok := <-late.Out(); ok {
+		t.Error("Expected the subscriptions of the listener id to end")
+	}
+}
@@ -104,11 +104,12 @@ type EventDataNewBlockHeader struct {
 
 // All txs fire EventDataTx
 type EventDataTx struct {
-	Tx    Tx       `json:"tx"`
-	Data  []byte   `json:"data"`
-	Log   string   `json:"log"`
-	Code  CodeType `json:"code"`
-	Error string   `json:"error"` // this is redundant information for now
+	Height int      `json:"height"`
+	Tx     Tx       `json:"tx"`
+	Data   []byte   `json:"data"`
+	Log    string   `json:"log"`
+	Code   CodeType `json:"code"`
+	Error  string   `json:"error"` // this is redundant information for now
 }
 
 // NOTE: This goes into the replay WAL
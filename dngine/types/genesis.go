@@ -4,6 +4,9 @@
 package types
 
 import (
+	"bytes"
+	"errors"
+	"fmt"
 	"time"
 
 	"encoding/json"
@@ -34,6 +37,48 @@ type GenesisDoc struct {
 	Validators  []GenesisValidator `json:"validators"`
 	AppHash     []byte             `json:"app_hash"`
 	Plugins     string             `json:"plugins"`
+
+	// AppState is read by the app when it starts from the genesis,
+	// AppHash must be the hash the app makes of it. The state keeps no copy.
+	AppState json.RawMessage `json:"app_state,omitempty" binary:"-"`
+}
+
+// Validate checks that the doc can start a chain, its errors name the faulty validator
+func (genDoc *GenesisDoc) Validate() error {
+	if genDoc.ChainID == "" {
+		return errors.New("the chain_id is empty")
+	}
+	if len(genDoc.Validators) == 0 {
+		return errors.New("there are no validators")
+	}
+	for i, val := range genDoc.Validators {
+		if val.PubKey == nil {
+			return fmt.Errorf("%s has no pub_key", val.desc(i))
+		}
+		if val.Amount <= 0 {
+			return fmt.Errorf("%s has a power of %d, it must be positive", val.desc(i), val.Amount)
+		}
+		for j, other := range genDoc.Validators[:i] {
+			if bytes.Equal(other.PubKey.Bytes(), val.PubKey.Bytes()) {
+				return fmt.Errorf("%s has the pub_key of %s", val.desc(i), other.desc(j))
+			}
+			if val.Name != "" && other.Name == val.Name {
+				return fmt.Errorf("%s has the name of %s", val.desc(i), other.desc(j))
+			}
+		}
+	}
+	if len(genDoc.AppState) != 0 && !json.Valid(genDoc.AppState) {
+		return errors.New("the app_state is not valid JSON")
+	}
+	return nil
+}
+
+// desc names the validator at index i of the genesis in errors
+func (gv *GenesisValidator) desc(i int) string {
+	if gv.Name == "" {
+		return fmt.Sprintf("validator %d", i+1)
+	}
+	return fmt.Sprintf("validator %d (%s)", i+1, gv.Name)
 }
 
 // Utility method for saving GenensisDoc as JSON file.
@@ -46,14 +91,19 @@ func (genDoc *GenesisDoc) SaveAs(file string) error {
 // Make genesis state from file
 
 func GenesisDocFromJSON(jsonBlob []byte) (genState *GenesisDoc) {
-	var err error
-	wire.ReadJSONPtr(&genState, jsonBlob, &err)
+	genState, err := ReadGenesisDoc(jsonBlob)
 	if err != nil {
 		Exit(Fmt("Couldn't read GenesisDoc: %v", err))
 	}
 	return
 }
 
+// ReadGenesisDoc is GenesisDocFromJSON returning the error instead of exiting
+func ReadGenesisDoc(jsonBlob []byte) (genState *GenesisDoc, err error) {
+	wire.ReadJSONPtr(&genState, jsonBlob, &err)
+	return
+}
+
 type GenesisValidatorJson struct {
 	PubKey     [32]byte `json:"pub_key"`
 	Amount     int64    `json:"amount"`
@@ -0,0 +1,52 @@
+// Copyright 2017 Delos Development Foundation and contributors. Licensed
+// under the Apache License, Version 2.0. See the COPYING file at the root
+// of this distribution or at http://www.apache.org/licenses/LICENSE-2.0
+package types
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/DelosIsland/core/module/lib/go-crypto"
+)
+
+func TestGenesisValidate(t *testing.T) {
+	key := func(b byte) crypto.PubKeyEd25519 {
+		var pubKey crypto.PubKeyEd25519
+		pubKey[0] = b
+		return pubKey
+	}
+	valid := func() *GenesisDoc {
+		return &GenesisDoc{
+			ChainID: "test",
+			Validators: []GenesisValidator{
+				{PubKey: key(1), Amount: 10, Name: "v1", IsCA: true},
+				{PubKey: key(2), Amount: 10, Name: "v2"},
+			},
+			AppState: []byte(`{"accounts":{}}`),
+		}
+	}
+	if err := valid().Validate(); err != nil {
+		t.Fatalf("Expected the genesis to be valid, got %v", err)
+	}
+
+	tests := []struct {
+		change func(*GenesisDoc)
+		err    string
+	}{
+		{func(g *GenesisDoc) { g.ChainID = "" }, "the chain_id is empty"},
+		{func(g *GenesisDoc) { g.Validators = nil }, "there are no validators"},
+		{func(g *GenesisDoc) { g.Validators[1].PubKey = nil }, "validator 2 (v2) has no pub_key"},
+		{func(g *GenesisDoc) { g.Validators[1].Amount = 0 }, "validator 2 (v2) has a power of 0"},
+		{func(g *GenesisDoc) { g.Validators[1].PubKey = key(1) }, "validator 2 (v2) has the pub_key of validator 1 (v1)"},
+		{func(g *GenesisDoc) { g.Validators[1].Name = "v1" }, "validator 2 (v1) has the name of validator 1 (v1)"},
+		{func(g *GenesisDoc) { g.AppState = []byte(`{"accounts"`) }, "the app_state is not valid JSON"},
+	}
+	for _, test := range tests {
+		genDoc := valid()
+		test.change(genDoc)
+		if err := genDoc.Validate(); err == nil || !strings.HasPrefix(err.Error(), test.err) {
+			t.Errorf("Expected %q, got %v", test.err, err)
+		}
+	}
+}
@@ -16,6 +16,7 @@ import (
 	"github.com/DelosIsland/core/module/lib/ed25519"
 	. "github.com/DelosIsland/core/module/lib/go-common"
 	"github.com/DelosIsland/core/module/lib/go-crypto"
+	"github.com/DelosIsland/core/module/lib/go-crypto/keystore"
 	"github.com/DelosIsland/core/module/lib/go-wire"
 )
 
@@ -56,6 +57,9 @@ type PrivValidator struct {
 	filePath string
 	mtx      sync.Mutex
 
+	// the key was unlocked from a keystore, it is never written to filePath
+	fromKeyStore bool
+
 	logger *zap.Logger
 }
 
@@ -140,6 +144,41 @@ func LoadOrGenPrivValidator(logger *zap.Logger, filePath string) *PrivValidator
 	return privValidator
 }
 
+// LoadPrivValidatorFromKeyStore unlocks the validator key stored under name in ks,
+// filePath only keeps the signing state and is created if missing.
+func LoadPrivValidatorFromKeyStore(logger *zap.Logger, filePath string, ks *keystore.KeyStore, name, passphrase string) (*PrivValidator, error) {
+	privKey, err := ks.Unlock(name, passphrase)
+	if err != nil {
+		return nil, err
+	}
+	edKey, ok := privKey.(crypto.PrivKeyEd25519)
+	if !ok {
+		return nil, fmt.Errorf("validator key %s must be ed25519", name)
+	}
+
+	privVal := &PrivValidator{}
+	if privValJSONBytes, err := ioutil.ReadFile(filePath); err == nil {
+		wire.ReadJSON(privVal, privValJSONBytes, &err)
+		if err != nil {
+			return nil, fmt.Errorf("Error reading PrivValidator from %v: %v", filePath, err)
+		}
+		if !bytes.Equal(privVal.Address, edKey.PubKey().Address()) {
+			return nil, fmt.Errorf("%v belongs to another validator than key %s", filePath, name)
+		}
+	} else if !os.IsNotExist(err) {
+		return nil, err
+	}
+	privVal.Address = edKey.PubKey().Address()
+	privVal.PubKey = edKey.PubKey()
+	privVal.PrivKey = edKey
+	privVal.Signer = NewDefaultSigner(edKey)
+	privVal.filePath = filePath
+	privVal.logger = logger
+	privVal.fromKeyStore = true
+	privVal.Save()
+	return privVal, nil
+}
+
 func (privVal *PrivValidator) SetFile(filePath string) {
 	privVal.mtx.Lock()
 	defer privVal.mtx.Unlock()
@@ -156,7 +195,20 @@ func (privVal *PrivValidator) save() {
 	if privVal.filePath == "" {
 		PanicSanity("Cannot save PrivValidator: filePath not set")
 	}
-	jsonBytes := wire.JSONBytesPretty(privVal)
+	var jsonBytes []byte
+	if privVal.fromKeyStore {
+		jsonBytes = wire.JSONBytesPretty(&PrivValidator{
+			Address:       privVal.Address,
+			PubKey:        privVal.PubKey,
+			LastHeight:    privVal.LastHeight,
+			LastRound:     privVal.LastRound,
+			LastStep:      privVal.LastStep,
+			LastSignature: privVal.LastSignature,
+			LastSignBytes: privVal.LastSignBytes,
+		})
+	} else {
+		jsonBytes = wire.JSONBytesPretty(privVal)
+	}
 	err := WriteFileAtomic(privVal.filePath, jsonBytes, 0600)
 	if err != nil {
 		// `@; BOOM!!!
@@ -182,6 +234,13 @@ func (privVal *PrivValidator) GetPrivateKey() crypto.PrivKey {
 	return privVal.PrivKey
 }
 
+// LastSignedHeight is the height of the last vote or proposal signed
+func (privVal *PrivValidator) LastSignedHeight() int {
+	privVal.mtx.Lock()
+	defer privVal.mtx.Unlock()
+	return privVal.LastHeight
+}
+
 func (privVal *PrivValidator) SignVote(chainID string, vote *Vote) error {
 	privVal.mtx.Lock()
 	defer privVal.mtx.Unlock()
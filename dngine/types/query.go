@@ -0,0 +1,39 @@
+// Copyright 2017 Delos Development Foundation and contributors. Licensed
+// under the Apache License, Version 2.0. See the COPYING file at the root
+// of this distribution or at http://www.apache.org/licenses/LICENSE-2.0
+package types
+
+// RequestQuery asks the application for the value stored under Path and Data.
+// A zero Height means the last committed state,
+// Prove asks for a proof of the value against the AppHash of that height.
+type RequestQuery struct {
+	Path   string `json:"path"`
+	Data   []byte `json:"data"`
+	Height uint64 `json:"height"`
+	Prove  bool   `json:"prove"`
+}
+
+// CONTRACT: a zero ResponseQuery is OK.
+type ResponseQuery struct {
+	Code   CodeType `json:"code"`
+	Log    string   `json:"log"` // Can be non-deterministic
+	Key    []byte   `json:"key"`
+	Value  []byte   `json:"value"`
+	Height uint64   `json:"height"` // height of the state the value was read from
+	Proof  []byte   `json:"proof"`  // app specific, empty unless asked for
+}
+
+func (res ResponseQuery) IsOK() bool {
+	return res.Code == CodeType_OK
+}
+
+func (res ResponseQuery) IsErr() bool {
+	return res.Code != CodeType_OK
+}
+
+func NewQueryError(code CodeType, log string) ResponseQuery {
+	return ResponseQuery{
+		Code: code,
+		Log:  log,
+	}
+}
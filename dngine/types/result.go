@@ -27,10 +27,14 @@ type ExecuteInvalidTx struct {
 	Error error
 }
 
+// ExecuteResult is what the app returns on execute.
+// The error of an invalid tx gives its code if it is a Result.
 type ExecuteResult struct {
-	ValidTxs   [][]byte
-	InvalidTxs []ExecuteInvalidTx
-	Error      error
+	ValidTxs [][]byte
+	// ValidTxsData is the optional result data of ValidTxs, in the same order
+	ValidTxsData [][]byte
+	InvalidTxs   []ExecuteInvalidTx
+	Error        error
 }
 
 func NewResult(code CodeType, data []byte, log string) Result {
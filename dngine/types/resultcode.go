@@ -52,6 +52,7 @@ var CodeType_name = map[int32]string{
 	4:   "Unauthorized",
 	5:   "InsufficientFunds",
 	6:   "UnknownRequest",
+	7:   "InvalidTx",
 	101: "BaseDuplicateAddress",
 	102: "BaseEncodingError",
 	103: "BaseInsufficientFees",
@@ -36,22 +36,38 @@ type ResultStatus struct {
 	LatestBlockHash   []byte        `json:"latest_block_hash"`
 	LatestAppHash     []byte        `json:"latest_app_hash"`
 	LatestBlockHeight int           `json:"latest_block_height"`
-	LatestBlockTime   int64         `json:"latest_block_time"` // nano
+	LatestBlockTime   int64         `json:"latest_block_time"`  // nano
+	CatchingUp        bool          `json:"catching_up"`        // fast syncing the blocks
+	SyncTargetHeight  int           `json:"sync_target_height"` // highest height of the peers while catching up
+	VotingPower       int64         `json:"voting_power"`       // 0 when the node isn't a validator
+	LastSignedHeight  int           `json:"last_signed_height"`
 }
 
 type ResultNetInfo struct {
-	Listening bool     `json:"listening"`
-	Listeners []string `json:"listeners"`
-	Peers     []*Peer  `json:"peers"`
+	Listening bool                 `json:"listening"`
+	Listeners []string             `json:"listeners"`
+	Peers     []*Peer              `json:"peers"`
+	Banned    []p2p.PeerScore      `json:"banned"`
+	Channels  []p2p.ChannelMetrics `json:"channels"`
+}
+
+type ResultP2PMetrics struct {
+	Channels []p2p.ChannelMetrics `json:"channels"`
 }
 
 type ResultDialSeeds struct {
+	Log string `json:"log"`
+}
+
+type ResultDialPeers struct {
+	Log string `json:"log"`
 }
 
 type Peer struct {
 	p2p.NodeInfo     `json:"node_info"`
 	IsOutbound       bool                 `json:"is_outbound"`
 	ConnectionStatus p2p.ConnectionStatus `json:"connection_status"`
+	Score            int                  `json:"score"`
 }
 
 type ResultValidators struct {
@@ -76,12 +92,27 @@ type ResultRequestSpecialOP struct {
 	Log  string   `json:"log"`
 }
 
+// ResultBroadcastTxCommit reports the CheckTx and the deliver phases of a tx apart,
+// Height is 0 and DeliverTx empty if CheckTx refused it.
 type ResultBroadcastTxCommit struct {
+	CheckTx   ResultTxPhase `json:"check_tx"`
+	DeliverTx ResultTxPhase `json:"deliver_tx"`
+	Hash      []byte        `json:"hash"`
+	Height    int           `json:"height"`
+}
+
+// ResultTxPhase is the outcome of a tx in one phase, Data is provided by the app
+type ResultTxPhase struct {
 	Code CodeType `json:"code"`
 	Data []byte   `json:"data"`
 	Log  string   `json:"log"`
 }
 
+// IsOK tells whether the tx was both accepted and executed
+func (r *ResultBroadcastTxCommit) IsOK() bool {
+	return r.CheckTx.Code == CodeType_OK && r.DeliverTx.Code == CodeType_OK && r.Height > 0
+}
+
 type ResultUnconfirmedTxs struct {
 	N   int  `json:"n_txs"`
 	Txs []Tx `json:"txs"`
@@ -95,7 +126,7 @@ type ResultInfo struct {
 }
 
 type ResultQuery struct {
-	Result Result `json:"result"`
+	Result ResponseQuery `json:"result"`
 }
 
 type ResultRefuseList struct {
@@ -104,6 +135,10 @@ type ResultRefuseList struct {
 
 type ResultUnsafeFlushMempool struct{}
 
+type ResultUnsafeUnbanPeer struct {
+	Peer p2p.PeerScore `json:"peer"`
+}
+
 type ResultUnsafeSetConfig struct{}
 
 type ResultUnsafeProfile struct{}
@@ -119,6 +154,44 @@ type ResultEvent struct {
 	Data TMEventData `json:"data"`
 }
 
+// RPCErrorData is the data of the rpc errors of requests refused by the application
+type RPCErrorData struct {
+	Code     CodeType `json:"code"`
+	CodeName string   `json:"code_name"`
+	Log      string   `json:"log"`
+}
+
+// NewRPCAppError reports the refusal of the application, err carries the code if it is a Result
+func NewRPCAppError(err error) *rpctypes.RPCError {
+	res := ResultOf(err)
+	return &rpctypes.RPCError{
+		Code:    rpctypes.CodeAppError,
+		Message: res.Log,
+		Data:    RPCErrorData{Code: res.Code, CodeName: CodeType_name[int32(res.Code)], Log: res.Log},
+	}
+}
+
+// ResultOf returns err if it is a Result, an InternalError otherwise
+func ResultOf(err error) Result {
+	if res, ok := err.(Result); ok {
+		return res
+	}
+	return NewResult(CodeType_InternalError, nil, err.Error())
+}
+
+// AppErrorData returns the application code of an rpc error, false if the application didn't raise it
+func AppErrorData(err error) (RPCErrorData, bool) {
+	var data RPCErrorData
+	rpcErr, ok := err.(*rpctypes.RPCError)
+	if !ok || rpcErr.Code != rpctypes.CodeAppError {
+		return data, false
+	}
+	if rpcErr.DecodeData(&data) != nil {
+		return data, false
+	}
+	return data, true
+}
+
 type ResultSurveillance struct {
 	NanoSecsPerTx time.Duration
 	Height        int
@@ -147,10 +220,12 @@ const (
 	ResultTypeBlock          = byte(0x03)
 
 	// 0x2 bytes are for the network
-	ResultTypeStatus    = byte(0x20)
-	ResultTypeNetInfo   = byte(0x21)
-	ResultTypeDialSeeds = byte(0x22)
-	ResultTypeShards    = byte(0x23)
+	ResultTypeStatus     = byte(0x20)
+	ResultTypeNetInfo    = byte(0x21)
+	ResultTypeDialSeeds  = byte(0x22)
+	ResultTypeShards     = byte(0x23)
+	ResultTypeDialPeers  = byte(0x24)
+	ResultTypeP2PMetrics = byte(0x25)
 
 	// 0x1  bytes are for refuseList
 	ResultTypeRefuseList = byte(0x10)
@@ -180,6 +255,7 @@ const (
 	ResultTypeUnsafeStopCPUProfiler  = byte(0xa2)
 	ResultTypeUnsafeWriteHeapProfile = byte(0xa3)
 	ResultTypeUnsafeFlushMempool     = byte(0xa4)
+	ResultTypeUnsafeUnbanPeer        = byte(0xa5)
 	ResultTypeCoreVersion            = byte(0xaf)
 
 	// 0x9 bytes are for za_surveillance
@@ -200,6 +276,8 @@ var _ = wire.RegisterInterface(
 	wire.ConcreteType{&ResultShards{}, ResultTypeShards},
 	wire.ConcreteType{&ResultNetInfo{}, ResultTypeNetInfo},
 	wire.ConcreteType{&ResultDialSeeds{}, ResultTypeDialSeeds},
+	wire.ConcreteType{&ResultDialPeers{}, ResultTypeDialPeers},
+	wire.ConcreteType{&ResultP2PMetrics{}, ResultTypeP2PMetrics},
 	wire.ConcreteType{&ResultValidators{}, ResultTypeValidators},
 	wire.ConcreteType{&ResultDumpConsensusState{}, ResultTypeDumpConsensusState},
 	wire.ConcreteType{&ResultBroadcastTx{}, ResultTypeBroadcastTx},
@@ -214,6 +292,7 @@ var _ = wire.RegisterInterface(
 	wire.ConcreteType{&ResultUnsafeProfile{}, ResultTypeUnsafeStopCPUProfiler},
 	wire.ConcreteType{&ResultUnsafeProfile{}, ResultTypeUnsafeWriteHeapProfile},
 	wire.ConcreteType{&ResultUnsafeFlushMempool{}, ResultTypeUnsafeFlushMempool},
+	wire.ConcreteType{&ResultUnsafeUnbanPeer{}, ResultTypeUnsafeUnbanPeer},
 	wire.ConcreteType{&ResultQuery{}, ResultTypeQuery},
 	wire.ConcreteType{&ResultInfo{}, ResultTypeInfo},
 	wire.ConcreteType{&ResultSurveillance{}, ResultTypeSurveillance},
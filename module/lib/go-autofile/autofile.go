@@ -103,6 +103,12 @@ func (af *AutoFile) Sync() error {
 	af.mtx.Lock()
 	defer af.mtx.Unlock()
 
+	// the file may have been closed by the ticker, a new handle syncs it as well
+	if af.file == nil {
+		if err := af.openFile(); err != nil {
+			return err
+		}
+	}
 	return af.file.Sync()
 }
 
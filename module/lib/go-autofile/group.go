@@ -150,12 +150,29 @@ func (g *Group) WriteLine(line string) error {
 	return err
 }
 
+// Write appends p to the head, it is buffered like WriteLine
+func (g *Group) Write(p []byte) (int, error) {
+	g.mtx.Lock()
+	defer g.mtx.Unlock()
+	return g.headBuf.Write(p)
+}
+
 func (g *Group) Flush() error {
 	g.mtx.Lock()
 	defer g.mtx.Unlock()
 	return g.headBuf.Flush()
 }
 
+// Writes the buffer to the head and syncs the head to the disk
+func (g *Group) FlushAndSync() error {
+	g.mtx.Lock()
+	defer g.mtx.Unlock()
+	if err := g.headBuf.Flush(); err != nil {
+		return err
+	}
+	return g.Head.Sync()
+}
+
 func (g *Group) processTicks() {
 	for {
 		_, ok := <-g.ticker.C
@@ -224,6 +241,10 @@ func (g *Group) RotateFile() {
 	g.mtx.Lock()
 	defer g.mtx.Unlock()
 
+	// what was written before belongs to the rotated file
+	if err := g.headBuf.Flush(); err != nil {
+		panic(err)
+	}
 	dstPath := filePathForIndex(g.Head.Path, g.maxIndex, g.maxIndex+1)
 	err := os.Rename(g.Head.Path, dstPath)
 	if err != nil {
@@ -494,6 +515,13 @@ func (g *Group) readGroupInfo() GroupInfo {
 	return GroupInfo{minIndex, maxIndex, totalSize, headSize}
 }
 
+// Returns the path of the file at index, the head's for the max index
+func (g *Group) FilePath(index int) string {
+	g.mtx.Lock()
+	defer g.mtx.Unlock()
+	return filePathForIndex(g.Head.Path, index, g.maxIndex)
+}
+
 func filePathForIndex(headPath string, index int, maxIndex int) string {
 	if index == maxIndex {
 		return headPath
@@ -583,6 +611,42 @@ func (gr *GroupReader) ReadLine() (string, error) {
 	}
 }
 
+// Reads the files one after the other, returns io.EOF at the end of the head
+func (gr *GroupReader) Read(p []byte) (int, error) {
+	gr.mtx.Lock()
+	defer gr.mtx.Unlock()
+
+	// From PushLine
+	if gr.curLine != nil {
+		n := copy(p, gr.curLine)
+		if n < len(gr.curLine) {
+			gr.curLine = gr.curLine[n:]
+		} else {
+			gr.curLine = nil
+		}
+		return n, nil
+	}
+
+	if gr.curReader == nil {
+		if err := gr.openFile(gr.curIndex); err != nil {
+			return 0, err
+		}
+	}
+	for {
+		n, err := gr.curReader.Read(p)
+		if err == io.EOF {
+			if n > 0 {
+				return n, nil
+			}
+			if err := gr.openFile(gr.curIndex + 1); err != nil {
+				return 0, err
+			}
+			continue
+		}
+		return n, err
+	}
+}
+
 // IF index > gr.Group.maxIndex, returns io.EOF
 // CONTRACT: caller should hold gr.mtx
 func (gr *GroupReader) openFile(index int) error {
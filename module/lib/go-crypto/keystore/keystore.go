@@ -0,0 +1,285 @@
+// Package keystore keeps private keys in a directory of armored files,
+// each encrypted with its own passphrase.
+package keystore
+
+import (
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path"
+	"sort"
+	"strings"
+
+	. "github.com/DelosIsland/core/module/lib/go-common"
+	"github.com/DelosIsland/core/module/lib/go-crypto"
+	"github.com/DelosIsland/core/module/lib/go-crypto/bcrypt"
+)
+
+const (
+	KeyTypeEd25519   = "ed25519"
+	KeyTypeSecp256k1 = "secp256k1"
+
+	armorBlockType = "ANN PRIVATE KEY"
+	fileExt        = ".key"
+	saltLen        = 16 // required by bcrypt
+	bcryptCost     = 12
+)
+
+var (
+	ErrKeyExists      = errors.New("key already exists")
+	ErrKeyNotFound    = errors.New("key not found")
+	ErrWrongPass      = errors.New("wrong passphrase")
+	ErrInvalidName    = errors.New("invalid key name")
+	ErrUnknownKeyType = errors.New("unknown key type")
+)
+
+// KeyInfo is the public part of a stored key, readable without the passphrase
+type KeyInfo struct {
+	Name   string        `json:"name"`
+	Type   string        `json:"type"`
+	PubKey crypto.PubKey `json:"pub_key"`
+}
+
+// KeyStore stores one armored file per key in its directory.
+// The private key is encrypted with Sha256(Bcrypt(passphrase)), the headers hold the public info.
+type KeyStore struct {
+	dir string
+}
+
+func NewKeyStore(dir string) *KeyStore {
+	return &KeyStore{dir: dir}
+}
+
+func (ks *KeyStore) Dir() string {
+	return ks.dir
+}
+
+func (ks *KeyStore) path(name string) string {
+	return path.Join(ks.dir, name+fileExt)
+}
+
+func checkName(name string) error {
+	if name == "" || strings.ContainsAny(name, `/\. `) {
+		return ErrInvalidName
+	}
+	return nil
+}
+
+func keyType(privKey crypto.PrivKey) (string, error) {
+	switch privKey.(type) {
+	case crypto.PrivKeyEd25519:
+		return KeyTypeEd25519, nil
+	case crypto.PrivKeySecp256k1:
+		return KeyTypeSecp256k1, nil
+	}
+	return "", ErrUnknownKeyType
+}
+
+// GenPrivKey makes a new key of the given type
+func GenPrivKey(typ string) (crypto.PrivKey, error) {
+	switch typ {
+	case KeyTypeEd25519:
+		return crypto.GenPrivKeyEd25519(), nil
+	case KeyTypeSecp256k1:
+		return crypto.GenPrivKeySecp256k1(), nil
+	}
+	return nil, ErrUnknownKeyType
+}
+
+func secret(salt []byte, passphrase string) ([]byte, error) {
+	hash, err := bcrypt.GenerateFromPassword(salt, []byte(passphrase), bcryptCost)
+	if err != nil {
+		return nil, err
+	}
+	return crypto.Sha256(hash), nil
+}
+
+// Create generates a key of type typ and stores it under name
+func (ks *KeyStore) Create(name, passphrase, typ string) (KeyInfo, error) {
+	privKey, err := GenPrivKey(typ)
+	if err != nil {
+		return KeyInfo{}, err
+	}
+	return ks.ImportPrivKey(name, passphrase, privKey)
+}
+
+// ImportPrivKey stores privKey under name, encrypted with passphrase
+func (ks *KeyStore) ImportPrivKey(name, passphrase string, privKey crypto.PrivKey) (KeyInfo, error) {
+	typ, err := keyType(privKey)
+	if err != nil {
+		return KeyInfo{}, err
+	}
+	salt := crypto.CRandBytes(saltLen)
+	sec, err := secret(salt, passphrase)
+	if err != nil {
+		return KeyInfo{}, err
+	}
+	headers := map[string]string{
+		"name":   name,
+		"type":   typ,
+		"pubkey": hex.EncodeToString(privKey.PubKey().Bytes()),
+		"kdf":    "bcrypt",
+		"salt":   hex.EncodeToString(salt),
+	}
+	armor := crypto.EncodeArmor(armorBlockType, headers, crypto.EncryptSymmetric(privKey.Bytes(), sec))
+	if err := ks.write(name, armor); err != nil {
+		return KeyInfo{}, err
+	}
+	return KeyInfo{Name: name, Type: typ, PubKey: privKey.PubKey()}, nil
+}
+
+// Import stores a key exported from another keystore under name, it keeps its passphrase
+func (ks *KeyStore) Import(name, armor string) (KeyInfo, error) {
+	info, _, _, err := parseArmor(armor)
+	if err != nil {
+		return KeyInfo{}, err
+	}
+	// the name header follows the file
+	blockType, headers, data, _ := crypto.DecodeArmor(armor)
+	headers["name"] = name
+	if err := ks.write(name, crypto.EncodeArmor(blockType, headers, data)); err != nil {
+		return KeyInfo{}, err
+	}
+	info.Name = name
+	return info, nil
+}
+
+// Export returns the armored, still encrypted, key stored under name
+func (ks *KeyStore) Export(name string) (string, error) {
+	return ks.read(name)
+}
+
+// Unlock decrypts the key stored under name
+func (ks *KeyStore) Unlock(name, passphrase string) (crypto.PrivKey, error) {
+	armor, err := ks.read(name)
+	if err != nil {
+		return nil, err
+	}
+	info, salt, ciphertext, err := parseArmor(armor)
+	if err != nil {
+		return nil, err
+	}
+	sec, err := secret(salt, passphrase)
+	if err != nil {
+		return nil, err
+	}
+	plain, err := crypto.DecryptSymmetric(ciphertext, sec)
+	if err != nil {
+		return nil, ErrWrongPass
+	}
+	privKey, err := crypto.PrivKeyFromBytes(plain)
+	if err != nil {
+		return nil, err
+	}
+	if !privKey.PubKey().Equals(info.PubKey) {
+		return nil, fmt.Errorf("key %s doesn't match its pubkey", name)
+	}
+	return privKey, nil
+}
+
+// Sign signs msg with the key stored under name
+func (ks *KeyStore) Sign(name, passphrase string, msg []byte) (crypto.Signature, error) {
+	privKey, err := ks.Unlock(name, passphrase)
+	if err != nil {
+		return nil, err
+	}
+	return privKey.Sign(msg), nil
+}
+
+// Delete removes the key stored under name, the passphrase proves it is not removed by mistake
+func (ks *KeyStore) Delete(name, passphrase string) error {
+	if _, err := ks.Unlock(name, passphrase); err != nil {
+		return err
+	}
+	return os.Remove(ks.path(name))
+}
+
+// Get returns the public info of the key stored under name
+func (ks *KeyStore) Get(name string) (KeyInfo, error) {
+	armor, err := ks.read(name)
+	if err != nil {
+		return KeyInfo{}, err
+	}
+	info, _, _, err := parseArmor(armor)
+	info.Name = name
+	return info, err
+}
+
+// List returns the public info of every key, sorted by name
+func (ks *KeyStore) List() ([]KeyInfo, error) {
+	files, err := ioutil.ReadDir(ks.dir)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	var infos []KeyInfo
+	for _, f := range files {
+		if f.IsDir() || !strings.HasSuffix(f.Name(), fileExt) {
+			continue
+		}
+		info, err := ks.Get(strings.TrimSuffix(f.Name(), fileExt))
+		if err != nil {
+			return nil, err
+		}
+		infos = append(infos, info)
+	}
+	sort.Slice(infos, func(i, j int) bool { return infos[i].Name < infos[j].Name })
+	return infos, nil
+}
+
+func (ks *KeyStore) read(name string) (string, error) {
+	if err := checkName(name); err != nil {
+		return "", err
+	}
+	buf, err := ioutil.ReadFile(ks.path(name))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return "", ErrKeyNotFound
+		}
+		return "", err
+	}
+	return string(buf), nil
+}
+
+// write never overwrites a key
+func (ks *KeyStore) write(name, armor string) error {
+	if err := checkName(name); err != nil {
+		return err
+	}
+	if FileExists(ks.path(name)) {
+		return ErrKeyExists
+	}
+	if err := EnsureDir(ks.dir, 0700); err != nil {
+		return err
+	}
+	return WriteFileAtomic(ks.path(name), []byte(armor), 0600)
+}
+
+func parseArmor(armor string) (info KeyInfo, salt, ciphertext []byte, err error) {
+	blockType, headers, data, err := crypto.DecodeArmor(armor)
+	if err != nil {
+		return info, nil, nil, err
+	}
+	if blockType != armorBlockType {
+		return info, nil, nil, fmt.Errorf("unexpected armor block %s", blockType)
+	}
+	if headers["kdf"] != "bcrypt" {
+		return info, nil, nil, fmt.Errorf("unknown kdf %s", headers["kdf"])
+	}
+	if salt, err = hex.DecodeString(headers["salt"]); err != nil || len(salt) != saltLen {
+		return info, nil, nil, fmt.Errorf("invalid salt")
+	}
+	pkBytes, err := hex.DecodeString(headers["pubkey"])
+	if err != nil {
+		return info, nil, nil, err
+	}
+	if info.PubKey, err = crypto.PubKeyFromBytes(pkBytes); err != nil {
+		return info, nil, nil, err
+	}
+	info.Name, info.Type = headers["name"], headers["type"]
+	return info, salt, data, nil
+}
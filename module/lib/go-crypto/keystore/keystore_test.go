@@ -0,0 +1,87 @@
+package keystore
+
+import (
+	"io/ioutil"
+	"os"
+	"testing"
+)
+
+func newTestKeyStore(t *testing.T) (*KeyStore, func()) {
+	dir, err := ioutil.TempDir("", "keystore")
+	if err != nil {
+		t.Fatal(err)
+	}
+	return NewKeyStore(dir), func() { os.RemoveAll(dir) }
+}
+
+func TestKeyStoreUnlock(t *testing.T) {
+	ks, cleanup := newTestKeyStore(t)
+	defer cleanup()
+
+	for _, typ := range []string{KeyTypeEd25519, KeyTypeSecp256k1} {
+		info, err := ks.Create(typ, "secret", typ)
+		if err != nil {
+			t.Fatalf("Expected %s key to be created, got %v", typ, err)
+		}
+		privKey, err := ks.Unlock(typ, "secret")
+		if err != nil {
+			t.Fatalf("Expected %s key to unlock, got %v", typ, err)
+		}
+		if !privKey.PubKey().Equals(info.PubKey) {
+			t.Errorf("Expected unlocked %s key to match its info", typ)
+		}
+		sig, err := ks.Sign(typ, "secret", []byte("msg"))
+		if err != nil || !info.PubKey.VerifyBytes([]byte("msg"), sig) {
+			t.Errorf("Expected a valid %s signature, got %v", typ, err)
+		}
+	}
+
+	if _, err := ks.Unlock(KeyTypeEd25519, "wrong"); err != ErrWrongPass {
+		t.Errorf("Expected wrong passphrase, got %v", err)
+	}
+	if _, err := ks.Create(KeyTypeEd25519, "secret", KeyTypeEd25519); err != ErrKeyExists {
+		t.Errorf("Expected existing key to be kept, got %v", err)
+	}
+	if _, err := ks.Create("../escape", "secret", KeyTypeEd25519); err != ErrInvalidName {
+		t.Errorf("Expected invalid name, got %v", err)
+	}
+	if infos, _ := ks.List(); len(infos) != 2 || infos[0].Name != KeyTypeEd25519 {
+		t.Errorf("Expected 2 sorted keys, got %v", infos)
+	}
+}
+
+func TestKeyStoreExportImport(t *testing.T) {
+	ks, cleanup := newTestKeyStore(t)
+	defer cleanup()
+	other, cleanupOther := newTestKeyStore(t)
+	defer cleanupOther()
+
+	info, err := ks.Create("alice", "secret", KeyTypeEd25519)
+	if err != nil {
+		t.Fatal(err)
+	}
+	armor, err := ks.Export("alice")
+	if err != nil {
+		t.Fatal(err)
+	}
+	imported, err := other.Import("bob", armor)
+	if err != nil {
+		t.Fatalf("Expected import to succeed, got %v", err)
+	}
+	if imported.Name != "bob" || !imported.PubKey.Equals(info.PubKey) {
+		t.Errorf("Unexpected imported key %+v", imported)
+	}
+	if _, err := other.Unlock("bob", "secret"); err != nil {
+		t.Errorf("Expected imported key to keep its passphrase, got %v", err)
+	}
+
+	if err := ks.Delete("alice", "wrong"); err != ErrWrongPass {
+		t.Errorf("Expected delete to need the passphrase, got %v", err)
+	}
+	if err := ks.Delete("alice", "secret"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := ks.Get("alice"); err != ErrKeyNotFound {
+		t.Errorf("Expected deleted key to be gone, got %v", err)
+	}
+}
@@ -2,6 +2,7 @@ package db
 
 import (
 	"fmt"
+	"sort"
 	"sync"
 )
 
@@ -64,9 +65,17 @@ func (db *MemDB) Print() {
 		fmt.Printf("[%X]:\t[%X]\n", []byte(key), value)
 	}
 }
+// Iterator goes through a snapshot of the db, in the order of the keys
 func (db *MemDB) Iterator() Iterator {
-	//todo
-	return nil
+	db.mtx.Lock()
+	defer db.mtx.Unlock()
+	iter := &memDBIterator{index: -1, db: make(map[string][]byte, len(db.db))}
+	for key, value := range db.db {
+		iter.keys = append(iter.keys, key)
+		iter.db[key] = value
+	}
+	sort.Strings(iter.keys)
+	return iter
 }
 
 func (db *MemDB) NewBatch() Batch {
@@ -114,3 +123,24 @@ func (mBatch *memDBBatch) Write() {
 	}
 
 }
+
+//--------------------------------------------------------------------------------
+
+type memDBIterator struct {
+	keys  []string
+	db    map[string][]byte
+	index int
+}
+
+func (it *memDBIterator) Next() bool {
+	it.index++
+	return it.index < len(it.keys)
+}
+
+func (it *memDBIterator) Key() []byte {
+	return []byte(it.keys[it.index])
+}
+
+func (it *memDBIterator) Value() []byte {
+	return it.db[it.keys[it.index]]
+}
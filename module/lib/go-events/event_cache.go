@@ -15,7 +15,7 @@ type EventCache struct {
 func NewEventCache(evsw Fireable) *EventCache {
 	return &EventCache{
 		evsw:   evsw,
-		events: make([]eventInfo, eventsBufferSize),
+		events: make([]eventInfo, 0, eventsBufferSize),
 	}
 }
 
@@ -37,5 +37,5 @@ func (evc *EventCache) Flush() {
 	for _, ei := range evc.events {
 		evc.evsw.FireEvent(ei.event, ei.data)
 	}
-	evc.events = make([]eventInfo, eventsBufferSize)
+	evc.events = make([]eventInfo, 0, eventsBufferSize)
 }
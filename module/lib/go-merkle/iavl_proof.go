@@ -153,3 +153,93 @@ func (t *IAVLTree) ConstructProof(key []byte) *IAVLProof {
 		return nil
 	}
 }
+
+// IAVLAbsenceProof proves that a key is not in a tree with the proofs of
+// the leaves around it, which have to be next to each other.
+// Left is nil when the key is before the first leaf, Right when it is after the last.
+type IAVLAbsenceProof struct {
+	KeyBytes []byte
+	Left     *IAVLProof
+	Right    *IAVLProof
+}
+
+func (proof *IAVLAbsenceProof) Key() []byte {
+	return proof.KeyBytes
+}
+
+func (proof *IAVLAbsenceProof) Root() []byte {
+	if proof.Left != nil {
+		return proof.Left.RootHash
+	}
+	if proof.Right != nil {
+		return proof.Right.RootHash
+	}
+	return nil
+}
+
+func (proof *IAVLAbsenceProof) Valid() bool {
+	left, right := proof.Left, proof.Right
+	if left == nil && right == nil {
+		return false
+	}
+	if left != nil && (!left.Valid() || bytes.Compare(left.Key(), proof.KeyBytes) >= 0) {
+		return false
+	}
+	if right != nil && (!right.Valid() || bytes.Compare(right.Key(), proof.KeyBytes) <= 0) {
+		return false
+	}
+	switch {
+	case left == nil:
+		index, _ := right.index()
+		return index == 0
+	case right == nil:
+		index, size := left.index()
+		return index == size-1
+	default:
+		leftIndex, _ := left.index()
+		rightIndex, _ := right.index()
+		return bytes.Equal(left.RootHash, right.RootHash) && rightIndex == leftIndex+1
+	}
+}
+
+// index returns the position of the proven leaf and the number of leaves of the tree,
+// the sizes are part of the hashes so a valid proof can't lie about them
+func (proof *IAVLProof) index() (index, size int) {
+	size = 1
+	for _, branch := range proof.InnerNodes {
+		if len(branch.Left) != 0 {
+			index += branch.Size - size
+		}
+		size = branch.Size
+	}
+	return index, size
+}
+
+// LoadAbsenceProof will deserialize a IAVLAbsenceProof from bytes
+func LoadAbsenceProof(data []byte) (*IAVLAbsenceProof, error) {
+	n, err := int(0), error(nil)
+	proof := wire.ReadBinary(&IAVLAbsenceProof{}, bytes.NewBuffer(data), proofLimit, &n, &err).(*IAVLAbsenceProof)
+	return proof, err
+}
+
+// Returns nil if key is in tree or if the tree is empty.
+func (t *IAVLTree) ConstructAbsenceProof(key []byte) *IAVLAbsenceProof {
+	if t.root == nil {
+		return nil
+	}
+	// the index of a missing key is the number of keys before it
+	index, _, exists := t.Get(key)
+	if exists {
+		return nil
+	}
+	proof := &IAVLAbsenceProof{KeyBytes: key}
+	if index > 0 {
+		leftKey, _ := t.GetByIndex(index - 1)
+		proof.Left = t.ConstructProof(leftKey)
+	}
+	if index < t.Size() {
+		rightKey, _ := t.GetByIndex(index)
+		proof.Right = t.ConstructProof(rightKey)
+	}
+	return proof
+}
@@ -574,6 +574,48 @@ func TestIAVLTreeProof(t *testing.T) {
 	}
 }
 
+func TestIAVLTreeAbsenceProof(t *testing.T) {
+	db := db.NewMemDB()
+	var tree *IAVLTree = NewIAVLTree(100, db)
+
+	// nothing to prove against an empty tree
+	_, exists := tree.AbsenceProof([]byte("foo"))
+	assert.False(t, exists)
+
+	for i := 0; i < 100; i++ {
+		tree.Set([]byte(fmt.Sprintf("k%03d", 2*i)), []byte(randstr(20)))
+	}
+	tree.Save()
+	root := tree.Hash()
+
+	// a key in the tree can't be proven absent
+	_, exists = tree.AbsenceProof([]byte("k010"))
+	assert.False(t, exists)
+
+	// between two leaves, before the first one and after the last one
+	for _, key := range []string{"k011", "a", "z"} {
+		proofBytes, exists := tree.AbsenceProof([]byte(key))
+		if assert.True(t, exists, key) {
+			proof, err := LoadAbsenceProof(proofBytes)
+			require.Nil(t, err, "Failed to read IAVLAbsenceProof from bytes: %v", err)
+			assert.Equal(t, []byte(key), proof.Key())
+			assert.Equal(t, root, proof.Root())
+			assert.True(t, proof.Valid(), key)
+		}
+	}
+
+	// leaves that aren't neighbours prove nothing
+	proof := tree.ConstructAbsenceProof([]byte("k011"))
+	proof.Right = tree.ConstructProof([]byte("k014"))
+	assert.False(t, proof.Valid())
+	proof = tree.ConstructAbsenceProof([]byte("z"))
+	proof.Left = tree.ConstructProof([]byte("k010"))
+	assert.False(t, proof.Valid())
+	proof = tree.ConstructAbsenceProof([]byte("k011"))
+	proof.KeyBytes = []byte("k013")
+	assert.False(t, proof.Valid())
+}
+
 func BenchmarkImmutableAvlTreeCLevelDB(b *testing.B) {
 	b.StopTimer()
 
@@ -92,6 +92,14 @@ func (t *IAVLTree) Proof(key []byte) ([]byte, bool) {
 	return proofBytes, true
 }
 
+func (t *IAVLTree) AbsenceProof(key []byte) ([]byte, bool) {
+	proof := t.ConstructAbsenceProof(key)
+	if proof == nil {
+		return nil, false
+	}
+	return wire.BinaryBytes(proof), true
+}
+
 func (t *IAVLTree) Set(key []byte, value []byte) (updated bool) {
 	if t.root == nil {
 		t.root = NewIAVLNode(key, value)
@@ -125,6 +133,17 @@ func (t *IAVLTree) Save() []byte {
 	return t.root.hash
 }
 
+// KeepVersions keeps the nodes of the n trees saved before the last one
+// so that they can still be loaded, 1 by default.
+func (t *IAVLTree) KeepVersions(n int) {
+	if t.ndb == nil || n < 1 {
+		return
+	}
+	t.ndb.mtx.Lock()
+	t.ndb.keep = n
+	t.ndb.mtx.Unlock()
+}
+
 // Sets the root node by reading from db.
 // If the hash is empty, then sets root to nil.
 func (t *IAVLTree) Load(hash []byte) {
@@ -203,18 +222,19 @@ type nodeDB struct {
 	db          dbm.DB
 	batch       dbm.Batch
 	orphans     map[string]struct{}
-	orphansPrev map[string]struct{}
+	orphansPrev []map[string]struct{} // of the last commits, the oldest first
+	keep        int
 }
 
 func newNodeDB(cacheSize int, db dbm.DB) *nodeDB {
 	ndb := &nodeDB{
-		cache:       make(map[string]*list.Element),
-		cacheSize:   cacheSize,
-		cacheQueue:  list.New(),
-		db:          db,
-		batch:       db.NewBatch(),
-		orphans:     make(map[string]struct{}),
-		orphansPrev: make(map[string]struct{}),
+		cache:      make(map[string]*list.Element),
+		cacheSize:  cacheSize,
+		cacheQueue: list.New(),
+		db:         db,
+		batch:      db.NewBatch(),
+		orphans:    make(map[string]struct{}),
+		keep:       1,
 	}
 	return ndb
 }
@@ -270,7 +290,9 @@ func (ndb *nodeDB) SaveNode(t *IAVLTree, node *IAVLNode) {
 	// Re-creating the orphan,
 	// Do not garbage collect.
 	delete(ndb.orphans, string(node.hash))
-	delete(ndb.orphansPrev, string(node.hash))
+	for _, orphans := range ndb.orphansPrev {
+		delete(orphans, string(node.hash))
+	}
 }
 
 func (ndb *nodeDB) RemoveNode(t *IAVLTree, node *IAVLNode) {
@@ -304,15 +326,18 @@ func (ndb *nodeDB) cacheNode(node *IAVLNode) {
 func (ndb *nodeDB) Commit() {
 	ndb.mtx.Lock()
 	defer ndb.mtx.Unlock()
-	// Delete orphans from previous block
-	for orphanHashStr, _ := range ndb.orphansPrev {
-		ndb.batch.Delete([]byte(orphanHashStr))
+	// Shift orphans
+	ndb.orphansPrev = append(ndb.orphansPrev, ndb.orphans)
+	ndb.orphans = make(map[string]struct{})
+	// Delete orphans from the blocks before the kept ones
+	for len(ndb.orphansPrev) > ndb.keep {
+		for orphanHashStr, _ := range ndb.orphansPrev[0] {
+			ndb.batch.Delete([]byte(orphanHashStr))
+		}
+		ndb.orphansPrev = ndb.orphansPrev[1:]
 	}
 	// Write saves & orphan deletes
 	ndb.batch.Write()
 	ndb.db.SetSync(nil, nil)
 	ndb.batch = ndb.db.NewBatch()
-	// Shift orphans
-	ndb.orphansPrev = ndb.orphans
-	ndb.orphans = make(map[string]struct{})
 }
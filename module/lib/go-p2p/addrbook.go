@@ -304,6 +304,19 @@ func (a *AddrBook) GetSelection() []*NetAddress {
 	return allAddr[:numAddresses]
 }
 
+// ListOfKnownAddresses returns a copy of every known address along with its dial history.
+func (a *AddrBook) ListOfKnownAddresses() []*knownAddress {
+	a.mtx.Lock()
+	defer a.mtx.Unlock()
+
+	addrs := make([]*knownAddress, 0, len(a.addrLookup))
+	for _, ka := range a.addrLookup {
+		kaCopy := *ka
+		addrs = append(addrs, &kaCopy)
+	}
+	return addrs
+}
+
 /* Loading & Saving */
 
 type addrBookJSON struct {
@@ -802,6 +815,16 @@ func (ka *knownAddress) removeBucketRef(bucketIdx int) int {
    All addresses that meet these criteria are assumed to be worthless and not
    worth keeping hold of.
 */
+// isDead is true once one more failed dial makes the address not worth keeping,
+// it is used by the seed crawler which checks every address regularly.
+func (ka *knownAddress) isDead() bool {
+	if ka.LastSuccess.IsZero() {
+		return ka.Attempts+1 >= numRetries
+	}
+	return ka.Attempts+1 >= maxFailures &&
+		ka.LastSuccess.Before(time.Now().Add(-1*minBadDays*time.Hour*24))
+}
+
 func (ka *knownAddress) isBad() bool {
 	// Has been attempted in the last minute --> good
 	if ka.LastAttempt.Before(time.Now().Add(-1 * time.Minute)) {
@@ -5,6 +5,8 @@ import (
 	"io/ioutil"
 	"math/rand"
 	"testing"
+
+	"go.uber.org/zap"
 )
 
 const addrBookStrict = true
@@ -27,10 +29,10 @@ func TestEmpty(t *testing.T) {
 	// t.Logf("New tempfile name: %v", fname)
 
 	// Save an empty book & load it
-	book := NewAddrBook(fname, addrBookStrict)
+	book := NewAddrBook(zap.NewNop(), fname, addrBookStrict)
 	book.saveToFile(fname)
 
-	book = NewAddrBook(fname, addrBookStrict)
+	book = NewAddrBook(zap.NewNop(), fname, addrBookStrict)
 	book.loadFromFile(fname)
 
 	if book.Size() != 0 {
@@ -76,7 +78,7 @@ func TestSaveAddresses(t *testing.T) {
 	}
 
 	// Create the book & populate & save
-	book := NewAddrBook(fname, addrBookStrict)
+	book := NewAddrBook(zap.NewNop(), fname, addrBookStrict)
 	for _, addrSrc := range randAddrs {
 		book.AddAddress(addrSrc.addr, addrSrc.src)
 	}
@@ -86,7 +88,7 @@ func TestSaveAddresses(t *testing.T) {
 	book.saveToFile(fname)
 
 	// Reload the book
-	book = NewAddrBook(fname, addrBookStrict)
+	book = NewAddrBook(zap.NewNop(), fname, addrBookStrict)
 	book.loadFromFile(fname)
 
 	// Test ...
@@ -130,7 +132,7 @@ func TestPromoteToOld(t *testing.T) {
 	}
 
 	// Create the book & populate & save
-	book := NewAddrBook(fname, addrBookStrict)
+	book := NewAddrBook(zap.NewNop(), fname, addrBookStrict)
 	for _, addrSrc := range randAddrs {
 		book.AddAddress(addrSrc.addr, addrSrc.src)
 	}
@@ -147,7 +149,7 @@ func TestPromoteToOld(t *testing.T) {
 	book.saveToFile(fname)
 
 	// Reload the book
-	book = NewAddrBook(fname, addrBookStrict)
+	book = NewAddrBook(zap.NewNop(), fname, addrBookStrict)
 	book.loadFromFile(fname)
 
 	// Test ...
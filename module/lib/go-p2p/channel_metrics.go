@@ -0,0 +1,101 @@
+package p2p
+
+import (
+	"sort"
+	"sync"
+)
+
+// ChannelMetrics sums the traffic of one channel over all peers,
+// including the peers which are already disconnected.
+type ChannelMetrics struct {
+	ID                byte   `json:"id"`
+	Reactor           string `json:"reactor"`
+	SentBytes         int64  `json:"sent_bytes"`
+	RecvBytes         int64  `json:"recv_bytes"`
+	SentMsgs          int64  `json:"sent_msgs"`
+	RecvMsgs          int64  `json:"recv_msgs"`
+	DroppedMsgs       int64  `json:"dropped_msgs"`
+	SendQueueSize     int    `json:"send_queue_size"`     // queued right now on live peers
+	SendQueueCapacity int    `json:"send_queue_capacity"` // per peer
+}
+
+func (m *ChannelMetrics) add(status ChannelStatus) {
+	m.SentBytes += status.SentBytes
+	m.RecvBytes += status.RecvBytes
+	m.SentMsgs += status.SentMsgs
+	m.RecvMsgs += status.RecvMsgs
+	m.DroppedMsgs += status.DroppedMsgs
+}
+
+// channelTotals keeps the counters of disconnected peers. Goroutine-safe.
+type channelTotals struct {
+	mtx      sync.Mutex
+	channels map[byte]ChannelMetrics
+}
+
+func newChannelTotals() *channelTotals {
+	return &channelTotals{channels: make(map[byte]ChannelMetrics)}
+}
+
+func (t *channelTotals) retire(status ConnectionStatus) {
+	t.mtx.Lock()
+	defer t.mtx.Unlock()
+
+	for _, chStatus := range status.Channels {
+		m := t.channels[chStatus.ID]
+		m.add(chStatus)
+		t.channels[chStatus.ID] = m
+	}
+}
+
+func (t *channelTotals) get(chID byte) ChannelMetrics {
+	t.mtx.Lock()
+	defer t.mtx.Unlock()
+
+	return t.channels[chID]
+}
+
+// ChannelMetrics returns the traffic of every channel since the switch started,
+// sorted by channel id.
+func (sw *Switch) ChannelMetrics() []ChannelMetrics {
+	metrics := make(map[byte]*ChannelMetrics, len(sw.chDescs))
+	for _, chDesc := range sw.chDescs {
+		m := sw.channelTotals.get(chDesc.ID)
+		m.ID = chDesc.ID
+		m.Reactor = sw.reactorName(chDesc.ID)
+		m.SendQueueSize = 0
+		m.SendQueueCapacity = chDesc.SendQueueCapacity
+		metrics[chDesc.ID] = &m
+	}
+	for _, peer := range sw.peers.List() {
+		for _, chStatus := range peer.Connection().Status().Channels {
+			if m, ok := metrics[chStatus.ID]; ok {
+				m.add(chStatus)
+				m.SendQueueSize += chStatus.SendQueueSize
+			}
+		}
+	}
+
+	res := make([]ChannelMetrics, 0, len(metrics))
+	for _, m := range metrics {
+		res = append(res, *m)
+	}
+	sort.Slice(res, func(i, j int) bool { return res[i].ID < res[j].ID })
+	return res
+}
+
+func (sw *Switch) reactorName(chID byte) string {
+	for name, reactor := range sw.reactors {
+		if reactor == sw.reactorsByCh[chID] {
+			return name
+		}
+	}
+	return ""
+}
+
+// retirePeerMetrics folds the counters of a removed peer into the switch totals.
+func (sw *Switch) retirePeerMetrics(peer *Peer) {
+	if conn := peer.Connection(); conn != nil {
+		sw.channelTotals.retire(conn.Status())
+	}
+}
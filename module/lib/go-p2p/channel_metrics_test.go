@@ -0,0 +1,62 @@
+package p2p
+
+import (
+	"testing"
+	"time"
+)
+
+func TestChannelTotalsRetire(t *testing.T) {
+	totals := newChannelTotals()
+	status := ConnectionStatus{Channels: []ChannelStatus{
+		{ID: 0x20, SentBytes: 100, RecvBytes: 50, SentMsgs: 2, RecvMsgs: 1, DroppedMsgs: 3},
+		{ID: 0x30, SentBytes: 7},
+	}}
+	totals.retire(status)
+	totals.retire(status)
+
+	m := totals.get(0x20)
+	if m.SentBytes != 200 || m.RecvBytes != 100 || m.SentMsgs != 4 || m.RecvMsgs != 2 || m.DroppedMsgs != 6 {
+		t.Errorf("Unexpected totals for channel 0x20: %+v", m)
+	}
+	if m := totals.get(0x30); m.SentBytes != 14 {
+		t.Errorf("Expected 14 bytes sent on channel 0x30, got %v", m.SentBytes)
+	}
+	if m := totals.get(0x40); m.SentBytes != 0 {
+		t.Errorf("Expected unknown channel to be empty, got %+v", m)
+	}
+}
+
+func TestSwitchRetirePeerMetricsOnce(t *testing.T) {
+	s1, s2 := makeSwitchPair(t, initSwitchFunc)
+	defer s1.Stop()
+	defer s2.Stop()
+
+	s1.Broadcast(byte(0x00), "channel zero")
+	peer := s1.Peers().List()[0]
+	deadline := time.Now().Add(5 * time.Second)
+	for peerSentBytes(peer, 0x00) == 0 && time.Now().Before(deadline) {
+		time.Sleep(20 * time.Millisecond)
+	}
+
+	// a peer can be stopped for an error by several routines
+	s1.StopPeerForError(peer, "first error")
+	s1.StopPeerForError(peer, "second error")
+	sent := peerSentBytes(peer, 0x00)
+	if sent == 0 {
+		t.Fatal("Expected the message to be sent on channel 0x00")
+	}
+	for _, m := range s1.ChannelMetrics() {
+		if m.ID == 0x00 && m.SentBytes != sent {
+			t.Errorf("Expected the %d bytes of the stopped peer to be counted once, got %d", sent, m.SentBytes)
+		}
+	}
+}
+
+func peerSentBytes(peer *Peer, chID byte) int64 {
+	for _, ch := range peer.Connection().Status().Channels {
+		if ch.ID == chID {
+			return ch.SentBytes
+		}
+	}
+	return 0
+}
@@ -11,6 +11,15 @@ const (
 	configKeyMaxNumPeers             = "max_num_peers"
 	configKeyAuthEnc                 = "authenticated_encryption"
 
+	// Persistent peers config keys
+	configKeyReconnectBaseSeconds = "reconnect_base_seconds"
+	configKeyReconnectMaxSeconds  = "reconnect_max_seconds"
+
+	// Peer scoring config keys
+	configKeyBanThreshold   = "ban_threshold"
+	configKeyBanBaseSeconds = "ban_base_seconds"
+	configKeyBanMaxSeconds  = "ban_max_seconds"
+
 	// MConnection config keys
 	configKeySendRate = "send_rate"
 	configKeyRecvRate = "recv_rate"
@@ -32,6 +41,15 @@ func setConfigDefaults(config cfg.Config) {
 	config.SetDefault(configKeyMaxNumPeers, 50)
 	config.SetDefault(configKeyAuthEnc, true)
 
+	// Persistent peers default config
+	config.SetDefault(configKeyReconnectBaseSeconds, 1)
+	config.SetDefault(configKeyReconnectMaxSeconds, 5*60)
+
+	// Peer scoring default config
+	config.SetDefault(configKeyBanThreshold, -100)
+	config.SetDefault(configKeyBanBaseSeconds, 60)
+	config.SetDefault(configKeyBanMaxSeconds, 24*60*60)
+
 	// MConnection default config
 	config.SetDefault(configKeySendRate, 5120000) // 5000KB/s
 	config.SetDefault(configKeyRecvRate, 5120000) // 5000KB/s
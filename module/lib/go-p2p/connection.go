@@ -487,6 +487,11 @@ type ChannelStatus struct {
 	SendQueueSize     int
 	Priority          int
 	RecentlySent      int64
+	SentBytes         int64 // total over the connection lifetime
+	RecvBytes         int64
+	SentMsgs          int64
+	RecvMsgs          int64
+	DroppedMsgs       int64 // messages which didn't fit in the send queue
 }
 
 func (c *MConnection) Status() ConnectionStatus {
@@ -498,9 +503,14 @@ func (c *MConnection) Status() ConnectionStatus {
 		status.Channels[i] = ChannelStatus{
 			ID:                channel.id,
 			SendQueueCapacity: cap(channel.sendQueue),
-			SendQueueSize:     int(channel.sendQueueSize), // TODO use atomic
+			SendQueueSize:     channel.loadSendQueueSize(),
 			Priority:          channel.priority,
 			RecentlySent:      channel.recentlySent,
+			SentBytes:         atomic.LoadInt64(&channel.sentBytes),
+			RecvBytes:         atomic.LoadInt64(&channel.recvBytes),
+			SentMsgs:          atomic.LoadInt64(&channel.sentMsgs),
+			RecvMsgs:          atomic.LoadInt64(&channel.recvMsgs),
+			DroppedMsgs:       atomic.LoadInt64(&channel.droppedMsgs),
 		}
 	}
 	return status
@@ -541,6 +551,13 @@ type Channel struct {
 	priority      int
 	recentlySent  int64 // exponential moving average
 
+	// lifetime counters, atomic.
+	sentBytes   int64
+	recvBytes   int64
+	sentMsgs    int64
+	recvMsgs    int64
+	droppedMsgs int64
+
 	slogger *zap.SugaredLogger
 }
 
@@ -567,6 +584,7 @@ func (ch *Channel) sendBytes(bytes []byte) bool {
 	select {
 	case <-timeout.C:
 		// timeout
+		atomic.AddInt64(&ch.droppedMsgs, 1)
 		return false
 	case ch.sendQueue <- bytes:
 		atomic.AddInt32(&ch.sendQueueSize, 1)
@@ -583,6 +601,7 @@ func (ch *Channel) trySendBytes(bytes []byte) bool {
 		atomic.AddInt32(&ch.sendQueueSize, 1)
 		return true
 	default:
+		atomic.AddInt64(&ch.droppedMsgs, 1)
 		return false
 	}
 }
@@ -621,6 +640,7 @@ func (ch *Channel) nextMsgPacket() msgPacket {
 		packet.EOF = byte(0x01)
 		ch.sending = nil
 		atomic.AddInt32(&ch.sendQueueSize, -1) // decrement sendQueueSize
+		atomic.AddInt64(&ch.sentMsgs, 1)
 	} else {
 		packet.EOF = byte(0x00)
 		ch.sending = ch.sending[MinInt(maxMsgPacketPayloadSize, len(ch.sending)):]
@@ -637,6 +657,7 @@ func (ch *Channel) writeMsgPacketTo(w io.Writer) (n int, err error) {
 	wire.WriteBinary(packet, w, &n, &err)
 	if err == nil {
 		ch.recentlySent += int64(n)
+		atomic.AddInt64(&ch.sentBytes, int64(n))
 	}
 	return
 }
@@ -649,7 +670,9 @@ func (ch *Channel) recvMsgPacket(packet msgPacket) ([]byte, error) {
 		return nil, wire.ErrBinaryReadOverflow
 	}
 	ch.recving = append(ch.recving, packet.Bytes...)
+	atomic.AddInt64(&ch.recvBytes, int64(len(packet.Bytes)))
 	if packet.EOF == byte(0x01) {
+		atomic.AddInt64(&ch.recvMsgs, 1)
 		msgBytes := ch.recving
 		// clear the slice without re-allocating.
 		// http://stackoverflow.com/questions/16971741/how-do-you-clear-a-slice-in-go
@@ -7,7 +7,7 @@ import (
 
 func TestListener(t *testing.T) {
 	// Create a listener
-	l := NewDefaultListener("tcp", ":8001", true)
+	l := NewDefaultListener(log, "tcp", ":8001", true)
 
 	// Dial the listener
 	lAddr := l.ExternalAddress()
@@ -37,26 +37,35 @@ func NewNetAddress(logger *zap.Logger, addr net.Addr) *NetAddress {
 
 // Also resolves the host if host is not an IP.
 func NewNetAddressString(addr string) *NetAddress {
-	host, portStr, err := net.SplitHostPort(addr)
+	na, err := ParseNetAddress(addr)
 	if err != nil {
 		PanicSanity(err)
 	}
+	return na
+}
+
+// ParseNetAddress is like NewNetAddressString but returns an error
+// instead of panicking, use it for addresses coming from users.
+func ParseNetAddress(addr string) (*NetAddress, error) {
+	host, portStr, err := net.SplitHostPort(addr)
+	if err != nil {
+		return nil, err
+	}
 	ip := net.ParseIP(host)
 	if ip == nil {
 		if len(host) > 0 {
 			ips, err := net.LookupIP(host)
 			if err != nil {
-				PanicSanity(err)
+				return nil, err
 			}
 			ip = ips[0]
 		}
 	}
 	port, err := strconv.ParseUint(portStr, 10, 16)
 	if err != nil {
-		PanicSanity(err)
+		return nil, err
 	}
-	na := NewNetAddressIPPort(ip, uint16(port))
-	return na
+	return NewNetAddressIPPort(ip, uint16(port)), nil
 }
 
 func NewNetAddressIPPort(ip net.IP, port uint16) *NetAddress {
@@ -0,0 +1,21 @@
+package p2p
+
+import (
+	"testing"
+)
+
+func TestParseNetAddress(t *testing.T) {
+	addr, err := ParseNetAddress("127.0.0.1:46656")
+	if err != nil {
+		t.Fatalf("Unexpected error %v", err)
+	}
+	if addr.String() != "127.0.0.1:46656" {
+		t.Errorf("Expected 127.0.0.1:46656, got %v", addr)
+	}
+
+	for _, bad := range []string{"127.0.0.1", "127.0.0.1:notaport", "127.0.0.1:70000"} {
+		if _, err := ParseNetAddress(bad); err == nil {
+			t.Errorf("Expected error parsing %v", bad)
+		}
+	}
+}
@@ -0,0 +1,229 @@
+package p2p
+
+import (
+	"sort"
+	"sync"
+	"time"
+)
+
+// PeerBehaviour is something a reactor observed a peer doing.
+// Bad behaviours lower the peer's score, good ones slowly raise it back.
+type PeerBehaviour int
+
+const (
+	PeerBehaviourGoodBlock PeerBehaviour = iota
+	PeerBehaviourGoodVote
+	PeerBehaviourBadMessage // message could not be decoded
+	PeerBehaviourBadBlock   // block failed verification
+	PeerBehaviourBadVote    // vote with invalid signature or unknown validator
+	PeerBehaviourTimeout    // peer did not answer a request in time
+)
+
+var peerBehaviourNames = map[PeerBehaviour]string{
+	PeerBehaviourGoodBlock:  "good_block",
+	PeerBehaviourGoodVote:   "good_vote",
+	PeerBehaviourBadMessage: "bad_message",
+	PeerBehaviourBadBlock:   "bad_block",
+	PeerBehaviourBadVote:    "bad_vote",
+	PeerBehaviourTimeout:    "timeout",
+}
+
+func (b PeerBehaviour) String() string {
+	if name, ok := peerBehaviourNames[b]; ok {
+		return name
+	}
+	return "unknown"
+}
+
+// score delta for each behaviour
+var peerBehaviourScores = map[PeerBehaviour]int{
+	PeerBehaviourGoodBlock:  1,
+	PeerBehaviourGoodVote:   1,
+	PeerBehaviourBadMessage: -20,
+	PeerBehaviourBadBlock:   -50,
+	PeerBehaviourBadVote:    -25,
+	PeerBehaviourTimeout:    -10,
+}
+
+const (
+	maxPeerScore = 100
+)
+
+// PeerScore is the public view of a tracked peer.
+type PeerScore struct {
+	Key         string    `json:"key"`
+	Score       int       `json:"score"`
+	NumBans     int       `json:"num_bans"`
+	BannedUntil time.Time `json:"banned_until"`
+	LastReason  string    `json:"last_reason"`
+}
+
+type peerRecord struct {
+	score       int
+	numBans     int
+	bannedUntil time.Time
+	lastReason  string
+}
+
+// PeerBehaviourTracker scores peers from the behaviours reported by reactors
+// and decides when a peer should be banned.
+// A banned peer is refused for banBase * 2^(numBans-1), capped at banMax.
+// The record of a peer is dropped when it disconnects, or once its ban is over.
+// Goroutine-safe.
+type PeerBehaviourTracker struct {
+	mtx   sync.Mutex
+	peers map[string]*peerRecord
+
+	banThreshold int
+	banBase      time.Duration
+	banMax       time.Duration
+
+	now func() time.Time // not time.Now so we can override with tests
+}
+
+func NewPeerBehaviourTracker(banThreshold int, banBase, banMax time.Duration) *PeerBehaviourTracker {
+	return &PeerBehaviourTracker{
+		peers:        make(map[string]*peerRecord),
+		banThreshold: banThreshold,
+		banBase:      banBase,
+		banMax:       banMax,
+		now:          time.Now,
+	}
+}
+
+// Report records the behaviour of the peer with the given key.
+// Returns true if the peer has just been banned.
+func (t *PeerBehaviourTracker) Report(peerKey string, b PeerBehaviour, reason string) bool {
+	t.mtx.Lock()
+	defer t.mtx.Unlock()
+
+	rec := t.peers[peerKey]
+	if rec == nil {
+		rec = &peerRecord{}
+		t.peers[peerKey] = rec
+	}
+	rec.score += peerBehaviourScores[b]
+	if rec.score > maxPeerScore {
+		rec.score = maxPeerScore
+	}
+	if peerBehaviourScores[b] < 0 {
+		rec.lastReason = b.String()
+		if reason != "" {
+			rec.lastReason += ": " + reason
+		}
+	}
+	if rec.score > t.banThreshold {
+		return false
+	}
+
+	rec.numBans++
+	rec.bannedUntil = t.now().Add(t.banDuration(rec.numBans))
+	rec.score = 0 // start over once the ban is lifted
+	return true
+}
+
+func (t *PeerBehaviourTracker) banDuration(numBans int) time.Duration {
+	d := t.banBase
+	for i := 1; i < numBans; i++ {
+		d *= 2
+		if d >= t.banMax {
+			return t.banMax
+		}
+	}
+	if d > t.banMax {
+		return t.banMax
+	}
+	return d
+}
+
+// IsBanned reports whether the peer is currently refused.
+func (t *PeerBehaviourTracker) IsBanned(peerKey string) bool {
+	t.mtx.Lock()
+	defer t.mtx.Unlock()
+
+	rec := t.peers[peerKey]
+	if rec != nil && t.banExpired(rec) {
+		delete(t.peers, peerKey)
+		return false
+	}
+	return rec != nil && t.now().Before(rec.bannedUntil)
+}
+
+// Forget drops the record of a peer which has disconnected,
+// a banned peer is remembered until its ban is over.
+func (t *PeerBehaviourTracker) Forget(peerKey string) {
+	t.mtx.Lock()
+	defer t.mtx.Unlock()
+
+	if rec := t.peers[peerKey]; rec != nil && !t.now().Before(rec.bannedUntil) {
+		delete(t.peers, peerKey)
+	}
+	t.pruneExpiredBans()
+}
+
+func (t *PeerBehaviourTracker) banExpired(rec *peerRecord) bool {
+	return !rec.bannedUntil.IsZero() && !t.now().Before(rec.bannedUntil)
+}
+
+// pruneExpiredBans drops the peers whose ban is over, they start afresh if they come back
+func (t *PeerBehaviourTracker) pruneExpiredBans() {
+	for key, rec := range t.peers {
+		if t.banExpired(rec) {
+			delete(t.peers, key)
+		}
+	}
+}
+
+// Score returns the current score of a peer, 0 for unknown peers.
+func (t *PeerBehaviourTracker) Score(peerKey string) int {
+	t.mtx.Lock()
+	defer t.mtx.Unlock()
+
+	if rec := t.peers[peerKey]; rec != nil {
+		return rec.score
+	}
+	return 0
+}
+
+// Unban lifts the ban on a peer and resets its score, the ban counter is kept.
+func (t *PeerBehaviourTracker) Unban(peerKey string) {
+	t.mtx.Lock()
+	defer t.mtx.Unlock()
+
+	if rec := t.peers[peerKey]; rec != nil {
+		rec.bannedUntil = time.Time{}
+		rec.score = 0
+	}
+}
+
+// Scores returns all tracked peers sorted by key.
+func (t *PeerBehaviourTracker) Scores() []PeerScore {
+	t.mtx.Lock()
+	defer t.mtx.Unlock()
+
+	t.pruneExpiredBans()
+	scores := make([]PeerScore, 0, len(t.peers))
+	for key, rec := range t.peers {
+		scores = append(scores, PeerScore{
+			Key:         key,
+			Score:       rec.score,
+			NumBans:     rec.numBans,
+			BannedUntil: rec.bannedUntil,
+			LastReason:  rec.lastReason,
+		})
+	}
+	sort.Slice(scores, func(i, j int) bool { return scores[i].Key < scores[j].Key })
+	return scores
+}
+
+// Banned returns the peers which are banned right now.
+func (t *PeerBehaviourTracker) Banned() []PeerScore {
+	now := t.now()
+	banned := make([]PeerScore, 0)
+	for _, s := range t.Scores() {
+		if now.Before(s.BannedUntil) {
+			banned = append(banned, s)
+		}
+	}
+	return banned
+}
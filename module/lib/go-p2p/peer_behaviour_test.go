@@ -0,0 +1,86 @@
+package p2p
+
+import (
+	"testing"
+	"time"
+)
+
+func TestPeerBehaviourBan(t *testing.T) {
+	now := time.Now()
+	tracker := NewPeerBehaviourTracker(-100, time.Minute, time.Hour)
+	tracker.now = func() time.Time { return now }
+
+	if tracker.Report("peer", PeerBehaviourBadBlock, "") {
+		t.Fatalf("Expected peer not to be banned after one bad block")
+	}
+	if tracker.Score("peer") != -50 {
+		t.Errorf("Expected score -50, got %v", tracker.Score("peer"))
+	}
+	if !tracker.Report("peer", PeerBehaviourBadBlock, "invalid commit") {
+		t.Fatalf("Expected peer to be banned after two bad blocks")
+	}
+	if !tracker.IsBanned("peer") {
+		t.Errorf("Expected peer to be banned")
+	}
+	if banned := tracker.Banned(); len(banned) != 1 || banned[0].LastReason != "bad_block: invalid commit" {
+		t.Errorf("Unexpected banned list %v", banned)
+	}
+
+	now = now.Add(time.Minute)
+	if tracker.IsBanned("peer") {
+		t.Errorf("Expected ban to expire after a minute")
+	}
+}
+
+func TestPeerBehaviourBackoff(t *testing.T) {
+	now := time.Now()
+	tracker := NewPeerBehaviourTracker(-100, time.Minute, 3*time.Minute)
+	tracker.now = func() time.Time { return now }
+
+	expected := []time.Duration{time.Minute, 2 * time.Minute, 3 * time.Minute, 3 * time.Minute}
+	for i, d := range expected {
+		tracker.Report("peer", PeerBehaviourBadBlock, "")
+		tracker.Report("peer", PeerBehaviourBadBlock, "")
+		until := tracker.Scores()[0].BannedUntil
+		if until.Sub(now) != d {
+			t.Errorf("Ban %d: expected %v, got %v", i+1, d, until.Sub(now))
+		}
+	}
+}
+
+func TestPeerBehaviourGoodScoreCapped(t *testing.T) {
+	tracker := NewPeerBehaviourTracker(-100, time.Minute, time.Hour)
+	for i := 0; i < 2*maxPeerScore; i++ {
+		tracker.Report("peer", PeerBehaviourGoodVote, "")
+	}
+	if tracker.Score("peer") != maxPeerScore {
+		t.Errorf("Expected score to be capped at %v, got %v", maxPeerScore, tracker.Score("peer"))
+	}
+	tracker.Report("peer", PeerBehaviourBadMessage, "")
+	if tracker.IsBanned("peer") {
+		t.Errorf("Expected well behaved peer to survive one bad message")
+	}
+}
+
+func TestPeerBehaviourForget(t *testing.T) {
+	now := time.Now()
+	tracker := NewPeerBehaviourTracker(-100, time.Minute, time.Hour)
+	tracker.now = func() time.Time { return now }
+
+	tracker.Report("gone", PeerBehaviourBadBlock, "")
+	tracker.Forget("gone")
+	if scores := tracker.Scores(); len(scores) != 0 {
+		t.Errorf("Expected a disconnected peer to be forgotten, got %v", scores)
+	}
+
+	tracker.Report("banned", PeerBehaviourBadBlock, "")
+	tracker.Report("banned", PeerBehaviourBadBlock, "")
+	tracker.Forget("banned")
+	if !tracker.IsBanned("banned") {
+		t.Fatal("Expected a banned peer to be remembered until its ban is over")
+	}
+	now = now.Add(time.Minute)
+	if scores := tracker.Scores(); len(scores) != 0 {
+		t.Errorf("Expected the peer to be forgotten once its ban is over, got %v", scores)
+	}
+}
@@ -81,12 +81,14 @@ func (ps *PeerSet) Get(peerKey string) *Peer {
 	return nil
 }
 
-func (ps *PeerSet) Remove(peer *Peer) {
+// Remove returns false if the peer isn't in the set,
+// like when it was removed already or replaced by a new connection with the same key
+func (ps *PeerSet) Remove(peer *Peer) bool {
 	ps.mtx.Lock()
 	defer ps.mtx.Unlock()
 	item := ps.lookup[peer.Key]
-	if item == nil {
-		return
+	if item == nil || item.peer != peer {
+		return false
 	}
 
 	index := item.index
@@ -98,7 +100,7 @@ func (ps *PeerSet) Remove(peer *Peer) {
 	if index == len(ps.list)-1 {
 		ps.list = newList
 		ps._lookUpMapDel(peer)
-		return
+		return true
 	}
 
 	// Move the last item from ps.list to "index" in list.
@@ -109,7 +111,7 @@ func (ps *PeerSet) Remove(peer *Peer) {
 	lastPeerItem.index = index
 	ps.list = newList
 	ps._lookUpMapDel(peer)
-
+	return true
 }
 
 func (ps *PeerSet) Size() int {
@@ -5,6 +5,7 @@ import (
 	"errors"
 	"fmt"
 	"math/rand"
+	"sort"
 	"time"
 
 	"go.uber.org/zap"
@@ -20,16 +21,30 @@ const (
 	ensurePeersPeriodSeconds = 30
 	minNumOutboundPeers      = 10
 	maxPexMessageSize        = 1048576 // 1MB
+
+	// seed mode
+	crawlPeerPeriodSeconds    = 30
+	minCrawlRedialPeriod      = 2 * time.Minute  // don't dial the same address more often
+	maxCrawlDialsPerRound     = 20               // addresses dialed every crawl round
+	seedDisconnectWaitPeriod  = 30 * time.Second // how long crawled peers stay connected
+	seedDisconnectAfterAnswer = 1 * time.Second  // time given to flush our answer
+	peerConnectedAtKey        = "pex.connected_at"
 )
 
 /*
 PEXReactor handles PEX (peer exchange) and ensures that an
 adequate number of peers are connected to the switch.
+
+In seed mode the reactor doesn't try to keep peers connected. It
+crawls the network instead: every address in the book is dialed
+from time to time to check it is alive and asked for more addresses.
+Peers asking us for addresses are answered and disconnected.
 */
 type PEXReactor struct {
 	BaseReactor
 
-	book *AddrBook
+	book     *AddrBook
+	seedMode bool
 
 	logger  *zap.Logger
 	slogger *zap.SugaredLogger
@@ -45,9 +60,22 @@ func NewPEXReactor(logger *zap.Logger, book *AddrBook) *PEXReactor {
 	return pexR
 }
 
+// SetSeedMode switches the reactor to crawling, must be called before start.
+func (pexR *PEXReactor) SetSeedMode(seedMode bool) {
+	pexR.seedMode = seedMode
+}
+
+func (pexR *PEXReactor) IsSeedMode() bool {
+	return pexR.seedMode
+}
+
 func (pexR *PEXReactor) OnStart() error {
 	pexR.BaseReactor.OnStart()
-	go pexR.ensurePeersRoutine()
+	if pexR.seedMode {
+		go pexR.crawlPeersRoutine()
+	} else {
+		go pexR.ensurePeersRoutine()
+	}
 	return nil
 }
 
@@ -69,9 +97,14 @@ func (pexR *PEXReactor) GetChannels() []*ChannelDescriptor {
 // Implements Reactor
 func (pexR *PEXReactor) AddPeer(peer *Peer) {
 	// Add the peer to the address book
+	peer.Data.Set(peerConnectedAtKey, time.Now())
 	netAddr := NewNetAddressString(peer.ListenAddr)
 	if peer.IsOutbound() {
-		if pexR.book.NeedMoreAddrs() {
+		if pexR.seedMode {
+			// we only dial to crawl, so the address is alive and we want its book
+			pexR.book.MarkGood(peer.Connection().RemoteAddress)
+			pexR.RequestPEX(peer)
+		} else if pexR.book.NeedMoreAddrs() {
 			pexR.RequestPEX(peer)
 		}
 	} else {
@@ -94,6 +127,7 @@ func (pexR *PEXReactor) Receive(chID byte, src *Peer, msgBytes []byte) {
 	_, msg, err := DecodeMessage(msgBytes)
 	if err != nil {
 		pexR.logger.Warn("Error decoding message", zap.String("error", err.Error()))
+		pexR.Switch.ReportPeerBehaviour(src, PeerBehaviourBadMessage, err)
 		return
 	}
 	pexR.slogger.Infow("Received message", "msg", msg)
@@ -103,6 +137,9 @@ func (pexR *PEXReactor) Receive(chID byte, src *Peer, msgBytes []byte) {
 		// src requested some peers.
 		// TODO: prevent abuse.
 		pexR.SendAddrs(src, pexR.book.GetSelection())
+		if pexR.seedMode {
+			go pexR.disconnectAfter(src, seedDisconnectAfterAnswer)
+		}
 	case *pexAddrsMessage:
 		// We received some peer addresses from src.
 		// TODO: prevent abuse.
@@ -222,6 +259,99 @@ func (pexR *PEXReactor) ensurePeers() {
 	}
 }
 
+// Crawls the network while in seed mode. (continuous)
+func (pexR *PEXReactor) crawlPeersRoutine() {
+	// fire once immediately.
+	pexR.crawlPeers()
+	// fire periodically
+	timer := NewRepeatTimer("pex-crawl", crawlPeerPeriodSeconds*time.Second)
+FOR_LOOP:
+	for {
+		select {
+		case <-timer.Ch:
+			pexR.attemptDisconnects()
+			pexR.crawlPeers()
+		case <-pexR.Quit:
+			break FOR_LOOP
+		}
+	}
+
+	// Cleanup
+	timer.Stop()
+}
+
+// Dials the addresses which were checked the longest time ago. (once)
+// Reachable addresses are marked good and asked for their peers,
+// addresses failing too often are dropped from the book.
+func (pexR *PEXReactor) crawlPeers() {
+	kas := pexR.book.ListOfKnownAddresses()
+	sort.Slice(kas, func(i, j int) bool { return kas[i].LastAttempt.Before(kas[j].LastAttempt) })
+
+	myAddr := pexR.Switch.nodeInfo.ListenAddr
+	connected := make(map[string]*Peer)
+	for _, peer := range pexR.Switch.Peers().List() {
+		connected[peer.ListenAddr] = peer
+	}
+
+	now := time.Now()
+	numDials := 0
+	for _, ka := range kas {
+		if numDials >= maxCrawlDialsPerRound {
+			break
+		}
+		addr := ka.Addr
+		addrStr := addr.String()
+		if addrStr == myAddr || pexR.Switch.IsDialing(addr) {
+			continue
+		}
+		if peer, ok := connected[addrStr]; ok {
+			pexR.RequestPEX(peer)
+			continue
+		}
+		// fresh addresses have LastAttempt set to when we heard of them
+		if ka.Attempts > 0 && now.Sub(ka.LastAttempt) < minCrawlRedialPeriod {
+			continue
+		}
+
+		numDials++
+		go func(ka *knownAddress) {
+			if _, err := pexR.Switch.DialPeerWithAddress(ka.Addr); err != nil {
+				pexR.logger.Debug("Crawl dial failed", zap.Stringer("addr", ka.Addr), zap.String("error", err.Error()))
+				if ka.isDead() {
+					pexR.book.MarkBad(ka.Addr)
+				} else {
+					pexR.book.MarkAttempt(ka.Addr)
+				}
+			}
+		}(ka)
+	}
+}
+
+// Disconnects the peers which had enough time to answer. (once)
+func (pexR *PEXReactor) attemptDisconnects() {
+	for _, peer := range pexR.Switch.Peers().List() {
+		connectedAt, ok := peer.Data.Get(peerConnectedAtKey).(time.Time)
+		if !ok || time.Since(connectedAt) < seedDisconnectWaitPeriod {
+			continue
+		}
+		if pexR.Switch.persistentAddr(peer) != nil {
+			continue
+		}
+		pexR.Switch.StopPeerGracefully(peer)
+	}
+}
+
+func (pexR *PEXReactor) disconnectAfter(peer *Peer, d time.Duration) {
+	select {
+	case <-time.After(d):
+	case <-pexR.Quit:
+		return
+	}
+	if pexR.Switch.Peers().Has(peer.Key) && pexR.Switch.persistentAddr(peer) == nil {
+		pexR.Switch.StopPeerGracefully(peer)
+	}
+}
+
 //-----------------------------------------------------------------------------
 // Messages
 
@@ -0,0 +1,121 @@
+package p2p
+
+import (
+	"net"
+	"os"
+	"testing"
+	"time"
+)
+
+// waitFor polls cond until it holds or the timeout expires
+func waitFor(timeout time.Duration, cond func() bool) bool {
+	deadline := time.Now().Add(timeout)
+	for time.Now().Before(deadline) {
+		if cond() {
+			return true
+		}
+		time.Sleep(20 * time.Millisecond)
+	}
+	return cond()
+}
+
+func TestPEXSeedMode(t *testing.T) {
+	// the node crawled by the seed, it records the pex messages it gets
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ln.Close()
+	peerR := NewTestReactor([]*ChannelDescriptor{&ChannelDescriptor{ID: PexChannel, Priority: 1}}, true)
+	peerSw := makeSwitch(log, &config, 1, "testing", "123.123.123", func(i int, sw *Switch) *Switch {
+		sw.AddReactor("PEX", peerR)
+		return sw
+	})
+	peerSw.NodeInfo().ListenAddr = ln.Addr().String()
+	if err := StartSwitches([]*Switch{peerSw}); err != nil {
+		t.Fatal(err)
+	}
+	defer peerSw.Stop()
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			go peerSw.AddPeerWithConnection(conn, false)
+		}
+	}()
+
+	fname := createTempFileName("addrbook_seed")
+	defer os.Remove(fname)
+	book := NewAddrBook(log, fname, false)
+	peerAddr := NewNetAddressString(ln.Addr().String())
+	knownAddr := NewNetAddressString("127.0.0.2:1")
+	book.AddAddress(peerAddr, peerAddr)
+	book.AddAddress(knownAddr, knownAddr)
+
+	pexR := NewPEXReactor(log, book)
+	pexR.SetSeedMode(true)
+	seedSw := makeSwitch(log, &config, 0, "testing", "123.123.123", func(i int, sw *Switch) *Switch {
+		sw.AddReactor("PEX", pexR)
+		return sw
+	})
+	if err := StartSwitches([]*Switch{seedSw}); err != nil {
+		t.Fatal(err)
+	}
+	defer seedSw.Stop()
+
+	// the seed dials the addresses of its book and asks them for theirs
+	crawled := waitFor(5*time.Second, func() bool {
+		for _, m := range peerR.getMsgs(PexChannel) {
+			if _, msg, err := DecodeMessage(m.Bytes); err == nil {
+				if _, ok := msg.(*pexRequestMessage); ok {
+					return true
+				}
+			}
+		}
+		return false
+	})
+	if !crawled {
+		t.Fatal("Expected the seed to crawl the peer and request its addresses")
+	}
+	if !waitFor(time.Second, func() bool { return peerSw.Peers().Size() == 1 }) {
+		t.Fatalf("Expected the peer to be connected to the seed, got %d peers", peerSw.Peers().Size())
+	}
+
+	// the seed answers a request with the addresses of its book
+	asked := time.Now()
+	peerSw.Peers().List()[0].Send(PexChannel, struct{ PexMessage }{&pexRequestMessage{}})
+	var addrs []*NetAddress
+	answered := waitFor(5*time.Second, func() bool {
+		for _, m := range peerR.getMsgs(PexChannel) {
+			if _, msg, err := DecodeMessage(m.Bytes); err == nil {
+				if msg, ok := msg.(*pexAddrsMessage); ok {
+					addrs = msg.Addrs
+					return true
+				}
+			}
+		}
+		return false
+	})
+	if !answered {
+		t.Fatal("Expected the seed to answer the pex request")
+	}
+	found := false
+	for _, addr := range addrs {
+		if addr.Equals(knownAddr) {
+			found = true
+		}
+	}
+	if !found {
+		t.Errorf("Expected the seed to hand out %v, got %v", knownAddr, addrs)
+	}
+
+	// and disconnects the peer once the answer had time to be flushed
+	if !waitFor(5*time.Second, func() bool { return seedSw.Peers().Size() == 0 }) {
+		t.Fatalf("Expected the seed to disconnect the peer, got %d peers", seedSw.Peers().Size())
+	}
+	if elapsed := time.Since(asked); elapsed < seedDisconnectAfterAnswer {
+		t.Errorf("Expected the seed to wait %v before disconnecting, it took %v", seedDisconnectAfterAnswer, elapsed)
+	}
+}
@@ -5,6 +5,8 @@ import (
 	"fmt"
 	"math/rand"
 	"net"
+	"strings"
+	"sync"
 	"time"
 
 	"go.uber.org/zap"
@@ -64,6 +66,9 @@ type Switch struct {
 	reactorsByCh map[byte]Reactor
 	peers        *PeerSet
 	dialing      *CMap
+	persistent   *CMap                 // addresses we always keep a connection to
+	reconnecting *CMap                 // persistent addresses being redialed right now
+	redialMtx    sync.Mutex            // makes the connected check and the dial of a persistent peer atomic
 	nodeInfo     *NodeInfo             // our node info
 	nodePrivKey  crypto.PrivKeyEd25519 // our node privkey
 
@@ -75,6 +80,9 @@ type Switch struct {
 
 	addToRefuselist func([32]byte) error
 
+	behaviours    *PeerBehaviourTracker
+	channelTotals *channelTotals // traffic of disconnected peers
+
 	logger  *zap.Logger
 	slogger *zap.SugaredLogger
 }
@@ -82,22 +90,30 @@ type Switch struct {
 var (
 	ErrSwitchDuplicatePeer      = errors.New("Duplicate peer")
 	ErrSwitchMaxPeersPerIPRange = errors.New("IP range has too many peers")
+	ErrSwitchPeerBanned         = errors.New("Peer is banned")
 )
 
 func NewSwitch(logger *zap.Logger, config cfg.Config) *Switch {
 	setConfigDefaults(config)
 
 	sw := &Switch{
-		config:       config,
-		reactors:     make(map[string]Reactor),
-		chDescs:      make([]*ChannelDescriptor, 0),
-		reactorsByCh: make(map[byte]Reactor),
-		peers:        NewPeerSet(),
-		dialing:      NewCMap(),
-		nodeInfo:     nil,
-		logger:       logger,
-		slogger:      logger.Sugar(),
-	}
+		config:        config,
+		reactors:      make(map[string]Reactor),
+		chDescs:       make([]*ChannelDescriptor, 0),
+		reactorsByCh:  make(map[byte]Reactor),
+		peers:         NewPeerSet(),
+		dialing:       NewCMap(),
+		persistent:    NewCMap(),
+		reconnecting:  NewCMap(),
+		nodeInfo:      nil,
+		channelTotals: newChannelTotals(),
+		logger:        logger,
+		slogger:       logger.Sugar(),
+	}
+	sw.behaviours = NewPeerBehaviourTracker(
+		config.GetInt(configKeyBanThreshold),
+		time.Duration(config.GetInt(configKeyBanBaseSeconds))*time.Second,
+		time.Duration(config.GetInt(configKeyBanMaxSeconds))*time.Second)
 	sw.BaseService = *NewBaseService(logger, "P2P Switch", sw)
 	return sw
 }
@@ -236,6 +252,11 @@ func (sw *Switch) AddPeerWithConnection(conn net.Conn, outbound bool) (*Peer, er
 		}
 	}
 
+	if sw.behaviours.IsBanned(sconn.(*SecretConnection).RemotePubKey().KeyString()) {
+		sconn.Close()
+		return nil, ErrSwitchPeerBanned
+	}
+
 	if err := sw.FilterConnByRefuselist(sconn.(*SecretConnection).RemotePubKey()); err != nil {
 		sconn.Close()
 		return nil, err
@@ -351,18 +372,30 @@ func (sw *Switch) startInitPeer(peer *Peer) {
 
 // Dial a list of seeds in random order
 // Spawns a go routine for each dial
-func (sw *Switch) DialSeeds(seeds []string) {
+// Returns an error without dialing anything if one of the seeds is malformed.
+func (sw *Switch) DialSeeds(seeds []string) error {
+	addrs := make([]*NetAddress, 0, len(seeds))
+	for _, seed := range seeds {
+		seed = strings.TrimSpace(seed)
+		if seed == "" {
+			continue
+		}
+		addr, err := ParseNetAddress(seed)
+		if err != nil {
+			return err
+		}
+		addrs = append(addrs, addr)
+	}
+
 	// permute the list, dial them in random order.
-	perm := rand.Perm(len(seeds))
+	perm := rand.Perm(len(addrs))
 	for i := 0; i < len(perm); i++ {
 		go func(i int) {
 			time.Sleep(time.Duration(rand.Int63n(3000)) * time.Millisecond)
-			j := perm[i]
-			addr := NewNetAddressString(seeds[j])
-
-			sw.dialSeed(addr)
+			sw.dialSeed(addrs[perm[i]])
 		}(i)
 	}
+	return nil
 }
 
 func (sw *Switch) dialSeed(addr *NetAddress) {
@@ -375,6 +408,124 @@ func (sw *Switch) dialSeed(addr *NetAddress) {
 	}
 }
 
+// DialPersistentPeers dials a list of peers and keeps redialing them,
+// with exponential backoff, whenever the connection is lost.
+func (sw *Switch) DialPersistentPeers(peers []string) error {
+	base := sw.config.GetInt(configKeyReconnectBaseSeconds)
+	max := sw.config.GetInt(configKeyReconnectMaxSeconds)
+	if base <= 0 || max < base {
+		return fmt.Errorf("Invalid reconnect backoff: %s must be positive and at most %s, got %d and %d",
+			configKeyReconnectBaseSeconds, configKeyReconnectMaxSeconds, base, max)
+	}
+
+	addrs := make([]*NetAddress, 0, len(peers))
+	for _, p := range peers {
+		p = strings.TrimSpace(p)
+		if p == "" {
+			continue
+		}
+		addr, err := ParseNetAddress(p)
+		if err != nil {
+			return err
+		}
+		addrs = append(addrs, addr)
+	}
+	for _, addr := range addrs {
+		sw.persistent.Set(addr.String(), addr)
+		go sw.reconnectToPeer(addr)
+	}
+	return nil
+}
+
+// IsPersistent reports whether addr has been registered as a persistent peer.
+func (sw *Switch) IsPersistent(addr *NetAddress) bool {
+	return sw.persistent.Has(addr.String())
+}
+
+// peerAddrs are the addresses a peer can be reached at: the one it listens on
+// and, for an outbound peer, the one we dialed.
+func peerAddrs(peer *Peer) []string {
+	addrs := []string{peer.ListenAddr}
+	if peer.IsOutbound() && peer.Connection() != nil {
+		addrs = append(addrs, peer.Connection().RemoteAddress.String())
+	}
+	return addrs
+}
+
+// persistentAddr returns the persistent address a peer was connected through, if any.
+func (sw *Switch) persistentAddr(peer *Peer) *NetAddress {
+	for _, k := range peerAddrs(peer) {
+		if addr := sw.persistent.Get(k); addr != nil {
+			return addr.(*NetAddress)
+		}
+	}
+	return nil
+}
+
+// hasPeerWithAddr reports whether we are connected to a peer reachable at addr
+func (sw *Switch) hasPeerWithAddr(addr *NetAddress) bool {
+	for _, peer := range sw.peers.List() {
+		for _, a := range peerAddrs(peer) {
+			if a == addr.String() {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// reconnectToPeer dials addr until it succeeds or the switch stops.
+// The wait between attempts doubles every time, up to reconnect_max_seconds.
+func (sw *Switch) reconnectToPeer(addr *NetAddress) {
+	if sw.reconnecting.Has(addr.String()) {
+		return
+	}
+	sw.reconnecting.Set(addr.String(), addr)
+	defer sw.reconnecting.Delete(addr.String())
+
+	backoff := time.Duration(sw.config.GetInt(configKeyReconnectBaseSeconds)) * time.Second
+	maxBackoff := time.Duration(sw.config.GetInt(configKeyReconnectMaxSeconds)) * time.Second
+	for i := 1; ; i++ {
+		if !sw.IsRunning() {
+			return
+		}
+		connected, peer, err := sw.redialPersistentPeer(addr)
+		if connected {
+			return
+		}
+		if err == nil {
+			sw.logger.Info("Connected to persistent peer", zap.Stringer("peer", peer), zap.Int("attempts", i))
+			return
+		}
+		if err == ErrSwitchDuplicatePeer {
+			return
+		}
+		sw.logger.Info("Error dialing persistent peer", zap.Stringer("address", addr), zap.Int("attempts", i), zap.Duration("retryIn", backoff), zap.String("error", err.Error()))
+
+		// add up to 1/4 jitter so restarted nodes don't redial in lockstep
+		jitter := time.Duration(rand.Int63n(int64(backoff)/4 + 1))
+		select {
+		case <-time.After(backoff + jitter):
+		case <-sw.Quit:
+			return
+		}
+		if backoff *= 2; backoff > maxBackoff {
+			backoff = maxBackoff
+		}
+	}
+}
+
+// redialPersistentPeer dials addr unless a peer reachable at it is already connected
+func (sw *Switch) redialPersistentPeer(addr *NetAddress) (connected bool, peer *Peer, err error) {
+	sw.redialMtx.Lock()
+	defer sw.redialMtx.Unlock()
+	if sw.hasPeerWithAddr(addr) {
+		return true, nil, nil
+	}
+	peer, err = sw.DialPeerWithAddress(addr)
+	return false, peer, err
+}
+
 func (sw *Switch) DialPeerWithAddress(addr *NetAddress) (*Peer, error) {
 	sw.logger.Debug("Dialing address", zap.Stringer("address", addr))
 	sw.dialing.Set(addr.IP.String(), addr)
@@ -440,17 +591,67 @@ func (sw *Switch) Peers() IPeerSet {
 // TODO: make record depending on reason.
 func (sw *Switch) StopPeerForError(peer *Peer, reason interface{}) {
 	sw.slogger.Infow("Stopping peer for error", "peer", peer, "error", reason)
-	sw.peers.Remove(peer)
+	removed := sw.peers.Remove(peer)
 	peer.Stop()
+	if removed {
+		// the peer may be stopped twice, its counters must be added once
+		sw.retirePeerMetrics(peer)
+		sw.behaviours.Forget(peer.Key)
+	}
 	sw.removePeerFromReactors(peer, reason)
+
+	if addr := sw.persistentAddr(peer); addr != nil && sw.IsRunning() {
+		go sw.reconnectToPeer(addr)
+	}
+}
+
+// ReportPeerBehaviour lets reactors tell the switch how a peer behaves.
+// A peer whose score drops below the ban threshold is disconnected
+// and refused until its temporary ban expires.
+func (sw *Switch) ReportPeerBehaviour(peer *Peer, b PeerBehaviour, reason interface{}) {
+	var reasonStr string
+	if reason != nil {
+		reasonStr = fmt.Sprintf("%v", reason)
+	}
+	if !sw.behaviours.Report(peer.Key, b, reasonStr) {
+		return
+	}
+	sw.slogger.Warnw("Banning peer", "peer", peer, "behaviour", b, "reason", reasonStr)
+	if sw.peers.Has(peer.Key) {
+		sw.StopPeerForError(peer, ErrSwitchPeerBanned)
+	}
+}
+
+// PeerScore returns the score of the peer with the given key.
+func (sw *Switch) PeerScore(peerKey string) int {
+	return sw.behaviours.Score(peerKey)
+}
+
+// PeerScores returns the scores of the reported peers still connected or banned.
+func (sw *Switch) PeerScores() []PeerScore {
+	return sw.behaviours.Scores()
+}
+
+// BannedPeers returns the peers which are refused right now.
+func (sw *Switch) BannedPeers() []PeerScore {
+	return sw.behaviours.Banned()
+}
+
+// UnbanPeer lifts a temporary ban before it expires.
+func (sw *Switch) UnbanPeer(peerKey string) {
+	sw.behaviours.Unban(peerKey)
 }
 
 // Disconnect from a peer gracefully.
 // TODO: handle graceful disconnects.
 func (sw *Switch) StopPeerGracefully(peer *Peer) {
 	sw.logger.Info("Stopping peer gracefully")
-	sw.peers.Remove(peer)
+	removed := sw.peers.Remove(peer)
 	peer.Stop()
+	if removed {
+		sw.retirePeerMetrics(peer)
+		sw.behaviours.Forget(peer.Key)
+	}
 	sw.removePeerFromReactors(peer, nil)
 }
 
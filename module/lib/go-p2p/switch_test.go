@@ -8,6 +8,8 @@ import (
 	"testing"
 	"time"
 
+	"go.uber.org/zap"
+
 	. "github.com/DelosIsland/core/module/lib/go-common"
 	cfg "github.com/DelosIsland/core/module/lib/go-config"
 	"github.com/DelosIsland/core/module/lib/go-crypto"
@@ -16,12 +18,13 @@ import (
 
 var (
 	config cfg.Config
+	log    = zap.NewNop()
 )
 
 func init() {
 	config = cfg.NewMapConfig(nil)
 	setConfigDefaults(config)
-
+	config.SetDefault("connection_reset_wait", 300)
 }
 
 type PeerMessage struct {
@@ -73,6 +76,8 @@ func (tr *TestReactor) Receive(chID byte, peer *Peer, msgBytes []byte) {
 		tr.mtx.Lock()
 		defer tr.mtx.Unlock()
 		//fmt.Printf("Received: %X, %X\n", chID, msgBytes)
+		// the connection reuses msgBytes for the next message
+		msgBytes = append([]byte(nil), msgBytes...)
 		tr.msgsReceived[chID] = append(tr.msgsReceived[chID], PeerMessage{peer.Key, msgBytes, tr.msgsCounter})
 		tr.msgsCounter++
 	}
@@ -90,7 +95,7 @@ func (tr *TestReactor) getMsgs(chID byte) []PeerMessage {
 // XXX: note this uses net.Pipe and not a proper TCP conn
 func makeSwitchPair(t testing.TB, initSwitch func(int, *Switch) *Switch) (*Switch, *Switch) {
 	// Create two switches that will be interconnected.
-	switches := MakeConnectedSwitches(2, initSwitch, Connect2Switches)
+	switches := MakeConnectedSwitches(log, &config, 2, initSwitch, Connect2Switches)
 	return switches[0], switches[1]
 }
 
@@ -161,8 +166,8 @@ func TestSwitches(t *testing.T) {
 }
 
 func TestConnAddrFilter(t *testing.T) {
-	s1 := makeSwitch(1, "testing", "123.123.123", initSwitchFunc)
-	s2 := makeSwitch(1, "testing", "123.123.123", initSwitchFunc)
+	s1 := makeSwitch(log, &config, 1, "testing", "123.123.123", initSwitchFunc)
+	s2 := makeSwitch(log, &config, 1, "testing", "123.123.123", initSwitchFunc)
 
 	c1, c2 := net.Pipe()
 
@@ -191,8 +196,8 @@ func TestConnAddrFilter(t *testing.T) {
 }
 
 func TestConnPubKeyFilter(t *testing.T) {
-	s1 := makeSwitch(1, "testing", "123.123.123", initSwitchFunc)
-	s2 := makeSwitch(1, "testing", "123.123.123", initSwitchFunc)
+	s1 := makeSwitch(log, &config, 1, "testing", "123.123.123", initSwitchFunc)
+	s2 := makeSwitch(log, &config, 1, "testing", "123.123.123", initSwitchFunc)
 
 	c1, c2 := net.Pipe()
 
@@ -221,6 +226,82 @@ func TestConnPubKeyFilter(t *testing.T) {
 	}
 }
 
+func TestSwitchBanPeer(t *testing.T) {
+	s1, s2 := makeSwitchPair(t, initSwitchFunc)
+	defer s1.Stop()
+	defer s2.Stop()
+
+	peer := s1.Peers().List()[0]
+	s1.ReportPeerBehaviour(peer, PeerBehaviourGoodVote, nil)
+	if score := s1.PeerScore(peer.Key); score != 1 {
+		t.Errorf("Expected a good vote to raise the score to 1, got %d", score)
+	}
+	for i := 0; i < 3 && s1.Peers().Size() > 0; i++ {
+		s1.ReportPeerBehaviour(peer, PeerBehaviourBadBlock, "bad block")
+	}
+	if s1.Peers().Size() != 0 {
+		t.Fatal("Expected the banned peer to be disconnected")
+	}
+	if banned := s1.BannedPeers(); len(banned) != 1 || banned[0].Key != peer.Key {
+		t.Fatalf("Expected the peer to be banned, got %v", banned)
+	}
+
+	s1.UnbanPeer(peer.Key)
+	if banned := s1.BannedPeers(); len(banned) != 0 {
+		t.Errorf("Expected no banned peer after the unban, got %v", banned)
+	}
+	scores := s1.PeerScores()
+	if len(scores) != 1 || scores[0].Key != peer.Key || scores[0].NumBans != 1 || scores[0].Score != 0 {
+		t.Errorf("Expected the unbanned peer to keep its ban count, got %v", scores)
+	}
+}
+
+func TestSwitchForgetPeerScore(t *testing.T) {
+	s1, s2 := makeSwitchPair(t, initSwitchFunc)
+	defer s1.Stop()
+	defer s2.Stop()
+
+	peer := s1.Peers().List()[0]
+	s1.ReportPeerBehaviour(peer, PeerBehaviourGoodVote, nil)
+	s1.StopPeerGracefully(peer)
+	if scores := s1.PeerScores(); len(scores) != 0 {
+		t.Errorf("Expected the score of a removed peer to be dropped, got %v", scores)
+	}
+}
+
+func TestSwitchReconnectConnectedPeer(t *testing.T) {
+	s1, s2 := makeSwitchPair(t, initSwitchFunc)
+	defer s1.Stop()
+	defer s2.Stop()
+
+	// nothing listens there, a dial would fail and be retried
+	addr := NewNetAddressString("127.0.0.1:1")
+	s1.Peers().List()[0].ListenAddr = addr.String()
+
+	done := make(chan struct{})
+	go func() {
+		s1.reconnectToPeer(addr)
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("Expected no redial of a connected peer")
+	}
+}
+
+func TestSwitchReconnectBackoffConfig(t *testing.T) {
+	for _, backoff := range [][2]int{{0, 60}, {-1, 60}, {10, 5}} {
+		c := cfg.NewMapConfig(nil)
+		c.Set(configKeyReconnectBaseSeconds, backoff[0])
+		c.Set(configKeyReconnectMaxSeconds, backoff[1])
+		sw := NewSwitch(log, c)
+		if err := sw.DialPersistentPeers([]string{"127.0.0.1:1"}); err == nil {
+			t.Errorf("Expected the backoff %v to be refused", backoff)
+		}
+	}
+}
+
 func BenchmarkSwitches(b *testing.B) {
 
 	b.StopTimer()
@@ -0,0 +1,240 @@
+// Package pubsub delivers published messages to the subscriptions whose query matches their tags.
+//
+// Each subscription has its own buffered channel, publishing never waits for a subscriber:
+// when a buffer is full the overflow policy of the subscription decides what is lost.
+package pubsub
+
+import (
+	"errors"
+	"sync"
+	"sync/atomic"
+)
+
+// DefaultCapacity is the buffer of a subscription made with no capacity
+const DefaultCapacity = 100
+
+var (
+	ErrAlreadySubscribed    = errors.New("already subscribed to the query")
+	ErrSubscriptionNotFound = errors.New("no subscription to the query")
+	ErrOutOfCapacity        = errors.New("the subscriber is too slow, its buffer is full")
+	ErrUnsubscribed         = errors.New("unsubscribed")
+)
+
+// Query selects the messages of a subscription, see the query package
+type Query interface {
+	Matches(tags map[string]string) bool
+	String() string
+}
+
+// OverflowPolicy is what happens to a message for a subscription whose buffer is full
+type OverflowPolicy int
+
+const (
+	// DropNewest drops the message
+	DropNewest OverflowPolicy = iota
+	// DropOldest drops the oldest buffered message to make room for it
+	DropOldest
+	// Cancel ends the subscription with ErrOutOfCapacity
+	Cancel
+)
+
+// Message is a published message with its tags
+type Message struct {
+	Data interface{}
+	Tags map[string]string
+}
+
+// Subscription receives the messages matching its query until it ends
+type Subscription struct {
+	query  Query
+	policy OverflowPolicy
+	out    chan Message
+
+	dropped uint64 // atomic
+
+	mtx    sync.Mutex
+	closed bool
+	err    error
+}
+
+// Out is closed when the subscription ends, after the buffered messages
+func (s *Subscription) Out() <-chan Message {
+	return s.out
+}
+
+// Err tells why the subscription ended, nil while it runs
+func (s *Subscription) Err() error {
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+	return s.err
+}
+
+// Query returns the query of the subscription
+func (s *Subscription) Query() Query {
+	return s.query
+}
+
+// Dropped counts the messages lost to the overflow policy
+func (s *Subscription) Dropped() uint64 {
+	return atomic.LoadUint64(&s.dropped)
+}
+
+// deliver returns false when the subscription must be cancelled
+func (s *Subscription) deliver(msg Message) bool {
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+	if s.closed {
+		return true
+	}
+	select {
+	case s.out <- msg:
+		return true
+	default:
+	}
+
+	switch s.policy {
+	case DropOldest:
+		select {
+		case <-s.out:
+			atomic.AddUint64(&s.dropped, 1)
+		default:
+		}
+		select {
+		case s.out <- msg:
+		default:
+			atomic.AddUint64(&s.dropped, 1)
+		}
+	case Cancel:
+		atomic.AddUint64(&s.dropped, 1)
+		return false
+	default:
+		atomic.AddUint64(&s.dropped, 1)
+	}
+	return true
+}
+
+func (s *Subscription) close(err error) {
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+	if !s.closed {
+		s.closed, s.err = true, err
+		close(s.out)
+	}
+}
+
+// Server keeps the subscriptions, by subscriber and query
+type Server struct {
+	mtx           sync.RWMutex
+	subscriptions map[string]map[string]*Subscription
+}
+
+func NewServer() *Server {
+	return &Server{subscriptions: make(map[string]map[string]*Subscription)}
+}
+
+// Subscribe makes a subscription to the messages matching q, buffering up to capacity of them.
+// A subscriber has a single subscription for a query.
+func (s *Server) Subscribe(subscriber string, q Query, capacity int, policy OverflowPolicy) (*Subscription, error) {
+	if capacity <= 0 {
+		capacity = DefaultCapacity
+	}
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+	subs := s.subscriptions[subscriber]
+	if subs == nil {
+		subs = make(map[string]*Subscription)
+		s.subscriptions[subscriber] = subs
+	}
+	if _, ok := subs[q.String()]; ok {
+		return nil, ErrAlreadySubscribed
+	}
+	sub := &Subscription{
+		query:  q,
+		policy: policy,
+		out:    make(chan Message, capacity),
+	}
+	subs[q.String()] = sub
+	return sub, nil
+}
+
+// Unsubscribe ends the subscription of subscriber to q, its channel is closed
+func (s *Server) Unsubscribe(subscriber string, q Query) error {
+	s.mtx.Lock()
+	sub, ok := s.subscriptions[subscriber][q.String()]
+	if ok {
+		s.remove(subscriber, q.String())
+	}
+	s.mtx.Unlock()
+	if !ok {
+		return ErrSubscriptionNotFound
+	}
+	sub.close(ErrUnsubscribed)
+	return nil
+}
+
+// UnsubscribeAll ends every subscription of subscriber
+func (s *Server) UnsubscribeAll(subscriber string) error {
+	s.mtx.Lock()
+	subs, ok := s.subscriptions[subscriber]
+	delete(s.subscriptions, subscriber)
+	s.mtx.Unlock()
+	if !ok {
+		return ErrSubscriptionNotFound
+	}
+	for _, sub := range subs {
+		sub.close(ErrUnsubscribed)
+	}
+	return nil
+}
+
+// NumSubscriptions counts the running subscriptions
+func (s *Server) NumSubscriptions() int {
+	s.mtx.RLock()
+	defer s.mtx.RUnlock()
+	n := 0
+	for _, subs := range s.subscriptions {
+		n += len(subs)
+	}
+	return n
+}
+
+// Publish hands data to the subscriptions matching tags, it doesn't wait for them
+func (s *Server) Publish(data interface{}, tags map[string]string) {
+	msg := Message{Data: data, Tags: tags}
+	type cancelled struct {
+		subscriber string
+		sub        *Subscription
+	}
+	var toCancel []cancelled
+
+	s.mtx.RLock()
+	for subscriber, subs := range s.subscriptions {
+		for _, sub := range subs {
+			if sub.query.Matches(tags) && !sub.deliver(msg) {
+				toCancel = append(toCancel, cancelled{subscriber, sub})
+			}
+		}
+	}
+	s.mtx.RUnlock()
+
+	if len(toCancel) == 0 {
+		return
+	}
+	s.mtx.Lock()
+	for _, c := range toCancel {
+		if s.subscriptions[c.subscriber][c.sub.query.String()] == c.sub {
+			s.remove(c.subscriber, c.sub.query.String())
+		}
+	}
+	s.mtx.Unlock()
+	for _, c := range toCancel {
+		c.sub.close(ErrOutOfCapacity)
+	}
+}
+
+func (s *Server) remove(subscriber, query string) {
+	delete(s.subscriptions[subscriber], query)
+	if len(s.subscriptions[subscriber]) == 0 {
+		delete(s.subscriptions, subscriber)
+	}
+}
@@ -0,0 +1,70 @@
+package pubsub
+
+import (
+	"testing"
+
+	"github.com/DelosIsland/core/module/lib/go-pubsub/query"
+)
+
+func TestPublish(t *testing.T) {
+	s := NewServer()
+	txs, err := s.Subscribe("client", query.MustParse("tm.event='Tx' AND tx.height>1"), 10, DropNewest)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := s.Subscribe("client", query.MustParse("tm.event='Tx' AND tx.height>1"), 10, DropNewest); err != ErrAlreadySubscribed {
+		t.Errorf("Expected ErrAlreadySubscribed, got %v", err)
+	}
+
+	s.Publish("tx1", map[string]string{"tm.event": "Tx", "tx.height": "1"})
+	s.Publish("tx2", map[string]string{"tm.event": "Tx", "tx.height": "2"})
+	s.Publish("block2", map[string]string{"tm.event": "NewBlock", "block.height": "2"})
+	if msg := <-txs.Out(); msg.Data != "tx2" || msg.Tags["tx.height"] != "2" {
+		t.Errorf("Expected tx2, got %v", msg)
+	}
+
+	if err := s.Unsubscribe("client", query.MustParse("tm.event='Tx' AND tx.height>1")); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := <-txs.Out(); ok || txs.Err() != ErrUnsubscribed {
+		t.Errorf("Expected the subscription to be closed, got %v", txs.Err())
+	}
+	if s.NumSubscriptions() != 0 {
+		t.Errorf("Expected no subscription left, got %d", s.NumSubscriptions())
+	}
+}
+
+func TestOverflowPolicies(t *testing.T) {
+	s := NewServer()
+	all := query.MustParse("n EXISTS")
+	newest, _ := s.Subscribe("newest", all, 2, DropNewest)
+	oldest, _ := s.Subscribe("oldest", all, 2, DropOldest)
+	cancel, _ := s.Subscribe("cancel", all, 2, Cancel)
+	for _, n := range []string{"1", "2", "3"} {
+		s.Publish(n, map[string]string{"n": n})
+	}
+
+	expect := func(sub *Subscription, name string, data ...string) {
+		for _, d := range data {
+			if msg, ok := <-sub.Out(); !ok || msg.Data != d {
+				t.Errorf("Expected %s to receive %s, got %v", name, d, msg.Data)
+			}
+		}
+		if sub.Dropped() != 1 {
+			t.Errorf("Expected %s to drop a message, got %d", name, sub.Dropped())
+		}
+	}
+	expect(newest, "newest", "1", "2")
+	expect(oldest, "oldest", "2", "3")
+	expect(cancel, "cancel", "1", "2")
+
+	if _, ok := <-cancel.Out(); ok || cancel.Err() != ErrOutOfCapacity {
+		t.Errorf("Expected the slow subscription to be cancelled, got %v", cancel.Err())
+	}
+	if s.NumSubscriptions() != 2 {
+		t.Errorf("Expected 2 subscriptions left, got %d", s.NumSubscriptions())
+	}
+	if err := s.UnsubscribeAll("oldest"); err != nil || oldest.Err() != ErrUnsubscribed {
+		t.Errorf("Expected the subscriptions of oldest to end, got %v %v", err, oldest.Err())
+	}
+}
@@ -0,0 +1,251 @@
+// Package query parses the queries subscriptions filter events with, and matches them
+// against the tags of the events.
+//
+//	tm.event = 'Tx' AND tx.height > 5
+//
+// A query is conditions joined by AND. A condition compares a tag with a 'string' or a number
+// using =, !=, <, <=, > or >=, looks for a substring with CONTAINS, or checks that the tag
+// is set with EXISTS. A tag compared with a number must hold a number to match.
+package query
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// Op is the operator of a condition
+type Op int
+
+const (
+	OpEqual Op = iota
+	OpNotEqual
+	OpLess
+	OpLessEqual
+	OpGreater
+	OpGreaterEqual
+	OpContains
+	OpExists
+)
+
+var opNames = map[Op]string{
+	OpEqual:        "=",
+	OpNotEqual:     "!=",
+	OpLess:         "<",
+	OpLessEqual:    "<=",
+	OpGreater:      ">",
+	OpGreaterEqual: ">=",
+	OpContains:     "CONTAINS",
+	OpExists:       "EXISTS",
+}
+
+func (op Op) String() string {
+	return opNames[op]
+}
+
+// Condition is a test of a single tag
+type Condition struct {
+	Tag     string
+	Op      Op
+	Operand string // the string, or the number as written
+	IsNum   bool
+
+	num float64
+}
+
+// Query is a parsed query, its conditions must all hold for an event to match
+type Query struct {
+	str        string
+	conditions []Condition
+}
+
+// New parses a query
+func New(s string) (*Query, error) {
+	p := &parser{input: s}
+	conditions, err := p.parse()
+	if err != nil {
+		return nil, err
+	}
+	return &Query{str: strings.TrimSpace(s), conditions: conditions}, nil
+}
+
+// MustParse parses a query known to be valid, it panics otherwise
+func MustParse(s string) *Query {
+	q, err := New(s)
+	if err != nil {
+		panic(err)
+	}
+	return q
+}
+
+// String returns the query as it was written, it identifies the subscriptions
+func (q *Query) String() string {
+	return q.str
+}
+
+// Conditions returns the conditions of the query
+func (q *Query) Conditions() []Condition {
+	return q.conditions
+}
+
+// Matches tells whether the tags satisfy every condition of the query
+func (q *Query) Matches(tags map[string]string) bool {
+	for i := range q.conditions {
+		if !q.conditions[i].matches(tags) {
+			return false
+		}
+	}
+	return true
+}
+
+func (c *Condition) matches(tags map[string]string) bool {
+	value, ok := tags[c.Tag]
+	if !ok {
+		return false
+	}
+	switch c.Op {
+	case OpExists:
+		return true
+	case OpContains:
+		return strings.Contains(value, c.Operand)
+	}
+	if !c.IsNum {
+		if c.Op == OpEqual {
+			return value == c.Operand
+		}
+		return value != c.Operand
+	}
+
+	num, err := strconv.ParseFloat(value, 64)
+	if err != nil {
+		return false
+	}
+	switch c.Op {
+	case OpEqual:
+		return num == c.num
+	case OpNotEqual:
+		return num != c.num
+	case OpLess:
+		return num < c.num
+	case OpLessEqual:
+		return num <= c.num
+	case OpGreater:
+		return num > c.num
+	case OpGreaterEqual:
+		return num >= c.num
+	}
+	return false
+}
+
+//-----------------------------------------------------------------------------
+
+type parser struct {
+	input string
+	pos   int
+}
+
+func (p *parser) parse() ([]Condition, error) {
+	var conditions []Condition
+	for {
+		c, err := p.condition()
+		if err != nil {
+			return nil, err
+		}
+		conditions = append(conditions, c)
+
+		p.skipSpaces()
+		if p.pos == len(p.input) {
+			return conditions, nil
+		}
+		if word := p.word(); !strings.EqualFold(word, "AND") {
+			return nil, p.errorf("expected AND, got %q", word)
+		}
+	}
+}
+
+func (p *parser) condition() (Condition, error) {
+	var c Condition
+	p.skipSpaces()
+	if c.Tag = p.word(); c.Tag == "" {
+		return c, p.errorf("expected a tag")
+	}
+
+	p.skipSpaces()
+	if word := p.peekWord(); strings.EqualFold(word, "EXISTS") {
+		p.word()
+		c.Op = OpExists
+		return c, nil
+	} else if strings.EqualFold(word, "CONTAINS") {
+		p.word()
+		c.Op = OpContains
+	} else if op, ok := p.operator(); ok {
+		c.Op = op
+	} else {
+		return c, p.errorf("expected an operator after %s", c.Tag)
+	}
+
+	p.skipSpaces()
+	if p.pos < len(p.input) && p.input[p.pos] == '\'' {
+		end := strings.IndexByte(p.input[p.pos+1:], '\'')
+		if end < 0 {
+			return c, p.errorf("unterminated string")
+		}
+		c.Operand = p.input[p.pos+1 : p.pos+1+end]
+		p.pos += end + 2
+		if c.Op != OpEqual && c.Op != OpNotEqual && c.Op != OpContains {
+			return c, p.errorf("%s compares numbers, not '%s'", c.Op, c.Operand)
+		}
+		return c, nil
+	}
+
+	c.Operand = p.word()
+	num, err := strconv.ParseFloat(c.Operand, 64)
+	if err != nil {
+		return c, p.errorf("expected a 'string' or a number after %s %s", c.Tag, c.Op)
+	}
+	if c.Op == OpContains {
+		return c, p.errorf("CONTAINS needs a 'string'")
+	}
+	c.IsNum, c.num = true, num
+	return c, nil
+}
+
+func (p *parser) operator() (Op, bool) {
+	for _, op := range []Op{OpNotEqual, OpLessEqual, OpGreaterEqual, OpEqual, OpLess, OpGreater} {
+		if strings.HasPrefix(p.input[p.pos:], op.String()) {
+			p.pos += len(op.String())
+			return op, true
+		}
+	}
+	return 0, false
+}
+
+func (p *parser) skipSpaces() {
+	for p.pos < len(p.input) && (p.input[p.pos] == ' ' || p.input[p.pos] == '\t' || p.input[p.pos] == '\n') {
+		p.pos++
+	}
+}
+
+func isWordByte(b byte) bool {
+	return b >= 'a' && b <= 'z' || b >= 'A' && b <= 'Z' || b >= '0' && b <= '9' || b == '.' || b == '_' || b == '-'
+}
+
+// word reads a tag, a keyword or a number
+func (p *parser) word() string {
+	start := p.pos
+	for p.pos < len(p.input) && isWordByte(p.input[p.pos]) {
+		p.pos++
+	}
+	return p.input[start:p.pos]
+}
+
+func (p *parser) peekWord() string {
+	pos := p.pos
+	word := p.word()
+	p.pos = pos
+	return word
+}
+
+func (p *parser) errorf(format string, args ...interface{}) error {
+	return fmt.Errorf("bad query at %d: %s", p.pos, fmt.Sprintf(format, args...))
+}
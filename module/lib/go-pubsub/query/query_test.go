@@ -0,0 +1,59 @@
+package query
+
+import (
+	"testing"
+)
+
+func TestMatches(t *testing.T) {
+	tags := map[string]string{
+		"tm.event":  "Tx",
+		"tx.hash":   "AB12",
+		"tx.height": "7",
+	}
+	tests := []struct {
+		query   string
+		matches bool
+	}{
+		{"tm.event='Tx'", true},
+		{"tm.event = 'NewBlock'", false},
+		{"tm.event='Tx' AND tx.height>5", true},
+		{"tm.event='Tx' and tx.height>7", false},
+		{"tx.height>=7 AND tx.height<=7", true},
+		{"tx.height<7", false},
+		{"tx.height!=6", true},
+		{"tx.height=7.0", true},
+		{"tx.hash CONTAINS 'B1'", true},
+		{"tx.hash!='AB12'", false},
+		{"tx.hash EXISTS", true},
+		{"block.height EXISTS", false},
+		{"tx.hash>5", false}, // not a number
+	}
+	for _, test := range tests {
+		q, err := New(test.query)
+		if err != nil {
+			t.Errorf("%s: %v", test.query, err)
+			continue
+		}
+		if q.Matches(tags) != test.matches {
+			t.Errorf("Expected %s to match: %v", test.query, test.matches)
+		}
+	}
+}
+
+func TestBadQueries(t *testing.T) {
+	for _, s := range []string{
+		"",
+		"tm.event",
+		"tm.event=",
+		"tm.event='Tx",
+		"tm.event='Tx' tx.height>5",
+		"tm.event='Tx' AND",
+		"tx.height>'5'",
+		"tx.hash CONTAINS 5",
+		"tx.height>five",
+	} {
+		if _, err := New(s); err == nil {
+			t.Errorf("Expected %q to be refused", s)
+		}
+	}
+}
@@ -2,6 +2,9 @@ package rpcclient
 
 import (
 	"bytes"
+	"context"
+	"crypto/tls"
+	"encoding/hex"
 	"encoding/json"
 	"errors"
 	"fmt"
@@ -10,11 +13,14 @@ import (
 	"net/http"
 	"net/url"
 	"reflect"
+	"strconv"
 	"strings"
+	"time"
 
 	"go.uber.org/zap"
 
 	. "github.com/DelosIsland/core/module/lib/go-common"
+	"github.com/DelosIsland/core/module/lib/go-crypto"
 	"github.com/DelosIsland/core/module/lib/go-rpc/types"
 	"github.com/DelosIsland/core/module/lib/go-wire"
 )
@@ -32,6 +38,11 @@ func makeHTTPDialer(logger *zap.Logger, remoteAddr string) (string, func(string,
 		protocol, address = parts[0], parts[1]
 	}
 
+	if protocol == "https" {
+		// TLS is done by the http.Transport on top of a tcp connection
+		protocol = "tcp"
+	}
+
 	trimmedAddress := strings.Replace(address, "/", ".", -1) // replace / with . for http requests (dummy domain)
 	return trimmedAddress, func(proto, addr string) (net.Conn, error) {
 		return net.Dial(protocol, address)
@@ -42,7 +53,11 @@ func makeHTTPDialer(logger *zap.Logger, remoteAddr string) (string, func(string,
 // remoteAddr should be fully featured (eg. with tcp:// or unix://)
 func makeHTTPClient(logger *zap.Logger, remoteAddr string) (string, *http.Client) {
 	address, dialer := makeHTTPDialer(logger, remoteAddr)
-	return "http://" + address, &http.Client{
+	scheme := "http://"
+	if strings.HasPrefix(remoteAddr, "https://") {
+		scheme = "https://"
+	}
+	return scheme + address, &http.Client{
 		Transport: &http.Transport{
 			Dial: dialer,
 		},
@@ -56,10 +71,51 @@ type Client interface {
 
 //------------------------------------------------------------------------------------
 
+// requestAuth adds the credentials expected by rpcserver.AccessControl to requests
+type requestAuth struct {
+	token  string
+	signer crypto.PrivKeyEd25519
+	signed bool
+}
+
+func (a *requestAuth) apply(req *http.Request, body []byte) {
+	if a.token != "" {
+		req.Header.Set("Authorization", "Bearer "+a.token)
+	}
+	if a.signed {
+		timestamp := strconv.FormatInt(time.Now().Unix(), 10)
+		nonce := crypto.CRandHex(32)
+		sig := a.signer.Sign(rpctypes.RequestSignBytes(timestamp, nonce, req.URL.RequestURI(), body)).(crypto.SignatureEd25519)
+		pubKey := a.signer.PubKey().(crypto.PubKeyEd25519)
+		req.Header.Set(rpctypes.HeaderPubKey, hex.EncodeToString(pubKey[:]))
+		req.Header.Set(rpctypes.HeaderTimestamp, timestamp)
+		req.Header.Set(rpctypes.HeaderNonce, nonce)
+		req.Header.Set(rpctypes.HeaderSignature, hex.EncodeToString(sig[:]))
+	}
+}
+
+func (a *requestAuth) post(ctx context.Context, client *http.Client, url, contentType string, body []byte) (*http.Response, error) {
+	req, err := http.NewRequest("POST", url, bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	req = req.WithContext(ctx)
+	req.Header.Set("Content-Type", contentType)
+	a.apply(req, body)
+	return client.Do(req)
+}
+
+func setTLSConfig(client *http.Client, tlsConfig *tls.Config) {
+	client.Transport.(*http.Transport).TLSClientConfig = tlsConfig
+}
+
+//------------------------------------------------------------------------------------
+
 // JSON rpc takes params as a slice
 type ClientJSONRPC struct {
 	address string
 	client  *http.Client
+	auth    requestAuth
 }
 
 func NewClientJSONRPC(logger *zap.Logger, remote string) *ClientJSONRPC {
@@ -70,11 +126,31 @@ func NewClientJSONRPC(logger *zap.Logger, remote string) *ClientJSONRPC {
 	}
 }
 
+// SetAuthToken sends the API token with every request
+func (c *ClientJSONRPC) SetAuthToken(token string) {
+	c.auth.token = token
+}
+
+// SetSigner signs every request with the key
+func (c *ClientJSONRPC) SetSigner(privKey crypto.PrivKeyEd25519) {
+	c.auth.signer, c.auth.signed = privKey, true
+}
+
+// SetTLSConfig is used for https:// remotes, e.g. to trust a private CA or present a client certificate
+func (c *ClientJSONRPC) SetTLSConfig(tlsConfig *tls.Config) {
+	setTLSConfig(c.client, tlsConfig)
+}
+
 func (c *ClientJSONRPC) Call(method string, params []interface{}, result interface{}) (interface{}, error) {
-	return c.call(method, params, result)
+	return c.call(context.Background(), method, params, result)
 }
 
-func (c *ClientJSONRPC) call(method string, params []interface{}, result interface{}) (interface{}, error) {
+// CallContext is Call, aborted when ctx is done
+func (c *ClientJSONRPC) CallContext(ctx context.Context, method string, params []interface{}, result interface{}) (interface{}, error) {
+	return c.call(ctx, method, params, result)
+}
+
+func (c *ClientJSONRPC) call(ctx context.Context, method string, params []interface{}, result interface{}) (interface{}, error) {
 	// Make request and get responseBytes
 	request := rpctypes.RPCRequest{
 		JSONRPC: "2.0",
@@ -83,9 +159,8 @@ func (c *ClientJSONRPC) call(method string, params []interface{}, result interfa
 		ID:      "",
 	}
 	requestBytes := wire.JSONBytes(request)
-	requestBuf := bytes.NewBuffer(requestBytes)
 	// log.Info(Fmt("RPC request to %v (%v): %v", c.remote, method, string(requestBytes)))
-	httpResponse, err := c.client.Post(c.address, "text/json", requestBuf)
+	httpResponse, err := c.auth.post(ctx, c.client, c.address, "text/json", requestBytes)
 	if err != nil {
 		return nil, err
 	}
@@ -104,6 +179,7 @@ func (c *ClientJSONRPC) call(method string, params []interface{}, result interfa
 type ClientURI struct {
 	address string
 	client  *http.Client
+	auth    requestAuth
 }
 
 func NewClientURI(logger *zap.Logger, remote string) *ClientURI {
@@ -114,6 +190,21 @@ func NewClientURI(logger *zap.Logger, remote string) *ClientURI {
 	}
 }
 
+// SetAuthToken sends the API token with every request
+func (c *ClientURI) SetAuthToken(token string) {
+	c.auth.token = token
+}
+
+// SetSigner signs every request with the key
+func (c *ClientURI) SetSigner(privKey crypto.PrivKeyEd25519) {
+	c.auth.signer, c.auth.signed = privKey, true
+}
+
+// SetTLSConfig is used for https:// remotes, e.g. to trust a private CA or present a client certificate
+func (c *ClientURI) SetTLSConfig(tlsConfig *tls.Config) {
+	setTLSConfig(c.client, tlsConfig)
+}
+
 func (c *ClientURI) Call(method string, params map[string]interface{}, result interface{}) (interface{}, error) {
 	return c.call(method, params, result)
 }
@@ -124,7 +215,7 @@ func (c *ClientURI) call(method string, params map[string]interface{}, result in
 		return nil, err
 	}
 	// log.Info(Fmt("URI request to %v (%v): %v", c.address, method, values))
-	resp, err := c.client.PostForm(c.address+"/"+method, values)
+	resp, err := c.auth.post(context.Background(), c.client, c.address+"/"+method, "application/x-www-form-urlencoded", []byte(values.Encode()))
 	if err != nil {
 		return nil, err
 	}
@@ -149,9 +240,9 @@ func unmarshalResponseBytes(responseBytes []byte, result interface{}) (interface
 	if err != nil {
 		return nil, errors.New(Fmt("Error unmarshalling rpc response: %v", err))
 	}
-	errorStr := response.Error
-	if errorStr != "" {
-		return nil, errors.New(Fmt("Response error: %v", errorStr))
+	// returned as is so that callers can look at the code and data
+	if response.Error != nil {
+		return nil, response.Error
 	}
 
 	// unmarshal the RawMessage into the result
@@ -2,7 +2,6 @@ package rpcclient
 
 import (
 	"encoding/json"
-	"fmt"
 	"net"
 	"net/http"
 	"time"
@@ -25,6 +24,7 @@ type WSClient struct {
 	Address  string // IP:PORT or /path/to/socket
 	Endpoint string // /websocket/url/endpoint
 	Dialer   func(string, string) (net.Conn, error)
+	Header   http.Header // sent with the handshake, e.g. the Authorization token
 	*websocket.Conn
 	ResultsCh chan json.RawMessage // closes upon WSClient.Stop()
 	ErrorsCh  chan error           // closes upon WSClient.Stop()
@@ -67,6 +67,9 @@ func (wsc *WSClient) dial() error {
 		Proxy:   http.ProxyFromEnvironment,
 	}
 	rHeader := http.Header{}
+	for k, v := range wsc.Header {
+		rHeader[k] = v
+	}
 	con, _, err := dialer.Dial("ws://"+wsc.Address+wsc.Endpoint, rHeader)
 	if err != nil {
 		return err
@@ -87,6 +90,10 @@ func (wsc *WSClient) dial() error {
 
 func (wsc *WSClient) OnStop() {
 	wsc.BaseService.OnStop()
+	// unblocks the read of receiveEventsRoutine
+	if wsc.Conn != nil {
+		wsc.Conn.Close()
+	}
 	// ResultsCh/ErrorsCh is closed in receiveEventsRoutine.
 }
 
@@ -105,8 +112,8 @@ func (wsc *WSClient) receiveEventsRoutine() {
 				wsc.ErrorsCh <- err
 				continue
 			}
-			if response.Error != "" {
-				wsc.ErrorsCh <- fmt.Errorf(response.Error)
+			if response.Error != nil {
+				wsc.ErrorsCh <- response.Error
 				continue
 			}
 			wsc.ResultsCh <- *response.Result
@@ -118,6 +125,16 @@ func (wsc *WSClient) receiveEventsRoutine() {
 	close(wsc.ErrorsCh)
 }
 
+// Call sends a request, its result comes back on ResultsCh
+func (wsc *WSClient) Call(method string, params []interface{}) error {
+	return wsc.WriteJSON(rpctypes.RPCRequest{
+		JSONRPC: "2.0",
+		ID:      "",
+		Method:  method,
+		Params:  params,
+	})
+}
+
 // subscribe to an event
 func (wsc *WSClient) Subscribe(eventid string) error {
 	err := wsc.WriteJSON(rpctypes.RPCRequest{
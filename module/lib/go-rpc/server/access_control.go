@@ -0,0 +1,248 @@
+package rpcserver
+
+import (
+	"bytes"
+	"context"
+	"crypto/subtle"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/DelosIsland/core/module/lib/go-crypto"
+	. "github.com/DelosIsland/core/module/lib/go-rpc/types"
+)
+
+// Method groups used by the access control lists.
+const (
+	GroupInfo      = "info"
+	GroupBroadcast = "broadcast"
+	GroupUnsafe    = "unsafe"
+	GroupSpecialOP = "specialop"
+)
+
+// signed requests older or newer than this are refused
+const defaultMaxClockSkew = 5 * time.Minute
+
+// larger request bodies are refused before the caller is authenticated
+const defaultMaxBodyBytes = 1 << 20
+
+var (
+	ErrRPCBadCredentials = errors.New("invalid RPC credentials")
+	ErrRPCSignatureStale = errors.New("RPC request signature expired")
+	ErrRPCReplayed       = errors.New("RPC request nonce already used")
+)
+
+type callerKey struct{}
+
+// caller is stored in the request context by AccessControl.Handler
+type caller struct {
+	ac            *AccessControl
+	authenticated bool
+}
+
+// AccessControl authenticates RPC callers and decides which method groups they may call.
+// Methods missing from the group map are considered unsafe.
+// Not goroutine safe while being configured, only the nonces change once the server runs.
+type AccessControl struct {
+	methodGroups map[string]string // method -> group
+	publicGroups map[string]bool   // callable by anyone
+	authGroups   map[string]bool   // callable once authenticated
+
+	tokens       map[string]bool
+	pubKeys      map[string]bool // hex pubkey
+	pubKeyFilter func(crypto.PubKeyEd25519) bool
+
+	maxClockSkew time.Duration
+	maxBodyBytes int64
+	now          func() time.Time // not time.Now so we can override with tests
+
+	// nonces of the signed requests still within the clock skew, by pubkey and nonce
+	nonceMtx sync.Mutex
+	nonces   map[string]time.Time
+	pruned   time.Time
+}
+
+func NewAccessControl(methodGroups map[string]string, publicGroups, authGroups []string) *AccessControl {
+	return &AccessControl{
+		methodGroups: methodGroups,
+		publicGroups: toSet(publicGroups),
+		authGroups:   toSet(authGroups),
+		tokens:       make(map[string]bool),
+		pubKeys:      make(map[string]bool),
+		nonces:       make(map[string]time.Time),
+		maxClockSkew: defaultMaxClockSkew,
+		maxBodyBytes: defaultMaxBodyBytes,
+		now:          time.Now,
+	}
+}
+
+func toSet(list []string) map[string]bool {
+	set := make(map[string]bool, len(list))
+	for _, s := range list {
+		if s = strings.TrimSpace(s); s != "" {
+			set[s] = true
+		}
+	}
+	return set
+}
+
+// AddTokens allows callers presenting one of the API tokens.
+func (ac *AccessControl) AddTokens(tokens ...string) {
+	for _, t := range tokens {
+		if t = strings.TrimSpace(t); t != "" {
+			ac.tokens[t] = true
+		}
+	}
+}
+
+// AddPubKeys allows requests signed by one of the keys.
+func (ac *AccessControl) AddPubKeys(pubKeys ...crypto.PubKeyEd25519) {
+	for _, pk := range pubKeys {
+		ac.pubKeys[pk.KeyString()] = true
+	}
+}
+
+// SetPubKeyFilter allows requests signed by keys accepted by f, on top of the configured ones.
+func (ac *AccessControl) SetPubKeyFilter(f func(crypto.PubKeyEd25519) bool) {
+	ac.pubKeyFilter = f
+}
+
+// SetMaxBodyBytes sets the size of the largest request body Handler reads
+func (ac *AccessControl) SetMaxBodyBytes(n int64) {
+	ac.maxBodyBytes = n
+}
+
+// Authenticate checks the credentials of a request.
+// It returns false without error when the request carries none,
+// a client certificate verified by the TLS layer counts as credentials.
+func (ac *AccessControl) Authenticate(r *http.Request, body []byte) (bool, error) {
+	if auth := r.Header.Get("Authorization"); auth != "" {
+		token := strings.TrimSpace(strings.TrimPrefix(auth, "Bearer "))
+		for t := range ac.tokens {
+			if subtle.ConstantTimeCompare([]byte(t), []byte(token)) == 1 {
+				return true, nil
+			}
+		}
+		return false, ErrRPCBadCredentials
+	}
+	if r.Header.Get(HeaderSignature) != "" {
+		return ac.verifySignature(r, body)
+	}
+	if r.TLS != nil && len(r.TLS.VerifiedChains) > 0 {
+		return true, nil
+	}
+	return false, nil
+}
+
+func (ac *AccessControl) verifySignature(r *http.Request, body []byte) (bool, error) {
+	pkBytes, err := hex.DecodeString(r.Header.Get(HeaderPubKey))
+	if err != nil || len(pkBytes) != 32 {
+		return false, ErrRPCBadCredentials
+	}
+	sigBytes, err := hex.DecodeString(r.Header.Get(HeaderSignature))
+	if err != nil || len(sigBytes) != 64 {
+		return false, ErrRPCBadCredentials
+	}
+	timestamp := r.Header.Get(HeaderTimestamp)
+	ts, err := strconv.ParseInt(timestamp, 10, 64)
+	if err != nil {
+		return false, ErrRPCBadCredentials
+	}
+	nonce := r.Header.Get(HeaderNonce)
+	if nonce == "" || len(nonce) > MaxNonceLength {
+		return false, ErrRPCBadCredentials
+	}
+	skew := ac.now().Sub(time.Unix(ts, 0))
+	if skew > ac.maxClockSkew || skew < -ac.maxClockSkew {
+		return false, ErrRPCSignatureStale
+	}
+
+	var pubKey crypto.PubKeyEd25519
+	var sig crypto.SignatureEd25519
+	copy(pubKey[:], pkBytes)
+	copy(sig[:], sigBytes)
+	if !ac.pubKeys[pubKey.KeyString()] && (ac.pubKeyFilter == nil || !ac.pubKeyFilter(pubKey)) {
+		return false, ErrRPCBadCredentials
+	}
+	if !pubKey.VerifyBytes(RequestSignBytes(timestamp, nonce, r.URL.RequestURI(), body), sig) {
+		return false, ErrRPCBadCredentials
+	}
+	if !ac.useNonce(pubKey.KeyString()+"/"+nonce, time.Unix(ts, 0)) {
+		return false, ErrRPCReplayed
+	}
+	return true, nil
+}
+
+// useNonce records the nonce of a signed request, false if it was already used.
+// A nonce is kept until its timestamp is out of the clock skew, the request can't be replayed after that.
+func (ac *AccessControl) useNonce(key string, at time.Time) bool {
+	ac.nonceMtx.Lock()
+	defer ac.nonceMtx.Unlock()
+	if now := ac.now(); now.Sub(ac.pruned) > time.Second {
+		for k, t := range ac.nonces {
+			if now.Sub(t) > ac.maxClockSkew {
+				delete(ac.nonces, k)
+			}
+		}
+		ac.pruned = now
+	}
+	if _, used := ac.nonces[key]; used {
+		return false
+	}
+	ac.nonces[key] = at
+	return true
+}
+
+// Authorize tells whether a caller may call the method.
+func (ac *AccessControl) Authorize(method string, authenticated bool) error {
+	group, ok := ac.methodGroups[method]
+	if !ok {
+		group = GroupUnsafe
+	}
+	if ac.publicGroups[group] || (authenticated && ac.authGroups[group]) {
+		return nil
+	}
+	if authenticated {
+		return fmt.Errorf("RPC method %s (%s) is not allowed", method, group)
+	}
+	return fmt.Errorf("RPC method %s (%s) requires authentication", method, group)
+}
+
+// Handler authenticates every request before handing it to next,
+// the rpc handlers then authorize each method they run.
+func (ac *AccessControl) Handler(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var body []byte
+		if r.Body != nil {
+			var err error
+			if body, err = ioutil.ReadAll(http.MaxBytesReader(w, r.Body, ac.maxBodyBytes)); err != nil {
+				WriteRPCResponseHTTP(w, NewRPCErrorResponse(nil, NewRPCError(CodeInvalidRequest, fmt.Sprintf("Error reading request: %v", err.Error()))))
+				return
+			}
+			r.Body = ioutil.NopCloser(bytes.NewReader(body))
+		}
+		authenticated, err := ac.Authenticate(r, body)
+		if err != nil {
+			writeRPCResponseHTTPStatus(w, http.StatusUnauthorized, NewRPCErrorResponse(nil, NewRPCError(CodeUnauthorized, err.Error())))
+			return
+		}
+		ctx := context.WithValue(r.Context(), callerKey{}, &caller{ac: ac, authenticated: authenticated})
+		next.ServeHTTP(w, r.WithContext(ctx))
+	})
+}
+
+// CheckAccess authorizes method for the caller of r.
+// Requests which didn't go through AccessControl.Handler are always allowed.
+func CheckAccess(r *http.Request, method string) error {
+	c, ok := r.Context().Value(callerKey{}).(*caller)
+	if !ok {
+		return nil
+	}
+	return c.ac.Authorize(method, c.authenticated)
+}
@@ -0,0 +1,129 @@
+package rpcserver
+
+import (
+	"encoding/hex"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/DelosIsland/core/module/lib/go-crypto"
+	. "github.com/DelosIsland/core/module/lib/go-rpc/types"
+)
+
+var testMethodGroups = map[string]string{
+	"status":             GroupInfo,
+	"broadcast_tx_sync":  GroupBroadcast,
+	"request_special_op": GroupSpecialOP,
+}
+
+func TestAccessControlAuthorize(t *testing.T) {
+	ac := NewAccessControl(testMethodGroups, []string{GroupInfo}, []string{GroupInfo, GroupBroadcast})
+
+	cases := []struct {
+		method        string
+		authenticated bool
+		allowed       bool
+	}{
+		{"status", false, true},
+		{"broadcast_tx_sync", false, false},
+		{"broadcast_tx_sync", true, true},
+		{"request_special_op", true, false},
+		{"unknown_method", true, false}, // unknown methods are unsafe
+	}
+	for _, c := range cases {
+		err := ac.Authorize(c.method, c.authenticated)
+		if (err == nil) != c.allowed {
+			t.Errorf("%s (authenticated %v): expected allowed %v, got %v", c.method, c.authenticated, c.allowed, err)
+		}
+	}
+}
+
+func TestAccessControlToken(t *testing.T) {
+	ac := NewAccessControl(testMethodGroups, nil, nil)
+	ac.AddTokens("secret")
+
+	r := httptest.NewRequest("POST", "/", nil)
+	if ok, err := ac.Authenticate(r, nil); ok || err != nil {
+		t.Errorf("Expected anonymous request, got %v %v", ok, err)
+	}
+	r.Header.Set("Authorization", "Bearer secret")
+	if ok, err := ac.Authenticate(r, nil); !ok || err != nil {
+		t.Errorf("Expected valid token to authenticate, got %v %v", ok, err)
+	}
+	r.Header.Set("Authorization", "Bearer wrong")
+	if _, err := ac.Authenticate(r, nil); err != ErrRPCBadCredentials {
+		t.Errorf("Expected bad credentials, got %v", err)
+	}
+}
+
+func TestAccessControlSignature(t *testing.T) {
+	privKey := crypto.GenPrivKeyEd25519()
+	pubKey := privKey.PubKey().(crypto.PubKeyEd25519)
+	ac := NewAccessControl(testMethodGroups, nil, nil)
+	ac.AddPubKeys(pubKey)
+
+	body := `{"method":"status"}`
+	signNonce := func(key crypto.PrivKeyEd25519, at time.Time, nonce string) (bool, error) {
+		timestamp := strconv.FormatInt(at.Unix(), 10)
+		sig := key.Sign(RequestSignBytes(timestamp, nonce, "/", []byte(body))).(crypto.SignatureEd25519)
+		pk := key.PubKey().(crypto.PubKeyEd25519)
+		r := httptest.NewRequest("POST", "/", strings.NewReader(body))
+		r.Header.Set(HeaderPubKey, hex.EncodeToString(pk[:]))
+		r.Header.Set(HeaderTimestamp, timestamp)
+		r.Header.Set(HeaderNonce, nonce)
+		r.Header.Set(HeaderSignature, hex.EncodeToString(sig[:]))
+		return ac.Authenticate(r, []byte(body))
+	}
+	sign := func(key crypto.PrivKeyEd25519, at time.Time) (bool, error) {
+		return signNonce(key, at, crypto.CRandHex(32))
+	}
+
+	if ok, err := sign(privKey, time.Now()); !ok || err != nil {
+		t.Errorf("Expected signed request to authenticate, got %v %v", ok, err)
+	}
+	if ok, err := signNonce(privKey, time.Now(), "n1"); !ok || err != nil {
+		t.Errorf("Expected a new nonce to authenticate, got %v %v", ok, err)
+	}
+	if _, err := signNonce(privKey, time.Now(), "n1"); err != ErrRPCReplayed {
+		t.Errorf("Expected a replayed request to be refused, got %v", err)
+	}
+	if _, err := signNonce(privKey, time.Now(), ""); err != ErrRPCBadCredentials {
+		t.Errorf("Expected a request without nonce to be refused, got %v", err)
+	}
+	if _, err := signNonce(privKey, time.Now(), strings.Repeat("n", MaxNonceLength+1)); err != ErrRPCBadCredentials {
+		t.Errorf("Expected a long nonce to be refused, got %v", err)
+	}
+	if _, err := sign(privKey, time.Now().Add(-time.Hour)); err != ErrRPCSignatureStale {
+		t.Errorf("Expected stale signature, got %v", err)
+	}
+	if _, err := sign(crypto.GenPrivKeyEd25519(), time.Now()); err != ErrRPCBadCredentials {
+		t.Errorf("Expected unknown key to be refused, got %v", err)
+	}
+
+	ac.SetPubKeyFilter(func(crypto.PubKeyEd25519) bool { return true })
+	if ok, err := sign(crypto.GenPrivKeyEd25519(), time.Now()); !ok || err != nil {
+		t.Errorf("Expected key accepted by the filter to authenticate, got %v %v", ok, err)
+	}
+}
+
+func TestAccessControlHandlerBodyLimit(t *testing.T) {
+	ac := NewAccessControl(testMethodGroups, []string{GroupInfo}, nil)
+	ac.SetMaxBodyBytes(32)
+	served := 0
+	handler := ac.Handler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		served++
+	}))
+
+	handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("POST", "/", strings.NewReader(`{"method":"status"}`)))
+	if served != 1 {
+		t.Fatal("Expected a small request to be served")
+	}
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, httptest.NewRequest("POST", "/", strings.NewReader(strings.Repeat(" ", 33))))
+	if served != 1 || !strings.Contains(w.Body.String(), "too large") {
+		t.Errorf("Expected a large request to be refused, got %s", w.Body.String())
+	}
+}
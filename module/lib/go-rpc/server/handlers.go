@@ -27,7 +27,7 @@ import (
 func RegisterRPCFuncs(logger *zap.Logger, mux *http.ServeMux, funcMap map[string]*RPCFunc) {
 	// HTTP endpoints
 	for funcName, rpcFunc := range funcMap {
-		mux.HandleFunc("/"+funcName, makeHTTPHandler(logger, rpcFunc))
+		mux.HandleFunc("/"+funcName, makeHTTPHandler(logger, funcName, rpcFunc))
 	}
 
 	// JSONRPC endpoints
@@ -96,7 +96,12 @@ func funcReturnTypes(f interface{}) []reflect.Type {
 //-----------------------------------------------------------------------------
 // rpc.json
 
-// jsonrpc calls grab the given method's function info and runs reflect.Call
+// maxBatchRequests is the most requests a batch may hold
+const maxBatchRequests = 100
+
+// jsonrpc calls grab the given method's function info and runs reflect.Call.
+// A batch, an array of requests, is answered by an array of responses in the same order.
+// Notifications, the requests without id, are run but get no response.
 func makeJSONRPCHandler(logger *zap.Logger, funcMap map[string]*RPCFunc) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		b, _ := ioutil.ReadAll(r.Body)
@@ -106,44 +111,134 @@ func makeJSONRPCHandler(logger *zap.Logger, funcMap map[string]*RPCFunc) http.Ha
 			writeListOfEndpoints(w, r, funcMap)
 			return
 		}
-
-		var request RPCRequest
-		err := json.Unmarshal(b, &request)
-		if err != nil {
-			WriteRPCResponseHTTP(w, NewRPCResponse("", nil, fmt.Sprintf("Error unmarshalling request: %v", err.Error())))
+		if !json.Valid(b) {
+			WriteRPCResponseHTTP(w, NewRPCErrorResponse(nil, NewRPCError(CodeParseError, "Error unmarshalling request: invalid JSON")))
 			return
 		}
 		if len(r.URL.Path) > 1 {
-			WriteRPCResponseHTTP(w, NewRPCResponse(request.ID, nil, fmt.Sprintf("Invalid JSONRPC endpoint %s", r.URL.Path)))
+			WriteRPCResponseHTTP(w, NewRPCErrorResponse(nil, NewRPCError(CodeInvalidRequest, fmt.Sprintf("Invalid JSONRPC endpoint %s", r.URL.Path))))
 			return
 		}
-		rpcFunc := funcMap[request.Method]
-		if rpcFunc == nil {
-			WriteRPCResponseHTTP(w, NewRPCResponse(request.ID, nil, "RPC method unknown: "+request.Method))
+
+		if b = bytes.TrimSpace(b); b[0] != '[' {
+			if res, ok := handleJSONRPCRequest(logger, funcMap, r, b); ok {
+				WriteRPCResponseHTTP(w, res)
+			} else {
+				w.WriteHeader(http.StatusNoContent)
+			}
 			return
 		}
-		if rpcFunc.ws {
-			WriteRPCResponseHTTP(w, NewRPCResponse(request.ID, nil, "RPC method is only for websockets: "+request.Method))
+		var batch []json.RawMessage
+		if err := json.Unmarshal(b, &batch); err != nil || len(batch) == 0 {
+			WriteRPCResponseHTTP(w, NewRPCErrorResponse(nil, NewRPCError(CodeInvalidRequest, "Empty batch")))
 			return
 		}
-		args, err := jsonParamsToArgs(rpcFunc, request.Params)
-		if err != nil {
-			WriteRPCResponseHTTP(w, NewRPCResponse(request.ID, nil, fmt.Sprintf("Error converting json params to arguments: %v", err.Error())))
+		if len(batch) > maxBatchRequests {
+			WriteRPCResponseHTTP(w, NewRPCErrorResponse(nil, NewRPCError(CodeInvalidRequest, fmt.Sprintf("Batch of %d requests, the limit is %d", len(batch), maxBatchRequests))))
 			return
 		}
-		returns := rpcFunc.f.Call(args)
-		logger.Sugar().Debugw("HTTPJSONRPC", "method", request.Method, "args", args, "returns", returns)
-		result, err := unreflectResult(returns)
-		if err != nil {
-			WriteRPCResponseHTTP(w, NewRPCResponse(request.ID, result, fmt.Sprintf("Error unreflecting result: %v", err.Error())))
+		responses := make([]RPCResponse, 0, len(batch))
+		for _, raw := range batch {
+			if res, ok := handleJSONRPCRequest(logger, funcMap, r, raw); ok {
+				responses = append(responses, res)
+			}
+		}
+		if len(responses) == 0 {
+			w.WriteHeader(http.StatusNoContent)
 			return
 		}
-		WriteRPCResponseHTTP(w, NewRPCResponse(request.ID, result, ""))
+		writeRPCResponsesHTTP(w, responses)
+	}
+}
+
+// handleJSONRPCRequest runs a request, it returns false when it was a notification which must not be answered.
+// Requests which aren't valid JSON-RPC 2.0 are always answered.
+func handleJSONRPCRequest(logger *zap.Logger, funcMap map[string]*RPCFunc, r *http.Request, raw []byte) (RPCResponse, bool) {
+	var request RPCRequest
+	if err := json.Unmarshal(raw, &request); err != nil {
+		return NewRPCErrorResponse(nil, NewRPCError(CodeInvalidRequest, fmt.Sprintf("Error unmarshalling request: %v", err.Error()))), true
 	}
+	if request.JSONRPC != "2.0" {
+		return NewRPCErrorResponse(request.ID, NewRPCError(CodeInvalidRequest, `The jsonrpc member must be "2.0"`)), true
+	}
+	if request.Method == "" {
+		return NewRPCErrorResponse(request.ID, NewRPCError(CodeInvalidRequest, "Missing method")), true
+	}
+	// a null id is still an id, only a missing one makes a notification
+	var id struct {
+		ID json.RawMessage `json:"id"`
+	}
+	json.Unmarshal(raw, &id)
+	return runJSONRPCRequest(logger, funcMap, r, request), id.ID != nil
+}
+
+func runJSONRPCRequest(logger *zap.Logger, funcMap map[string]*RPCFunc, r *http.Request, request RPCRequest) RPCResponse {
+	rpcFunc := funcMap[request.Method]
+	if rpcFunc == nil {
+		return NewRPCErrorResponse(request.ID, NewRPCError(CodeMethodNotFound, "RPC method unknown: "+request.Method))
+	}
+	if rpcFunc.ws {
+		return NewRPCErrorResponse(request.ID, NewRPCError(CodeMethodNotFound, "RPC method is only for websockets: "+request.Method))
+	}
+	if err := CheckAccess(r, request.Method); err != nil {
+		return NewRPCErrorResponse(request.ID, NewRPCError(CodeUnauthorized, err.Error()))
+	}
+	args, err := jsonParamsToArgs(rpcFunc, request.Params)
+	if err != nil {
+		return NewRPCErrorResponse(request.ID, NewRPCError(CodeInvalidParams, fmt.Sprintf("Error converting json params to arguments: %v", err.Error())))
+	}
+	returns := rpcFunc.f.Call(args)
+	logger.Sugar().Debugw("HTTPJSONRPC", "method", request.Method, "args", args, "returns", returns)
+	result, rpcErr := unreflectResult(returns)
+	if rpcErr != nil {
+		return NewRPCErrorResponse(request.ID, rpcErr)
+	}
+	return NewRPCResponse(request.ID, result)
 }
 
-// Convert a list of interfaces to properly typed values
-func jsonParamsToArgs(rpcFunc *RPCFunc, params []interface{}) ([]reflect.Value, error) {
+// paramsList puts params in the order of argNames, they are either positional or named
+func paramsList(argNames []string, params interface{}) ([]interface{}, error) {
+	switch ps := params.(type) {
+	case nil:
+		return []interface{}{}, nil
+	case []interface{}:
+		return ps, nil
+	case map[string]interface{}:
+		list := make([]interface{}, len(argNames))
+		for i, name := range argNames {
+			p, ok := ps[name]
+			if !ok {
+				return nil, fmt.Errorf("Missing parameter %s", name)
+			}
+			list[i] = p
+		}
+		if len(ps) > len(argNames) {
+			for name := range ps {
+				if !containsString(argNames, name) {
+					return nil, fmt.Errorf("Unknown parameter %s", name)
+				}
+			}
+		}
+		return list, nil
+	}
+	return nil, fmt.Errorf("Params must be an array or an object")
+}
+
+func containsString(list []string, s string) bool {
+	for _, e := range list {
+		if e == s {
+			return true
+		}
+	}
+	return false
+}
+
+// Convert positional or named params to properly typed values
+func jsonParamsToArgs(rpcFunc *RPCFunc, rawParams interface{}) ([]reflect.Value, error) {
+	params, err := paramsList(rpcFunc.argNames, rawParams)
+	if err != nil {
+		return nil, err
+	}
 	if len(rpcFunc.argNames) != len(params) {
 		return nil, errors.New(fmt.Sprintf("Expected %v parameters (%v), got %v (%v)",
 			len(rpcFunc.argNames), rpcFunc.argNames, len(params), params))
@@ -161,10 +256,14 @@ func jsonParamsToArgs(rpcFunc *RPCFunc, params []interface{}) ([]reflect.Value,
 }
 
 // Same as above, but with the first param the websocket connection
-func jsonParamsToArgsWS(rpcFunc *RPCFunc, params []interface{}, wsCtx WSRPCContext) ([]reflect.Value, error) {
+func jsonParamsToArgsWS(rpcFunc *RPCFunc, rawParams interface{}, wsCtx WSRPCContext) ([]reflect.Value, error) {
+	params, err := paramsList(rpcFunc.argNames, rawParams)
+	if err != nil {
+		return nil, err
+	}
 	if len(rpcFunc.argNames) != len(params) {
 		return nil, errors.New(fmt.Sprintf("Expected %v parameters (%v), got %v (%v)",
-			len(rpcFunc.argNames)-1, rpcFunc.argNames[1:], len(params), params))
+			len(rpcFunc.argNames), rpcFunc.argNames, len(params), params))
 	}
 	values := make([]reflect.Value, len(params)+1)
 	values[0] = reflect.ValueOf(wsCtx)
@@ -195,29 +294,33 @@ func _jsonObjectToArg(ty reflect.Type, object interface{}) (reflect.Value, error
 // rpc.http
 
 // convert from a function name to the http handler
-func makeHTTPHandler(logger *zap.Logger, rpcFunc *RPCFunc) func(http.ResponseWriter, *http.Request) {
+func makeHTTPHandler(logger *zap.Logger, funcName string, rpcFunc *RPCFunc) func(http.ResponseWriter, *http.Request) {
 	// Exception for websocket endpoints
 	if rpcFunc.ws {
 		return func(w http.ResponseWriter, r *http.Request) {
-			WriteRPCResponseHTTP(w, NewRPCResponse("", nil, "This RPC method is only for websockets"))
+			WriteRPCResponseHTTP(w, NewRPCErrorResponse("", NewRPCError(CodeMethodNotFound, "This RPC method is only for websockets")))
 		}
 	}
 	// All other endpoints
 	return func(w http.ResponseWriter, r *http.Request) {
 		logger.Sugar().Debugw("HTTP HANDLER", "req", r)
+		if err := CheckAccess(r, funcName); err != nil {
+			WriteRPCResponseHTTP(w, NewRPCErrorResponse("", NewRPCError(CodeUnauthorized, err.Error())))
+			return
+		}
 		args, err := httpParamsToArgs(rpcFunc, r)
 		if err != nil {
-			WriteRPCResponseHTTP(w, NewRPCResponse("", nil, fmt.Sprintf("Error converting http params to args: %v", err.Error())))
+			WriteRPCResponseHTTP(w, NewRPCErrorResponse("", NewRPCError(CodeInvalidParams, fmt.Sprintf("Error converting http params to args: %v", err.Error()))))
 			return
 		}
 		returns := rpcFunc.f.Call(args)
 		logger.Sugar().Debugw("HTTPRestRPC", "method", r.URL.Path, "args", args, "returns", returns)
-		result, err := unreflectResult(returns)
-		if err != nil {
-			WriteRPCResponseHTTP(w, NewRPCResponse("", nil, fmt.Sprintf("Error unreflecting result: %v", err.Error())))
+		result, rpcErr := unreflectResult(returns)
+		if rpcErr != nil {
+			WriteRPCResponseHTTP(w, NewRPCErrorResponse("", rpcErr))
 			return
 		}
-		WriteRPCResponseHTTP(w, NewRPCResponse("", result, ""))
+		WriteRPCResponseHTTP(w, NewRPCResponse("", result))
 	}
 }
 
@@ -322,8 +425,9 @@ type wsConnection struct {
 	readTimeout *time.Timer
 	pingTicker  *time.Ticker
 
-	funcMap map[string]*RPCFunc
-	evsw    events.EventSwitch
+	funcMap   map[string]*RPCFunc
+	evsw      events.EventSwitch
+	authorize func(method string) error // nil allows every method
 
 	logger  *zap.Logger
 	slogger *zap.SugaredLogger
@@ -413,6 +517,11 @@ func (wsc *wsConnection) WriteRPCResponse(resp RPCResponse) {
 	}
 }
 
+// Implements WSRPCConnection
+func (wsc *wsConnection) Done() <-chan struct{} {
+	return wsc.Quit
+}
+
 // Implements WSRPCConnection
 // Nonblocking write.
 // Goroutine-safe
@@ -454,7 +563,7 @@ func (wsc *wsConnection) readRoutine() {
 			err = json.Unmarshal(in, &request)
 			if err != nil {
 				errStr := fmt.Sprintf("Error unmarshaling data: %s", err.Error())
-				wsc.WriteRPCResponse(NewRPCResponse(request.ID, nil, errStr))
+				wsc.WriteRPCResponse(NewRPCErrorResponse(nil, NewRPCError(CodeParseError, errStr)))
 				continue
 			}
 
@@ -462,9 +571,15 @@ func (wsc *wsConnection) readRoutine() {
 
 			rpcFunc := wsc.funcMap[request.Method]
 			if rpcFunc == nil {
-				wsc.WriteRPCResponse(NewRPCResponse(request.ID, nil, "RPC method unknown: "+request.Method))
+				wsc.WriteRPCResponse(NewRPCErrorResponse(request.ID, NewRPCError(CodeMethodNotFound, "RPC method unknown: "+request.Method)))
 				continue
 			}
+			if wsc.authorize != nil {
+				if err := wsc.authorize(request.Method); err != nil {
+					wsc.WriteRPCResponse(NewRPCErrorResponse(request.ID, NewRPCError(CodeUnauthorized, err.Error())))
+					continue
+				}
+			}
 			var args []reflect.Value
 			if rpcFunc.ws {
 				wsCtx := WSRPCContext{Request: request, WSRPCConnection: wsc}
@@ -473,17 +588,17 @@ func (wsc *wsConnection) readRoutine() {
 				args, err = jsonParamsToArgs(rpcFunc, request.Params)
 			}
 			if err != nil {
-				wsc.WriteRPCResponse(NewRPCResponse(request.ID, nil, err.Error()))
+				wsc.WriteRPCResponse(NewRPCErrorResponse(request.ID, NewRPCError(CodeInvalidParams, err.Error())))
 				continue
 			}
 			returns := rpcFunc.f.Call(args)
 			wsc.slogger.Infow("WSJSONRPC", "method", request.Method, "args", args, "returns", returns)
-			result, err := unreflectResult(returns)
-			if err != nil {
-				wsc.WriteRPCResponse(NewRPCResponse(request.ID, nil, err.Error()))
+			result, rpcErr := unreflectResult(returns)
+			if rpcErr != nil {
+				wsc.WriteRPCResponse(NewRPCErrorResponse(request.ID, rpcErr))
 				continue
 			} else {
-				wsc.WriteRPCResponse(NewRPCResponse(request.ID, result, ""))
+				wsc.WriteRPCResponse(NewRPCResponse(request.ID, result))
 				continue
 			}
 
@@ -564,6 +679,7 @@ func (wm *WebsocketManager) WebsocketHandler(w http.ResponseWriter, r *http.Requ
 
 	// register connection
 	con := NewWSConnection(wm.logger, wsConn, wm.funcMap, wm.evsw)
+	con.authorize = func(method string) error { return CheckAccess(r, method) }
 	wm.logger.Info("New websocket connection", zap.String("remote", con.remoteAddr))
 	con.Start() // Blocking
 }
@@ -571,11 +687,15 @@ func (wm *WebsocketManager) WebsocketHandler(w http.ResponseWriter, r *http.Requ
 // rpc.websocket
 //-----------------------------------------------------------------------------
 
-// NOTE: assume returns is result struct and error. If error is not nil, return it
-func unreflectResult(returns []reflect.Value) (interface{}, error) {
+// NOTE: assume returns is result struct and error. If error is not nil, return it,
+// an *RPCError as is, any other error as a server error.
+func unreflectResult(returns []reflect.Value) (interface{}, *RPCError) {
 	errV := returns[1]
 	if errV.Interface() != nil {
-		return nil, fmt.Errorf("%v", errV.Interface())
+		if rpcErr, ok := errV.Interface().(*RPCError); ok {
+			return nil, rpcErr
+		}
+		return nil, NewRPCError(CodeServerError, fmt.Sprintf("%v", errV.Interface()))
 	}
 	rv := returns[0]
 	// the result is a registered interface,
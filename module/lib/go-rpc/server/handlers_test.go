@@ -0,0 +1,149 @@
+package rpcserver
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"go.uber.org/zap"
+
+	. "github.com/DelosIsland/core/module/lib/go-rpc/types"
+)
+
+type testResult struct {
+	Sum int `json:"sum"`
+}
+
+var testRoutes = map[string]*RPCFunc{
+	"add": NewRPCFunc(func(a, b int) (*testResult, error) {
+		return &testResult{Sum: a + b}, nil
+	}, "a,b"),
+	"fail": NewRPCFunc(func() (*testResult, error) {
+		return nil, fmt.Errorf("failed")
+	}, ""),
+	"refuse": NewRPCFunc(func() (*testResult, error) {
+		return nil, &RPCError{Code: CodeAppError, Message: "refused", Data: map[string]int{"code": 7}}
+	}, ""),
+}
+
+func serveJSONRPC(t *testing.T, body string) []byte {
+	w := httptest.NewRecorder()
+	makeJSONRPCHandler(zap.NewNop(), testRoutes)(w, httptest.NewRequest("POST", "/", strings.NewReader(body)))
+	return w.Body.Bytes()
+}
+
+func TestJSONRPCNotifications(t *testing.T) {
+	for _, body := range []string{
+		`{"jsonrpc":"2.0","method":"add","params":[1,2]}`,
+		`{"jsonrpc":"2.0","method":"fail","params":[]}`,
+		`[{"jsonrpc":"2.0","method":"add","params":[1,2]},{"jsonrpc":"2.0","method":"sub"}]`,
+	} {
+		w := httptest.NewRecorder()
+		makeJSONRPCHandler(zap.NewNop(), testRoutes)(w, httptest.NewRequest("POST", "/", strings.NewReader(body)))
+		if w.Code != http.StatusNoContent || w.Body.Len() != 0 {
+			t.Errorf("%s: expected no response, got %d %s", body, w.Code, w.Body.Bytes())
+		}
+	}
+
+	// only the requests with an id, even a null one, are answered
+	body := `[
+		{"jsonrpc":"2.0","method":"add","params":[1,2]},
+		{"jsonrpc":"2.0","id":null,"method":"add","params":[3,4]}
+	]`
+	var res []RPCResponse
+	if err := json.Unmarshal(serveJSONRPC(t, body), &res); err != nil {
+		t.Fatal(err)
+	}
+	if len(res) != 1 || res[0].Result == nil || string(*res[0].Result) != `{"sum":7}` {
+		t.Errorf("Expected the response of the request with a null id only, got %+v", res)
+	}
+}
+
+func TestJSONRPCParams(t *testing.T) {
+	for _, body := range []string{
+		`{"jsonrpc":"2.0","id":1,"method":"add","params":[1,2]}`,
+		`{"jsonrpc":"2.0","id":1,"method":"add","params":{"b":2,"a":1}}`,
+	} {
+		var res RPCResponse
+		if err := json.Unmarshal(serveJSONRPC(t, body), &res); err != nil {
+			t.Fatal(err)
+		}
+		if res.Error != nil || res.Result == nil || string(*res.Result) != `{"sum":3}` {
+			t.Errorf("%s: unexpected response %+v", body, res)
+		}
+		if res.ID != float64(1) {
+			t.Errorf("%s: expected the id to be echoed, got %v", body, res.ID)
+		}
+	}
+}
+
+func TestJSONRPCErrors(t *testing.T) {
+	cases := []struct {
+		body string
+		code int
+	}{
+		{`{"jsonrpc":"2.0","id":1,"method":"add"`, CodeParseError},
+		{`{"jsonrpc":"2.0","id":1}`, CodeInvalidRequest},
+		{`{"id":1,"method":"add","params":[1,2]}`, CodeInvalidRequest},
+		{`{"jsonrpc":"1.0","id":1,"method":"add","params":[1,2]}`, CodeInvalidRequest},
+		{`{"jsonrpc":"2.0","id":1,"method":"sub","params":[]}`, CodeMethodNotFound},
+		{`{"jsonrpc":"2.0","id":1,"method":"add","params":{"a":1}}`, CodeInvalidParams},
+		{`{"jsonrpc":"2.0","id":1,"method":"add","params":{"a":1,"b":2,"c":3}}`, CodeInvalidParams},
+		{`{"jsonrpc":"2.0","id":1,"method":"add","params":["x",2]}`, CodeInvalidParams},
+		{`{"jsonrpc":"2.0","id":1,"method":"fail","params":[]}`, CodeServerError},
+		{`{"jsonrpc":"2.0","id":1,"method":"refuse","params":[]}`, CodeAppError},
+		{`[]`, CodeInvalidRequest},
+	}
+	for _, c := range cases {
+		var res RPCResponse
+		if err := json.Unmarshal(serveJSONRPC(t, c.body), &res); err != nil {
+			t.Fatal(err)
+		}
+		if res.Error == nil || res.Error.Code != c.code || res.Result != nil {
+			t.Errorf("%s: expected error %d, got %+v", c.body, c.code, res)
+		}
+	}
+
+	var res RPCResponse
+	json.Unmarshal(serveJSONRPC(t, `{"jsonrpc":"2.0","id":1,"method":"refuse","params":[]}`), &res)
+	var data struct{ Code int }
+	if err := res.Error.DecodeData(&data); err != nil || data.Code != 7 {
+		t.Errorf("Expected the error data to carry code 7, got %v %v", data, err)
+	}
+}
+
+func TestJSONRPCBatch(t *testing.T) {
+	body := `[
+		{"jsonrpc":"2.0","id":"a","method":"add","params":[1,2]},
+		{"jsonrpc":"2.0","id":"b","method":"fail","params":[]},
+		1
+	]`
+	var res []RPCResponse
+	if err := json.Unmarshal(serveJSONRPC(t, body), &res); err != nil {
+		t.Fatal(err)
+	}
+	if len(res) != 3 {
+		t.Fatalf("Expected 3 responses, got %d", len(res))
+	}
+	if res[0].ID != "a" || res[0].Error != nil {
+		t.Errorf("Unexpected first response %+v", res[0])
+	}
+	if res[1].ID != "b" || res[1].Error == nil || res[1].Error.Code != CodeServerError {
+		t.Errorf("Unexpected second response %+v", res[1])
+	}
+	if res[2].Error == nil || res[2].Error.Code != CodeInvalidRequest {
+		t.Errorf("Unexpected third response %+v", res[2])
+	}
+
+	large := "[" + strings.TrimSuffix(strings.Repeat(`{"jsonrpc":"2.0","id":1,"method":"add","params":[1,2]},`, maxBatchRequests+1), ",") + "]"
+	var tooLarge RPCResponse
+	if err := json.Unmarshal(serveJSONRPC(t, large), &tooLarge); err != nil {
+		t.Fatal(err)
+	}
+	if tooLarge.Error == nil || tooLarge.Error.Code != CodeInvalidRequest {
+		t.Errorf("Expected a batch over the limit to be refused, got %+v", tooLarge)
+	}
+}
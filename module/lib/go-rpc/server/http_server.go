@@ -3,8 +3,11 @@ package rpcserver
 
 import (
 	"bufio"
+	"crypto/tls"
+	"crypto/x509"
 	"encoding/json"
 	"fmt"
+	"io/ioutil"
 	"net"
 	"net/http"
 	"runtime/debug"
@@ -19,6 +22,11 @@ import (
 )
 
 func StartHTTPServer(logger *zap.Logger, listenAddr string, handler http.Handler) (listener net.Listener, err error) {
+	return StartHTTPSServer(logger, listenAddr, handler, nil)
+}
+
+// StartHTTPSServer is StartHTTPServer over TLS, plain HTTP is served if tlsConfig is nil.
+func StartHTTPSServer(logger *zap.Logger, listenAddr string, handler http.Handler, tlsConfig *tls.Config) (listener net.Listener, err error) {
 	// listenAddr should be fully formed including tcp:// or unix:// prefix
 	var proto, addr string
 	parts := strings.SplitN(listenAddr, "://", 2)
@@ -38,6 +46,9 @@ func StartHTTPServer(logger *zap.Logger, listenAddr string, handler http.Handler
 	if err != nil {
 		return nil, fmt.Errorf("Failed to listen to %v: %v", listenAddr, err)
 	}
+	if tlsConfig != nil {
+		listener = tls.NewListener(listener, tlsConfig)
+	}
 
 	go func() {
 		// res := http.Serve(
@@ -51,14 +62,52 @@ func StartHTTPServer(logger *zap.Logger, listenAddr string, handler http.Handler
 	return listener, nil
 }
 
+// NewServerTLSConfig loads the server certificate.
+// Clients must present a certificate signed by clientCAFile when it is set (mutual TLS).
+func NewServerTLSConfig(certFile, keyFile, clientCAFile string) (*tls.Config, error) {
+	cert, err := tls.LoadX509KeyPair(certFile, keyFile)
+	if err != nil {
+		return nil, fmt.Errorf("Failed to load TLS certificate: %v", err)
+	}
+	tlsConfig := &tls.Config{
+		Certificates: []tls.Certificate{cert},
+		MinVersion:   tls.VersionTLS12,
+	}
+	if clientCAFile != "" {
+		caBytes, err := ioutil.ReadFile(clientCAFile)
+		if err != nil {
+			return nil, fmt.Errorf("Failed to read client CA: %v", err)
+		}
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(caBytes) {
+			return nil, fmt.Errorf("No certificate found in %v", clientCAFile)
+		}
+		tlsConfig.ClientCAs = pool
+		tlsConfig.ClientAuth = tls.RequireAndVerifyClientCert
+	}
+	return tlsConfig, nil
+}
+
 func WriteRPCResponseHTTP(w http.ResponseWriter, res RPCResponse) {
+	writeRPCResponseHTTPStatus(w, 200, res)
+}
+
+func writeRPCResponsesHTTP(w http.ResponseWriter, res []RPCResponse) {
+	writeJSONHTTP(w, 200, res)
+}
+
+func writeRPCResponseHTTPStatus(w http.ResponseWriter, status int, res RPCResponse) {
+	writeJSONHTTP(w, status, res)
+}
+
+func writeJSONHTTP(w http.ResponseWriter, status int, res interface{}) {
 	// jsonBytes := wire.JSONBytesPretty(res)
 	jsonBytes, err := json.Marshal(res)
 	if err != nil {
 		panic(err)
 	}
 	w.Header().Set("Content-Type", "application/json")
-	w.WriteHeader(200)
+	w.WriteHeader(status)
 	w.Write(jsonBytes)
 }
 
@@ -93,7 +142,7 @@ func RecoverAndLogHandler(logger *zap.Logger, handler http.Handler) http.Handler
 					// For the rest,
 					logger.Sugar().Errorw("Panic in RPC HTTP handler", "error", e, "stack", string(debug.Stack()))
 					rww.WriteHeader(http.StatusInternalServerError)
-					WriteRPCResponseHTTP(rww, NewRPCResponse("", nil, Fmt("Internal Server Error: %v", e)))
+					WriteRPCResponseHTTP(rww, NewRPCErrorResponse(nil, NewRPCError(CodeInternalError, Fmt("Internal Server Error: %v", e))))
 				}
 			}
 
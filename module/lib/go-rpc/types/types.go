@@ -2,20 +2,24 @@ package rpctypes
 
 import (
 	"encoding/json"
+	"fmt"
 	"strings"
 
 	"github.com/DelosIsland/core/module/lib/go-events"
 	"github.com/DelosIsland/core/module/lib/go-wire"
 )
 
+// RPCRequest is a JSON-RPC 2.0 request.
+// ID is a string, a number or null and is echoed in the response.
+// Params are positional, a []interface{}, or named after the arguments, a map[string]interface{}.
 type RPCRequest struct {
-	JSONRPC string        `json:"jsonrpc"`
-	ID      string        `json:"id"`
-	Method  string        `json:"method"`
-	Params  []interface{} `json:"params"`
+	JSONRPC string      `json:"jsonrpc"`
+	ID      interface{} `json:"id"`
+	Method  string      `json:"method"`
+	Params  interface{} `json:"params"`
 }
 
-func NewRPCRequest(id string, method string, params []interface{}) RPCRequest {
+func NewRPCRequest(id interface{}, method string, params interface{}) RPCRequest {
 	return RPCRequest{
 		JSONRPC: "2.0",
 		ID:      id,
@@ -42,14 +46,58 @@ type Result interface {
 
 //----------------------------------------
 
+// JSON-RPC 2.0 error codes, -32000 to -32099 are left to the implementation
+const (
+	CodeParseError     = -32700
+	CodeInvalidRequest = -32600
+	CodeMethodNotFound = -32601
+	CodeInvalidParams  = -32602
+	CodeInternalError  = -32603
+
+	// CodeServerError is returned when the method failed
+	CodeServerError = -32000
+	// CodeUnauthorized is returned when the caller may not call the method
+	CodeUnauthorized = -32001
+	// CodeAppError is returned when the application refused the request, the data tells why
+	CodeAppError = -32002
+	// CodeNotFound is returned when the requested item, e.g. a block, doesn't exist
+	CodeNotFound = -32003
+)
+
+// RPCError is the error object of a response, it is also returned as the error of client calls.
+// Methods may return an *RPCError to choose the code and data.
+type RPCError struct {
+	Code    int         `json:"code"`
+	Message string      `json:"message"`
+	Data    interface{} `json:"data,omitempty"`
+}
+
+func NewRPCError(code int, message string) *RPCError {
+	return &RPCError{Code: code, Message: message}
+}
+
+func (e *RPCError) Error() string {
+	return fmt.Sprintf("%s (code %d)", e.Message, e.Code)
+}
+
+// DecodeData unmarshals the data into v, e.g. once a client got it as a generic JSON value
+func (e *RPCError) DecodeData(v interface{}) error {
+	buf, err := json.Marshal(e.Data)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(buf, v)
+}
+
+// RPCResponse carries either the result or the error of a request
 type RPCResponse struct {
 	JSONRPC string           `json:"jsonrpc"`
-	ID      string           `json:"id"`
-	Result  *json.RawMessage `json:"result"`
-	Error   string           `json:"error"`
+	ID      interface{}      `json:"id"`
+	Result  *json.RawMessage `json:"result,omitempty"`
+	Error   *RPCError        `json:"error,omitempty"`
 }
 
-func NewRPCResponse(id string, res interface{}, err string) RPCResponse {
+func NewRPCResponse(id interface{}, res interface{}) RPCResponse {
 	var raw *json.RawMessage
 	if res != nil {
 		rawMsg := json.RawMessage(wire.JSONBytes(res))
@@ -59,6 +107,13 @@ func NewRPCResponse(id string, res interface{}, err string) RPCResponse {
 		JSONRPC: "2.0",
 		ID:      id,
 		Result:  raw,
+	}
+}
+
+func NewRPCErrorResponse(id interface{}, err *RPCError) RPCResponse {
+	return RPCResponse{
+		JSONRPC: "2.0",
+		ID:      id,
 		Error:   err,
 	}
 }
@@ -71,6 +126,9 @@ type WSRPCConnection interface {
 	GetEventSwitch() events.EventSwitch
 	WriteRPCResponse(resp RPCResponse)
 	TryWriteRPCResponse(resp RPCResponse) bool
+	// Done is closed when the connection stops, for the subscriptions
+	// which aren't listeners of the EventSwitch to end with it
+	Done() <-chan struct{}
 }
 
 // websocket-only RPCFuncs take this as the first parameter.
@@ -79,6 +137,38 @@ type WSRPCContext struct {
 	WSRPCConnection
 }
 
+//----------------------------------------
+// authentication
+//
+// A request is authenticated either with an API token,
+// "Authorization: Bearer <token>", or by signing it with an ed25519 key.
+// Signed requests carry the hex public key, the unix timestamp, a random nonce
+// and the hex signature of RequestSignBytes in the headers below.
+// A nonce is accepted once per key while the timestamp is fresh.
+
+const (
+	HeaderPubKey    = "X-Rpc-Pubkey"
+	HeaderTimestamp = "X-Rpc-Timestamp"
+	HeaderNonce     = "X-Rpc-Nonce"
+	HeaderSignature = "X-Rpc-Signature"
+)
+
+// MaxNonceLength is the longest nonce header a signed request may carry
+const MaxNonceLength = 64
+
+// RequestSignBytes is what the caller signs: the timestamp and the nonce headers,
+// the request uri (path and query) and the body, separated by newlines.
+func RequestSignBytes(timestamp, nonce, uri string, body []byte) []byte {
+	buf := make([]byte, 0, len(timestamp)+len(nonce)+len(uri)+len(body)+3)
+	buf = append(buf, timestamp...)
+	buf = append(buf, '\n')
+	buf = append(buf, nonce...)
+	buf = append(buf, '\n')
+	buf = append(buf, uri...)
+	buf = append(buf, '\n')
+	return append(buf, body...)
+}
+
 //----------------------------------------
 // sockets
 //
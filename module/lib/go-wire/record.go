@@ -0,0 +1,100 @@
+package wire
+
+import (
+	"encoding/binary"
+	"fmt"
+	"hash/crc32"
+	"io"
+)
+
+// A record is crc32c (4 bytes) | length (4 bytes) | go-wire binary of a value,
+// the framing of the files read back after a crash or a copy.
+const RecordHeaderSize = 8
+
+var crc32c = crc32.MakeTable(crc32.Castagnoli)
+
+// RecordCorruption is returned for a record which is torn or doesn't match its checksum
+type RecordCorruption struct {
+	Offset int64 // of the record from the start of the reader
+	Reason string
+}
+
+func (c *RecordCorruption) Error() string {
+	return fmt.Sprintf("corrupted record at offset %d: %s", c.Offset, c.Reason)
+}
+
+// RecordWriter writes the values as records of at most maxSize bytes
+type RecordWriter struct {
+	wr      io.Writer
+	maxSize int
+}
+
+func NewRecordWriter(wr io.Writer, maxSize int) *RecordWriter {
+	return &RecordWriter{wr: wr, maxSize: maxSize}
+}
+
+// WriteRecord writes the record with a single Write so that it is never interleaved
+func (w *RecordWriter) WriteRecord(o interface{}) error {
+	data := BinaryBytes(o)
+	if len(data) > w.maxSize {
+		return fmt.Errorf("record of %d bytes is larger than %d", len(data), w.maxSize)
+	}
+	record := make([]byte, RecordHeaderSize+len(data))
+	binary.BigEndian.PutUint32(record[0:4], crc32.Checksum(data, crc32c))
+	binary.BigEndian.PutUint32(record[4:8], uint32(len(data)))
+	copy(record[RecordHeaderSize:], data)
+	_, err := w.wr.Write(record)
+	return err
+}
+
+// RecordReader reads the records written by RecordWriter,
+// a length above maxSize is taken for a corruption
+type RecordReader struct {
+	rd      io.Reader
+	maxSize int
+	offset  int64
+}
+
+func NewRecordReader(rd io.Reader, maxSize int) *RecordReader {
+	return &RecordReader{rd: rd, maxSize: maxSize}
+}
+
+// Offset of the next record from the start of the reader
+func (r *RecordReader) Offset() int64 {
+	return r.offset
+}
+
+// ReadRecord decodes the next record into ptr. It returns io.EOF after the last record,
+// a *RecordCorruption when the record is torn, doesn't match its checksum or can't be decoded.
+func (r *RecordReader) ReadRecord(ptr interface{}) error {
+	corrupted := func(reason string) error {
+		return &RecordCorruption{Offset: r.offset, Reason: reason}
+	}
+
+	var header [RecordHeaderSize]byte
+	if _, err := io.ReadFull(r.rd, header[:]); err == io.ErrUnexpectedEOF {
+		return corrupted("truncated record header")
+	} else if err != nil {
+		return err
+	}
+	crc := binary.BigEndian.Uint32(header[0:4])
+	length := binary.BigEndian.Uint32(header[4:8])
+	if int64(length) > int64(r.maxSize) {
+		return corrupted(fmt.Sprintf("record length %d is larger than %d", length, r.maxSize))
+	}
+
+	data := make([]byte, length)
+	if _, err := io.ReadFull(r.rd, data); err == io.EOF || err == io.ErrUnexpectedEOF {
+		return corrupted("truncated record")
+	} else if err != nil {
+		return err
+	}
+	if crc32.Checksum(data, crc32c) != crc {
+		return corrupted("checksum mismatch")
+	}
+	if err := ReadBinaryBytes(data, ptr); err != nil {
+		return corrupted(fmt.Sprintf("cannot decode the record: %v", err))
+	}
+	r.offset += RecordHeaderSize + int64(length)
+	return nil
+}
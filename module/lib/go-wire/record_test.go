@@ -0,0 +1,61 @@
+package wire
+
+import (
+	"bytes"
+	"io"
+	"testing"
+)
+
+type testRecord struct {
+	Name  string
+	Value int
+}
+
+func TestRecords(t *testing.T) {
+	buf := bytes.NewBuffer(nil)
+	w := NewRecordWriter(buf, 64)
+	for i := 0; i < 3; i++ {
+		if err := w.WriteRecord(testRecord{"record", i}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := w.WriteRecord(testRecord{string(make([]byte, 64)), 0}); err == nil {
+		t.Error("Expected a record larger than the limit to be refused")
+	}
+	data := buf.Bytes()
+
+	r := NewRecordReader(bytes.NewReader(data), 64)
+	for i := 0; i < 3; i++ {
+		var rec testRecord
+		if err := r.ReadRecord(&rec); err != nil || rec.Value != i {
+			t.Fatalf("Expected record %d, got %v (%v)", i, rec, err)
+		}
+	}
+	if err := r.ReadRecord(&testRecord{}); err != io.EOF {
+		t.Errorf("Expected io.EOF after the last record, got %v", err)
+	}
+	if r.Offset() != int64(len(data)) {
+		t.Errorf("Expected the offset %d at the end, got %d", len(data), r.Offset())
+	}
+
+	// a torn record is reported at its offset
+	r = NewRecordReader(bytes.NewReader(data[:len(data)-1]), 64)
+	for i := 0; i < 2; i++ {
+		if err := r.ReadRecord(&testRecord{}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	offset := r.Offset()
+	err := r.ReadRecord(&testRecord{})
+	if c, ok := err.(*RecordCorruption); !ok || c.Offset != offset || c.Reason != "truncated record" {
+		t.Errorf("Expected a truncated record at %d, got %v", offset, err)
+	}
+
+	// as is a record not matching its checksum
+	corrupted := append([]byte{}, data...)
+	corrupted[RecordHeaderSize] ^= 0xff
+	err = NewRecordReader(bytes.NewReader(corrupted), 64).ReadRecord(&testRecord{})
+	if c, ok := err.(*RecordCorruption); !ok || c.Offset != 0 || c.Reason != "checksum mismatch" {
+		t.Errorf("Expected a checksum mismatch at 0, got %v", err)
+	}
+}
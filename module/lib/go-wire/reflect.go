@@ -6,6 +6,7 @@ import (
 	"errors"
 	"io"
 	"reflect"
+	"strconv"
 	"strings"
 	"sync"
 	"time"
@@ -30,6 +31,7 @@ type Options struct {
 	JSONName      string      // (JSON) Corresponding JSON field name. (override with `json=""`)
 	JSONOmitEmpty bool        // (JSON) Omit field if value is empty
 	Varint        bool        // (Binary) Use length-prefixed encoding for (u)int64
+	BinarySkip    bool        // (Binary) Leave the field out (override with `binary:"-"`)
 	Unsafe        bool        // (JSON/Binary) Explicitly enable support for floats or maps
 	ZeroValue     interface{} // Prototype zero object
 }
@@ -56,6 +58,9 @@ func getOptionsFromField(field reflect.StructField) (skip bool, opts Options) {
 	if binTag == "varint" { // TODO: extend
 		opts.Varint = true
 	}
+	if binTag == "-" {
+		opts.BinarySkip = true
+	}
 	if wireTag == "unsafe" {
 		opts.Unsafe = true
 	}
@@ -84,7 +89,8 @@ func GetTypeFromStructDeclaration(o interface{}) reflect.Type {
 
 // Predeclaration of common types
 var (
-	timeType = GetTypeFromStructDeclaration(struct{ time.Time }{})
+	timeType       = GetTypeFromStructDeclaration(struct{ time.Time }{})
+	rawMessageType = reflect.TypeOf(json.RawMessage{})
 )
 
 const (
@@ -313,6 +319,9 @@ func readReflectBinary(rv reflect.Value, rt reflect.Type, opts Options, r io.Rea
 		} else {
 			for _, fieldInfo := range typeInfo.Fields {
 				fieldIdx, fieldType, opts := fieldInfo.unpack()
+				if opts.BinarySkip {
+					continue
+				}
 				fieldRv := rv.Field(fieldIdx)
 				readReflectBinary(fieldRv, fieldType, opts, r, lmt, n, err)
 			}
@@ -523,6 +532,9 @@ func writeReflectBinary(rv reflect.Value, rt reflect.Type, opts Options, w io.Wr
 		} else {
 			for _, fieldInfo := range typeInfo.Fields {
 				fieldIdx, fieldType, opts := fieldInfo.unpack()
+				if opts.BinarySkip {
+					continue
+				}
 				fieldRv := rv.Field(fieldIdx)
 				writeReflectBinary(fieldRv, fieldType, opts, w, n, err)
 			}
@@ -607,12 +619,24 @@ func readByteJSON(o interface{}) (typeByte byte, rest interface{}, err error) {
 		err = errors.New(Fmt("Expected [Byte,?] len 2 but got len %v", len(oSlice)))
 		return
 	}
-	typeByte_, ok := oSlice[0].(float64)
+	typeByte_, _ := jsonFloat(oSlice[0])
 	typeByte = byte(typeByte_)
 	rest = oSlice[1]
 	return
 }
 
+// jsonFloat reads a number decoded by json.Unmarshal, or as a json.Number by ReadJSON
+func jsonFloat(o interface{}) (float64, bool) {
+	switch num := o.(type) {
+	case float64:
+		return num, true
+	case json.Number:
+		f, err := num.Float64()
+		return f, err == nil
+	}
+	return 0, false
+}
+
 // Contract: Caller must ensure that rt is supported
 // (e.g. is recursively composed of supported native types, and structs and slices.)
 // rv and rt refer to the object we're unmarhsaling into, whereas o is the result of naiive json unmarshal (map[string]interface{})
@@ -710,7 +734,18 @@ func readReflectJSON(rv reflect.Value, rt reflect.Type, opts Options, o interfac
 
 	case reflect.Slice:
 		elemRt := rt.Elem()
-		if elemRt.Kind() == reflect.Uint8 {
+		if rt == rawMessageType {
+			// Special case: JSON kept as it is
+			if o == nil {
+				return
+			}
+			raw, err_ := json.Marshal(o)
+			if err_ != nil {
+				*err = err_
+				return
+			}
+			rv.SetBytes(raw)
+		} else if elemRt.Kind() == reflect.Uint8 {
 			// Special case: Byteslices
 			oString, ok := o.(string)
 			if !ok {
@@ -792,7 +827,14 @@ func readReflectJSON(rv reflect.Value, rt reflect.Type, opts Options, o interfac
 		rv.SetString(str)
 
 	case reflect.Int64, reflect.Int32, reflect.Int16, reflect.Int8, reflect.Int:
-		num, ok := o.(float64)
+		if num, ok := o.(json.Number); ok {
+			// exact unless it isn't an integer
+			if i, err_ := strconv.ParseInt(string(num), 10, 64); err_ == nil {
+				rv.SetInt(i)
+				return
+			}
+		}
+		num, ok := jsonFloat(o)
 		if !ok {
 			*err = errors.New(Fmt("Expected numeric but got type %v", reflect.TypeOf(o)))
 			return
@@ -801,7 +843,13 @@ func readReflectJSON(rv reflect.Value, rt reflect.Type, opts Options, o interfac
 		rv.SetInt(int64(num))
 
 	case reflect.Uint64, reflect.Uint32, reflect.Uint16, reflect.Uint8, reflect.Uint:
-		num, ok := o.(float64)
+		if num, ok := o.(json.Number); ok {
+			if u, err_ := strconv.ParseUint(string(num), 10, 64); err_ == nil {
+				rv.SetUint(u)
+				return
+			}
+		}
+		num, ok := jsonFloat(o)
 		if !ok {
 			*err = errors.New(Fmt("Expected numeric but got type %v", reflect.TypeOf(o)))
 			return
@@ -818,7 +866,7 @@ func readReflectJSON(rv reflect.Value, rt reflect.Type, opts Options, o interfac
 			*err = errors.New("Wire float* support requires `wire:\"unsafe\"`")
 			return
 		}
-		num, ok := o.(float64)
+		num, ok := jsonFloat(o)
 		if !ok {
 			*err = errors.New(Fmt("Expected numeric but got type %v", reflect.TypeOf(o)))
 			return
@@ -928,7 +976,14 @@ func writeReflectJSON(rv reflect.Value, rt reflect.Type, opts Options, w io.Writ
 
 	case reflect.Slice:
 		elemRt := rt.Elem()
-		if elemRt.Kind() == reflect.Uint8 {
+		if rt == rawMessageType {
+			// Special case: JSON kept as it is
+			if rv.Len() == 0 {
+				WriteTo([]byte("null"), w, n, err)
+			} else {
+				WriteTo(rv.Bytes(), w, n, err)
+			}
+		} else if elemRt.Kind() == reflect.Uint8 {
 			// Special case: Byteslices
 			byteslice := rv.Bytes()
 			WriteTo([]byte(Fmt("\"%X\"", byteslice)), w, n, err)
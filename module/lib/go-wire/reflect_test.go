@@ -3,6 +3,7 @@ package wire
 import (
 	"bytes"
 	"encoding/hex"
+	"encoding/json"
 	"fmt"
 	"reflect"
 	"testing"
@@ -803,3 +804,58 @@ func TestUnwrap(t *testing.T) {
 	}
 
 }
+
+func TestJSONOnlyField(t *testing.T) {
+	type Doc struct {
+		Name  string          `json:"name"`
+		State json.RawMessage `json:"state,omitempty" binary:"-"`
+	}
+
+	doc := Doc{Name: "a", State: json.RawMessage(`{"b":[1,2]}`)}
+	jsonBytes := JSONBytes(doc)
+	if string(jsonBytes) != `{"name":"a","state":{"b":[1,2]}}` {
+		t.Error("Unexpected jsonBytes", string(jsonBytes))
+	}
+	var fromJSON Doc
+	err := error(nil)
+	ReadJSON(&fromJSON, jsonBytes, &err)
+	if err != nil || string(fromJSON.State) != `{"b":[1,2]}` {
+		t.Error("Expected the raw JSON to be read back, got", string(fromJSON.State), err)
+	}
+
+	// the binary leaves the field out
+	if !bytes.Equal(BinaryBytes(doc), BinaryBytes(struct{ Name string }{"a"})) {
+		t.Error("Expected the field to be left out of the binary", BinaryBytes(doc))
+	}
+	var fromBinary Doc
+	if err := ReadBinaryBytes(BinaryBytes(doc), &fromBinary); err != nil || fromBinary.Name != "a" || fromBinary.State != nil {
+		t.Error("Unexpected struct read from binary", fromBinary, err)
+	}
+}
+
+func TestJSONLargeNumbers(t *testing.T) {
+	type Doc struct {
+		Max   uint64          `json:"max"`
+		Min   int64           `json:"min"`
+		State json.RawMessage `json:"state"`
+	}
+
+	jsonBytes := []byte(`{"max":18446744073709551615,"min":-9223372036854775808,"state":{"balance":9007199254740993}}`)
+	var doc Doc
+	err := error(nil)
+	ReadJSON(&doc, jsonBytes, &err)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if doc.Max != 18446744073709551615 || doc.Min != -9223372036854775808 {
+		t.Error("Expected the integers to be exact, got", doc.Max, doc.Min)
+	}
+	if string(doc.State) != `{"balance":9007199254740993}` {
+		t.Error("Expected the raw JSON to keep its numbers, got", string(doc.State))
+	}
+
+	ReadJSON(&doc, []byte(`{"max":1} {}`), &err)
+	if err == nil {
+		t.Error("Expected the data after the object to be refused")
+	}
+}
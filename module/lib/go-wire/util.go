@@ -35,19 +35,13 @@ func JSONBytes(o interface{}) []byte {
 	return w.Bytes()
 }
 
-// NOTE: inefficient
+// JSONBytesPretty indents the JSON of o, the values are kept as written
 func JSONBytesPretty(o interface{}) []byte {
-	jsonBytes := JSONBytes(o)
-	var object interface{}
-	err := json.Unmarshal(jsonBytes, &object)
-	if err != nil {
+	var buf bytes.Buffer
+	if err := json.Indent(&buf, JSONBytes(o), "", "\t"); err != nil {
 		PanicSanity(err)
 	}
-	jsonBytes, err = json.MarshalIndent(object, "", "\t")
-	if err != nil {
-		PanicSanity(err)
-	}
-	return jsonBytes
+	return buf.Bytes()
 }
 
 // o: a pointer to the object to be filled
@@ -81,9 +81,23 @@ func WriteBinaryLengthPrefixed(o interface{}, w io.Writer, n *int, err *error) {
 	WriteTo(buf.Bytes(), w, n, err)
 }
 
+// unmarshalJSON is json.Unmarshal keeping the numbers as json.Number,
+// so that large integers and raw JSON aren't rounded through a float64
+func unmarshalJSON(data []byte, object *interface{}) error {
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.UseNumber()
+	if err := dec.Decode(object); err != nil {
+		return err
+	}
+	if _, err := dec.Token(); err != io.EOF {
+		return errors.New("invalid data after top-level JSON value")
+	}
+	return nil
+}
+
 func ReadJSON(o interface{}, bytes []byte, err *error) interface{} {
 	var object interface{}
-	*err = json.Unmarshal(bytes, &object)
+	*err = unmarshalJSON(bytes, &object)
 	if *err != nil {
 		return o
 	}
@@ -94,7 +108,7 @@ func ReadJSON(o interface{}, bytes []byte, err *error) interface{} {
 func ReadJSONPtr(o interface{}, bytes []byte, err *error) interface{} {
 	var object interface{}
 
-	*err = json.Unmarshal(bytes, &object)
+	*err = unmarshalJSON(bytes, &object)
 	if *err != nil {
 		return o
 	}